@@ -1,10 +1,29 @@
 package configs
 
 type Config struct {
-	Server   *ServerConfig   `json:"server"`
-	Metric   *MetricConfig   `json:"metric"`
-	Amazon   *AmazonConfig   `json:"amazon"`
-	Database *DatabaseConfig `json:"database"`
+	Server       *ServerConfig       `json:"server"`
+	GRPC         *GRPCConfig         `json:"grpc"`
+	Metric       *MetricConfig       `json:"metric"`
+	Amazon       *AmazonConfig       `json:"amazon"`
+	Database     *DatabaseConfig     `json:"database"`
+	Auth         *AuthConfig         `json:"auth"`
+	Policy       *PolicyConfig       `json:"policy"`
+	Tenancy      *TenancyConfig      `json:"tenancy"`
+	RateLimit    *RateLimitConfig    `json:"rate_limit"`
+	Tracer       *TracerConfig       `json:"tracer"`
+	Health       *HealthConfig       `json:"health"`
+	Shutdown     *ShutdownConfig     `json:"shutdown"`
+	Startup      *StartupConfig      `json:"startup"`
+	Events       *EventsConfig       `json:"events"`
+	Webhooks     *WebhooksConfig     `json:"webhooks"`
+	Thumbnails   *ThumbnailsConfig   `json:"thumbnails"`
+	ContentTypes *ContentTypesConfig `json:"content_types"`
+	Search       *SearchConfig       `json:"search"`
+	Archives     *ArchivesConfig     `json:"archives"`
+	Expiry       *ExpiryConfig       `json:"expiry"`
+	Cache        *CacheConfig        `json:"cache"`
+	Logging      *LoggingConfig      `json:"logging"`
+	AccessLog    *AccessLogConfig    `json:"access_log"`
 }
 
 func NewConfig(name, version string) *Config {
@@ -13,12 +32,100 @@ func NewConfig(name, version string) *Config {
 			Name:    name,
 			Version: version,
 		},
+		GRPC: &GRPCConfig{
+			Name: name,
+		},
 		Metric: &MetricConfig{},
 		Amazon: &AmazonConfig{
 			DirtyRegion: &AmazonConnectConfig{},
 			CleanRegion: &AmazonConnectConfig{},
 		},
 		Database: &DatabaseConfig{},
+		Auth: &AuthConfig{
+			Enabled: true,
+		},
+		Policy: &PolicyConfig{
+			ReloadInterval: "10s",
+		},
+		Tenancy: &TenancyConfig{
+			Default: "default",
+			Tenants: []*TenantConfig{
+				{ID: "default", Bucket: "micro-store-s3"},
+			},
+		},
+		RateLimit: &RateLimitConfig{
+			Mode:       "fixed_window",
+			MaxClients: 10000,
+		},
+		Tracer: &TracerConfig{},
+		Health: &HealthConfig{
+			Interval: "10s",
+			Timeout:  "2s",
+		},
+		Shutdown: &ShutdownConfig{
+			DrainTimeout:   "30s",
+			WorkersTimeout: "10s",
+		},
+		Startup: &StartupConfig{
+			Attempts:       5,
+			InitialBackoff: "500ms",
+			MaxBackoff:     "10s",
+		},
+		Events: &EventsConfig{
+			Interval:       "1s",
+			BatchSize:      100,
+			Lease:          "30s",
+			InitialBackoff: "1s",
+			MaxBackoff:     "5m",
+		},
+		Webhooks: &WebhooksConfig{
+			Interval:       "1s",
+			BatchSize:      20,
+			Lease:          "1m",
+			Timeout:        "10s",
+			MaxAttempts:    10,
+			InitialBackoff: "10s",
+			MaxBackoff:     "1h",
+		},
+		Thumbnails: &ThumbnailsConfig{
+			Sizes:         []int{200, 800},
+			MaxBytes:      50 << 20,
+			MaxPixels:     50000000,
+			RenderTimeout: "30s",
+		},
+		ContentTypes: &ContentTypesConfig{},
+		Search: &SearchConfig{
+			Language:       "simple",
+			MaxFileBytes:   50 << 20,
+			MaxTextBytes:   512 << 10,
+			ExtractTimeout: "30s",
+		},
+		Archives: &ArchivesConfig{
+			MaxBytes:         1 << 30,
+			MaxDepth:         3,
+			MaxEntries:       10000,
+			MaxRatio:         100,
+			MaxExpandedBytes: 4 << 30,
+		},
+		Expiry: &ExpiryConfig{
+			Interval:  "1h",
+			Notice:    "720h",
+			BatchSize: 100,
+		},
+		Cache: &CacheConfig{
+			MaxEntries:  10000,
+			TTL:         "5m",
+			LoadTimeout: "10s",
+		},
+		Logging: &LoggingConfig{
+			Level:      "info",
+			SampleRate: 1,
+		},
+		AccessLog: &AccessLogConfig{
+			BufferSize: 10000,
+			BatchSize:  500,
+			Interval:   "1s",
+		},
 	}
 }
 
@@ -28,12 +135,20 @@ type ServerConfig struct {
 	Version string `json:"version"`
 }
 
+type GRPCConfig struct {
+	Addr string `json:"addr" env:"GRPC_ADDR"`
+	Name string `json:"name"`
+}
+
 type MetricConfig struct {
 	Addr string `json:"addr"`
 }
 
+// DatabaseConfig points to the postgres database, the pending migrations are
+// applied at startup with AutoMigrate
 type DatabaseConfig struct {
-	URL string `json:"url"`
+	URL         string `json:"url"`
+	AutoMigrate bool   `json:"auto_migrate" env:"DATABASE_AUTO_MIGRATE"`
 }
 
 type AmazonConfig struct {
@@ -48,3 +163,237 @@ type AmazonConnectConfig struct {
 	SecretKey string `json:"secret_key"`
 	Endpoint  string `json:"endpoint"`
 }
+
+// AuthConfig configures the authentication of the http api, JWKS is a file
+// path or an url of the key set, the bearer tokens are not accepted without it
+type AuthConfig struct {
+	Enabled  bool   `json:"enabled" env:"AUTH_ENABLED"`
+	JWKS     string `json:"jwks" env:"AUTH_JWKS"`
+	Issuer   string `json:"issuer" env:"AUTH_ISSUER"`
+	Audience string `json:"audience" env:"AUTH_AUDIENCE"`
+}
+
+// PolicyConfig points to the access policy file, which is checked for changes
+// every ReloadInterval, everything is allowed without a file
+type PolicyConfig struct {
+	File           string `json:"file" env:"POLICY_FILE"`
+	ReloadInterval string `json:"reload_interval" env:"POLICY_RELOAD_INTERVAL"`
+}
+
+// TenancyConfig lists the tenants of the deployment, the requests without a
+// tenant claim or header belong to Default, they are rejected if it is empty
+type TenancyConfig struct {
+	Default string          `json:"default" env:"TENANCY_DEFAULT"`
+	Tenants []*TenantConfig `json:"tenants"`
+}
+
+// TenantConfig holds the bucket and the quotas of a tenant, the tenants without
+// a bucket share the default one under their id prefix, zero quotas are unlimited
+type TenantConfig struct {
+	ID         string `json:"id"`
+	Bucket     string `json:"bucket"`
+	Prefix     string `json:"prefix"`
+	MaxObjects int64  `json:"max_objects"`
+	MaxBytes   int64  `json:"max_bytes"`
+}
+
+// RateLimitConfig limits the requests and the bandwidth of every client, the
+// clients are the principals or the addresses of the anonymous callers. Mode is
+// fixed_window or token_bucket, the limits without Limit are not applied.
+// Addresses limits the requests of every address before the authentication.
+// The limits apply to the HTTP requests and the grpc calls alike.
+type RateLimitConfig struct {
+	Enabled           bool                `json:"enabled" env:"RATE_LIMIT_ENABLED"`
+	Mode              string              `json:"mode" env:"RATE_LIMIT_MODE"`
+	MaxClients        int                 `json:"max_clients"`
+	TrustForwardedFor bool                `json:"trust_forwarded_for" env:"RATE_LIMIT_TRUST_FORWARDED_FOR"`
+	Addresses         *LimitConfig        `json:"addresses"`
+	Requests          *LimitConfig        `json:"requests"`
+	Routes            []*RouteLimitConfig `json:"routes"`
+	UploadBytes       *LimitConfig        `json:"upload_bytes"`
+	DownloadBytes     *LimitConfig        `json:"download_bytes"`
+}
+
+// LimitConfig allows Limit units every Period, Burst is the bucket size of the token bucket mode
+type LimitConfig struct {
+	Limit  uint64 `json:"limit"`
+	Period string `json:"period"`
+	Burst  uint64 `json:"burst"`
+}
+
+// RouteLimitConfig replaces the requests limit on the route of Method and the Path
+// template, the grpc calls have the GRPC method and their endpoint as the path
+// like FileProcessing.UploadFile
+type RouteLimitConfig struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Limit  uint64 `json:"limit"`
+	Period string `json:"period"`
+	Burst  uint64 `json:"burst"`
+}
+
+// TracerConfig configures the jaeger tracer, the spans are sent to the agent
+// when AgentHost is set and to the Collector endpoint otherwise
+type TracerConfig struct {
+	Enabled   bool   `json:"enabled" env:"TRACER_ENABLED"`
+	Collector string `json:"collector" env:"TRACER_COLLECTOR"`
+	AgentHost string `json:"agent_host" env:"TRACER_AGENT_HOST"`
+	AgentPort string `json:"agent_port" env:"TRACER_AGENT_PORT"`
+}
+
+// HealthConfig configures the readiness checks of the database and the buckets,
+// they run in background every Interval and fail after Timeout
+type HealthConfig struct {
+	Interval string `json:"interval" env:"HEALTH_INTERVAL"`
+	Timeout  string `json:"timeout" env:"HEALTH_TIMEOUT"`
+}
+
+// ShutdownConfig limits the graceful shutdown, the requests in flight are aborted
+// after DrainTimeout and the background workers after WorkersTimeout
+type ShutdownConfig struct {
+	DrainTimeout   string `json:"drain_timeout" env:"SHUTDOWN_DRAIN_TIMEOUT"`
+	WorkersTimeout string `json:"workers_timeout" env:"SHUTDOWN_WORKERS_TIMEOUT"`
+}
+
+// StartupConfig bounds the retries of the connections to the database and the
+// stores at startup, the backoff doubles from InitialBackoff up to MaxBackoff
+type StartupConfig struct {
+	Attempts       int    `json:"attempts" env:"STARTUP_ATTEMPTS"`
+	InitialBackoff string `json:"initial_backoff" env:"STARTUP_INITIAL_BACKOFF"`
+	MaxBackoff     string `json:"max_backoff" env:"STARTUP_MAX_BACKOFF"`
+}
+
+// EventsConfig configures the relay of the domain events from the outbox to the
+// broker, the events stay in the outbox while the relay is disabled. A failed
+// event is retried with a backoff doubling from InitialBackoff up to MaxBackoff.
+// The relay also runs for the webhooks when only they are enabled.
+type EventsConfig struct {
+	Enabled        bool   `json:"enabled" env:"EVENTS_ENABLED"`
+	Interval       string `json:"interval" env:"EVENTS_INTERVAL"`
+	BatchSize      int    `json:"batch_size" env:"EVENTS_BATCH_SIZE"`
+	Lease          string `json:"lease" env:"EVENTS_LEASE"`
+	InitialBackoff string `json:"initial_backoff" env:"EVENTS_INITIAL_BACKOFF"`
+	MaxBackoff     string `json:"max_backoff" env:"EVENTS_MAX_BACKOFF"`
+}
+
+// WebhooksConfig configures the webhook subscriptions of the tenants and the
+// dispatcher of their deliveries. A delivery is posted with Timeout and retried
+// with a backoff doubling from InitialBackoff up to MaxBackoff, it is dead-lettered
+// after MaxAttempts.
+type WebhooksConfig struct {
+	Enabled        bool   `json:"enabled" env:"WEBHOOKS_ENABLED"`
+	Interval       string `json:"interval" env:"WEBHOOKS_INTERVAL"`
+	BatchSize      int    `json:"batch_size" env:"WEBHOOKS_BATCH_SIZE"`
+	Lease          string `json:"lease" env:"WEBHOOKS_LEASE"`
+	Timeout        string `json:"timeout" env:"WEBHOOKS_TIMEOUT"`
+	MaxAttempts    int    `json:"max_attempts" env:"WEBHOOKS_MAX_ATTEMPTS"`
+	InitialBackoff string `json:"initial_backoff" env:"WEBHOOKS_INITIAL_BACKOFF"`
+	MaxBackoff     string `json:"max_backoff" env:"WEBHOOKS_MAX_BACKOFF"`
+}
+
+// ThumbnailsConfig configures the thumbnails of the clean images and PDFs, they are
+// generated by the relay of the events in Sizes and served in the first size by
+// default. The files of more than MaxBytes and the images of more than MaxPixels
+// are skipped. PDFRenderer is the
+// command rendering the first page of the PDF on its stdin to a PNG or JPEG on
+// its stdout, the PDFs get no thumbnails without it.
+type ThumbnailsConfig struct {
+	Enabled       bool     `json:"enabled" env:"THUMBNAILS_ENABLED"`
+	Sizes         []int    `json:"sizes"`
+	MaxBytes      int64    `json:"max_bytes" env:"THUMBNAILS_MAX_BYTES"`
+	MaxPixels     int      `json:"max_pixels" env:"THUMBNAILS_MAX_PIXELS"`
+	RenderTimeout string   `json:"render_timeout" env:"THUMBNAILS_RENDER_TIMEOUT"`
+	PDFRenderer   []string `json:"pdf_renderer"`
+}
+
+// ContentTypesConfig holds the content types allowed for the document classes, a
+// type may end with /* to allow all its subtypes. The classes without an entry
+// accept any type which matches the declared type and the filename extension.
+type ContentTypesConfig struct {
+	Allow map[string][]string `json:"allow"`
+}
+
+// SearchConfig configures the extraction of the text of the clean files by the
+// relay of the events. Language is the text search configuration of postgres
+// used for the indexing and the queries. The files of more than MaxFileBytes
+// are not extracted, the text is cut to MaxTextBytes.
+type SearchConfig struct {
+	Enabled        bool   `json:"enabled" env:"SEARCH_ENABLED"`
+	Language       string `json:"language" env:"SEARCH_LANGUAGE"`
+	MaxFileBytes   int64  `json:"max_file_bytes" env:"SEARCH_MAX_FILE_BYTES"`
+	MaxTextBytes   int    `json:"max_text_bytes" env:"SEARCH_MAX_TEXT_BYTES"`
+	ExtractTimeout string `json:"extract_timeout" env:"SEARCH_EXTRACT_TIMEOUT"`
+}
+
+// ArchivesConfig configures the inspection of the uploaded ZIP and TAR archives,
+// the archives of more than MaxBytes are rejected. The nested archives are listed
+// up to MaxDepth levels, the bytes of all the entries must not exceed MaxRatio
+// times the size of the archive nor MaxExpandedBytes.
+type ArchivesConfig struct {
+	Enabled          bool    `json:"enabled" env:"ARCHIVES_ENABLED"`
+	MaxBytes         int64   `json:"max_bytes" env:"ARCHIVES_MAX_BYTES"`
+	MaxDepth         int     `json:"max_depth" env:"ARCHIVES_MAX_DEPTH"`
+	MaxEntries       int     `json:"max_entries" env:"ARCHIVES_MAX_ENTRIES"`
+	MaxRatio         float64 `json:"max_ratio" env:"ARCHIVES_MAX_RATIO"`
+	MaxExpandedBytes int64   `json:"max_expanded_bytes" env:"ARCHIVES_MAX_EXPANDED_BYTES"`
+}
+
+// ExpiryConfig designates the metadata field holding the validity end date of the
+// document classes, the classes without an entry have no end date. The dates are
+// checked every Interval, the files get an expiring event once their date is within
+// Notice and an expired event once it has passed.
+type ExpiryConfig struct {
+	Enabled   bool              `json:"enabled" env:"EXPIRY_ENABLED"`
+	Fields    map[string]string `json:"fields"`
+	Interval  string            `json:"interval" env:"EXPIRY_INTERVAL"`
+	Notice    string            `json:"notice" env:"EXPIRY_NOTICE"`
+	BatchSize int               `json:"batch_size" env:"EXPIRY_BATCH_SIZE"`
+}
+
+// CacheConfig bounds the in process cache of the file metadata, the entries are
+// kept for up to TTL even when a change notification of the database is missed.
+// A miss is loaded once for all its concurrent readers for up to LoadTimeout.
+type CacheConfig struct {
+	Enabled     bool   `json:"enabled" env:"CACHE_ENABLED"`
+	MaxEntries  int    `json:"max_entries" env:"CACHE_MAX_ENTRIES"`
+	TTL         string `json:"ttl" env:"CACHE_TTL"`
+	LoadTimeout string `json:"load_timeout" env:"CACHE_LOAD_TIMEOUT"`
+}
+
+// AccessLogConfig sets the log of the metadata reads and the downloads. The
+// events are queued up to BufferSize and written in batches of up to BatchSize
+// events at least every Interval, the events recorded on a full queue are
+// dropped. The client address is the first X-Forwarded-For address when
+// TrustForwardedFor is set.
+type AccessLogConfig struct {
+	Enabled           bool   `json:"enabled" env:"ACCESS_LOG_ENABLED"`
+	BufferSize        int    `json:"buffer_size" env:"ACCESS_LOG_BUFFER_SIZE"`
+	BatchSize         int    `json:"batch_size" env:"ACCESS_LOG_BATCH_SIZE"`
+	Interval          string `json:"interval" env:"ACCESS_LOG_INTERVAL"`
+	TrustForwardedFor bool   `json:"trust_forwarded_for" env:"ACCESS_LOG_TRUST_FORWARDED_FOR"`
+}
+
+// LoggingConfig sets the log of the HTTP requests. Level is debug, info, warn,
+// error or off and SampleRate is the share of the requests logged. The JSON
+// bodies and the fields of the multipart forms, without their files, are logged
+// up to MaxBodyBytes when it is positive, the values at the Redact JSONPaths
+// are replaced.
+type LoggingConfig struct {
+	Level        string                `json:"level" env:"LOGGING_LEVEL"`
+	SampleRate   float64               `json:"sample_rate" env:"LOGGING_SAMPLE_RATE"`
+	MaxBodyBytes int                   `json:"max_body_bytes" env:"LOGGING_MAX_BODY_BYTES"`
+	Redact       []string              `json:"redact"`
+	Routes       []*RouteLoggingConfig `json:"routes"`
+}
+
+// RouteLoggingConfig overrides the logging of the route of Method and the Path
+// template, the zero fields keep the defaults. A negative MaxBodyBytes logs no
+// bodies and Redact is added to the default paths.
+type RouteLoggingConfig struct {
+	Method       string   `json:"method"`
+	Path         string   `json:"path"`
+	Level        string   `json:"level"`
+	SampleRate   float64  `json:"sample_rate"`
+	MaxBodyBytes int      `json:"max_body_bytes"`
+	Redact       []string `json:"redact"`
+}
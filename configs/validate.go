@@ -0,0 +1,303 @@
+package configs
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ValidationError lists every problem found in the config
+type ValidationError struct {
+	Problems []string
+}
+
+func (ve *ValidationError) Error() string {
+	return fmt.Sprintf("Error validating config, %s", strings.Join(ve.Problems, "; "))
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.addf("%s is required", field)
+	}
+}
+
+func (v *validator) url(field, value string, schemes ...string) {
+	if value == "" {
+		v.addf("%s is required", field)
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		v.addf("%s is not a valid url, %v", field, err)
+		return
+	}
+	if u.Host == "" {
+		v.addf("%s has no host", field)
+	}
+	for _, s := range schemes {
+		if u.Scheme == s {
+			return
+		}
+	}
+	v.addf("%s must have one of the schemes %s", field, strings.Join(schemes, ", "))
+}
+
+func (v *validator) logLevel(field, value string) {
+	switch value {
+	case "debug", "info", "warn", "error", "off":
+	default:
+		v.addf("%s must be debug, info, warn, error or off", field)
+	}
+}
+
+func (v *validator) sampleRate(field string, value float64) {
+	if value < 0 || value > 1 {
+		v.addf("%s must be between 0 and 1", field)
+	}
+}
+
+// jsonPaths checks the start of the paths, their syntax is checked by the logger
+func (v *validator) jsonPaths(field string, paths []string) {
+	for i, p := range paths {
+		if !strings.HasPrefix(p, "$") {
+			v.addf("%s[%d] must start with $", field, i)
+		}
+	}
+}
+
+func (v *validator) duration(field, value string) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		v.addf("%s is not a valid duration, %v", field, err)
+		return
+	}
+	if d <= 0 {
+		v.addf("%s must be positive", field)
+	}
+}
+
+// Validate checks the required fields, the urls, the credentials and the
+// durations of the config, it reports all of the problems at once
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.required("server.addr", c.Server.Addr)
+	v.required("server.name", c.Server.Name)
+	v.required("grpc.addr", c.GRPC.Addr)
+	v.required("metric.addr", c.Metric.Addr)
+	v.url("database.url", c.Database.URL, "postgres", "postgresql")
+
+	regions := []struct {
+		field  string
+		region *AmazonConnectConfig
+	}{
+		{"amazon.dirty_region", c.Amazon.DirtyRegion},
+		{"amazon.clean_region", c.Amazon.CleanRegion},
+	}
+	for _, r := range regions {
+		v.required(r.field+".name", r.region.Name)
+		v.url(r.field+".endpoint", r.region.Endpoint, "http", "https")
+		v.required(r.field+".access_key", r.region.AccessKey)
+		v.required(r.field+".secret_key", r.region.SecretKey)
+	}
+	if c.Amazon.DirtyRegion.Name != "" && c.Amazon.DirtyRegion.Name == c.Amazon.CleanRegion.Name {
+		v.addf("amazon.dirty_region.name and amazon.clean_region.name must differ")
+	}
+
+	if c.Auth.Enabled && c.Auth.JWKS != "" {
+		v.required("auth.audience", c.Auth.Audience)
+	}
+	v.duration("policy.reload_interval", c.Policy.ReloadInterval)
+
+	ids := make(map[string]bool, len(c.Tenancy.Tenants))
+	for i, t := range c.Tenancy.Tenants {
+		v.required(fmt.Sprintf("tenancy.tenants[%d].id", i), t.ID)
+		if ids[t.ID] {
+			v.addf("tenancy.tenants[%d].id %s is duplicated", i, t.ID)
+		}
+		ids[t.ID] = true
+	}
+	if c.Tenancy.Default != "" && !ids[c.Tenancy.Default] {
+		v.addf("tenancy.default %s is not in tenancy.tenants", c.Tenancy.Default)
+	}
+
+	if c.RateLimit.Enabled {
+		if c.RateLimit.Mode != "fixed_window" && c.RateLimit.Mode != "token_bucket" {
+			v.addf("rate_limit.mode must be fixed_window or token_bucket")
+		}
+		limits := []struct {
+			field string
+			limit *LimitConfig
+		}{
+			{"rate_limit.addresses", c.RateLimit.Addresses},
+			{"rate_limit.requests", c.RateLimit.Requests},
+			{"rate_limit.upload_bytes", c.RateLimit.UploadBytes},
+			{"rate_limit.download_bytes", c.RateLimit.DownloadBytes},
+		}
+		for _, l := range limits {
+			if l.limit != nil && l.limit.Limit != 0 {
+				v.duration(l.field+".period", l.limit.Period)
+			}
+		}
+		for i, r := range c.RateLimit.Routes {
+			v.required(fmt.Sprintf("rate_limit.routes[%d].path", i), r.Path)
+			v.duration(fmt.Sprintf("rate_limit.routes[%d].period", i), r.Period)
+		}
+	}
+
+	v.logLevel("logging.level", c.Logging.Level)
+	v.sampleRate("logging.sample_rate", c.Logging.SampleRate)
+	v.jsonPaths("logging.redact", c.Logging.Redact)
+	for i, r := range c.Logging.Routes {
+		field := fmt.Sprintf("logging.routes[%d]", i)
+		v.required(field+".path", r.Path)
+		if r.Level != "" {
+			v.logLevel(field+".level", r.Level)
+		}
+		v.sampleRate(field+".sample_rate", r.SampleRate)
+		v.jsonPaths(field+".redact", r.Redact)
+	}
+
+	if c.Tracer.Enabled && c.Tracer.AgentHost == "" {
+		v.url("tracer.collector", c.Tracer.Collector, "http", "https")
+	}
+
+	v.duration("health.interval", c.Health.Interval)
+	v.duration("health.timeout", c.Health.Timeout)
+	v.duration("shutdown.drain_timeout", c.Shutdown.DrainTimeout)
+	v.duration("shutdown.workers_timeout", c.Shutdown.WorkersTimeout)
+
+	if c.Startup.Attempts < 1 {
+		v.addf("startup.attempts must be at least 1")
+	}
+	v.duration("startup.initial_backoff", c.Startup.InitialBackoff)
+	v.duration("startup.max_backoff", c.Startup.MaxBackoff)
+
+	if c.Events.Enabled || c.Webhooks.Enabled || c.Thumbnails.Enabled || c.Search.Enabled {
+		if c.Events.BatchSize < 1 {
+			v.addf("events.batch_size must be at least 1")
+		}
+		v.duration("events.interval", c.Events.Interval)
+		v.duration("events.lease", c.Events.Lease)
+		v.duration("events.initial_backoff", c.Events.InitialBackoff)
+		v.duration("events.max_backoff", c.Events.MaxBackoff)
+	}
+	if c.Webhooks.Enabled {
+		if c.Webhooks.BatchSize < 1 {
+			v.addf("webhooks.batch_size must be at least 1")
+		}
+		if c.Webhooks.MaxAttempts < 1 {
+			v.addf("webhooks.max_attempts must be at least 1")
+		}
+		v.duration("webhooks.interval", c.Webhooks.Interval)
+		v.duration("webhooks.lease", c.Webhooks.Lease)
+		v.duration("webhooks.timeout", c.Webhooks.Timeout)
+		v.duration("webhooks.initial_backoff", c.Webhooks.InitialBackoff)
+		v.duration("webhooks.max_backoff", c.Webhooks.MaxBackoff)
+	}
+	if c.Thumbnails.Enabled {
+		if len(c.Thumbnails.Sizes) == 0 {
+			v.addf("thumbnails.sizes must not be empty")
+		}
+		for _, size := range c.Thumbnails.Sizes {
+			if size < 1 {
+				v.addf("thumbnails.sizes must be positive, got %d", size)
+			}
+		}
+		if c.Thumbnails.MaxBytes < 1 {
+			v.addf("thumbnails.max_bytes must be at least 1")
+		}
+		v.duration("thumbnails.render_timeout", c.Thumbnails.RenderTimeout)
+	}
+	if c.Search.Enabled {
+		if c.Search.Language == "" {
+			v.addf("search.language must not be empty")
+		}
+		if c.Search.MaxFileBytes < 1 {
+			v.addf("search.max_file_bytes must be at least 1")
+		}
+		if c.Search.MaxTextBytes < 1 {
+			v.addf("search.max_text_bytes must be at least 1")
+		}
+		v.duration("search.extract_timeout", c.Search.ExtractTimeout)
+	}
+	if c.Archives.Enabled {
+		if c.Archives.MaxBytes < 1 {
+			v.addf("archives.max_bytes must be at least 1")
+		}
+		if c.Archives.MaxDepth < 1 {
+			v.addf("archives.max_depth must be at least 1")
+		}
+		if c.Archives.MaxEntries < 1 {
+			v.addf("archives.max_entries must be at least 1")
+		}
+		if c.Archives.MaxRatio <= 0 {
+			v.addf("archives.max_ratio must be positive")
+		}
+		if c.Archives.MaxExpandedBytes < 1 {
+			v.addf("archives.max_expanded_bytes must be at least 1")
+		}
+	}
+	if c.Expiry.Enabled {
+		if c.Expiry.BatchSize < 1 {
+			v.addf("expiry.batch_size must be at least 1")
+		}
+		v.duration("expiry.interval", c.Expiry.Interval)
+		v.duration("expiry.notice", c.Expiry.Notice)
+		classes := make([]string, 0, len(c.Expiry.Fields))
+		for class := range c.Expiry.Fields {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			v.required("expiry.fields."+class, c.Expiry.Fields[class])
+		}
+	}
+	if c.Cache.Enabled {
+		if c.Cache.MaxEntries < 1 {
+			v.addf("cache.max_entries must be at least 1")
+		}
+		v.duration("cache.ttl", c.Cache.TTL)
+		v.duration("cache.load_timeout", c.Cache.LoadTimeout)
+	}
+	if c.AccessLog.Enabled {
+		if c.AccessLog.BufferSize < 1 {
+			v.addf("access_log.buffer_size must be at least 1")
+		}
+		if c.AccessLog.BatchSize < 1 {
+			v.addf("access_log.batch_size must be at least 1")
+		}
+		v.duration("access_log.interval", c.AccessLog.Interval)
+	}
+	classes := make([]string, 0, len(c.ContentTypes.Allow))
+	for class := range c.ContentTypes.Allow {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		for _, t := range c.ContentTypes.Allow[class] {
+			if strings.HasSuffix(t, "/*") {
+				continue
+			}
+			if _, _, err := mime.ParseMediaType(t); err != nil || !strings.Contains(t, "/") {
+				v.addf("content_types.allow.%s has invalid type %q", class, t)
+			}
+		}
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
@@ -1,6 +1,6 @@
 module github.com/vielendanke/file-service
 
-go 1.15
+go 1.16
 
 require (
 	github.com/DATA-DOG/go-sqlmock v1.5.0
@@ -13,6 +13,7 @@ require (
 	github.com/jmoiron/sqlx v1.3.1
 	github.com/klauspost/compress v1.11.7
 	github.com/lib/pq v1.9.0
+	github.com/minio/minio-go/v7 v7.0.7
 	github.com/opentracing-contrib/go-gorilla v0.0.0-20190110000444-ced666783644
 	github.com/opentracing-contrib/go-stdlib v1.0.0
 	github.com/opentracing/opentracing-go v1.2.0
@@ -22,14 +23,17 @@ require (
 	github.com/uber/jaeger-lib v2.4.0+incompatible // indirect
 	github.com/unistack-org/micro-client-http/v3 v3.2.2
 	github.com/unistack-org/micro-codec-json/v3 v3.1.1
+	github.com/unistack-org/micro-codec-proto/v3 v3.1.1
 	github.com/unistack-org/micro-config-env/v3 v3.1.3
 	github.com/unistack-org/micro-config-file/v3 v3.1.2
 	github.com/unistack-org/micro-metrics-prometheus/v3 v3.1.1
+	github.com/unistack-org/micro-server-grpc/v3 v3.2.2
 	github.com/unistack-org/micro-server-http/v3 v3.2.0
 	github.com/unistack-org/micro-store-s3/v3 v3.2.0
 	github.com/unistack-org/micro-wrapper-requestid/v3 v3.1.3
 	github.com/unistack-org/micro/v3 v3.2.2
 	google.golang.org/genproto v0.0.0-20210126160654-44e461bb6506
+	google.golang.org/grpc v1.35.0
 	google.golang.org/protobuf v1.25.0
 	gopkg.in/DATA-DOG/go-sqlmock.v1 v1.3.0 // indirect
 )
@@ -0,0 +1,145 @@
+// Package access records who read and downloaded the files of the tenants, the
+// events are written to the access log in batches in the background.
+package access
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/unistack-org/micro/v3/logger"
+	metrics "github.com/vielendanke/file-service/internal/app/fileservice/commons/metrics"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+var accessEvents = metrics.GetOrMakeCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.NS,
+		Name:      "access_events_total",
+		Help:      "Total number of the access events by result, written, dropped on a full queue or failed to be written.",
+	},
+	[]string{"result"},
+)
+
+// Client identifies the client of the request, it is recorded with the events
+// of the request
+type Client struct {
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+type clientKey struct{}
+
+// NewContext returns a copy of ctx carrying c
+func NewContext(ctx context.Context, c *Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, c)
+}
+
+// FromContext returns the client of ctx or an empty one
+func FromContext(ctx context.Context) *Client {
+	if c, ok := ctx.Value(clientKey{}).(*Client); ok {
+		return c
+	}
+	return &Client{}
+}
+
+// Recorder ...
+type Recorder interface {
+	// Record queues e to be written, it never blocks the request
+	Record(e *model.AccessEvent)
+}
+
+// Writer writes the recorded events in batches of up to batchSize events. The
+// events recorded while the queue is full are dropped and counted, the access
+// log is best effort and never slows down the downloads.
+type Writer struct {
+	repo      repository.AccessRepository
+	queue     chan *model.AccessEvent
+	batchSize int
+}
+
+// NewWriter returns a writer queueing up to bufferSize events
+func NewWriter(repo repository.AccessRepository, bufferSize, batchSize int) *Writer {
+	return &Writer{
+		repo:      repo,
+		queue:     make(chan *model.AccessEvent, bufferSize),
+		batchSize: batchSize,
+	}
+}
+
+// Record ...
+func (w *Writer) Record(e *model.AccessEvent) {
+	select {
+	case w.queue <- e:
+	default:
+		accessEvents.WithLabelValues("dropped").Inc()
+	}
+}
+
+// Run writes a batch when it is full or every interval until ctx is done, then
+// the queued events are written before it returns
+func (w *Writer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	batch := make([]*model.AccessEvent, 0, w.batchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			w.drain(batch)
+			return
+		case e := <-w.queue:
+			batch = append(batch, e)
+			if len(batch) < w.batchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+		w.write(ctx, batch)
+		// the written batch may still be referenced by the repository
+		batch = make([]*model.AccessEvent, 0, w.batchSize)
+	}
+}
+
+// drain writes batch and the queued events, the servers are stopped before the
+// workers so no events are recorded anymore
+func (w *Writer) drain(batch []*model.AccessEvent) {
+	for {
+		select {
+		case e := <-w.queue:
+			batch = append(batch, e)
+			if len(batch) < w.batchSize {
+				continue
+			}
+			w.write(context.Background(), batch)
+			batch = make([]*model.AccessEvent, 0, w.batchSize)
+		default:
+			w.write(context.Background(), batch)
+			return
+		}
+	}
+}
+
+// write saves the events of every tenant of batch, a failing tenant does not
+// stop the others
+func (w *Writer) write(ctx context.Context, batch []*model.AccessEvent) {
+	tenants := []string{}
+	events := map[string][]*model.AccessEvent{}
+	for _, e := range batch {
+		if _, ok := events[e.TenantID]; !ok {
+			tenants = append(tenants, e.TenantID)
+		}
+		events[e.TenantID] = append(events[e.TenantID], e)
+	}
+	for _, id := range tenants {
+		tenantCtx := tenant.NewContext(ctx, &tenant.Tenant{ID: id})
+		if err := w.repo.SaveAccessEvents(tenantCtx, events[id]); err != nil {
+			logger.Errorf(ctx, "Error writing %d access events of tenant %s, %v", len(events[id]), id, err)
+			accessEvents.WithLabelValues("failed").Add(float64(len(events[id])))
+			continue
+		}
+		accessEvents.WithLabelValues("written").Add(float64(len(events[id])))
+	}
+}
@@ -0,0 +1,87 @@
+package access_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/vielendanke/file-service/internal/app/fileservice/access"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+func isTenant(id string) interface{} {
+	return mock.MatchedBy(func(ctx context.Context) bool {
+		return tenant.FromContext(ctx).ID == id
+	})
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, &access.Client{}, access.FromContext(context.Background()))
+
+	c := &access.Client{IP: "10.0.0.1", UserAgent: "curl/7.68.0", RequestID: "req-1"}
+	assert.Equal(t, c, access.FromContext(access.NewContext(context.Background(), c)))
+}
+
+func TestWriter_Run_WritesFullBatchesByTenant(t *testing.T) {
+	repo := new(mocks.AccessRepository)
+	a1 := &model.AccessEvent{TenantID: "a", FileID: "f1"}
+	b1 := &model.AccessEvent{TenantID: "b", FileID: "f2"}
+	a2 := &model.AccessEvent{TenantID: "a", FileID: "f3"}
+	written := make(chan struct{})
+	repo.On("SaveAccessEvents", isTenant("a"), []*model.AccessEvent{a1, a2}).Return(errors.New("connection refused")).Once()
+	repo.On("SaveAccessEvents", isTenant("b"), []*model.AccessEvent{b1}).Return(nil).Once().Run(func(mock.Arguments) {
+		close(written)
+	})
+	w := access.NewWriter(repo, 10, 3)
+	w.Record(a1)
+	w.Record(b1)
+	w.Record(a2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		w.Run(ctx, time.Hour)
+		close(stopped)
+	}()
+	<-written
+	cancel()
+	<-stopped
+
+	repo.AssertExpectations(t)
+}
+
+func TestWriter_Run_DrainsQueueOnStop(t *testing.T) {
+	repo := new(mocks.AccessRepository)
+	a1 := &model.AccessEvent{TenantID: "a", FileID: "f1"}
+	a2 := &model.AccessEvent{TenantID: "a", FileID: "f2"}
+	repo.On("SaveAccessEvents", isTenant("a"), []*model.AccessEvent{a1, a2}).Return(nil).Once()
+	w := access.NewWriter(repo, 10, 10)
+	w.Record(a1)
+	w.Record(a2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w.Run(ctx, time.Hour)
+
+	repo.AssertExpectations(t)
+}
+
+func TestWriter_Record_DropsOnFullQueue(t *testing.T) {
+	repo := new(mocks.AccessRepository)
+	a1 := &model.AccessEvent{TenantID: "a", FileID: "f1"}
+	repo.On("SaveAccessEvents", isTenant("a"), []*model.AccessEvent{a1}).Return(nil).Once()
+	w := access.NewWriter(repo, 1, 10)
+	w.Record(a1)
+	w.Record(&model.AccessEvent{TenantID: "a", FileID: "f2"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w.Run(ctx, time.Hour)
+
+	repo.AssertExpectations(t)
+}
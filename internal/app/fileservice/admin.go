@@ -0,0 +1,184 @@
+package fileservice
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	jsoncodec "github.com/unistack-org/micro-codec-json/v3"
+	"github.com/unistack-org/micro/v3/logger"
+	"github.com/unistack-org/micro/v3/store"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+// AdminUsage describes the arguments of RunAdmin
+const AdminUsage = `inspect [-tenant id] <file id>
+promote [-tenant id] <file id>
+quarantine [-tenant id] <file id>
+reupload [-tenant id] <file id> <path>
+export [-tenant id] [-o path]
+verify [-tenant id] <file id>
+purge [-tenant id] -yes <file id>`
+
+// adminCommands are the names of the admin commands with the number of their arguments
+var adminCommands = map[string]int{
+	"inspect":    1,
+	"promote":    1,
+	"quarantine": 1,
+	"reupload":   2,
+	"export":     0,
+	"verify":     1,
+	"purge":      1,
+}
+
+// IsAdminCommand reports if name is one of the admin commands
+func IsAdminCommand(name string) bool {
+	_, ok := adminCommands[name]
+	return ok
+}
+
+// RunAdmin runs the admin command args[0] against the configured database and
+// stores, the files belong to the tenant of the -tenant flag or the default one
+func RunAdmin(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 || !IsAdminCommand(args[0]) {
+		return fmt.Errorf("Error parsing arguments, usage:\n%s", AdminUsage)
+	}
+	command := args[0]
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	tenantID := flags.String("tenant", "", "tenant of the files, the default tenant if empty")
+	output := flags.String("o", "", "file the export is written to, the standard output if empty")
+	yes := flags.Bool("yes", false, "confirms the purge")
+	if err := flags.Parse(args[1:]); err != nil {
+		return fmt.Errorf("Error parsing arguments of %s, %v", command, err)
+	}
+	if flags.NArg() != adminCommands[command] {
+		return fmt.Errorf("Error parsing arguments, usage:\n%s", AdminUsage)
+	}
+	if command == "purge" && !*yes {
+		return fmt.Errorf("Error purging file %s, the purge can not be undone, confirm it with -yes", flags.Arg(0))
+	}
+
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if *tenantID == "" {
+		*tenantID = cfg.Tenancy.Default
+	}
+	if *tenantID != "" {
+		t, err := newTenantRegistry(cfg.Tenancy).Lookup(*tenantID)
+		if err != nil {
+			return err
+		}
+		ctx = tenant.NewContext(ctx, t)
+	}
+
+	backoff := newBackoff(cfg.Startup)
+	db, err := connectDB(ctx, "postgres", cfg.Database.URL, backoff)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	s3Dirty := newStore(cfg.Amazon.DirtyRegion)
+	s3Clean := newStore(cfg.Amazon.CleanRegion)
+	for _, s := range []store.Store{s3Dirty, s3Clean} {
+		defer func(s store.Store) {
+			if err := s.Disconnect(context.Background()); err != nil {
+				logger.Errorf(ctx, "Error during disconnect from s3, %v", err)
+			}
+		}(s)
+	}
+	if err := connectStore(ctx, s3Dirty, backoff); err != nil {
+		return err
+	}
+	if err := connectStore(ctx, s3Clean, backoff); err != nil {
+		return err
+	}
+
+	cleanOpener, err := newOpener(cfg.Amazon.CleanRegion)
+	if err != nil {
+		return err
+	}
+	dirtyOpener, err := newOpener(cfg.Amazon.DirtyRegion)
+	if err != nil {
+		return err
+	}
+
+	fr := repository.NewAWSFileRepository(db)
+	as := service.NewAdminService(
+		fr,
+		service.NewAWSProcessingService(jsoncodec.NewCodec(), fr, s3Clean, s3Dirty, cleanOpener),
+		s3Clean,
+		s3Dirty,
+		cleanOpener,
+		dirtyOpener,
+	)
+	id := flags.Arg(0)
+
+	switch command {
+	case "inspect":
+		report, err := as.Inspect(ctx, id)
+		if err != nil {
+			return err
+		}
+		return writeJSON(out, report)
+	case "promote":
+		return as.Promote(ctx, id)
+	case "quarantine":
+		return as.Quarantine(ctx, id)
+	case "reupload":
+		f, err := os.Open(flags.Arg(1))
+		if err != nil {
+			return fmt.Errorf("Error opening %s, %v", flags.Arg(1), err)
+		}
+		defer f.Close()
+		return as.Reupload(ctx, id, f)
+	case "export":
+		w := out
+		if *output != "" {
+			f, err := os.Create(*output)
+			if err != nil {
+				return fmt.Errorf("Error creating %s, %v", *output, err)
+			}
+			defer f.Close()
+			w = f
+		}
+		n, err := as.Export(ctx, w)
+		if err != nil {
+			return err
+		}
+		logger.Infof(ctx, "Exported %d files", n)
+		return nil
+	case "verify":
+		v, err := as.Verify(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := writeJSON(out, v); err != nil {
+			return err
+		}
+		if !v.OK() {
+			return fmt.Errorf("Error verifying file %s, %d problems found", id, len(v.Problems))
+		}
+		return nil
+	}
+	return as.Purge(ctx, id)
+}
+
+func writeJSON(out io.Writer, v interface{}) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("Error writing result, %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+// Package apperrors contains the domain errors of the file service and their
+// translation to HTTP problem details and gRPC status codes.
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind ...
+type Kind int
+
+// Kinds of the domain errors
+const (
+	KindInternal Kind = iota
+	KindNotFound
+	KindAlreadyExists
+	KindInvalid
+	KindConflict
+	KindNotReady
+	KindUnauthenticated
+	KindForbidden
+	KindQuotaExceeded
+	KindUnavailable
+	KindUnsupportedMedia
+	KindRateLimited
+)
+
+// Machine readable error codes
+const (
+	CodeInternal           = "internal_error"
+	CodeInvalidRequest     = "invalid_request"
+	CodeInvalidMetadata    = "invalid_metadata"
+	CodeFileNotFound       = "file_not_found"
+	CodeFileAlreadyExists  = "file_already_exists"
+	CodeFileNotReady       = "file_not_ready"
+	CodeFileIDMissing      = "file_id_missing"
+	CodeRouteNotFound      = "route_not_found"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeUnauthenticated    = "unauthenticated"
+	CodeForbidden          = "forbidden"
+	CodeUnknownTenant      = "unknown_tenant"
+	CodeQuotaExceeded      = "quota_exceeded"
+	CodeRateLimited        = "rate_limited"
+	CodeShuttingDown       = "shutting_down"
+	CodeWebhookNotFound    = "webhook_not_found"
+	CodeDeliveryNotFound   = "delivery_not_found"
+	CodeThumbnailNotFound  = "thumbnail_not_found"
+	CodeContentMismatch    = "content_type_mismatch"
+	CodeContentNotAllowed  = "content_type_not_allowed"
+	CodeArchiveRejected    = "archive_rejected"
+	CodeInvalidRelation    = "invalid_relation"
+	CodeRelationExists     = "relation_already_exists"
+	CodeRelationNotFound   = "relation_not_found"
+	CodeRelationCycle      = "relation_cycle"
+	CodeFileHeld           = "file_under_legal_hold"
+	CodeCollectionExists   = "collection_already_exists"
+	CodeCollectionNotFound = "collection_not_found"
+)
+
+// Error ...
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Err     error
+}
+
+// Error ...
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s, %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+// Unwrap ...
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func newError(kind Kind, code, format string, args ...interface{}) *Error {
+	return &Error{
+		Kind:    kind,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// NotFound ...
+func NotFound(code, format string, args ...interface{}) error {
+	return newError(KindNotFound, code, format, args...)
+}
+
+// AlreadyExists ...
+func AlreadyExists(code, format string, args ...interface{}) error {
+	return newError(KindAlreadyExists, code, format, args...)
+}
+
+// Invalid ...
+func Invalid(code, format string, args ...interface{}) error {
+	return newError(KindInvalid, code, format, args...)
+}
+
+// Conflict ...
+func Conflict(code, format string, args ...interface{}) error {
+	return newError(KindConflict, code, format, args...)
+}
+
+// NotReady ...
+func NotReady(code, format string, args ...interface{}) error {
+	return newError(KindNotReady, code, format, args...)
+}
+
+// Unauthenticated ...
+func Unauthenticated(code, format string, args ...interface{}) error {
+	return newError(KindUnauthenticated, code, format, args...)
+}
+
+// Forbidden ...
+func Forbidden(code, format string, args ...interface{}) error {
+	return newError(KindForbidden, code, format, args...)
+}
+
+// QuotaExceeded ...
+func QuotaExceeded(code, format string, args ...interface{}) error {
+	return newError(KindQuotaExceeded, code, format, args...)
+}
+
+// RateLimited ...
+func RateLimited(code, format string, args ...interface{}) error {
+	return newError(KindRateLimited, code, format, args...)
+}
+
+// Unavailable ...
+func Unavailable(code, format string, args ...interface{}) error {
+	return newError(KindUnavailable, code, format, args...)
+}
+
+// UnsupportedMedia ...
+func UnsupportedMedia(code, format string, args ...interface{}) error {
+	return newError(KindUnsupportedMedia, code, format, args...)
+}
+
+// Wrap returns an error of the given kind and code keeping err as the cause
+func Wrap(err error, kind Kind, code, format string, args ...interface{}) error {
+	e := newError(kind, code, format, args...)
+	e.Err = err
+	return e
+}
+
+// KindOf returns the kind of the first domain error in the chain of err,
+// errors which are not domain errors are internal
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindInternal
+}
+
+// CodeOf returns the machine readable code of err
+func CodeOf(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return CodeInternal
+}
+
+// Is reports whether err is a domain error of the given kind
+func Is(err error, kind Kind) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Kind == kind
+}
@@ -0,0 +1,109 @@
+package apperrors_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestKindOf_WrappedError(t *testing.T) {
+	err := fmt.Errorf("Error finding file, %w", apperrors.Wrap(sql.ErrNoRows, apperrors.KindNotFound, apperrors.CodeFileNotFound, "File %s not found", "testID"))
+
+	assert.Equal(t, apperrors.KindNotFound, apperrors.KindOf(err))
+	assert.Equal(t, apperrors.CodeFileNotFound, apperrors.CodeOf(err))
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestKindOf_PlainError(t *testing.T) {
+	err := fmt.Errorf("Error")
+
+	assert.Equal(t, apperrors.KindInternal, apperrors.KindOf(err))
+	assert.Equal(t, apperrors.CodeInternal, apperrors.CodeOf(err))
+	assert.Equal(t, http.StatusInternalServerError, apperrors.HTTPStatus(err))
+	assert.Equal(t, codes.Internal, apperrors.GRPCCode(err))
+}
+
+func TestHTTPStatus(t *testing.T) {
+	assert.Equal(t, http.StatusNotFound, apperrors.HTTPStatus(apperrors.NotFound(apperrors.CodeFileNotFound, "not found")))
+	assert.Equal(t, http.StatusConflict, apperrors.HTTPStatus(apperrors.AlreadyExists(apperrors.CodeFileAlreadyExists, "exists")))
+	assert.Equal(t, http.StatusConflict, apperrors.HTTPStatus(apperrors.NotReady(apperrors.CodeFileNotReady, "not ready")))
+	assert.Equal(t, http.StatusBadRequest, apperrors.HTTPStatus(apperrors.Invalid(apperrors.CodeInvalidRequest, "invalid")))
+	assert.Equal(t, http.StatusUnauthorized, apperrors.HTTPStatus(apperrors.Unauthenticated(apperrors.CodeUnauthenticated, "unauthenticated")))
+	assert.Equal(t, http.StatusInsufficientStorage, apperrors.HTTPStatus(apperrors.QuotaExceeded(apperrors.CodeQuotaExceeded, "quota")))
+	assert.Equal(t, http.StatusTooManyRequests, apperrors.HTTPStatus(apperrors.RateLimited(apperrors.CodeRateLimited, "rate")))
+	assert.Equal(t, http.StatusServiceUnavailable, apperrors.HTTPStatus(apperrors.Unavailable(apperrors.CodeShuttingDown, "shutting down")))
+	assert.Equal(t, http.StatusUnsupportedMediaType, apperrors.HTTPStatus(apperrors.UnsupportedMedia(apperrors.CodeContentNotAllowed, "pdf only")))
+}
+
+func TestGRPCCode(t *testing.T) {
+	assert.Equal(t, codes.NotFound, apperrors.GRPCCode(apperrors.NotFound(apperrors.CodeFileNotFound, "not found")))
+	assert.Equal(t, codes.AlreadyExists, apperrors.GRPCCode(apperrors.AlreadyExists(apperrors.CodeFileAlreadyExists, "exists")))
+	assert.Equal(t, codes.FailedPrecondition, apperrors.GRPCCode(apperrors.NotReady(apperrors.CodeFileNotReady, "not ready")))
+	assert.Equal(t, codes.InvalidArgument, apperrors.GRPCCode(apperrors.Invalid(apperrors.CodeInvalidRequest, "invalid")))
+	assert.Equal(t, codes.ResourceExhausted, apperrors.GRPCCode(apperrors.QuotaExceeded(apperrors.CodeQuotaExceeded, "quota")))
+	assert.Equal(t, codes.ResourceExhausted, apperrors.GRPCCode(apperrors.RateLimited(apperrors.CodeRateLimited, "rate")))
+	assert.Equal(t, codes.Unavailable, apperrors.GRPCCode(apperrors.Unavailable(apperrors.CodeShuttingDown, "shutting down")))
+	assert.Equal(t, codes.InvalidArgument, apperrors.GRPCCode(apperrors.UnsupportedMedia(apperrors.CodeContentNotAllowed, "pdf only")))
+}
+
+func TestNewProblem(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/metadata/testID", nil)
+
+	p := apperrors.NewProblem(req, apperrors.NotFound(apperrors.CodeFileNotFound, "File not found"))
+
+	assert.Equal(t, "urn:file-service:problem:file_not_found", p.Type)
+	assert.Equal(t, http.StatusNotFound, p.Status)
+	assert.Equal(t, "/metadata/testID", p.Instance)
+	assert.Equal(t, apperrors.CodeFileNotFound, p.Code)
+}
+
+func TestNewProblem_InternalError(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/metadata/testID", nil)
+
+	p := apperrors.NewProblem(req, fmt.Errorf("Error querying files, pq: relation \"files\" does not exist"))
+
+	assert.Equal(t, "about:blank", p.Type)
+	assert.Equal(t, http.StatusInternalServerError, p.Status)
+	assert.Equal(t, apperrors.CodeInternal, p.Code)
+	assert.Equal(t, apperrors.InternalDetail, p.Detail)
+}
+
+func TestNewProblem_ClientError(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/metadata/testID", nil)
+
+	p := apperrors.NewProblem(req, apperrors.Invalid(apperrors.CodeInvalidRequest, "Error, id is empty"))
+
+	assert.Equal(t, "Error, id is empty", p.Detail)
+}
+
+func TestNewProblem_WrappedCause(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/metadata", nil)
+	cause := fmt.Errorf("pq: duplicate key value violates unique constraint \"files_pkey\"")
+
+	p := apperrors.NewProblem(req, apperrors.Wrap(cause, apperrors.KindAlreadyExists, apperrors.CodeFileAlreadyExists, "File testID already exists"))
+
+	assert.Equal(t, http.StatusConflict, p.Status)
+	assert.Equal(t, "File testID already exists", p.Detail)
+}
+
+func TestGRPCError(t *testing.T) {
+	err := apperrors.GRPCError(context.Background(), apperrors.NotFound(apperrors.CodeFileNotFound, "File testID not found"))
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "File testID not found", status.Convert(err).Message())
+}
+
+func TestGRPCError_InternalError(t *testing.T) {
+	err := apperrors.GRPCError(context.Background(), fmt.Errorf("Error accessing clean store, dial tcp 10.0.0.1:9000: connection refused"))
+
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, apperrors.InternalDetail, status.Convert(err).Message())
+}
@@ -0,0 +1,142 @@
+package apperrors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/unistack-org/micro/v3/logger"
+	"github.com/unistack-org/micro/v3/metadata"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// InternalDetail is sent in place of the message of the internal errors, the
+// message may tell about the database or the storage and is only logged
+const InternalDetail = "Internal error"
+
+// Problem is the RFC 7807 problem details body
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// HTTPStatus ...
+func HTTPStatus(err error) int {
+	switch KindOf(err) {
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindAlreadyExists, KindConflict, KindNotReady:
+		return http.StatusConflict
+	case KindInvalid:
+		return http.StatusBadRequest
+	case KindUnauthenticated:
+		return http.StatusUnauthorized
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindQuotaExceeded:
+		// the storage of the tenant is full, retrying does not help until
+		// files are deleted or the quota is raised
+		return http.StatusInsufficientStorage
+	case KindRateLimited:
+		return http.StatusTooManyRequests
+	case KindUnavailable:
+		return http.StatusServiceUnavailable
+	case KindUnsupportedMedia:
+		return http.StatusUnsupportedMediaType
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// GRPCCode ...
+func GRPCCode(err error) codes.Code {
+	switch KindOf(err) {
+	case KindNotFound:
+		return codes.NotFound
+	case KindAlreadyExists:
+		return codes.AlreadyExists
+	case KindConflict:
+		return codes.Aborted
+	case KindInvalid, KindUnsupportedMedia:
+		return codes.InvalidArgument
+	case KindNotReady:
+		return codes.FailedPrecondition
+	case KindUnauthenticated:
+		return codes.Unauthenticated
+	case KindForbidden:
+		return codes.PermissionDenied
+	case KindQuotaExceeded, KindRateLimited:
+		return codes.ResourceExhausted
+	case KindUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+// NewProblem builds the problem details of err for the request r
+func NewProblem(r *http.Request, err error) *Problem {
+	code := HTTPStatus(err)
+	p := &Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(code),
+		Status:   code,
+		Detail:   Detail(r.Context(), err),
+		Instance: r.URL.Path,
+		Code:     CodeOf(err),
+	}
+	if p.Code != CodeInternal {
+		p.Type = "urn:file-service:problem:" + p.Code
+	}
+	if id, ok := metadata.Get(r.Context(), middleware.MetadataKey); ok {
+		p.RequestID = id
+	}
+	return p
+}
+
+// Write ...
+func (p *Problem) Write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// WriteProblem writes err as application/problem+json
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	NewProblem(r, err).Write(w)
+}
+
+// GRPCError converts err to a gRPC status error of the call ctx
+func GRPCError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	return status.Error(GRPCCode(err), Detail(ctx, err))
+}
+
+// Detail returns the message of err shown to the client. The internal errors
+// are logged with the request id of ctx and replaced by InternalDetail, the
+// domain errors show their own message and their cause is only logged.
+func Detail(ctx context.Context, err error) string {
+	id, _ := metadata.Get(ctx, middleware.MetadataKey)
+	var e *Error
+	if !errors.As(err, &e) {
+		logger.Errorf(ctx, "Internal error of request %s, %v", id, err)
+		return InternalDetail
+	}
+	if e.Err != nil {
+		logger.Infof(ctx, "Error of request %s, %v", id, err)
+	}
+	return e.Message
+}
@@ -0,0 +1,134 @@
+package archives_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/archives"
+)
+
+var testLimits = archives.Limits{MaxDepth: 3, MaxEntries: 100, MaxRatio: 100, MaxExpandedBytes: 1 << 20}
+
+type testEntry struct {
+	name    string
+	content []byte
+}
+
+func zipOf(t *testing.T, entries ...testEntry) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, e := range entries {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatalf("Error creating zip entry, %v", err)
+		}
+		w.Write(e.content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Error closing zip, %v", err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzOf(t *testing.T, entries ...testEntry) []byte {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for _, e := range entries {
+		if err := tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("Error writing tar header, %v", err)
+		}
+		tw.Write(e.content)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Error closing tar, %v", err)
+	}
+	gw.Close()
+	return buf.Bytes()
+}
+
+func inspect(data []byte, contentType string, limits archives.Limits) error {
+	_, err := archives.Inspect(bytes.NewReader(data), int64(len(data)), contentType, limits)
+	return err
+}
+
+func TestInspect_Zip(t *testing.T) {
+	data := zipOf(t,
+		testEntry{"invoice.pdf", []byte("%PDF-1.7\n")},
+		testEntry{"inner.zip", zipOf(t, testEntry{"notes.txt", []byte("notes")})},
+	)
+
+	entries, err := archives.Inspect(bytes.NewReader(data), int64(len(data)), archives.TypeZip, testLimits)
+
+	assert.Nil(t, err)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "invoice.pdf", entries[0].Path)
+	assert.Equal(t, "application/pdf", entries[0].ContentType)
+	assert.Equal(t, int64(9), entries[0].Size)
+	assert.Equal(t, 1, entries[0].Depth)
+	assert.Equal(t, archives.TypeZip, entries[1].ContentType)
+	assert.Equal(t, "inner.zip/notes.txt", entries[2].Path)
+	assert.Equal(t, 2, entries[2].Depth)
+}
+
+func TestInspect_TarGz(t *testing.T) {
+	data := tarGzOf(t, testEntry{"docs/a.txt", []byte("a")}, testEntry{"docs/b.txt", []byte("bb")})
+
+	entries, err := archives.Inspect(bytes.NewReader(data), int64(len(data)), archives.TypeGzip, testLimits)
+
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "docs/b.txt", entries[1].Path)
+	assert.Equal(t, int64(2), entries[1].Size)
+}
+
+func TestInspect_NestedTarGz(t *testing.T) {
+	data := zipOf(t, testEntry{"docs.tar.gz", tarGzOf(t, testEntry{"docs/a.txt", []byte("a")}, testEntry{"docs/b.txt", []byte("bb")})})
+
+	entries, err := archives.Inspect(bytes.NewReader(data), int64(len(data)), archives.TypeZip, testLimits)
+
+	assert.Nil(t, err)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, archives.TypeGzip, entries[0].ContentType)
+	assert.Equal(t, "docs.tar.gz/docs/b.txt", entries[2].Path)
+	assert.Equal(t, int64(2), entries[2].Size)
+	assert.Equal(t, 2, entries[2].Depth)
+}
+
+func TestInspect_Depth(t *testing.T) {
+	data := zipOf(t, testEntry{"a.zip", zipOf(t, testEntry{"b.zip", zipOf(t, testEntry{"c.txt", []byte("c")})})})
+
+	err := inspect(data, archives.TypeZip, archives.Limits{MaxDepth: 2})
+
+	assert.True(t, apperrors.Is(err, apperrors.KindInvalid))
+	assert.Nil(t, inspect(data, archives.TypeZip, archives.Limits{MaxDepth: 3}))
+}
+
+func TestInspect_Entries(t *testing.T) {
+	data := zipOf(t, testEntry{"a.txt", []byte("a")}, testEntry{"b.txt", []byte("b")}, testEntry{"c.txt", []byte("c")})
+
+	err := inspect(data, archives.TypeZip, archives.Limits{MaxEntries: 2})
+
+	assert.True(t, apperrors.Is(err, apperrors.KindInvalid))
+}
+
+func TestInspect_Ratio(t *testing.T) {
+	data := zipOf(t, testEntry{"zeros.txt", []byte(strings.Repeat("0", 1<<20))})
+
+	err := inspect(data, archives.TypeZip, archives.Limits{MaxRatio: 10})
+
+	assert.True(t, apperrors.Is(err, apperrors.KindInvalid))
+	assert.Nil(t, inspect(data, archives.TypeZip, archives.Limits{MaxRatio: 10000}))
+}
+
+func TestInspect_Broken(t *testing.T) {
+	err := inspect([]byte("PK\x03\x04 broken"), archives.TypeZip, testLimits)
+
+	assert.True(t, apperrors.Is(err, apperrors.KindInvalid))
+}
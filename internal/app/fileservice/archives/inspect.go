@@ -0,0 +1,203 @@
+// Package archives lists the entries of the uploaded ZIP and TAR archives, the
+// nested archives are listed too. The limits of the nesting depth, the number
+// of entries and the expansion ratio reject the archive bombs before anything
+// is stored. The entries are read but not extracted.
+package archives
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/contenttype"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+)
+
+// Content types of the supported archives
+const (
+	TypeZip  = "application/zip"
+	TypeTar  = "application/x-tar"
+	TypeGzip = "application/x-gzip"
+)
+
+// headLen is the number of bytes of an entry sniffed for its content type
+const headLen = 512
+
+// Limits of the inspection, MaxRatio bounds the bytes of all the entries relative
+// to the size of the archive and MaxExpandedBytes bounds them absolutely. The
+// zero limits are not checked.
+type Limits struct {
+	MaxDepth         int
+	MaxEntries       int
+	MaxRatio         float64
+	MaxExpandedBytes int64
+}
+
+// IsArchive reports if the files of contentType are inspected
+func IsArchive(contentType string) bool {
+	return contentType == TypeZip || contentType == TypeTar || contentType == TypeGzip
+}
+
+// Inspect lists the entries of the archive of contentType read from r, an error
+// of the kind Invalid is returned if the archive is broken or exceeds limits
+func Inspect(r io.ReaderAt, size int64, contentType string, limits Limits) ([]*model.ArchiveEntry, error) {
+	budget := int64(math.MaxInt64 - 1)
+	if limits.MaxRatio > 0 {
+		budget = int64(limits.MaxRatio * float64(size))
+	}
+	if limits.MaxExpandedBytes > 0 && budget > limits.MaxExpandedBytes {
+		budget = limits.MaxExpandedBytes
+	}
+	in := &inspector{
+		limits:  limits,
+		budget:  budget,
+		entries: []*model.ArchiveEntry{},
+	}
+	var err error
+	switch contentType {
+	case TypeZip:
+		err = in.zip(r, size, "", 1)
+	case TypeTar:
+		err = in.tar(io.NewSectionReader(r, 0, size), "", 1)
+	case TypeGzip:
+		err = in.gzip(io.NewSectionReader(r, 0, size), "", 1)
+	default:
+		return nil, apperrors.Invalid(apperrors.CodeArchiveRejected, "Content type %s is not an archive", contentType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return in.entries, nil
+}
+
+type inspector struct {
+	limits  Limits
+	budget  int64
+	entries []*model.ArchiveEntry
+}
+
+func (in *inspector) zip(r io.ReaderAt, size int64, prefix string, depth int) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return apperrors.Invalid(apperrors.CodeArchiveRejected, "Error reading zip archive %s, %v", prefix, err)
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return apperrors.Invalid(apperrors.CodeArchiveRejected, "Error reading entry %s, %v", prefix+f.Name, err)
+		}
+		err = in.entry(prefix+f.Name, rc, depth)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (in *inspector) tar(r io.Reader, prefix string, depth int) error {
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return apperrors.Invalid(apperrors.CodeArchiveRejected, "Error reading tar archive %s, %v", prefix, err)
+		}
+		if h.Typeflag != tar.TypeReg && h.Typeflag != tar.TypeRegA {
+			continue
+		}
+		if err := in.entry(prefix+h.Name, tr, depth); err != nil {
+			return err
+		}
+	}
+}
+
+// gzip lists the entries of a compressed tar, any other compressed file is the
+// single entry named by its header
+func (in *inspector) gzip(r io.Reader, prefix string, depth int) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return apperrors.Invalid(apperrors.CodeArchiveRejected, "Error reading gzip archive %s, %v", prefix, err)
+	}
+	defer gr.Close()
+	br := bufio.NewReaderSize(gr, headLen)
+	head, _ := br.Peek(headLen)
+	if contenttype.Detect(head) == TypeTar {
+		return in.tar(br, prefix, depth)
+	}
+	name := gr.Name
+	if name == "" {
+		name = "content"
+	}
+	return in.entry(prefix+path.Base(name), br, depth)
+}
+
+// entry records the entry read from r and lists it if it is an archive itself
+func (in *inspector) entry(name string, r io.Reader, depth int) error {
+	if in.limits.MaxEntries > 0 && len(in.entries) >= in.limits.MaxEntries {
+		return apperrors.Invalid(apperrors.CodeArchiveRejected, "Archive has more than %d entries", in.limits.MaxEntries)
+	}
+	br := bufio.NewReaderSize(r, headLen)
+	head, _ := br.Peek(headLen)
+	e := &model.ArchiveEntry{
+		Path:        name,
+		ContentType: contenttype.Detect(head),
+		Depth:       depth,
+	}
+	in.entries = append(in.entries, e)
+	limited := io.LimitReader(br, in.budget+1)
+	if !IsArchive(e.ContentType) {
+		n, err := io.Copy(ioutil.Discard, limited)
+		e.Size = n
+		return in.spend(name, n, err)
+	}
+	if in.limits.MaxDepth > 0 && depth >= in.limits.MaxDepth {
+		return apperrors.Invalid(apperrors.CodeArchiveRejected, "Archive %s is nested deeper than %d levels", name, in.limits.MaxDepth)
+	}
+	// the nested archive is spooled to a file like the uploaded one, it may
+	// expand to the whole budget which is not held in memory
+	spool, err := ioutil.TempFile("", "nested-archive-*")
+	if err != nil {
+		return fmt.Errorf("Error creating nested archive spool, %v", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	e.Size, err = io.Copy(spool, limited)
+	if err := in.spend(name, e.Size, err); err != nil {
+		return err
+	}
+	prefix := name + "/"
+	switch e.ContentType {
+	case TypeZip:
+		return in.zip(spool, e.Size, prefix, depth+1)
+	case TypeTar:
+		return in.tar(io.NewSectionReader(spool, 0, e.Size), prefix, depth+1)
+	default:
+		return in.gzip(io.NewSectionReader(spool, 0, e.Size), prefix, depth+1)
+	}
+}
+
+// spend takes the n bytes read of the entry name from the budget of the expansion
+func (in *inspector) spend(name string, n int64, err error) error {
+	if err != nil {
+		return apperrors.Invalid(apperrors.CodeArchiveRejected, "Error reading entry %s, %v", name, err)
+	}
+	in.budget -= n
+	if in.budget < 0 {
+		return apperrors.Invalid(apperrors.CodeArchiveRejected, "Archive expands beyond its limits at entry %s", name)
+	}
+	return nil
+}
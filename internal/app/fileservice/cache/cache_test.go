@@ -0,0 +1,137 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/cache"
+)
+
+var (
+	keyA = cache.Key{TenantID: "default", FileID: "a"}
+	keyB = cache.Key{TenantID: "default", FileID: "b"}
+	keyC = cache.Key{TenantID: "hr", FileID: "a"}
+)
+
+func TestLRU_GetAdd(t *testing.T) {
+	c := cache.NewLRU(2, time.Minute)
+	_, version, ok := c.Get(keyA)
+	assert.False(t, ok)
+	assert.True(t, c.Add(keyA, map[string]interface{}{"class": "invoice"}, version))
+
+	value, _, ok := c.Get(keyA)
+	assert.True(t, ok)
+	assert.Equal(t, "invoice", value["class"])
+	_, _, ok = c.Get(keyC)
+	assert.False(t, ok)
+}
+
+func TestLRU_Evicts(t *testing.T) {
+	c := cache.NewLRU(2, time.Minute)
+	c.Add(keyA, map[string]interface{}{}, 0)
+	c.Add(keyB, map[string]interface{}{}, 0)
+	c.Get(keyA)
+	c.Add(keyC, map[string]interface{}{}, 0)
+
+	_, _, ok := c.Get(keyB)
+	assert.False(t, ok)
+	_, _, ok = c.Get(keyA)
+	assert.True(t, ok)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestLRU_Expires(t *testing.T) {
+	c := cache.NewLRU(2, time.Millisecond)
+	c.Add(keyA, map[string]interface{}{}, 0)
+	time.Sleep(5 * time.Millisecond)
+
+	_, _, ok := c.Get(keyA)
+	assert.False(t, ok)
+}
+
+func TestLRU_InvalidateRejectsStaleLoad(t *testing.T) {
+	c := cache.NewLRU(2, time.Minute)
+	_, version, _ := c.Get(keyA)
+	c.Invalidate(keyA)
+
+	assert.False(t, c.Add(keyA, map[string]interface{}{}, version))
+	_, version, _ = c.Get(keyA)
+	assert.True(t, c.Add(keyA, map[string]interface{}{}, version))
+}
+
+func TestLRU_EvictedInvalidationRejectsStaleLoad(t *testing.T) {
+	c := cache.NewLRU(1, time.Minute)
+	_, version, _ := c.Get(keyA)
+	c.Invalidate(keyA)
+	c.Invalidate(keyB)
+
+	assert.False(t, c.Add(keyA, map[string]interface{}{}, version))
+}
+
+func TestLRU_PurgeRejectsStaleLoad(t *testing.T) {
+	c := cache.NewLRU(2, time.Minute)
+	_, version, _ := c.Get(keyA)
+	c.Add(keyB, map[string]interface{}{}, version)
+	c.Purge()
+
+	assert.Equal(t, 0, c.Len())
+	assert.False(t, c.Add(keyA, map[string]interface{}{}, version))
+}
+
+func TestGroup_Do(t *testing.T) {
+	g := cache.Group{}
+	release := make(chan struct{})
+	var calls int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, _, err := g.Do(context.Background(), keyA, 0, func() (map[string]interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return map[string]interface{}{"class": "invoice"}, nil
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, "invoice", value["class"])
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestGroup_Do_CallerDone(t *testing.T) {
+	g := cache.Group{}
+	release := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := g.Do(ctx, keyA, 0, func() (map[string]interface{}, error) {
+		<-release
+		return map[string]interface{}{"class": "invoice"}, nil
+	})
+	assert.Equal(t, context.Canceled, err)
+
+	// the load goes on and is joined by the next caller
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		value, shared, err := g.Do(context.Background(), keyA, 0, func() (map[string]interface{}, error) {
+			return nil, fmt.Errorf("loaded twice")
+		})
+		assert.Nil(t, err)
+		assert.True(t, shared)
+		assert.Equal(t, "invoice", value["class"])
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	<-done
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"context"
+	"sync"
+)
+
+// Group collapses the concurrent loads of the same key into one, the loads
+// started after an invalidation do not join the ones started before it
+type Group struct {
+	mu    sync.Mutex
+	calls map[flightKey]*call
+}
+
+type flightKey struct {
+	key     Key
+	version uint64
+}
+
+type call struct {
+	done  chan struct{}
+	value map[string]interface{}
+	err   error
+}
+
+// Do calls fn once for the concurrent callers of key as of version, all of them
+// get its result and shared reports whether it was loaded by another caller.
+// fn runs apart from the callers, it must not use their contexts: a caller
+// whose ctx is done returns ctx.Err() and the load goes on for the others.
+func (g *Group) Do(ctx context.Context, key Key, version uint64, fn func() (map[string]interface{}, error)) (value map[string]interface{}, shared bool, err error) {
+	fk := flightKey{key: key, version: version}
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[flightKey]*call)
+	}
+	c, shared := g.calls[fk]
+	if !shared {
+		c = &call{done: make(chan struct{})}
+		g.calls[fk] = c
+		go g.load(fk, c, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.value, shared, c.err
+	case <-ctx.Done():
+		return nil, shared, ctx.Err()
+	}
+}
+
+func (g *Group) load(fk flightKey, c *call, fn func() (map[string]interface{}, error)) {
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, fk)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.value, c.err = fn()
+}
@@ -0,0 +1,143 @@
+// Package cache holds the file metadata of the recent reads in process, the
+// entries are dropped on the changes of the files in this and the other
+// replicas and expire after a while in any case.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Key identifies the cached file across the tenants
+type Key struct {
+	TenantID string
+	FileID   string
+}
+
+// LRU is a size bound cache of the file metadata with a TTL. The loads racing
+// with an invalidation are told apart by the version Get returns on a miss,
+// an Add of a value loaded before the invalidation is ignored.
+type LRU struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	evictList  *list.List
+	items      map[Key]*list.Element
+	// version is bumped by every invalidation
+	version uint64
+	// floor is the oldest version whose invalidations are still known, the
+	// loads started before it may miss an evicted invalidation
+	floor uint64
+	now   func() time.Time
+}
+
+type entry struct {
+	key     Key
+	value   map[string]interface{}
+	version uint64
+	expires time.Time
+}
+
+// NewLRU returns a cache of up to maxEntries files kept for ttl
+func NewLRU(maxEntries int, ttl time.Duration) *LRU {
+	return &LRU{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		evictList:  list.New(),
+		items:      make(map[Key]*list.Element),
+		now:        time.Now,
+	}
+}
+
+// Get returns the metadata of key, on a miss it returns the version to Add the
+// loaded metadata with
+func (c *LRU) Get(key Key) (map[string]interface{}, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		ent := e.Value.(*entry)
+		if ent.value != nil && c.now().Before(ent.expires) {
+			c.evictList.MoveToFront(e)
+			return ent.value, ent.version, true
+		}
+	}
+	return nil, c.version, false
+}
+
+// Add caches the metadata of key loaded as of version and reports whether it
+// was added, it is not when key was invalidated since
+func (c *LRU) Add(key Key, value map[string]interface{}, version uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if version < c.floor {
+		return false
+	}
+	if e, ok := c.items[key]; ok {
+		ent := e.Value.(*entry)
+		if ent.value == nil && ent.version > version {
+			return false
+		}
+		ent.value = value
+		ent.version = version
+		ent.expires = c.now().Add(c.ttl)
+		c.evictList.MoveToFront(e)
+		return true
+	}
+	c.push(&entry{key: key, value: value, version: version, expires: c.now().Add(c.ttl)})
+	return true
+}
+
+// Invalidate drops the metadata of key, the loads in flight are not added
+func (c *LRU) Invalidate(key Key) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version++
+	if e, ok := c.items[key]; ok {
+		ent := e.Value.(*entry)
+		ent.value = nil
+		ent.version = c.version
+		c.evictList.MoveToFront(e)
+		return
+	}
+	c.push(&entry{key: key, version: c.version})
+}
+
+// Purge drops all of the entries, the loads in flight are not added
+func (c *LRU) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version++
+	c.floor = c.version
+	c.evictList.Init()
+	c.items = make(map[Key]*list.Element)
+}
+
+// Len returns the number of the cached files
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, e := range c.items {
+		if e.Value.(*entry).value != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// push adds ent to the front and evicts the least recently used entry beyond
+// maxEntries, an evicted invalidation raises the floor
+func (c *LRU) push(ent *entry) {
+	c.items[ent.key] = c.evictList.PushFront(ent)
+	if c.evictList.Len() <= c.maxEntries {
+		return
+	}
+	oldest := c.evictList.Back()
+	old := oldest.Value.(*entry)
+	c.evictList.Remove(oldest)
+	delete(c.items, old.key)
+	if old.value == nil && old.version > c.floor {
+		c.floor = old.version
+	}
+}
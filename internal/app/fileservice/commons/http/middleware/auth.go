@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/unistack-org/micro/v3/logger"
+)
+
+// Kinds of the authenticated principals
+const (
+	PrincipalUser    = "user"
+	PrincipalService = "service"
+)
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the request
+	// carries no credentials it understands, the next one is tried then
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned when the credentials are present but not valid
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type principalKey struct{}
+
+// Principal is the authenticated caller of the request
+type Principal struct {
+	Subject string
+	Kind    string
+	Tenant  string
+	Roles   []string
+	Scopes  []string
+	Claims  map[string]interface{}
+}
+
+// HasScope ...
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// NewPrincipalContext returns a copy of ctx carrying p
+func NewPrincipalContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal of the request if it was authenticated
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// Authenticator resolves the principal from the credentials of the request
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// AuthErrorHandler writes the response of a request that failed authentication
+type AuthErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// AuthMiddleware ...
+type AuthMiddleware struct {
+	authenticators []Authenticator
+	onError        AuthErrorHandler
+}
+
+// NewAuthMiddleware tries the authenticators in order, the first one which
+// recognizes the credentials decides whether the request is let through
+func NewAuthMiddleware(onError AuthErrorHandler, authenticators ...Authenticator) *AuthMiddleware {
+	if onError == nil {
+		onError = func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		}
+	}
+	return &AuthMiddleware{
+		authenticators: authenticators,
+		onError:        onError,
+	}
+}
+
+// Authenticate returns the principal of r, ErrNoCredentials if none of the
+// authenticators recognized the credentials
+func (s *AuthMiddleware) Authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range s.authenticators {
+		p, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return p, err
+	}
+	return nil, ErrNoCredentials
+}
+
+func (s *AuthMiddleware) Wrapper(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p, err := s.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="file-service"`)
+			s.onError(w, r, err)
+			return
+		}
+		ctx := NewPrincipalContext(r.Context(), p)
+		if log, ok := logger.FromContext(ctx); ok {
+			ctx = logger.NewContext(ctx, log.Fields(map[string]interface{}{"principal": p.Subject}))
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
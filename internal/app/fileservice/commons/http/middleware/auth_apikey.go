@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIKeyHeader is the header service callers put their api key into
+const APIKeyHeader = "X-Api-Key"
+
+// APIKeyStore finds the principal owning the api key with the given hash,
+// ErrInvalidCredentials is returned for unknown or revoked keys
+type APIKeyStore interface {
+	FindAPIKey(ctx context.Context, hash string) (*Principal, error)
+}
+
+// HashAPIKey returns the hex encoded sha256 of key, only the hashes are stored
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyAuthenticator authenticates service to service callers by api key
+type APIKeyAuthenticator struct {
+	store APIKeyStore
+}
+
+// NewAPIKeyAuthenticator ...
+func NewAPIKeyAuthenticator(store APIKeyStore) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{
+		store: store,
+	}
+}
+
+// Authenticate reads the key from the X-Api-Key header or from "Authorization: ApiKey <key>"
+func (aa *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if h := r.Header.Get("Authorization"); key == "" && len(h) > 7 && strings.EqualFold(h[:7], "apikey ") {
+		key = strings.TrimSpace(h[7:])
+	}
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	p, err := aa.store.FindAPIKey(r.Context(), HashAPIKey(key))
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("Error finding api key, %v", err)
+	}
+	p.Kind = PrincipalService
+	return p, nil
+}
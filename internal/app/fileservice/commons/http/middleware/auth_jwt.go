@@ -0,0 +1,365 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jsonWebKey is a single key of a JWKS document, only the RSA and EC
+// signing keys are supported
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding modulus, %v", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding exponent, %v", err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("Unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding x, %v", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding y, %v", err)
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	default:
+		return nil, fmt.Errorf("Unsupported key type %s", k.Kty)
+	}
+}
+
+// KeySet holds the public keys of a JWKS document by their key id
+type KeySet struct {
+	keys map[string]crypto.PublicKey
+}
+
+// ParseKeySet parses a JWKS document, keys which are not meant for signatures are skipped
+func ParseKeySet(data []byte) (*KeySet, error) {
+	doc := struct {
+		Keys []*jsonWebKey `json:"keys"`
+	}{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("Error parsing jwks, %v", err)
+	}
+	ks := &KeySet{keys: make(map[string]crypto.PublicKey, len(doc.Keys))}
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pk, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing key %s, %v", k.Kid, err)
+		}
+		ks.keys[k.Kid] = pk
+	}
+	return ks, nil
+}
+
+// LoadKeySet loads a JWKS document from a file path or a http(s) url
+func LoadKeySet(ctx context.Context, location string) (*KeySet, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		data, err := ioutil.ReadFile(location)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading jwks file, %v", err)
+		}
+		return ParseKeySet(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating jwks request, %v", err)
+	}
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching jwks, %v", err)
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error fetching jwks, status %d", rsp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading jwks, %v", err)
+	}
+	return ParseKeySet(data)
+}
+
+// key returns the key by kid, a token without kid is accepted only if the set has a single key
+func (ks *KeySet) key(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(ks.keys) == 1 {
+		for _, k := range ks.keys {
+			return k, true
+		}
+	}
+	k, ok := ks.keys[kid]
+	return k, ok
+}
+
+// JWTAuthenticator validates the bearer tokens of the Authorization header
+type JWTAuthenticator struct {
+	location string
+	issuer   string
+	audience string
+	leeway   time.Duration
+
+	// minRefresh limits how often an unknown kid triggers a reload of the key set
+	minRefresh time.Duration
+	refreshed  time.Time
+	keys       *KeySet
+	lock       sync.RWMutex
+}
+
+// NewJWTAuthenticator loads the key set from location, empty issuer or audience are not checked
+func NewJWTAuthenticator(ctx context.Context, location, issuer, audience string) (*JWTAuthenticator, error) {
+	keys, err := LoadKeySet(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	return &JWTAuthenticator{
+		location:   location,
+		issuer:     issuer,
+		audience:   audience,
+		leeway:     time.Minute,
+		minRefresh: time.Minute,
+		refreshed:  time.Now(),
+		keys:       keys,
+	}, nil
+}
+
+// Authenticate ...
+func (ja *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	h := r.Header.Get("Authorization")
+	if len(h) < 7 || !strings.EqualFold(h[:7], "bearer ") {
+		return nil, ErrNoCredentials
+	}
+	claims, err := ja.Verify(r.Context(), strings.TrimSpace(h[7:]))
+	if err != nil {
+		return nil, fmt.Errorf("%w, %v", ErrInvalidCredentials, err)
+	}
+	sub, _ := claims["sub"].(string)
+	tenant, _ := claims["tenant"].(string)
+	if sub == "" {
+		return nil, fmt.Errorf("%w, token has no subject", ErrInvalidCredentials)
+	}
+	return &Principal{
+		Subject: sub,
+		Kind:    PrincipalUser,
+		Tenant:  tenant,
+		Roles:   stringsOf(claims["roles"]),
+		Scopes:  scopesOf(claims),
+		Claims:  claims,
+	}, nil
+}
+
+// Verify checks the signature and the registered claims of token and returns its claims
+func (ja *JWTAuthenticator) Verify(ctx context.Context, token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("Error decoding token header, %v", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding token signature, %v", err)
+	}
+	key, err := ja.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+	claims := make(map[string]interface{})
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("Error decoding token claims, %v", err)
+	}
+	if err := ja.validateClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (ja *JWTAuthenticator) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	ja.lock.RLock()
+	k, ok := ja.keys.key(kid)
+	stale := time.Since(ja.refreshed) > ja.minRefresh
+	ja.lock.RUnlock()
+	if ok {
+		return k, nil
+	}
+	if !stale {
+		return nil, fmt.Errorf("unknown key %s", kid)
+	}
+
+	// the signing keys may have been rotated
+	keys, err := LoadKeySet(ctx, ja.location)
+	ja.lock.Lock()
+	defer ja.lock.Unlock()
+	ja.refreshed = time.Now()
+	if err != nil {
+		return nil, err
+	}
+	ja.keys = keys
+	if k, ok := ja.keys.key(kid); ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown key %s", kid)
+}
+
+func (ja *JWTAuthenticator) validateClaims(claims map[string]interface{}) error {
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("token has no expiration")
+	}
+	if now.Add(-ja.leeway).After(time.Unix(int64(exp), 0)) {
+		return fmt.Errorf("token is expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(ja.leeway).Before(time.Unix(int64(nbf), 0)) {
+		return fmt.Errorf("token is not valid yet")
+	}
+	if ja.issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != ja.issuer {
+			return fmt.Errorf("unexpected issuer %s", iss)
+		}
+	}
+	if ja.audience != "" && !hasAudience(claims["aud"], ja.audience) {
+		return fmt.Errorf("token is not issued for %s", ja.audience)
+	}
+	return nil
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed string, sig []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported algorithm %s", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg[:2] != "RS" {
+			return fmt.Errorf("algorithm %s does not match the key", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(k, hash, digest, sig); err != nil {
+			return fmt.Errorf("invalid signature")
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg[:2] != "ES" || len(sig) != 2*size {
+			return fmt.Errorf("algorithm %s does not match the key", alg)
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return fmt.Errorf("invalid signature")
+		}
+	default:
+		return fmt.Errorf("unsupported key")
+	}
+	return nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func hasAudience(aud interface{}, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// scopesOf reads the space separated "scope" claim or the "scp" list
+func scopesOf(claims map[string]interface{}) []string {
+	if s, ok := claims["scope"].(string); ok {
+		return strings.Fields(s)
+	}
+	return stringsOf(claims["scp"])
+}
+
+func stringsOf(claim interface{}) []string {
+	values := []string{}
+	if l, ok := claim.([]interface{}); ok {
+		for _, v := range l {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,203 @@
+package middleware_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+)
+
+type apiKeyStore map[string]*middleware.Principal
+
+func (s apiKeyStore) FindAPIKey(ctx context.Context, hash string) (*middleware.Principal, error) {
+	p, ok := s[hash]
+	if !ok {
+		return nil, middleware.ErrInvalidCredentials
+	}
+	return p, nil
+}
+
+func prepareKeySet(t *testing.T, key *rsa.PrivateKey) string {
+	jwks, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Error marshaling jwks, %v", err)
+	}
+	dir, err := ioutil.TempDir("", "jwks")
+	if err != nil {
+		t.Fatalf("Error creating temp dir, %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "jwks.json")
+	if err := ioutil.WriteFile(path, jwks, 0600); err != nil {
+		t.Fatalf("Error writing jwks, %v", err)
+	}
+	return path
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("Error marshaling claims, %v", err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("Error signing token, %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func prepareAuthMiddleware(t *testing.T, key *rsa.PrivateKey) *middleware.AuthMiddleware {
+	jwtAuth, err := middleware.NewJWTAuthenticator(context.Background(), prepareKeySet(t, key), "issuer", "file-service")
+	if err != nil {
+		t.Fatalf("Error creating jwt authenticator, %v", err)
+	}
+	keys := apiKeyStore{
+		middleware.HashAPIKey("secret"): {Subject: "billing", Scopes: []string{"files:read"}},
+	}
+	return middleware.NewAuthMiddleware(nil, jwtAuth, middleware.NewAPIKeyAuthenticator(keys))
+}
+
+func serveAuth(am *middleware.AuthMiddleware, req *http.Request) (*httptest.ResponseRecorder, *middleware.Principal) {
+	var principal *middleware.Principal
+	rec := httptest.NewRecorder()
+	am.Wrapper(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = middleware.PrincipalFromContext(r.Context())
+	})).ServeHTTP(rec, req)
+	return rec, principal
+}
+
+func TestAuthMiddleware_ValidToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key, %v", err)
+	}
+	am := prepareAuthMiddleware(t, key)
+	req := httptest.NewRequest(http.MethodGet, "/metadata/testID", nil)
+	req.Header.Set("Authorization", "Bearer "+signToken(t, key, map[string]interface{}{
+		"sub":   "user",
+		"iss":   "issuer",
+		"aud":   []string{"file-service"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "files:read files:write",
+	}))
+
+	rec, principal := serveAuth(am, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "user", principal.Subject)
+	assert.Equal(t, middleware.PrincipalUser, principal.Kind)
+	assert.True(t, principal.HasScope("files:write"))
+}
+
+func TestAuthMiddleware_ExpiredToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key, %v", err)
+	}
+	am := prepareAuthMiddleware(t, key)
+	req := httptest.NewRequest(http.MethodGet, "/metadata/testID", nil)
+	req.Header.Set("Authorization", "Bearer "+signToken(t, key, map[string]interface{}{
+		"sub": "user",
+		"iss": "issuer",
+		"aud": "file-service",
+		"exp": time.Now().Add(-time.Hour).Unix(),
+	}))
+
+	rec, principal := serveAuth(am, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Nil(t, principal)
+}
+
+func TestAuthMiddleware_TokenSignedByOtherKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key, %v", err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key, %v", err)
+	}
+	am := prepareAuthMiddleware(t, key)
+	req := httptest.NewRequest(http.MethodGet, "/metadata/testID", nil)
+	req.Header.Set("Authorization", "Bearer "+signToken(t, other, map[string]interface{}{
+		"sub": "user",
+		"iss": "issuer",
+		"aud": "file-service",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}))
+
+	rec, _ := serveAuth(am, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAuthMiddleware_APIKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key, %v", err)
+	}
+	am := prepareAuthMiddleware(t, key)
+	req := httptest.NewRequest(http.MethodGet, "/metadata/testID", nil)
+	req.Header.Set(middleware.APIKeyHeader, "secret")
+
+	rec, principal := serveAuth(am, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "billing", principal.Subject)
+	assert.Equal(t, middleware.PrincipalService, principal.Kind)
+}
+
+func TestAuthMiddleware_UnknownAPIKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key, %v", err)
+	}
+	am := prepareAuthMiddleware(t, key)
+	req := httptest.NewRequest(http.MethodGet, "/metadata/testID", nil)
+	req.Header.Set("Authorization", "ApiKey unknown")
+
+	rec, _ := serveAuth(am, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAuthMiddleware_NoCredentials(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key, %v", err)
+	}
+	am := prepareAuthMiddleware(t, key)
+	req := httptest.NewRequest(http.MethodGet, "/metadata/testID", nil)
+
+	rec, _ := serveAuth(am, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+}
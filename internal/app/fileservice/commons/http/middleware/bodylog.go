@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"strings"
+)
+
+// limitedBuffer keeps the first max bytes written to it and counts the rest
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	max   int
+	total int64
+}
+
+func (lb *limitedBuffer) Write(p []byte) (int, error) {
+	if room := lb.max - lb.buf.Len(); room > 0 {
+		if len(p) > room {
+			lb.buf.Write(p[:room])
+		} else {
+			lb.buf.Write(p)
+		}
+	}
+	lb.total += int64(len(p))
+	return len(p), nil
+}
+
+// bodyObserver is given the bytes of a body as they are read and returns the
+// redacted body for the log
+type bodyObserver interface {
+	io.Writer
+	body(rd *Redactor) (string, bool)
+}
+
+// newBodyObserver returns the observer of the bodies of contentType which are
+// logged, the JSON bodies and the multipart forms
+func newBodyObserver(contentType string, max int) bodyObserver {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+	switch {
+	case isJSON(mediaType):
+		return &jsonObserver{limitedBuffer{max: max}}
+	case mediaType == "multipart/form-data" && params["boundary"] != "":
+		return newMultipartObserver(params["boundary"], max)
+	}
+	return nil
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+type jsonObserver struct {
+	limitedBuffer
+}
+
+func (jo *jsonObserver) body(rd *Redactor) (string, bool) {
+	return rd.Redact(jo.buf.Bytes(), jo.max)
+}
+
+// multipartObserver parses the form as it is read and keeps up to max bytes of
+// its fields, the file parts are skipped
+type multipartObserver struct {
+	pw     *io.PipeWriter
+	failed bool
+	done   chan struct{}
+	max    int
+	names  []string
+	values map[string][]byte
+	// cut is set when a value did not fit in max
+	cut bool
+}
+
+func newMultipartObserver(boundary string, max int) *multipartObserver {
+	pr, pw := io.Pipe()
+	mo := &multipartObserver{pw: pw, done: make(chan struct{}), max: max, values: map[string][]byte{}}
+	go func() {
+		defer close(mo.done)
+		// the reads of the handler never wait for the parser
+		defer io.Copy(ioutil.Discard, pr)
+		mr := multipart.NewReader(pr, boundary)
+		room := max
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				return
+			}
+			name := part.FormName()
+			if part.FileName() != "" || name == "" {
+				continue
+			}
+			value, _ := ioutil.ReadAll(io.LimitReader(part, int64(room)))
+			room -= len(value)
+			if n, _ := io.Copy(ioutil.Discard, part); n > 0 {
+				mo.cut = true
+			}
+			if _, ok := mo.values[name]; !ok {
+				mo.names = append(mo.names, name)
+			}
+			mo.values[name] = value
+		}
+	}()
+	return mo
+}
+
+func (mo *multipartObserver) Write(p []byte) (int, error) {
+	if !mo.failed {
+		if _, err := mo.pw.Write(p); err != nil {
+			mo.failed = true
+		}
+	}
+	return len(p), nil
+}
+
+// body logs the fields as an object, the JSON values are redacted at the path
+// of their field
+func (mo *multipartObserver) body(rd *Redactor) (string, bool) {
+	mo.pw.Close()
+	<-mo.done
+	out := &bytes.Buffer{}
+	out.WriteByte('{')
+	truncated := mo.cut
+	for i, name := range mo.names {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		writeJSON(out, name)
+		out.WriteByte(':')
+		value := bytes.TrimSpace(mo.values[name])
+		if len(value) == 0 || (value[0] != '{' && value[0] != '[') {
+			// the plain values are redacted as strings
+			value, _ = json.Marshal(string(value))
+		}
+		s, cut := rd.redactAt([]pathElem{{key: name}}, value, mo.max)
+		if cut {
+			truncated = true
+			writeJSON(out, s)
+			continue
+		}
+		out.WriteString(s)
+	}
+	out.WriteByte('}')
+	s := out.String()
+	if len(s) > mo.max {
+		s, truncated = s[:mo.max]+truncatedMark, true
+	}
+	return s, truncated
+}
+
+// observedBody passes the read bytes of a request body to the observer
+type observedBody struct {
+	io.ReadCloser
+	w io.Writer
+}
+
+func (ob *observedBody) Read(p []byte) (int, error) {
+	n, err := ob.ReadCloser.Read(p)
+	if n > 0 {
+		ob.w.Write(p[:n])
+	}
+	return n, err
+}
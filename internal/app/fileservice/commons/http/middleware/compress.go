@@ -328,8 +328,16 @@ func (cw *compressResponseWriter) writer() io.Writer {
 	}
 }
 
+// Flush sends the data compressed so far, the encoders buffer their output
+// until they are flushed
 func (cw *compressResponseWriter) Flush() {
-	if f, ok := cw.writer().(http.Flusher); ok {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := cw.writer().(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
 		f.Flush()
 	}
 }
@@ -348,8 +356,12 @@ func (cw *compressResponseWriter) Push(target string, opts *http.PushOptions) er
 	return errors.New("chi/middleware: http.Pusher is unavailable on the writer")
 }
 
+// Close ends the compressed stream, the uncompressed responses are left to the server
 func (cw *compressResponseWriter) Close() error {
-	if c, ok := cw.writer().(io.WriteCloser); ok {
+	if !cw.compressable {
+		return nil
+	}
+	if c, ok := cw.w.(io.WriteCloser); ok {
 		return c.Close()
 	}
 	return errors.New("chi/middleware: io.WriteCloser is unavailable on the writer")
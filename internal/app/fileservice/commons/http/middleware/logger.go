@@ -1,20 +1,74 @@
 package middleware
 
 import (
-	"bytes"
+	"bufio"
 	"context"
-	"io/ioutil"
+	"errors"
+	"math/rand"
+	"mime"
+	"net"
 	"net/http"
-	"net/http/httptest"
 
+	"github.com/gorilla/mux"
 	"github.com/unistack-org/micro/v3/logger"
 	"github.com/unistack-org/micro/v3/metadata"
 )
 
-type LoggerMiddleware struct{}
+// Levels of the request logs, LevelOff turns the logs of a route off
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+	LevelOff   = "off"
+)
+
+// LogRule sets how the requests of a route are logged. SampleRate is the share
+// of the requests logged, the JSON and the multipart bodies of the logged
+// requests are kept up to MaxBodyBytes when it is positive.
+type LogRule struct {
+	Level        string
+	SampleRate   float64
+	MaxBodyBytes int
+	Redactor     *Redactor
+}
+
+// DefaultLogRule logs every request at the info level without the bodies
+var DefaultLogRule = LogRule{Level: LevelInfo, SampleRate: 1, Redactor: &Redactor{}}
+
+// LoggerOption configures the LoggerMiddleware
+type LoggerOption func(*LoggerMiddleware)
 
-func NewLoggerMiddleware() *LoggerMiddleware {
-	return &LoggerMiddleware{}
+// WithDefaultLogRule sets the rule of the routes without their own one
+func WithDefaultLogRule(rule LogRule) LoggerOption {
+	return func(lm *LoggerMiddleware) {
+		lm.rule = rule
+	}
+}
+
+// WithRouteLogRule sets the rule of the route with the method and the path template
+func WithRouteLogRule(method, path string, rule LogRule) LoggerOption {
+	return func(lm *LoggerMiddleware) {
+		lm.routes[routeKey(method, path)] = rule
+	}
+}
+
+type LoggerMiddleware struct {
+	rule   LogRule
+	routes map[string]LogRule
+	sample func() float64
+}
+
+func NewLoggerMiddleware(opts ...LoggerOption) *LoggerMiddleware {
+	lm := &LoggerMiddleware{
+		rule:   DefaultLogRule,
+		routes: map[string]LogRule{},
+		sample: rand.Float64,
+	}
+	for _, o := range opts {
+		o(lm)
+	}
+	return lm
 }
 
 func (s *LoggerMiddleware) Wrapper(next http.Handler) http.Handler {
@@ -30,38 +84,131 @@ func (s *LoggerMiddleware) Wrapper(next http.Handler) http.Handler {
 			return
 		}
 
+		rule := s.ruleOf(r)
+		// the response is passed through as it is written, the downloads are never buffered
+		lw := &loggingResponseWriter{ResponseWriter: w, r: r, status: http.StatusOK}
+		logged := rule.Level != LevelOff && s.sample() < rule.SampleRate
+		var reqBody bodyObserver
+		if logged && rule.MaxBodyBytes > 0 {
+			if r.Body != nil {
+				if reqBody = newBodyObserver(r.Header.Get("Content-Type"), rule.MaxBodyBytes); reqBody != nil {
+					r.Body = &observedBody{ReadCloser: r.Body, w: reqBody}
+				}
+			}
+			lw.maxBodyBytes = rule.MaxBodyBytes
+		}
+		next.ServeHTTP(lw, r)
+		if !lw.wroteHeader {
+			lw.WriteHeader(http.StatusOK)
+		}
+		if !logged {
+			return
+		}
+
 		log, ok := logger.FromContext(r.Context())
 		if !ok {
 			log = logger.DefaultLogger
 		}
-		var body []byte
-		if r.Body != nil {
-			// use http.MaxBytesReader to avoid DoS
-			body, _ = ioutil.ReadAll(r.Body)
-			// Restore the io.ReadCloser to its original state
-			r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
-		}
-		rw := httptest.NewRecorder()
-		next.ServeHTTP(rw, r)
-
-		log.Fields(map[string]interface{}{
+		fields := map[string]interface{}{
 			"http_method": r.Method,
 			"http_uri":    r.URL.String(),
-			//		"http_reqbody": strconv.Quote(string(body)),
-			//		"http_rspbody": strconv.Quote(string(rw.Body.Bytes())),
-			"http_code": rw.Code,
-		}).Info(context.Background())
-		// this copies the recorded response to the response writer
-		for k, v := range rw.HeaderMap {
-			w.Header()[k] = v
+			"http_code":   lw.status,
+			"http_bytes":  lw.size,
 		}
-		if _, ok := rw.HeaderMap[MetadataKey]; !ok {
-			if id, ok := metadata.Get(r.Context(), MetadataKey); ok {
-				w.Header()[MetadataKey] = []string{id}
+		if reqBody != nil {
+			body, truncated := reqBody.body(rule.Redactor)
+			fields["http_reqbody"] = body
+			fields["http_reqbody_truncated"] = truncated
+		}
+		if lw.body != nil {
+			body, truncated := lw.body.body(rule.Redactor)
+			fields["http_rspbody"] = body
+			fields["http_rspbody_truncated"] = truncated
+		}
+		log = log.Fields(fields)
+		switch rule.Level {
+		case LevelDebug:
+			log.Debug(context.Background())
+		case LevelWarn:
+			log.Warn(context.Background())
+		case LevelError:
+			log.Error(context.Background())
+		default:
+			log.Info(context.Background())
+		}
+	})
+}
+
+// ruleOf returns the rule of the route of r
+func (s *LoggerMiddleware) ruleOf(r *http.Request) LogRule {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			if rule, ok := s.routes[routeKey(r.Method, tpl)]; ok {
+				return rule
 			}
 		}
+	}
+	return s.rule
+}
 
-		w.WriteHeader(rw.Code)
-		rw.Body.WriteTo(w)
-	})
+// loggingResponseWriter records the status and the size of the response, keeps
+// the beginning of the JSON bodies and sets the request id header before the
+// status is sent
+type loggingResponseWriter struct {
+	http.ResponseWriter
+	r            *http.Request
+	status       int
+	size         int64
+	wroteHeader  bool
+	maxBodyBytes int
+	body         *jsonObserver
+}
+
+func (lw *loggingResponseWriter) WriteHeader(code int) {
+	if lw.wroteHeader {
+		return
+	}
+	lw.wroteHeader = true
+	lw.status = code
+	if _, ok := lw.Header()[MetadataKey]; !ok {
+		if id, ok := metadata.Get(lw.r.Context(), MetadataKey); ok {
+			lw.Header()[MetadataKey] = []string{id}
+		}
+	}
+	// the bodies encoded by the handler are not readable in the log
+	if lw.maxBodyBytes > 0 && lw.Header().Get("Content-Encoding") == "" {
+		mediaType, _, err := mime.ParseMediaType(lw.Header().Get("Content-Type"))
+		if err == nil && isJSON(mediaType) {
+			lw.body = &jsonObserver{limitedBuffer{max: lw.maxBodyBytes}}
+		}
+	}
+	lw.ResponseWriter.WriteHeader(code)
+}
+
+func (lw *loggingResponseWriter) Write(p []byte) (int, error) {
+	if !lw.wroteHeader {
+		lw.WriteHeader(http.StatusOK)
+	}
+	n, err := lw.ResponseWriter.Write(p)
+	lw.size += int64(n)
+	if lw.body != nil {
+		lw.body.Write(p[:n])
+	}
+	return n, err
+}
+
+func (lw *loggingResponseWriter) Flush() {
+	if !lw.wroteHeader {
+		lw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := lw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (lw *loggingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := lw.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return nil, nil, errors.New("middleware: http.Hijacker is unavailable on the writer")
 }
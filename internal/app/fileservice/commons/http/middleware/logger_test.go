@@ -0,0 +1,100 @@
+package middleware_test
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/stretchr/testify/assert"
+	"github.com/unistack-org/micro/v3/metadata"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+)
+
+func TestLoggerMiddleware_PassesResponseThrough(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handler := middleware.NewLoggerMiddleware().Wrapper(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("first"))
+		w.(http.Flusher).Flush()
+		// the written part reaches the client before the handler returns
+		assert.Equal(t, "first", rec.Body.String())
+		assert.True(t, rec.Flushed)
+		w.Write([]byte(" second"))
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/files/f1", nil)
+	req = req.WithContext(metadata.Set(context.Background(), middleware.MetadataKey, "req-1"))
+
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "first second", rec.Body.String())
+	assert.Equal(t, "req-1", rec.Header().Get(middleware.MetadataKey))
+}
+
+func TestLoggerMiddleware_SetsRequestIDWithoutBody(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handler := middleware.NewLoggerMiddleware().Wrapper(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodDelete, "/files/f1", nil)
+	req = req.WithContext(metadata.Set(context.Background(), middleware.MetadataKey, "req-1"))
+
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "req-1", rec.Header().Get(middleware.MetadataKey))
+}
+
+func TestLoggerMiddleware_ObservesBodies(t *testing.T) {
+	rd, err := middleware.NewRedactor([]string{"$.iin"})
+	assert.Nil(t, err)
+	lm := middleware.NewLoggerMiddleware(middleware.WithDefaultLogRule(middleware.LogRule{
+		Level:        middleware.LevelInfo,
+		SampleRate:   1,
+		MaxBodyBytes: 8,
+		Redactor:     rd,
+	}))
+	reqBody := `{"iin":"123","name":"a long name past the cap"}`
+	rec := httptest.NewRecorder()
+	handler := lm.Wrapper(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the handler reads and writes the whole bodies whatever is logged
+		data, err := ioutil.ReadAll(r.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, reqBody, string(data))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	req := httptest.NewRequest(http.MethodPut, "/files/f1", strings.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, reqBody, rec.Body.String())
+}
+
+func TestCompressor_FlushesEncoder(t *testing.T) {
+	text := strings.Repeat("compressible text ", 100)
+	rec := httptest.NewRecorder()
+	handler := middleware.NewCompressMiddleware(flate.BestSpeed).Wrapper(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(text))
+		w.(http.Flusher).Flush()
+		// the flushed part can be decompressed before the stream is closed
+		assert.True(t, rec.Flushed)
+		assert.NotZero(t, rec.Body.Len())
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/files/f1", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	zr, err := gzip.NewReader(rec.Body)
+	assert.Nil(t, err)
+	data, err := ioutil.ReadAll(zr)
+	assert.Nil(t, err)
+	assert.Equal(t, text, string(data))
+}
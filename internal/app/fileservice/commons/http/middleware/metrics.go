@@ -74,22 +74,37 @@ func HttpMetricsWrapper(next http.Handler) http.Handler {
 		method := r.Method
 		mw.requestSize.WithLabelValues(method, protocol, handler).Observe(computeApproximateRequestSize(r))
 		timer := prometheus.NewTimer(mw.duration.WithLabelValues(method, protocol, handler))
-		sw := &statusWriter{http.StatusOK, w, w.(http.Hijacker)}
+		hj, _ := w.(http.Hijacker)
+		sw := &statusWriter{status: http.StatusOK, ResponseWriter: w, Hijacker: hj}
 		mw.inflight.Inc()
 		defer mw.inflight.Dec()
 		next.ServeHTTP(sw, r)
-		// TODO: not implemented	:	ins.responseSize
 		timer.ObserveDuration()
-		mw.requests.WithLabelValues(fmt.Sprintf("%d", sw.status), method, protocol, handler).Inc()
+		code := fmt.Sprintf("%d", sw.status)
+		mw.requests.WithLabelValues(code, method, protocol, handler).Inc()
+		mw.responseSize.WithLabelValues(code, method, protocol, handler).Observe(float64(sw.size))
 	})
 }
 
 type statusWriter struct {
 	status int
+	size   int
 	http.ResponseWriter
 	http.Hijacker
 }
 
+func (w *statusWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func (w *statusWriter) WriteHeader(code int) {
 	w.status = code
 	w.ResponseWriter.WriteHeader(code)
@@ -0,0 +1,46 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+	metrics "github.com/vielendanke/file-service/internal/app/fileservice/commons/metrics"
+)
+
+const expectedResponseSize = `
+# HELP sberbank_external_service_response_size_histogram_bytes Response size in bytes.
+# TYPE sberbank_external_service_response_size_histogram_bytes histogram
+sberbank_external_service_response_size_histogram_bytes_bucket{code="201",handler="/size",method="POST",protocol="HTTP/1.1",le="100"} 0
+sberbank_external_service_response_size_histogram_bytes_bucket{code="201",handler="/size",method="POST",protocol="HTTP/1.1",le="1000"} 1
+sberbank_external_service_response_size_histogram_bytes_bucket{code="201",handler="/size",method="POST",protocol="HTTP/1.1",le="2000"} 1
+sberbank_external_service_response_size_histogram_bytes_bucket{code="201",handler="/size",method="POST",protocol="HTTP/1.1",le="5000"} 1
+sberbank_external_service_response_size_histogram_bytes_bucket{code="201",handler="/size",method="POST",protocol="HTTP/1.1",le="10000"} 1
+sberbank_external_service_response_size_histogram_bytes_bucket{code="201",handler="/size",method="POST",protocol="HTTP/1.1",le="+Inf"} 1
+sberbank_external_service_response_size_histogram_bytes_sum{code="201",handler="/size",method="POST",protocol="HTTP/1.1"} 500
+sberbank_external_service_response_size_histogram_bytes_count{code="201",handler="/size",method="POST",protocol="HTTP/1.1"} 1
+`
+
+func TestHttpMetricsWrapper_ResponseSize(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(middleware.HttpMetricsWrapper)
+	router.HandleFunc("/size", func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusCreated)
+		rw.Write([]byte(strings.Repeat("a", 300)))
+		rw.Write([]byte(strings.Repeat("b", 200)))
+	}).Methods(http.MethodPost)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/size", nil))
+
+	err := testutil.GatherAndCompare(
+		metrics.Gatherer(),
+		strings.NewReader(expectedResponseSize),
+		"sberbank_external_service_response_size_histogram_bytes",
+	)
+	assert.Nil(t, err)
+}
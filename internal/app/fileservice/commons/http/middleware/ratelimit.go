@@ -31,6 +31,8 @@ type entry struct {
 	value uint64
 	// stores the time that the entry was first incremented
 	updated time.Time
+	// tokens left in the bucket at updated, used by Take
+	tokens float64
 }
 
 // New creates a new Cache.
@@ -85,7 +87,7 @@ func (c *Cache) Incr(key interface{}, maxValue int) (uint64, bool) {
 
 	} else {
 		// new item
-		item := &entry{key, uint64(1), time.Now().UTC()}
+		item := &entry{key: key, value: 1, updated: time.Now().UTC()}
 
 		entry := c.evictList.PushFront(item)
 		c.cache[key] = entry
@@ -95,10 +97,75 @@ func (c *Cache) Incr(key interface{}, maxValue int) (uint64, bool) {
 
 }
 
+// IncrBy adds n to the counter of key in the fixed window of ratePeriod which
+// started with the first increment. The counter is left as is when it would go
+// over maxValue, the time left until the window resets is returned as well
+func (c *Cache) IncrBy(key interface{}, n, maxValue uint64) (uint64, time.Duration, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now().UTC()
+	e, ok := c.element(key, now)
+	if ok && c.ratePeriod > 0 && now.Sub(e.updated) >= c.ratePeriod {
+		e.value = 0
+		e.updated = now
+	}
+	reset := c.ratePeriod - now.Sub(e.updated)
+	if e.value+n > maxValue {
+		return e.value, reset, false
+	}
+	e.value += n
+	return e.value, reset, true
+}
+
+// Take removes n tokens from the bucket of key, which holds up to burst tokens
+// and is refilled with rate tokens per second. When the bucket has not enough
+// tokens nothing is taken and the time until they are refilled is returned
+func (c *Cache) Take(key interface{}, n, rate, burst float64) (float64, time.Duration, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now().UTC()
+	e, ok := c.element(key, now)
+	if !ok {
+		e.value = 0
+		e.tokens = burst
+	}
+	e.tokens += now.Sub(e.updated).Seconds() * rate
+	if e.tokens > burst {
+		e.tokens = burst
+	}
+	e.updated = now
+	if e.tokens < n {
+		if rate <= 0 {
+			return e.tokens, c.ratePeriod, false
+		}
+		return e.tokens, time.Duration((n - e.tokens) / rate * float64(time.Second)), false
+	}
+	e.tokens -= n
+	e.value++
+	return e.tokens, 0, true
+}
+
+// element returns the entry of key moving it to the front, a new empty entry
+// is added when the key is not present and false is returned then
+func (c *Cache) element(key interface{}, now time.Time) (*entry, bool) {
+	if ee, ok := c.cache[key]; ok {
+		c.evictList.MoveToFront(ee)
+		return ee.Value.(*entry), true
+	}
+	if c.evictList.Len() > c.MaxEntries-1 {
+		c.removeOldest()
+	}
+	item := &entry{key: key, updated: now}
+	c.cache[key] = c.evictList.PushFront(item)
+	return item, false
+}
+
 // Get looks up a key's value from the cache.
 func (c *Cache) Get(key interface{}) (value uint64, ok bool) {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
+	c.lock.Lock()
+	defer c.lock.Unlock()
 
 	if ent, ok := c.cache[key]; ok {
 		c.evictList.MoveToFront(ent)
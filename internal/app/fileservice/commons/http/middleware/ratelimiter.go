@@ -0,0 +1,464 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/unistack-org/micro/v3/logger"
+	metrics "github.com/vielendanke/file-service/internal/app/fileservice/commons/metrics"
+)
+
+// Modes of the rate limiters
+const (
+	RateLimitFixedWindow = "fixed_window"
+	RateLimitTokenBucket = "token_bucket"
+)
+
+var (
+	throttledRequests = metrics.GetOrMakeCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NS,
+			Name:      "rate_limit_throttled_requests_total",
+			Help:      "Total number of requests rejected by the rate limits.",
+		},
+		[]string{"method", "handler", "limit"},
+	)
+	throttledSeconds = metrics.GetOrMakeCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NS,
+			Name:      "rate_limit_throttled_seconds_total",
+			Help:      "Total time the transfers waited for the bandwidth limits.",
+		},
+		[]string{"direction"},
+	)
+)
+
+// RateLimit allows Limit units per Period, Burst is the size of the bucket in
+// the token bucket mode and defaults to Limit
+type RateLimit struct {
+	Limit  uint64
+	Period time.Duration
+	Burst  uint64
+}
+
+// Decision is the result of a rate limit check
+type Decision struct {
+	Allowed   bool
+	Limit     uint64
+	Remaining uint64
+	// Reset is the time until the limit is available again
+	Reset time.Duration
+}
+
+// Limiter counts the units taken by the clients
+type Limiter interface {
+	Allow(key string, n uint64) Decision
+	// Max is the largest number of units which can be allowed at once
+	Max() uint64
+}
+
+// NewLimiter returns a limiter of the mode which tracks up to maxClients keys
+func NewLimiter(mode string, maxClients int, limit RateLimit) (Limiter, error) {
+	if limit.Limit == 0 || limit.Period <= 0 {
+		return nil, fmt.Errorf("Error creating rate limiter, limit and period must be positive")
+	}
+	switch mode {
+	case "", RateLimitFixedWindow:
+		cache, err := NewCache(maxClients, limit.Period)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating rate limiter, %v", err)
+		}
+		return &fixedWindowLimiter{cache: cache, limit: limit.Limit}, nil
+	case RateLimitTokenBucket:
+		cache, err := NewCache(maxClients, limit.Period)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating rate limiter, %v", err)
+		}
+		burst := limit.Burst
+		if burst == 0 {
+			burst = limit.Limit
+		}
+		return &tokenBucketLimiter{
+			cache: cache,
+			rate:  float64(limit.Limit) / limit.Period.Seconds(),
+			burst: burst,
+		}, nil
+	default:
+		return nil, fmt.Errorf("Error creating rate limiter, unknown mode %s", mode)
+	}
+}
+
+type fixedWindowLimiter struct {
+	cache *Cache
+	limit uint64
+}
+
+func (fwl *fixedWindowLimiter) Allow(key string, n uint64) Decision {
+	value, reset, ok := fwl.cache.IncrBy(key, n, fwl.limit)
+	remaining := uint64(0)
+	if value < fwl.limit {
+		remaining = fwl.limit - value
+	}
+	return Decision{Allowed: ok, Limit: fwl.limit, Remaining: remaining, Reset: reset}
+}
+
+func (fwl *fixedWindowLimiter) Max() uint64 {
+	return fwl.limit
+}
+
+type tokenBucketLimiter struct {
+	cache *Cache
+	rate  float64
+	burst uint64
+}
+
+func (tbl *tokenBucketLimiter) Allow(key string, n uint64) Decision {
+	tokens, wait, ok := tbl.cache.Take(key, float64(n), tbl.rate, float64(tbl.burst))
+	reset := wait
+	if ok {
+		// time until the bucket is full again
+		reset = time.Duration((float64(tbl.burst) - tokens) / tbl.rate * float64(time.Second))
+	}
+	return Decision{Allowed: ok, Limit: tbl.burst, Remaining: uint64(tokens), Reset: reset}
+}
+
+func (tbl *tokenBucketLimiter) Max() uint64 {
+	return tbl.burst
+}
+
+// RateLimitHandler writes the response of a request over the rate limit
+type RateLimitHandler func(w http.ResponseWriter, r *http.Request, d Decision)
+
+// RateLimitOption ...
+type RateLimitOption func(*RateLimitMiddleware)
+
+// WithRequestLimiter limits the requests of every client
+func WithRequestLimiter(l Limiter) RateLimitOption {
+	return func(rlm *RateLimitMiddleware) {
+		rlm.requests = l
+	}
+}
+
+// WithRouteLimiter limits the requests of every client to the route with the
+// method and the path template, it is used instead of the request limiter
+func WithRouteLimiter(method, path string, l Limiter) RateLimitOption {
+	return func(rlm *RateLimitMiddleware) {
+		rlm.routes[routeKey(method, path)] = l
+	}
+}
+
+// WithUploadLimiter limits the bytes per client read from the request bodies
+func WithUploadLimiter(l Limiter) RateLimitOption {
+	return func(rlm *RateLimitMiddleware) {
+		rlm.upload = l
+	}
+}
+
+// WithDownloadLimiter limits the bytes per client written to the responses
+func WithDownloadLimiter(l Limiter) RateLimitOption {
+	return func(rlm *RateLimitMiddleware) {
+		rlm.download = l
+	}
+}
+
+// WithAddressLimiter limits the requests of every client address, it is
+// applied by AddressWrapper ahead of the authentication so that the requests
+// with wrong credentials are throttled too
+func WithAddressLimiter(l Limiter) RateLimitOption {
+	return func(rlm *RateLimitMiddleware) {
+		rlm.addresses = l
+	}
+}
+
+// WithTrustedForwardedFor identifies the anonymous clients by the first
+// address of X-Forwarded-For, only for the deployments behind a proxy
+func WithTrustedForwardedFor() RateLimitOption {
+	return func(rlm *RateLimitMiddleware) {
+		rlm.trustForwardedFor = true
+	}
+}
+
+// RateLimitMiddleware throttles the clients by the principal or the address,
+// Wrapper runs after the authentication to see the principal and
+// AddressWrapper before it
+type RateLimitMiddleware struct {
+	addresses         Limiter
+	requests          Limiter
+	routes            map[string]Limiter
+	upload            Limiter
+	download          Limiter
+	trustForwardedFor bool
+	onLimited         RateLimitHandler
+}
+
+// NewRateLimitMiddleware ...
+func NewRateLimitMiddleware(onLimited RateLimitHandler, opts ...RateLimitOption) *RateLimitMiddleware {
+	if onLimited == nil {
+		onLimited = func(w http.ResponseWriter, r *http.Request, d Decision) {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		}
+	}
+	rlm := &RateLimitMiddleware{
+		routes:    make(map[string]Limiter),
+		onLimited: onLimited,
+	}
+	for _, o := range opts {
+		o(rlm)
+	}
+	return rlm
+}
+
+// AddressWrapper limits the requests of every address with the address
+// limiter, it does not depend on the principal and goes before the
+// authentication
+func (rlm *RateLimitMiddleware) AddressWrapper(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := rlm.AllowAddress(r.Context(), r.Method, routeTemplate(r), rlm.addressKey(r))
+		if d != nil && !d.Allowed {
+			setRateLimitHeaders(w, *d)
+			rlm.limited(w, r, *d)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Wrapper ...
+func (rlm *RateLimitMiddleware) Wrapper(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := ClientKey(r.Context(), rlm.addressKey(r))
+		if d := rlm.AllowCall(r.Context(), r.Method, routeTemplate(r), client); d != nil {
+			setRateLimitHeaders(w, *d)
+			if !d.Allowed {
+				rlm.limited(w, r, *d)
+				return
+			}
+		}
+		if t := rlm.UploadThrottle(r.Context(), client); t != nil && r.Body != nil && r.Body != http.NoBody {
+			r.Body = &throttledReader{ReadCloser: r.Body, throttle: t}
+		}
+		if t := rlm.DownloadThrottle(r.Context(), client); t != nil {
+			w = &throttledWriter{ResponseWriter: w, throttle: t}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AllowAddress takes a request of the client address from the address limiter,
+// it returns nil when the addresses are not limited. The grpc calls pass GRPC
+// as the method and their endpoint as the handler.
+func (rlm *RateLimitMiddleware) AllowAddress(ctx context.Context, method, handler, client string) *Decision {
+	if rlm.addresses == nil {
+		return nil
+	}
+	d := rlm.addresses.Allow("address|"+client, 1)
+	if !d.Allowed {
+		rlm.throttled(ctx, method, handler, client, "address")
+	}
+	return &d
+}
+
+// AllowCall takes a request of the client from the limiter of the route of
+// method and handler or else from the request limiter, it returns nil when
+// neither applies
+func (rlm *RateLimitMiddleware) AllowCall(ctx context.Context, method, handler, client string) *Decision {
+	limit := "requests"
+	key := "requests|" + client
+	limiter := rlm.requests
+	if l, ok := rlm.routes[routeKey(method, handler)]; ok {
+		limit = "route"
+		key = "route|" + routeKey(method, handler) + "|" + client
+		limiter = l
+	}
+	if limiter == nil {
+		return nil
+	}
+	d := limiter.Allow(key, 1)
+	if !d.Allowed {
+		rlm.throttled(ctx, method, handler, client, limit)
+	}
+	return &d
+}
+
+// UploadThrottle returns the throttle of the bytes the client sends, nil when
+// the uploads are not limited
+func (rlm *RateLimitMiddleware) UploadThrottle(ctx context.Context, client string) *Throttle {
+	if rlm.upload == nil {
+		return nil
+	}
+	return newThrottle(ctx, rlm.upload, "upload|"+client, "upload")
+}
+
+// DownloadThrottle returns the throttle of the bytes sent to the client, nil
+// when the downloads are not limited
+func (rlm *RateLimitMiddleware) DownloadThrottle(ctx context.Context, client string) *Throttle {
+	if rlm.download == nil {
+		return nil
+	}
+	return newThrottle(ctx, rlm.download, "download|"+client, "download")
+}
+
+// throttled logs and counts a request of client over the limit
+func (rlm *RateLimitMiddleware) throttled(ctx context.Context, method, handler, client, limit string) {
+	logger.Infof(ctx, "Rate limit of %s exceeded on %s %s", client, method, handler)
+	throttledRequests.WithLabelValues(method, handler, limit).Inc()
+}
+
+// limited answers the request over the limit
+func (rlm *RateLimitMiddleware) limited(w http.ResponseWriter, r *http.Request, d Decision) {
+	w.Header().Set("Retry-After", strconv.Itoa(seconds(d.Reset)))
+	rlm.onLimited(w, r, d)
+}
+
+// ClientKey returns the rate limit key of the principal of ctx, the key of the
+// address is used for the anonymous callers
+func ClientKey(ctx context.Context, address string) string {
+	if p, ok := PrincipalFromContext(ctx); ok && p.Subject != "" {
+		return p.Kind + ":" + p.Subject
+	}
+	return address
+}
+
+func (rlm *RateLimitMiddleware) addressKey(r *http.Request) string {
+	if rlm.trustForwardedFor {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			return "ip:" + strings.TrimSpace(strings.Split(fwd, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// routeTemplate returns the path template of the route of r
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return "unknown"
+}
+
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+func setRateLimitHeaders(w http.ResponseWriter, d Decision) {
+	w.Header().Set("RateLimit-Limit", strconv.FormatUint(d.Limit, 10))
+	w.Header().Set("RateLimit-Remaining", strconv.FormatUint(d.Remaining, 10))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(d.Reset)))
+}
+
+// seconds rounds d up to whole seconds
+func seconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
+
+// Throttle waits until the bandwidth limiter allows the bytes of a transfer
+type Throttle struct {
+	ctx       context.Context
+	limiter   Limiter
+	key       string
+	direction string
+}
+
+func newThrottle(ctx context.Context, limiter Limiter, key, direction string) *Throttle {
+	return &Throttle{ctx: ctx, limiter: limiter, key: key, direction: direction}
+}
+
+// chunk returns how many of n bytes can be transferred at once
+func (t *Throttle) chunk(n int) int {
+	if max := t.limiter.Max(); uint64(n) > max {
+		return int(max)
+	}
+	return n
+}
+
+// Wait blocks until n bytes are allowed or the request is done, the bytes
+// above the largest allowance of the limiter are waited for in several takes
+func (t *Throttle) Wait(n int) error {
+	for n > 0 {
+		c := t.chunk(n)
+		if err := t.wait(c); err != nil {
+			return err
+		}
+		n -= c
+	}
+	return nil
+}
+
+// wait blocks until n bytes are allowed or the request is done
+func (t *Throttle) wait(n int) error {
+	for {
+		d := t.limiter.Allow(t.key, uint64(n))
+		if d.Allowed {
+			return nil
+		}
+		throttledSeconds.WithLabelValues(t.direction).Add(d.Reset.Seconds())
+		timer := time.NewTimer(d.Reset)
+		select {
+		case <-t.ctx.Done():
+			timer.Stop()
+			return t.ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+type throttledReader struct {
+	io.ReadCloser
+	throttle *Throttle
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return tr.ReadCloser.Read(p)
+	}
+	p = p[:tr.throttle.chunk(len(p))]
+	if err := tr.throttle.wait(len(p)); err != nil {
+		return 0, err
+	}
+	return tr.ReadCloser.Read(p)
+}
+
+type throttledWriter struct {
+	http.ResponseWriter
+	throttle *Throttle
+}
+
+func (tw *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		n := tw.throttle.chunk(len(p) - written)
+		if err := tw.throttle.wait(n); err != nil {
+			return written, err
+		}
+		m, err := tw.ResponseWriter.Write(p[written : written+n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (tw *throttledWriter) Flush() {
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,163 @@
+package middleware_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+)
+
+func prepareLimiter(t *testing.T, mode string, limit middleware.RateLimit) middleware.Limiter {
+	l, err := middleware.NewLimiter(mode, 10, limit)
+	if err != nil {
+		t.Fatalf("Error creating limiter, %v", err)
+	}
+	return l
+}
+
+func prepareRateLimitRouter(opts ...middleware.RateLimitOption) *mux.Router {
+	router := mux.NewRouter()
+	router.Use(middleware.NewRateLimitMiddleware(nil, opts...).Wrapper)
+	router.HandleFunc("/files", func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		rw.Write(body)
+	}).Methods(http.MethodPost)
+	router.HandleFunc("/files/{id}", func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}).Methods(http.MethodGet)
+	return router
+}
+
+func TestLimiter_FixedWindow(t *testing.T) {
+	l := prepareLimiter(t, middleware.RateLimitFixedWindow, middleware.RateLimit{Limit: 2, Period: 50 * time.Millisecond})
+
+	assert.True(t, l.Allow("client", 1).Allowed)
+	d := l.Allow("client", 1)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, uint64(0), d.Remaining)
+	assert.False(t, l.Allow("client", 1).Allowed)
+	assert.True(t, l.Allow("other", 1).Allowed)
+
+	time.Sleep(60 * time.Millisecond)
+
+	d = l.Allow("client", 1)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, uint64(1), d.Remaining)
+}
+
+func TestLimiter_TokenBucket(t *testing.T) {
+	l := prepareLimiter(t, middleware.RateLimitTokenBucket, middleware.RateLimit{Limit: 10, Period: 100 * time.Millisecond, Burst: 2})
+
+	assert.True(t, l.Allow("client", 2).Allowed)
+	d := l.Allow("client", 1)
+	assert.False(t, d.Allowed)
+	assert.True(t, d.Reset > 0 && d.Reset <= 10*time.Millisecond)
+
+	time.Sleep(15 * time.Millisecond)
+
+	assert.True(t, l.Allow("client", 1).Allowed)
+}
+
+func TestNewLimiter_UnknownMode(t *testing.T) {
+	_, err := middleware.NewLimiter("leaky", 10, middleware.RateLimit{Limit: 1, Period: time.Second})
+
+	assert.NotNil(t, err)
+}
+
+func TestRateLimitMiddleware_Headers(t *testing.T) {
+	router := prepareRateLimitRouter(
+		middleware.WithRequestLimiter(prepareLimiter(t, "", middleware.RateLimit{Limit: 1, Period: time.Minute})),
+	)
+
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/files/1", nil))
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "1", rw.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", rw.Header().Get("RateLimit-Remaining"))
+
+	rw = httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/files/2", nil))
+
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "60", rw.Header().Get("Retry-After"))
+}
+
+func TestRateLimitMiddleware_RouteAndPrincipal(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			p := &middleware.Principal{Subject: r.Header.Get("X-Subject"), Kind: middleware.PrincipalUser}
+			next.ServeHTTP(rw, r.WithContext(middleware.NewPrincipalContext(r.Context(), p)))
+		})
+	})
+	router.Use(middleware.NewRateLimitMiddleware(nil,
+		middleware.WithRequestLimiter(prepareLimiter(t, "", middleware.RateLimit{Limit: 100, Period: time.Minute})),
+		middleware.WithRouteLimiter(http.MethodPost, "/files", prepareLimiter(t, "", middleware.RateLimit{Limit: 1, Period: time.Minute})),
+	).Wrapper)
+	router.HandleFunc("/files", func(rw http.ResponseWriter, r *http.Request) {}).Methods(http.MethodPost)
+	upload := func(subject string) int {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/files", nil)
+		req.Header.Set("X-Subject", subject)
+		router.ServeHTTP(rw, req)
+		return rw.Code
+	}
+
+	assert.Equal(t, http.StatusOK, upload("alice"))
+	assert.Equal(t, http.StatusTooManyRequests, upload("alice"))
+	assert.Equal(t, http.StatusOK, upload("bob"))
+}
+
+func TestRateLimitMiddleware_UploadBandwidth(t *testing.T) {
+	router := prepareRateLimitRouter(
+		middleware.WithUploadLimiter(prepareLimiter(t, middleware.RateLimitTokenBucket, middleware.RateLimit{Limit: 1000, Period: time.Second, Burst: 10})),
+	)
+	body := bytes.Repeat([]byte("a"), 60)
+
+	start := time.Now()
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/files", bytes.NewReader(body)))
+
+	assert.Equal(t, body, rw.Body.Bytes())
+	assert.True(t, time.Since(start) >= 40*time.Millisecond)
+}
+
+// rejectAll fails the authentication of every request
+type rejectAll struct{}
+
+func (rejectAll) Authenticate(r *http.Request) (*middleware.Principal, error) {
+	return nil, middleware.ErrInvalidCredentials
+}
+
+func TestRateLimitMiddleware_AddressBeforeAuthentication(t *testing.T) {
+	rlm := middleware.NewRateLimitMiddleware(nil,
+		middleware.WithAddressLimiter(prepareLimiter(t, "", middleware.RateLimit{Limit: 3, Period: time.Minute})),
+	)
+	router := mux.NewRouter()
+	router.Use(rlm.AddressWrapper)
+	router.Use(middleware.NewAuthMiddleware(nil, rejectAll{}).Wrapper)
+	router.Use(rlm.Wrapper)
+	router.HandleFunc("/files/{id}", func(rw http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+	get := func() *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/files/1", nil)
+		req.Header.Set("Authorization", "Bearer guessed")
+		router.ServeHTTP(rw, req)
+		return rw
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, get().Code)
+	}
+	rw := get()
+
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "60", rw.Header().Get("Retry-After"))
+}
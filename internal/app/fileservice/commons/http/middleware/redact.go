@@ -0,0 +1,275 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Redacted replaces the values of the redacted fields
+const Redacted = "[REDACTED]"
+
+// truncatedMark ends the bodies which are cut short
+const truncatedMark = "..."
+
+// Redactor replaces the values at the paths of a JSONPath subset in the JSON
+// documents, the paths are made of the $ root and of .name, ['name'], .*, [n],
+// [*] and ..name for the name at any depth
+type Redactor struct {
+	paths [][]pathSegment
+}
+
+type pathSegment struct {
+	name  string
+	index int
+	any   bool
+	deep  bool
+}
+
+// pathElem is a step from the root to a value, a key of an object or an index of an array
+type pathElem struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// NewRedactor parses the JSONPaths of the redacted values
+func NewRedactor(paths []string) (*Redactor, error) {
+	rd := &Redactor{}
+	for _, p := range paths {
+		segments, err := parsePath(p)
+		if err != nil {
+			return nil, err
+		}
+		rd.paths = append(rd.paths, segments)
+	}
+	return rd, nil
+}
+
+func parsePath(p string) ([]pathSegment, error) {
+	if !strings.HasPrefix(p, "$") {
+		return nil, fmt.Errorf("Error parsing JSONPath %q, it must start with $", p)
+	}
+	segments := []pathSegment{}
+	rest := p[1:]
+	for rest != "" {
+		s := pathSegment{index: -1}
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			s.deep = true
+			rest = rest[2:]
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("Error parsing JSONPath %q, unclosed [", p)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				s.any = true
+			case len(inner) >= 2 && inner[0] == '\'' && inner[len(inner)-1] == '\'':
+				s.name = inner[1 : len(inner)-1]
+			default:
+				i, err := strconv.Atoi(inner)
+				if err != nil || i < 0 {
+					return nil, fmt.Errorf("Error parsing JSONPath %q, invalid index %q", p, inner)
+				}
+				s.index = i
+			}
+			segments = append(segments, s)
+			continue
+		default:
+			return nil, fmt.Errorf("Error parsing JSONPath %q at %q", p, rest)
+		}
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		name := rest[:end]
+		rest = rest[end:]
+		if name == "" {
+			return nil, fmt.Errorf("Error parsing JSONPath %q, empty name", p)
+		}
+		if name == "*" {
+			s.any = true
+		} else {
+			s.name = name
+		}
+		segments = append(segments, s)
+	}
+	return segments, nil
+}
+
+func (s pathSegment) matches(e pathElem) bool {
+	switch {
+	case s.any:
+		return true
+	case s.index >= 0:
+		return e.isIndex && e.index == s.index
+	default:
+		return !e.isIndex && e.key == s.name
+	}
+}
+
+func matchPath(segments []pathSegment, path []pathElem) bool {
+	if len(segments) == 0 {
+		return len(path) == 0
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if segments[0].matches(path[0]) && matchPath(segments[1:], path[1:]) {
+		return true
+	}
+	return segments[0].deep && matchPath(segments, path[1:])
+}
+
+func (rd *Redactor) redacted(path []pathElem) bool {
+	for _, segments := range rd.paths {
+		if matchPath(segments, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// container is an open object or array of the document
+type container struct {
+	array     bool
+	expectKey bool
+	elem      pathElem
+	n         int
+}
+
+// Redact returns the document data with the redacted values replaced, data may
+// be the beginning of a longer document. The result is cut to max bytes and the
+// bool reports whether it is incomplete.
+func (rd *Redactor) Redact(data []byte, max int) (string, bool) {
+	return rd.redactAt(nil, data, max)
+}
+
+// redactAt redacts the document data found at the base path
+func (rd *Redactor) redactAt(base []pathElem, data []byte, max int) (string, bool) {
+	out := &bytes.Buffer{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	stack := []*container{}
+	path := func() []pathElem {
+		p := append([]pathElem{}, base...)
+		for _, c := range stack {
+			p = append(p, c.elem)
+		}
+		return p
+	}
+	truncated := false
+	for {
+		t, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			truncated = true
+			break
+		}
+		var top *container
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		if d, ok := t.(json.Delim); ok && (d == '}' || d == ']') {
+			out.WriteByte(byte(d))
+			stack = stack[:len(stack)-1]
+			valueDone(stack)
+			continue
+		}
+		if top != nil && top.expectKey {
+			if top.n > 0 {
+				out.WriteByte(',')
+			}
+			key, _ := t.(string)
+			writeJSON(out, key)
+			out.WriteByte(':')
+			top.elem = pathElem{key: key}
+			top.expectKey = false
+			continue
+		}
+		if top != nil && top.array {
+			if top.n > 0 {
+				out.WriteByte(',')
+			}
+			top.elem = pathElem{index: top.n, isIndex: true}
+		}
+		if rd.redacted(path()) {
+			writeJSON(out, Redacted)
+			if d, ok := t.(json.Delim); ok && (d == '{' || d == '[') {
+				if !skipValue(dec) {
+					truncated = true
+					break
+				}
+			}
+			valueDone(stack)
+			continue
+		}
+		switch d := t.(type) {
+		case json.Delim:
+			out.WriteByte(byte(d))
+			stack = append(stack, &container{array: d == '[', expectKey: d == '{'})
+		default:
+			writeJSON(out, d)
+			valueDone(stack)
+		}
+	}
+	if len(stack) > 0 {
+		truncated = true
+	}
+	s := out.String()
+	if len(s) > max {
+		s, truncated = s[:max], true
+	}
+	if truncated {
+		s += truncatedMark
+	}
+	return s, truncated
+}
+
+// valueDone moves the innermost container past its current value
+func valueDone(stack []*container) {
+	if len(stack) == 0 {
+		return
+	}
+	top := stack[len(stack)-1]
+	top.n++
+	if !top.array {
+		top.expectKey = true
+	}
+}
+
+// skipValue reads the rest of the object or array just opened, it reports
+// whether the end was found
+func skipValue(dec *json.Decoder) bool {
+	for depth := 1; depth > 0; {
+		t, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		if d, ok := t.(json.Delim); ok {
+			if d == '{' || d == '[' {
+				depth++
+			} else {
+				depth--
+			}
+		}
+	}
+	return true
+}
+
+func writeJSON(out *bytes.Buffer, v interface{}) {
+	// the tokens of the decoder always marshal
+	b, _ := json.Marshal(v)
+	out.Write(b)
+}
@@ -0,0 +1,42 @@
+package middleware_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+)
+
+func TestRedactor_Redact(t *testing.T) {
+	rd, err := middleware.NewRedactor([]string{"$.metadata.iin", "$..password", "$.items[*].secret", "$.list[1]"})
+	assert.Nil(t, err)
+
+	body, truncated := rd.Redact([]byte(`{"metadata":{"iin":"123","name":"a"},"auth":{"password":{"old":"x"}},"n":1,"ok":true}`), 1000)
+	assert.False(t, truncated)
+	assert.Equal(t, `{"metadata":{"iin":"[REDACTED]","name":"a"},"auth":{"password":"[REDACTED]"},"n":1,"ok":true}`, body)
+
+	body, truncated = rd.Redact([]byte(`{"items":[{"secret":1,"k":2},{"secret":[3]}],"list":[0,1,2]}`), 1000)
+	assert.False(t, truncated)
+	assert.Equal(t, `{"items":[{"secret":"[REDACTED]","k":2},{"secret":"[REDACTED]"}],"list":[0,"[REDACTED]",2]}`, body)
+}
+
+func TestRedactor_Redact_Truncated(t *testing.T) {
+	rd, err := middleware.NewRedactor([]string{"$.metadata.iin"})
+	assert.Nil(t, err)
+
+	// the cut value is left out even though it can not be matched completely
+	body, truncated := rd.Redact([]byte(`{"metadata":{"name":"a","iin":"1234`), 1000)
+	assert.True(t, truncated)
+	assert.Equal(t, `{"metadata":{"name":"a","iin":...`, body)
+
+	body, truncated = rd.Redact([]byte(`{"name":"abcdefghijklmnopqrstuvwxyz"}`), 12)
+	assert.True(t, truncated)
+	assert.Equal(t, `{"name":"abc...`, body)
+}
+
+func TestNewRedactor_InvalidPath(t *testing.T) {
+	for _, p := range []string{"metadata.iin", "$.", "$[x]", "$.a["} {
+		_, err := middleware.NewRedactor([]string{p})
+		assert.NotNil(t, err, p)
+	}
+}
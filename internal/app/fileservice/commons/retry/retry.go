@@ -0,0 +1,53 @@
+// Package retry retries the operations which depend on the availability of other services
+package retry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/unistack-org/micro/v3/logger"
+)
+
+// Backoff allows Attempts calls, the wait between the calls starts at Initial
+// and doubles up to Max
+type Backoff struct {
+	Attempts int
+	Initial  time.Duration
+	Max      time.Duration
+}
+
+// Delay returns the wait after the failed attempt, the attempts start at 1
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := b.Initial
+	for i := 1; i < attempt && d < b.Max; i++ {
+		d *= 2
+	}
+	if d > b.Max {
+		return b.Max
+	}
+	return d
+}
+
+// Do calls fn until it succeeds, the attempts are exhausted or ctx is done,
+// name describes the operation in the logs and the error
+func Do(ctx context.Context, name string, b Backoff, fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(ctx); err == nil {
+			return nil
+		}
+		if attempt >= b.Attempts {
+			return fmt.Errorf("Error %s after %d attempts, %v", name, attempt, err)
+		}
+		delay := b.Delay(attempt)
+		logger.Infof(ctx, "Attempt %d/%d of %s failed, retrying in %s, %v", attempt, b.Attempts, name, delay, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("Error %s, interrupted after %d attempts, %v", name, attempt, err)
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/retry"
+)
+
+func TestBackoff_Delay(t *testing.T) {
+	b := retry.Backoff{Attempts: 5, Initial: 100 * time.Millisecond, Max: time.Second}
+
+	assert.Equal(t, 100*time.Millisecond, b.Delay(1))
+	assert.Equal(t, 400*time.Millisecond, b.Delay(3))
+	assert.Equal(t, time.Second, b.Delay(5))
+}
+
+func TestDo_RetriesUntilSuccess(t *testing.T) {
+	calls := 0
+	err := retry.Do(context.Background(), "connecting to db", retry.Backoff{Attempts: 3, Initial: time.Millisecond, Max: time.Millisecond}, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestDo_AttemptsExhausted(t *testing.T) {
+	calls := 0
+	err := retry.Do(context.Background(), "connecting to db", retry.Backoff{Attempts: 2, Initial: time.Millisecond, Max: time.Millisecond}, func(ctx context.Context) error {
+		calls++
+		return errors.New("connection refused")
+	})
+
+	assert.EqualError(t, err, "Error connecting to db after 2 attempts, connection refused")
+	assert.Equal(t, 2, calls)
+}
+
+func TestDo_ContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := retry.Do(ctx, "connecting to db", retry.Backoff{Attempts: 5, Initial: time.Minute, Max: time.Minute}, func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+
+	assert.EqualError(t, err, "Error connecting to db, interrupted after 1 attempts, connection refused")
+}
@@ -0,0 +1,31 @@
+package stats
+
+import (
+	"context"
+	"time"
+
+	"github.com/heptiolabs/healthcheck"
+)
+
+// maxGoroutines is the number of goroutines above which the service is considered stuck
+const maxGoroutines = 10000
+
+// WithChecks serves the liveness of the process and the readiness of the
+// dependencies, see NewHealth
+func WithChecks(ctx context.Context, interval time.Duration, readiness map[string]healthcheck.Check) Option {
+	return WithHealth(NewHealth(ctx, interval, readiness))
+}
+
+// NewHealth returns a health handler with the readiness checks run every interval
+// in background until ctx is done, the probes only read the cached results and
+// /ready?full=1 returns the result of every check. A check is failing until its first run.
+func NewHealth(ctx context.Context, interval time.Duration, readiness map[string]healthcheck.Check) healthcheck.Handler {
+	health := healthcheck.NewHandler()
+
+	health.AddLivenessCheck("goroutines", healthcheck.GoroutineCountCheck(maxGoroutines))
+	for name, check := range readiness {
+		health.AddReadinessCheck(name, healthcheck.AsyncWithContext(ctx, check, interval))
+	}
+
+	return health
+}
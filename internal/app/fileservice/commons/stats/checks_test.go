@@ -0,0 +1,39 @@
+package stats_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/heptiolabs/healthcheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/stats"
+)
+
+func TestWithChecks_Ready(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := stats.NewServer(stats.WithChecks(ctx, 10*time.Millisecond, map[string]healthcheck.Check{
+		"postgres":     func() error { return nil },
+		"clean_region": func() error { return errors.New("bucket unreachable") },
+	}))
+	time.Sleep(50 * time.Millisecond)
+
+	rw := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/ready?full=1", nil))
+
+	results := map[string]string{}
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+	assert.Nil(t, json.Unmarshal(rw.Body.Bytes(), &results))
+	assert.Equal(t, "OK", results["postgres"])
+	assert.Equal(t, "bucket unreachable", results["clean_region"])
+
+	rw = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/live", nil))
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+}
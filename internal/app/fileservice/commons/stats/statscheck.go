@@ -3,6 +3,7 @@
 package stats
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -21,11 +22,13 @@ type Option func(*Server)
 
 type Server struct {
 	mux *mux.Router
+	srv *http.Server
 }
 
 func NewServer(options ...Option) *Server {
 	res := &Server{
 		mux: mux.NewRouter(),
+		srv: &http.Server{},
 	}
 
 	for _, o := range options {
@@ -47,8 +50,16 @@ func NewDefaultServer(conf Config) *Server {
 	)
 }
 
+// Serve listens on addr until Shutdown, it returns http.ErrServerClosed after Shutdown
 func (s Server) Serve(addr string) error {
-	return http.ListenAndServe(addr, s.mux)
+	s.srv.Addr = addr
+	s.srv.Handler = s.mux
+	return s.srv.ListenAndServe()
+}
+
+// Shutdown stops the server waiting for the active probes until ctx is done
+func (s Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
 }
 
 func (s Server) Mux() *mux.Router {
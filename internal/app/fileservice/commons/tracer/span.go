@@ -0,0 +1,34 @@
+package tracer
+
+import (
+	"context"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+)
+
+// Tags of the spans of the file operations
+const (
+	TagFileID   = "file.id"
+	TagDocClass = "file.doc_class"
+	TagBytes    = "file.bytes"
+	TagBucket   = "s3.bucket"
+	TagKey      = "s3.key"
+	TagTenant   = "tenant.id"
+)
+
+// StartSpan starts a child span of the span in ctx with the global tracer,
+// the global tracer is a noop until NewTracer is called
+func StartSpan(ctx context.Context, operation string, tags opentracing.Tags) (opentracing.Span, context.Context) {
+	return opentracing.StartSpanFromContext(ctx, operation, tags)
+}
+
+// FinishSpan marks span as failed when err is not nil and finishes it
+func FinishSpan(span opentracing.Span, err error) {
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+	}
+	span.Finish()
+}
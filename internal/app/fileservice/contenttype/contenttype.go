@@ -0,0 +1,116 @@
+// Package contenttype detects the type of the uploaded files from their first
+// bytes and checks it against the type declared by the client, the extension of
+// the filename and the allowlists of the document classes.
+package contenttype
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+)
+
+// Default is the type of the files which are not recognized
+const Default = "application/octet-stream"
+
+// sniffLen is the number of bytes considered by the detection
+const sniffLen = 512
+
+// Sniff reads the head of r and returns its detected type with a reader of the
+// whole content, the rest of r is not read
+func Sniff(r io.Reader) (string, io.Reader, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", nil, err
+	}
+	head = head[:n]
+	return Detect(head), io.MultiReader(bytes.NewReader(head), r), nil
+}
+
+// Detect returns the media type of data without its parameters, the tar archives
+// are recognized by the magic of their first header
+func Detect(data []byte) string {
+	detected := Base(http.DetectContentType(data))
+	if detected == Default && len(data) >= 262 && string(data[257:262]) == "ustar" {
+		return "application/x-tar"
+	}
+	return detected
+}
+
+// Base returns the media type without its parameters in lower case, the invalid
+// types are returned as they are
+func Base(contentType string) string {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return t
+}
+
+// Matches reports if the content detected as detected may be of the claimed type.
+// The detection does not look into the containers, a zip matches the office
+// documents and a text matches the text based formats. The unrecognized binary
+// content and the undeclared types match anything.
+func Matches(detected, claimed string) bool {
+	claimed = Base(claimed)
+	switch {
+	case claimed == "" || claimed == Default || detected == Default || claimed == detected:
+		return true
+	case detected == "application/zip":
+		return strings.HasPrefix(claimed, "application/vnd.openxmlformats-officedocument.") ||
+			strings.HasPrefix(claimed, "application/vnd.oasis.opendocument.") ||
+			claimed == "application/epub+zip" || claimed == "application/java-archive" || claimed == "application/x-zip-compressed"
+	case detected == "application/x-gzip":
+		return claimed == "application/gzip" || claimed == "application/x-gtar" || claimed == "application/x-compressed-tar"
+	case detected == "text/xml":
+		return claimed == "application/xml" || strings.HasSuffix(claimed, "+xml") || strings.HasPrefix(claimed, "text/")
+	case strings.HasPrefix(detected, "text/"):
+		return strings.HasPrefix(claimed, "text/") || claimed == "application/json" || claimed == "application/xml" ||
+			claimed == "application/javascript" || strings.HasSuffix(claimed, "+json") || strings.HasSuffix(claimed, "+xml")
+	}
+	return false
+}
+
+// Check returns an error if the detected type of the content of filename does
+// not match the declared type or the type of the extension of filename
+func Check(detected, declared, filename string) error {
+	if !Matches(detected, declared) {
+		return apperrors.UnsupportedMedia(apperrors.CodeContentMismatch, "Content of %s is %s, it is declared as %s", filename, detected, Base(declared))
+	}
+	ext := strings.ToLower(filepath.Ext(filename))
+	if byExt := mime.TypeByExtension(ext); byExt != "" && !Matches(detected, byExt) {
+		return apperrors.UnsupportedMedia(apperrors.CodeContentMismatch, "Content of %s is %s, it does not match the extension %s", filename, detected, ext)
+	}
+	return nil
+}
+
+// Allowlist holds the types allowed for the document classes, a type may end
+// with /* to allow all its subtypes. The classes without an entry allow any type.
+type Allowlist map[string][]string
+
+// Allows ...
+func (a Allowlist) Allows(docClass, contentType string) bool {
+	allowed, ok := a[docClass]
+	if !ok {
+		return true
+	}
+	for _, t := range allowed {
+		if t == contentType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(t, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns an error if the document class does not allow contentType
+func (a Allowlist) Check(docClass, contentType string) error {
+	if !a.Allows(docClass, contentType) {
+		return apperrors.UnsupportedMedia(apperrors.CodeContentNotAllowed, "Content type %s is not allowed for class %s, expected one of %s", contentType, docClass, strings.Join(a[docClass], ", "))
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package contenttype_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/contenttype"
+)
+
+var pdf = []byte("%PDF-1.7\n1 0 obj\n<< /Type /Catalog >>\nendobj\n")
+
+func TestSniff_ReplaysContent(t *testing.T) {
+	content := append(append([]byte{}, pdf...), bytes.Repeat([]byte("x"), 2000)...)
+
+	detected, r, err := contenttype.Sniff(bytes.NewReader(content))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "application/pdf", detected)
+	replayed, _ := ioutil.ReadAll(r)
+	assert.Equal(t, content, replayed)
+}
+
+func TestDetect_Tar(t *testing.T) {
+	header := make([]byte, 512)
+	copy(header, "scan.pdf")
+	copy(header[257:], "ustar\x0000")
+
+	assert.Equal(t, "application/x-tar", contenttype.Detect(header))
+}
+
+func TestCheck(t *testing.T) {
+	assert.Nil(t, contenttype.Check("application/pdf", "application/pdf", "invoice.pdf"))
+	assert.Nil(t, contenttype.Check("application/pdf", "application/octet-stream", "scan"))
+	assert.Nil(t, contenttype.Check("text/plain", "text/csv; charset=utf-8", "report.csv"))
+	assert.Nil(t, contenttype.Check("application/zip", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", "contract.docx"))
+	assert.Nil(t, contenttype.Check(contenttype.Default, "application/msword", "contract.doc"))
+	assert.Nil(t, contenttype.Check("application/x-gzip", "application/gzip", "scans.tar.gz"))
+
+	err := contenttype.Check("text/html", "application/pdf", "invoice.pdf")
+	assert.True(t, apperrors.Is(err, apperrors.KindUnsupportedMedia))
+	assert.Equal(t, apperrors.CodeContentMismatch, apperrors.CodeOf(err))
+	err = contenttype.Check("image/png", "", "invoice.pdf")
+	assert.Equal(t, apperrors.CodeContentMismatch, apperrors.CodeOf(err))
+}
+
+func TestAllowlist(t *testing.T) {
+	allowlist := contenttype.Allowlist{"invoice": {"application/pdf"}, "photo": {"image/*"}}
+
+	assert.True(t, allowlist.Allows("invoice", "application/pdf"))
+	assert.False(t, allowlist.Allows("invoice", "image/png"))
+	assert.True(t, allowlist.Allows("photo", "image/jpeg"))
+	assert.False(t, allowlist.Allows("photo", "application/pdf"))
+	assert.True(t, allowlist.Allows("contract", "application/zip"))
+	assert.Equal(t, apperrors.CodeContentNotAllowed, apperrors.CodeOf(allowlist.Check("invoice", "image/png")))
+}
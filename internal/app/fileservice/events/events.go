@@ -0,0 +1,154 @@
+// Package events builds the domain events of the files and relays them from the
+// outbox to the broker. The payloads are the versioned messages of file-service.proto,
+// a breaking change gets a new message published on its own topic next to the old one.
+package events
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	pb "github.com/vielendanke/file-service/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Topics of the events, the suffix is the version of the message
+const (
+	TopicFileCreated         = "file.created.v1"
+	TopicFileClean           = "file.clean.v1"
+	TopicFileMetadataChanged = "file.metadata_changed.v1"
+	TopicFileDeleted         = "file.deleted.v1"
+	TopicFileExpiring        = "file.expiring.v1"
+	TopicFileExpired         = "file.expired.v1"
+)
+
+// Topics lists the topics of all the events
+var Topics = []string{TopicFileCreated, TopicFileClean, TopicFileMetadataChanged, TopicFileDeleted, TopicFileExpiring, TopicFileExpired}
+
+// NewMessage returns the empty message published on topic, nil for an unknown topic
+func NewMessage(topic string) proto.Message {
+	switch topic {
+	case TopicFileCreated:
+		return &pb.FileCreatedV1{}
+	case TopicFileClean:
+		return &pb.FileCleanV1{}
+	case TopicFileMetadataChanged:
+		return &pb.FileMetadataChangedV1{}
+	case TopicFileDeleted:
+		return &pb.FileDeletedV1{}
+	case TopicFileExpiring:
+		return &pb.FileExpiringV1{}
+	case TopicFileExpired:
+		return &pb.FileExpiredV1{}
+	}
+	return nil
+}
+
+// FileCreated is the event of the saved metadata of a new file
+func FileCreated(tenantID string, f *model.AWSModel, metadata string) (*model.Event, error) {
+	md, err := toStruct(metadata)
+	if err != nil {
+		return nil, err
+	}
+	h := newHeader(tenantID, f.GetFileID(), f.GetDocClass())
+	return newEvent(TopicFileCreated, h, &pb.FileCreatedV1{
+		Header:   h,
+		Filename: f.GetFileName(),
+		DocClass: f.GetDocClass(),
+		DocType:  f.GetDocType(),
+		DocNum:   f.GetDocNum(),
+		Metadata: md,
+	})
+}
+
+// FileClean is the event of a file moved to the clean store
+func FileClean(tenantID, fileID, docClass string, size int64) (*model.Event, error) {
+	h := newHeader(tenantID, fileID, docClass)
+	return newEvent(TopicFileClean, h, &pb.FileCleanV1{
+		Header: h,
+		Size:   size,
+	})
+}
+
+// FileMetadataChanged is the event of the updated metadata of a file
+func FileMetadataChanged(tenantID, fileID, docClass, metadata string) (*model.Event, error) {
+	md, err := toStruct(metadata)
+	if err != nil {
+		return nil, err
+	}
+	h := newHeader(tenantID, fileID, docClass)
+	return newEvent(TopicFileMetadataChanged, h, &pb.FileMetadataChangedV1{
+		Header:   h,
+		Metadata: md,
+	})
+}
+
+// FileDeleted is the event of a deleted file
+func FileDeleted(tenantID, fileID, docClass string) (*model.Event, error) {
+	h := newHeader(tenantID, fileID, docClass)
+	return newEvent(TopicFileDeleted, h, &pb.FileDeletedV1{
+		Header: h,
+	})
+}
+
+// FileExpiring is the event of a file whose validity end date in field comes within the notice period
+func FileExpiring(tenantID, fileID, docClass, field string, expiresAt time.Time) (*model.Event, error) {
+	h := newHeader(tenantID, fileID, docClass)
+	return newEvent(TopicFileExpiring, h, &pb.FileExpiringV1{
+		Header:    h,
+		ExpiresAt: timestamppb.New(expiresAt),
+		Field:     field,
+	})
+}
+
+// FileExpired is the event of a file whose validity end date in field has passed
+func FileExpired(tenantID, fileID, docClass, field string, expiresAt time.Time) (*model.Event, error) {
+	h := newHeader(tenantID, fileID, docClass)
+	return newEvent(TopicFileExpired, h, &pb.FileExpiredV1{
+		Header:    h,
+		ExpiresAt: timestamppb.New(expiresAt),
+		Field:     field,
+	})
+}
+
+func newHeader(tenantID, fileID, docClass string) *pb.EventHeader {
+	return &pb.EventHeader{
+		EventId:    strings.ReplaceAll(uuid.New().String(), "-", ""),
+		TenantId:   tenantID,
+		FileId:     fileID,
+		OccurredAt: timestamppb.Now(),
+		DocClass:   docClass,
+	}
+}
+
+func newEvent(topic string, h *pb.EventHeader, m proto.Message) (*model.Event, error) {
+	payload, err := proto.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshalling event %s, %v", topic, err)
+	}
+	return &model.Event{
+		ID:       h.GetEventId(),
+		Topic:    topic,
+		TenantID: h.GetTenantId(),
+		FileID:   h.GetFileId(),
+		DocClass: h.GetDocClass(),
+		Payload:  payload,
+	}, nil
+}
+
+// toStruct converts the json metadata of a file
+func toStruct(metadata string) (*structpb.Struct, error) {
+	md := &structpb.Struct{}
+	if metadata == "" {
+		return md, nil
+	}
+	if err := protojson.Unmarshal([]byte(metadata), md); err != nil {
+		return nil, fmt.Errorf("Error converting metadata of event, %v", err)
+	}
+	return md, nil
+}
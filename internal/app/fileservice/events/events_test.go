@@ -0,0 +1,67 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/events"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	pb "github.com/vielendanke/file-service/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFileCreated(t *testing.T) {
+	f := &model.AWSModel{FileID: "f1", FileName: "a.pdf", DocClass: "invoice", DocType: "pdf", DocNum: "7"}
+
+	e, err := events.FileCreated("sales", f, `{"owner":"bob","pages":3}`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, events.TopicFileCreated, e.Topic)
+	assert.Equal(t, "sales", e.TenantID)
+	assert.Equal(t, "f1", e.FileID)
+	msg := &pb.FileCreatedV1{}
+	assert.Nil(t, proto.Unmarshal(e.Payload, msg))
+	assert.Equal(t, e.ID, msg.GetHeader().GetEventId())
+	assert.Equal(t, "sales", msg.GetHeader().GetTenantId())
+	assert.Equal(t, "f1", msg.GetHeader().GetFileId())
+	assert.NotNil(t, msg.GetHeader().GetOccurredAt())
+	assert.Equal(t, "a.pdf", msg.GetFilename())
+	assert.Equal(t, "invoice", msg.GetDocClass())
+	assert.Equal(t, map[string]interface{}{"owner": "bob", "pages": float64(3)}, msg.GetMetadata().AsMap())
+}
+
+func TestFileMetadataChanged_InvalidMetadata(t *testing.T) {
+	_, err := events.FileMetadataChanged("sales", "f1", "invoice", "not json")
+
+	assert.NotNil(t, err)
+}
+
+func TestFileDeleted_UniqueIDs(t *testing.T) {
+	first, err := events.FileDeleted("sales", "f1", "invoice")
+	assert.Nil(t, err)
+	second, err := events.FileDeleted("sales", "f1", "invoice")
+	assert.Nil(t, err)
+
+	assert.NotEqual(t, first.ID, second.ID)
+}
+
+func TestFileExpired(t *testing.T) {
+	expiresAt := time.Date(2030, 5, 2, 0, 0, 0, 0, time.UTC)
+
+	e, err := events.FileExpired("sales", "f1", "passport", "valid_until", expiresAt)
+
+	assert.Nil(t, err)
+	assert.Equal(t, events.TopicFileExpired, e.Topic)
+	msg := &pb.FileExpiredV1{}
+	assert.Nil(t, proto.Unmarshal(e.Payload, msg))
+	assert.Equal(t, "valid_until", msg.GetField())
+	assert.True(t, expiresAt.Equal(msg.GetExpiresAt().AsTime()))
+}
+
+func TestNewMessage(t *testing.T) {
+	for _, topic := range events.Topics {
+		assert.NotNil(t, events.NewMessage(topic), topic)
+	}
+	assert.Nil(t, events.NewMessage("file.unknown.v1"))
+}
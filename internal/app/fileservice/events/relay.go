@@ -0,0 +1,148 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/unistack-org/micro/v3/broker"
+	"github.com/unistack-org/micro/v3/logger"
+	"github.com/unistack-org/micro/v3/metadata"
+	metrics "github.com/vielendanke/file-service/internal/app/fileservice/commons/metrics"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/retry"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+)
+
+// Headers of the published messages, the consumers deduplicate by HeaderEventID
+const (
+	HeaderEventID     = "Event-Id"
+	HeaderTenantID    = "Tenant-Id"
+	HeaderFileID      = "File-Id"
+	HeaderDocClass    = "Doc-Class"
+	HeaderContentType = "Content-Type"
+)
+
+var (
+	published = metrics.GetOrMakeCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NS,
+			Name:      "events_published_total",
+			Help:      "Total number of the published domain events.",
+		},
+		[]string{"topic"},
+	)
+	publishErrors = metrics.GetOrMakeCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NS,
+			Name:      "events_publish_errors_total",
+			Help:      "Total number of the failed attempts to publish a domain event.",
+		},
+		[]string{"topic"},
+	)
+)
+
+// Outbox keeps the events until they are published
+type Outbox interface {
+	// Claim leases up to limit due events, the events not deleted or retried
+	// within lease are claimed again
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]*model.Event, error)
+	Delete(ctx context.Context, id string) error
+	Retry(ctx context.Context, id string, delay time.Duration, cause string) error
+}
+
+// Publisher is the part of broker.Broker used by the relay
+type Publisher interface {
+	Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error
+}
+
+// Publishers publishes the messages to each of its publishers in order, a
+// message is retried on all of them if one fails so the consumers deduplicate
+type Publishers []Publisher
+
+// Publish ...
+func (ps Publishers) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	for _, p := range ps {
+		if err := p.Publish(ctx, topic, msg, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Relay publishes the events of the outbox, an event is deleted once the broker
+// accepted it so it is delivered at least once
+type Relay struct {
+	outbox    Outbox
+	publisher Publisher
+	batchSize int
+	lease     time.Duration
+	backoff   retry.Backoff
+}
+
+// NewRelay returns a relay publishing up to batchSize events at once, a failed
+// event is retried after the delay of backoff for its number of attempts
+func NewRelay(outbox Outbox, publisher Publisher, batchSize int, lease time.Duration, backoff retry.Backoff) *Relay {
+	return &Relay{
+		outbox:    outbox,
+		publisher: publisher,
+		batchSize: batchSize,
+		lease:     lease,
+		backoff:   backoff,
+	}
+}
+
+// Run publishes the due events every interval until ctx is done, the full
+// batches are followed by the next one without waiting
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := r.PublishDue(ctx)
+		if err != nil {
+			logger.Errorf(ctx, "Error relaying events, %v", err)
+		}
+		if n == r.batchSize && ctx.Err() == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PublishDue publishes one batch of the due events and returns the number of claimed events
+func (r *Relay) PublishDue(ctx context.Context) (int, error) {
+	events, err := r.outbox.Claim(ctx, r.batchSize, r.lease)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range events {
+		if err := r.publish(ctx, e); err != nil {
+			return len(events), err
+		}
+	}
+	return len(events), nil
+}
+
+func (r *Relay) publish(ctx context.Context, e *model.Event) error {
+	err := r.publisher.Publish(ctx, e.Topic, &broker.Message{
+		Header: metadata.Metadata{
+			HeaderEventID:     e.ID,
+			HeaderTenantID:    e.TenantID,
+			HeaderFileID:      e.FileID,
+			HeaderDocClass:    e.DocClass,
+			HeaderContentType: "application/protobuf",
+		},
+		Body: e.Payload,
+	})
+	if err != nil {
+		publishErrors.WithLabelValues(e.Topic).Inc()
+		delay := r.backoff.Delay(e.Attempts + 1)
+		logger.Errorf(ctx, "Error publishing event %s on %s, attempt %d, retrying in %s, %v", e.ID, e.Topic, e.Attempts+1, delay, err)
+		return r.outbox.Retry(ctx, e.ID, delay, err.Error())
+	}
+	published.WithLabelValues(e.Topic).Inc()
+	return r.outbox.Delete(ctx, e.ID)
+}
@@ -0,0 +1,102 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unistack-org/micro/v3/broker"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/retry"
+	"github.com/vielendanke/file-service/internal/app/fileservice/events"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+)
+
+type publisher struct {
+	failures map[string]error
+	topics   []string
+	messages []*broker.Message
+}
+
+func (p *publisher) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	if err := p.failures[msg.Header[events.HeaderEventID]]; err != nil {
+		return err
+	}
+	p.topics = append(p.topics, topic)
+	p.messages = append(p.messages, msg)
+	return nil
+}
+
+func prepareRelay(outbox *mocks.Outbox, p *publisher) *events.Relay {
+	return events.NewRelay(outbox, p, 10, 30*time.Second, retry.Backoff{Initial: time.Second, Max: time.Minute})
+}
+
+func TestRelay_PublishDue(t *testing.T) {
+	ctx := context.Background()
+	outbox := &mocks.Outbox{}
+	p := &publisher{}
+	outbox.On("Claim", ctx, 10, 30*time.Second).Return([]*model.Event{
+		{ID: "e1", Topic: events.TopicFileCreated, TenantID: "sales", FileID: "f1", Payload: []byte("created")},
+		{ID: "e2", Topic: events.TopicFileDeleted, TenantID: "sales", FileID: "f1", Payload: []byte("deleted")},
+	}, nil)
+	outbox.On("Delete", ctx, "e1").Return(nil)
+	outbox.On("Delete", ctx, "e2").Return(nil)
+
+	n, err := prepareRelay(outbox, p).PublishDue(ctx)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{events.TopicFileCreated, events.TopicFileDeleted}, p.topics)
+	assert.Equal(t, "e1", p.messages[0].Header[events.HeaderEventID])
+	assert.Equal(t, "sales", p.messages[0].Header[events.HeaderTenantID])
+	assert.Equal(t, "f1", p.messages[0].Header[events.HeaderFileID])
+	assert.Equal(t, []byte("created"), p.messages[0].Body)
+	outbox.AssertExpectations(t)
+}
+
+func TestRelay_PublishDue_RetriesWithBackoff(t *testing.T) {
+	ctx := context.Background()
+	outbox := &mocks.Outbox{}
+	p := &publisher{failures: map[string]error{"e1": errors.New("broker unavailable")}}
+	outbox.On("Claim", ctx, 10, 30*time.Second).Return([]*model.Event{
+		{ID: "e1", Topic: events.TopicFileClean, Attempts: 2},
+		{ID: "e2", Topic: events.TopicFileClean},
+	}, nil)
+	outbox.On("Retry", ctx, "e1", 4*time.Second, "broker unavailable").Return(nil)
+	outbox.On("Delete", ctx, "e2").Return(nil)
+
+	n, err := prepareRelay(outbox, p).PublishDue(ctx)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{events.TopicFileClean}, p.topics)
+	outbox.AssertExpectations(t)
+}
+
+func TestRelay_PublishDue_KeepsEventOnDeleteError(t *testing.T) {
+	ctx := context.Background()
+	outbox := &mocks.Outbox{}
+	outbox.On("Claim", ctx, 10, 30*time.Second).Return([]*model.Event{
+		{ID: "e1", Topic: events.TopicFileClean},
+		{ID: "e2", Topic: events.TopicFileClean},
+	}, nil)
+	outbox.On("Delete", ctx, "e1").Return(errors.New("connection reset"))
+
+	_, err := prepareRelay(outbox, &publisher{}).PublishDue(ctx)
+
+	assert.NotNil(t, err)
+	outbox.AssertNotCalled(t, "Delete", ctx, "e2")
+}
+
+func TestPublishers_StopsOnError(t *testing.T) {
+	failing := &publisher{failures: map[string]error{"e1": errors.New("broker unavailable")}}
+	next := &publisher{}
+	msg := &broker.Message{Header: map[string]string{events.HeaderEventID: "e1"}}
+
+	err := events.Publishers{failing, next}.Publish(context.Background(), events.TopicFileClean, msg)
+
+	assert.NotNil(t, err)
+	assert.Empty(t, next.topics)
+}
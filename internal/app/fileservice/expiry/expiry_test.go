@@ -0,0 +1,65 @@
+package expiry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/expiry"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+func TestParse(t *testing.T) {
+	d, err := expiry.Parse("2030-05-01")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2030, 5, 2, 0, 0, 0, 0, time.UTC), d)
+
+	ts, err := expiry.Parse("2030-05-01T12:00:00+02:00")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2030, 5, 1, 10, 0, 0, 0, time.UTC), ts)
+
+	_, err = expiry.Parse("01.05.2030")
+	assert.NotNil(t, err)
+}
+
+func TestFields_Read(t *testing.T) {
+	fields := expiry.Fields{"passport": "valid_until"}
+
+	field, expiresAt, err := fields.Read("invoice", map[string]interface{}{"valid_until": "2030-05-01"})
+	assert.Nil(t, err)
+	assert.Empty(t, field)
+	assert.Nil(t, expiresAt)
+
+	field, expiresAt, err = fields.Read("passport", map[string]interface{}{"valid_until": nil})
+	assert.Nil(t, err)
+	assert.Equal(t, "valid_until", field)
+	assert.Nil(t, expiresAt)
+
+	_, _, err = fields.Read("passport", map[string]interface{}{"valid_until": 2030})
+	assert.True(t, apperrors.Is(err, apperrors.KindInvalid))
+}
+
+func TestNotifier_NotifyDue(t *testing.T) {
+	repo := new(mocks.ExpiryRepository)
+	tenants := tenant.NewRegistry("default", []*tenant.Tenant{{ID: "default"}, {ID: "hr"}})
+	now := time.Now()
+	isTenant := func(id string) interface{} {
+		return mock.MatchedBy(func(ctx context.Context) bool {
+			return tenant.FromContext(ctx).ID == id
+		})
+	}
+	repo.On("NotifyExpiry", isTenant("default"), now, 24*time.Hour, 2).Return(2, nil).Once()
+	repo.On("NotifyExpiry", isTenant("default"), now, 24*time.Hour, 2).Return(1, nil).Once()
+	repo.On("NotifyExpiry", isTenant("hr"), now, 24*time.Hour, 2).Return(0, errors.New("connection refused")).Once()
+
+	n, err := expiry.NewNotifier(repo, tenants, 24*time.Hour, 2).NotifyDue(context.Background(), now)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, n)
+	repo.AssertExpectations(t)
+}
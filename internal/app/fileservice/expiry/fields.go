@@ -0,0 +1,52 @@
+// Package expiry reads the validity end dates of the time-bound documents from
+// their metadata and notifies the files whose date comes close or has passed.
+package expiry
+
+import (
+	"time"
+
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+)
+
+// dateLayout is the layout of the end dates without a time
+const dateLayout = "2006-01-02"
+
+// Fields holds the metadata field of the end date of the document classes, the
+// classes without an entry have no end date
+type Fields map[string]string
+
+// Read returns the field of the class and the end date it holds in metadata, the
+// date is nil when the field is missing or null. The field is empty for the classes
+// without an end date.
+func (fs Fields) Read(docClass string, metadata map[string]interface{}) (string, *time.Time, error) {
+	field, ok := fs[docClass]
+	if !ok {
+		return "", nil, nil
+	}
+	value, ok := metadata[field]
+	if !ok || value == nil {
+		return field, nil, nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", nil, apperrors.Invalid(apperrors.CodeInvalidMetadata, "Field %s of class %s must be a date string", field, docClass)
+	}
+	expiresAt, err := Parse(s)
+	if err != nil {
+		return "", nil, apperrors.Invalid(apperrors.CodeInvalidMetadata, "Field %s of class %s is not a date, %s", field, docClass, s)
+	}
+	return field, &expiresAt, nil
+}
+
+// Parse reads an RFC 3339 timestamp or a date, a document valid until a date
+// expires at the end of that day in UTC
+func Parse(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.AddDate(0, 0, 1), nil
+}
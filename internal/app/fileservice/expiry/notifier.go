@@ -0,0 +1,80 @@
+package expiry
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/unistack-org/micro/v3/logger"
+	metrics "github.com/vielendanke/file-service/internal/app/fileservice/commons/metrics"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+var notices = metrics.GetOrMakeCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.NS,
+		Name:      "expiry_notices_total",
+		Help:      "Total number of the expiring and expired events written by tenant.",
+	},
+	[]string{"tenant"},
+)
+
+// Notifier writes the expiring and expired events of the files of every tenant
+// to the outbox, the relay publishes them to the broker and the webhooks
+type Notifier struct {
+	repo      repository.ExpiryRepository
+	tenants   *tenant.Registry
+	notice    time.Duration
+	batchSize int
+}
+
+// NewNotifier returns a notifier of the files whose end date is within notice,
+// the events are written in transactions of up to batchSize files
+func NewNotifier(repo repository.ExpiryRepository, tenants *tenant.Registry, notice time.Duration, batchSize int) *Notifier {
+	return &Notifier{
+		repo:      repo,
+		tenants:   tenants,
+		notice:    notice,
+		batchSize: batchSize,
+	}
+}
+
+// Run notifies the due files every interval until ctx is done
+func (n *Notifier) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// the failures are logged by tenant
+		n.NotifyDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// NotifyDue notifies all of the due files of the tenants as of now and returns
+// their number, a failing tenant does not stop the others
+func (n *Notifier) NotifyDue(ctx context.Context, now time.Time) (int, error) {
+	total := 0
+	var failure error
+	for _, t := range n.tenants.Tenants() {
+		tenantCtx := tenant.NewContext(ctx, t)
+		for ctx.Err() == nil {
+			count, err := n.repo.NotifyExpiry(tenantCtx, now, n.notice, n.batchSize)
+			if err != nil {
+				logger.Errorf(ctx, "Error notifying expiring files of tenant %s, %v", t.ID, err)
+				failure = err
+				break
+			}
+			total += count
+			notices.WithLabelValues(t.ID).Add(float64(count))
+			if count < n.batchSize {
+				break
+			}
+		}
+	}
+	return total, failure
+}
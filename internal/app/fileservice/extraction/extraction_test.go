@@ -0,0 +1,192 @@
+package extraction_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/unistack-org/micro/v3/broker"
+	"github.com/vielendanke/file-service/internal/app/fileservice/events"
+	"github.com/vielendanke/file-service/internal/app/fileservice/extraction"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+func zipOf(t *testing.T, entries map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, content := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Error creating zip entry, %v", err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Error closing zip, %v", err)
+	}
+	return buf.Bytes()
+}
+
+func pdfOf(t *testing.T, content string) []byte {
+	compressed := &bytes.Buffer{}
+	zw := zlib.NewWriter(compressed)
+	zw.Write([]byte(content))
+	zw.Close()
+	return []byte(fmt.Sprintf(
+		"%%PDF-1.4\n1 0 obj\n<< /Type /Font /Subtype /Type1 >>\nendobj\n4 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream\nendobj\ntrailer\n<< /Root 1 0 R >>\n%%%%EOF\n",
+		compressed.Len(), compressed.String(),
+	))
+}
+
+const docx = `<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:r><w:t>Supply</w:t></w:r><w:r><w:t xml:space="preserve"> agreement</w:t></w:r></w:p>
+<w:p><w:r><w:t>Term</w:t><w:tab/><w:t>12 months</w:t></w:r></w:p>
+</w:body></w:document>`
+
+func TestDOCXExtractor(t *testing.T) {
+	data := zipOf(t, map[string]string{"word/document.xml": docx, "[Content_Types].xml": "<Types/>"})
+
+	text, err := extraction.DOCXExtractor{}.Extract(context.Background(), data)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Supply agreement\nTerm\t12 months\n", text)
+	assert.Equal(t, extraction.TypeDOCX, extraction.Detect(data))
+}
+
+func TestXLSXExtractor(t *testing.T) {
+	data := zipOf(t, map[string]string{
+		"xl/workbook.xml":      "<workbook/>",
+		"xl/sharedStrings.xml": `<sst><si><t>Invoice</t></si><si><r><t>Total </t></r><r><t>due</t></r></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>
+<row><c r="A1" t="s"><v>0</v></c><c r="B1" t="inlineStr"><is><t>INV-7</t></is></c></row>
+<row><c r="A2" t="s"><v>1</v></c><c r="B2"><v>1250.5</v></c></row>
+</sheetData></worksheet>`,
+	})
+
+	text, err := extraction.XLSXExtractor{}.Extract(context.Background(), data)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Invoice\tINV-7\nTotal due\t1250.5\n", text)
+	assert.Equal(t, extraction.TypeXLSX, extraction.Detect(data))
+}
+
+func TestPDFExtractor(t *testing.T) {
+	data := pdfOf(t, "BT /F1 12 Tf 72 720 Td (Invoice \\(copy\\)) Tj 0 -14 Td [(Tot) 20 (al) -300 (due)] TJ T* <FEFF00E9> Tj ET")
+
+	text, err := extraction.PDFExtractor{}.Extract(context.Background(), data)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Invoice (copy)\nTotal due\né\n", text)
+}
+
+func TestPDFExtractor_NotPDF(t *testing.T) {
+	_, err := extraction.PDFExtractor{}.Extract(context.Background(), []byte("plain text"))
+
+	assert.NotNil(t, err)
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "a\tb\n\n c", extraction.Normalize("a\tb\x00\n\n\n\n c\xff ", 0))
+	assert.Equal(t, "zü", extraction.Normalize("züge", 3))
+}
+
+func prepareIndexer(o *mocks.ObjectOpener, repo *mocks.SearchRepository) *extraction.Indexer {
+	tenants := tenant.NewRegistry("sales", []*tenant.Tenant{{ID: "sales", Bucket: "docs", Prefix: "sales/"}})
+	extractors := map[string]extraction.Extractor{
+		"text/plain":        extraction.PlainTextExtractor{},
+		extraction.TypeDOCX: extraction.DOCXExtractor{},
+	}
+	return extraction.NewIndexer(o, tenants, repo, extractors, 1<<20, 1024, time.Second)
+}
+
+func objectOf(data string) io.ReadCloser {
+	return ioutil.NopCloser(strings.NewReader(data))
+}
+
+func TestIndexer_Publish_FileClean(t *testing.T) {
+	ctx := context.Background()
+	o := new(mocks.ObjectOpener)
+	repo := new(mocks.SearchRepository)
+	o.On("Open", ctx, "docs", "sales/f1").Return(objectOf("delivery note\r\n"), nil)
+	repo.On("SaveFileText", mock.MatchedBy(func(ctx context.Context) bool {
+		return tenant.FromContext(ctx).ID == "sales"
+	}), "f1", "delivery note").Return(nil)
+
+	err := prepareIndexer(o, repo).Publish(ctx, events.TopicFileClean, &broker.Message{
+		Header: map[string]string{events.HeaderTenantID: "sales", events.HeaderFileID: "f1"},
+	})
+
+	assert.Nil(t, err)
+	o.AssertExpectations(t)
+	repo.AssertExpectations(t)
+}
+
+func TestIndexer_Publish_SkipsUnsupported(t *testing.T) {
+	ctx := context.Background()
+	o := new(mocks.ObjectOpener)
+	repo := new(mocks.SearchRepository)
+	body := &countingReader{r: strings.NewReader("\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 4<<20))}
+	o.On("Open", ctx, "docs", "sales/f1").Return(ioutil.NopCloser(body), nil)
+
+	err := prepareIndexer(o, repo).Publish(ctx, events.TopicFileClean, &broker.Message{
+		Header: map[string]string{events.HeaderTenantID: "sales", events.HeaderFileID: "f1"},
+	})
+
+	assert.Nil(t, err)
+	// only the head of the file is read to sniff its type
+	assert.LessOrEqual(t, body.n, 512)
+	repo.AssertNotCalled(t, "SaveFileText", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestIndexer_Publish_DOCX(t *testing.T) {
+	ctx := context.Background()
+	o := new(mocks.ObjectOpener)
+	repo := new(mocks.SearchRepository)
+	data := zipOf(t, map[string]string{"word/document.xml": docx, "[Content_Types].xml": "<Types/>"})
+	o.On("Open", ctx, "docs", "sales/f1").Return(objectOf(string(data)), nil)
+	repo.On("SaveFileText", mock.Anything, "f1", "Supply agreement\nTerm\t12 months").Return(nil)
+
+	err := prepareIndexer(o, repo).Publish(ctx, events.TopicFileClean, &broker.Message{
+		Header: map[string]string{events.HeaderTenantID: "sales", events.HeaderFileID: "f1"},
+	})
+
+	assert.Nil(t, err)
+	repo.AssertExpectations(t)
+}
+
+func TestIndexer_Publish_SkipsTooLarge(t *testing.T) {
+	ctx := context.Background()
+	o := new(mocks.ObjectOpener)
+	repo := new(mocks.SearchRepository)
+	o.On("Open", ctx, "docs", "sales/f1").Return(objectOf(strings.Repeat("delivery note\n", 1<<17)), nil)
+
+	err := prepareIndexer(o, repo).Publish(ctx, events.TopicFileClean, &broker.Message{
+		Header: map[string]string{events.HeaderTenantID: "sales", events.HeaderFileID: "f1"},
+	})
+
+	assert.Nil(t, err)
+	repo.AssertNotCalled(t, "SaveFileText", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// countingReader counts the bytes read from the object
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += n
+	return n, err
+}
@@ -0,0 +1,82 @@
+// Package extraction extracts the text of the clean files for the full-text
+// search, the extractors are pure Go and keyed by the detected content type.
+package extraction
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/vielendanke/file-service/internal/app/fileservice/contenttype"
+)
+
+// Content types of the Office Open XML documents, they are detected as zip archives
+const (
+	TypeDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	TypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// Extractor returns the text of a document
+type Extractor interface {
+	Extract(ctx context.Context, data []byte) (string, error)
+}
+
+// PlainTextExtractor returns the text files as they are
+type PlainTextExtractor struct{}
+
+// Extract ...
+func (PlainTextExtractor) Extract(ctx context.Context, data []byte) (string, error) {
+	return string(data), nil
+}
+
+// Detect returns the content type of data, the zip archives are looked into to
+// tell the DOCX and XLSX documents apart
+func Detect(data []byte) string {
+	detected := contenttype.Detect(data)
+	if detected != "application/zip" {
+		return detected
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return detected
+	}
+	for _, f := range zr.File {
+		switch f.Name {
+		case "word/document.xml":
+			return TypeDOCX
+		case "xl/workbook.xml":
+			return TypeXLSX
+		}
+	}
+	return detected
+}
+
+// Normalize makes text storable in the database, the invalid UTF-8 and the
+// control characters are dropped, the runs of blank lines are collapsed and
+// the result is cut to at most maxBytes on a rune boundary
+func Normalize(text string, maxBytes int) string {
+	text = strings.ToValidUTF8(text, "")
+	b := strings.Builder{}
+	newlines := 0
+	for _, r := range text {
+		switch {
+		case r == '\n':
+			newlines++
+			if newlines > 2 {
+				continue
+			}
+		case r == '\t' || !unicode.IsControl(r):
+			newlines = 0
+		default:
+			continue
+		}
+		if maxBytes > 0 && b.Len()+utf8.RuneLen(r) > maxBytes {
+			break
+		}
+		b.WriteRune(r)
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,151 @@
+package extraction
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/unistack-org/micro/v3/broker"
+	"github.com/unistack-org/micro/v3/logger"
+	"github.com/unistack-org/micro/v3/store"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	metrics "github.com/vielendanke/file-service/internal/app/fileservice/commons/metrics"
+	"github.com/vielendanke/file-service/internal/app/fileservice/contenttype"
+	"github.com/vielendanke/file-service/internal/app/fileservice/events"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+var (
+	extracted = metrics.GetOrMakeCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NS,
+			Name:      "texts_extracted_total",
+			Help:      "Total number of the files with extracted text by content type.",
+		},
+		[]string{"content_type"},
+	)
+	extractErrors = metrics.GetOrMakeCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NS,
+			Name:      "text_extraction_errors_total",
+			Help:      "Total number of the files whose text could not be extracted by content type.",
+		},
+		[]string{"content_type"},
+	)
+)
+
+// sniffLen is the number of bytes of a file sniffed for its content type
+const sniffLen = 512
+
+// Opener opens the objects of the clean store for streaming reads, a missing
+// object is reported as store.ErrNotFound
+type Opener interface {
+	Open(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+}
+
+// Indexer is the publisher of the relay extracting the text of a file when it
+// becomes clean and saving it for the search. A file which can not be extracted
+// is skipped, the failures of the store and the database are retried by the relay.
+type Indexer struct {
+	opener         Opener
+	tenants        *tenant.Registry
+	maxFileBytes   int64
+	repository     repository.SearchRepository
+	extractors     map[string]Extractor
+	maxTextBytes   int
+	extractTimeout time.Duration
+}
+
+// NewIndexer returns an indexer of the files of the clean store read through
+// cleanOpener, extractors are keyed by the content type returned by Detect. The
+// files of more than maxFileBytes are not extracted.
+func NewIndexer(cleanOpener Opener, tenants *tenant.Registry, repository repository.SearchRepository, extractors map[string]Extractor, maxFileBytes int64, maxTextBytes int, extractTimeout time.Duration) *Indexer {
+	return &Indexer{
+		opener:         cleanOpener,
+		tenants:        tenants,
+		maxFileBytes:   maxFileBytes,
+		repository:     repository,
+		extractors:     extractors,
+		maxTextBytes:   maxTextBytes,
+		extractTimeout: extractTimeout,
+	}
+}
+
+// Publish ...
+func (ix *Indexer) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	if topic != events.TopicFileClean {
+		return nil
+	}
+	id := msg.Header[events.HeaderFileID]
+	t, err := ix.tenants.Lookup(msg.Header[events.HeaderTenantID])
+	if err != nil {
+		logger.Errorf(ctx, "Error extracting text of %s, %v", id, err)
+		return nil
+	}
+	data, contentType, err := ix.read(ctx, t, id)
+	if err != nil {
+		return err
+	}
+	extractor, ok := ix.extractors[contentType]
+	if !ok {
+		return nil
+	}
+	extractCtx, cancel := context.WithTimeout(ctx, ix.extractTimeout)
+	defer cancel()
+	text, err := extractor.Extract(extractCtx, data)
+	if err != nil {
+		extractErrors.WithLabelValues(contentType).Inc()
+		logger.Errorf(ctx, "Error extracting text of %s of tenant %s, %v", id, t.ID, err)
+		return nil
+	}
+	err = ix.repository.SaveFileText(tenant.NewContext(ctx, t), id, Normalize(text, ix.maxTextBytes))
+	if apperrors.Is(err, apperrors.KindNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	extracted.WithLabelValues(contentType).Inc()
+	return nil
+}
+
+// read returns the file id of the tenant t and its content type, no data is
+// returned for the missing files, the types without an extractor and the files
+// of more than maxFileBytes. The type is sniffed from the head of the file
+// before the rest is read, the zip archives are read whole to tell the DOCX and
+// XLSX documents apart.
+func (ix *Indexer) read(ctx context.Context, t *tenant.Tenant, id string) ([]byte, string, error) {
+	body, err := ix.opener.Open(ctx, t.Bucket, t.Key(id))
+	if errors.Is(err, store.ErrNotFound) {
+		logger.Infof(ctx, "File %s of tenant %s is no longer in the clean store, skipping text extraction", id, t.ID)
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("Error reading file %s for text extraction, %v", id, err)
+	}
+	defer body.Close()
+	br := bufio.NewReaderSize(body, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return nil, "", fmt.Errorf("Error reading file %s for text extraction, %v", id, err)
+	}
+	sniffed := contenttype.Detect(head)
+	if _, ok := ix.extractors[sniffed]; !ok && sniffed != "application/zip" {
+		return nil, "", nil
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(br, ix.maxFileBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("Error reading file %s for text extraction, %v", id, err)
+	}
+	if int64(len(data)) > ix.maxFileBytes {
+		logger.Infof(ctx, "File %s of tenant %s is larger than %d bytes, skipping text extraction", id, t.ID, ix.maxFileBytes)
+		return nil, "", nil
+	}
+	return data, Detect(data), nil
+}
@@ -0,0 +1,217 @@
+package extraction
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxEntryBytes limits the uncompressed size of a read entry of the archives
+const maxEntryBytes = 64 << 20
+
+// DOCXExtractor returns the text of the paragraphs of the body of a DOCX document
+type DOCXExtractor struct{}
+
+// Extract ...
+func (DOCXExtractor) Extract(ctx context.Context, data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("Error opening DOCX, %v", err)
+	}
+	f := findEntry(zr, "word/document.xml")
+	if f == nil {
+		return "", fmt.Errorf("Error opening DOCX, word/document.xml not found")
+	}
+	b := strings.Builder{}
+	err = walkEntry(f, func(d *xml.Decoder, tok xml.Token) error {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				text, err := elementText(d)
+				if err != nil {
+					return err
+				}
+				b.WriteString(text)
+			case "tab":
+				b.WriteByte('\t')
+			case "br", "cr":
+				b.WriteByte('\n')
+			}
+		case xml.EndElement:
+			if t.Name.Local == "p" {
+				b.WriteByte('\n')
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("Error reading DOCX, %v", err)
+	}
+	return b.String(), nil
+}
+
+// XLSXExtractor returns the values of the cells of all the sheets of a XLSX
+// workbook, the cells are separated by tabs and the rows by newlines
+type XLSXExtractor struct{}
+
+// Extract ...
+func (XLSXExtractor) Extract(ctx context.Context, data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("Error opening XLSX, %v", err)
+	}
+	shared, err := sharedStrings(zr)
+	if err != nil {
+		return "", fmt.Errorf("Error reading XLSX shared strings, %v", err)
+	}
+	sheets := []*zip.File{}
+	for _, f := range zr.File {
+		if strings.HasPrefix(f.Name, "xl/worksheets/") && strings.HasSuffix(f.Name, ".xml") {
+			sheets = append(sheets, f)
+		}
+	}
+	sort.Slice(sheets, func(i, j int) bool { return sheets[i].Name < sheets[j].Name })
+	b := strings.Builder{}
+	for _, sheet := range sheets {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if err := readSheet(sheet, shared, &b); err != nil {
+			return "", fmt.Errorf("Error reading XLSX sheet %s, %v", sheet.Name, err)
+		}
+	}
+	return b.String(), nil
+}
+
+// sharedStrings returns the strings referenced by the cells of type s
+func sharedStrings(zr *zip.Reader) ([]string, error) {
+	f := findEntry(zr, "xl/sharedStrings.xml")
+	if f == nil {
+		return nil, nil
+	}
+	shared := []string{}
+	current := strings.Builder{}
+	err := walkEntry(f, func(d *xml.Decoder, tok xml.Token) error {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "t" {
+				text, err := elementText(d)
+				if err != nil {
+					return err
+				}
+				current.WriteString(text)
+			}
+		case xml.EndElement:
+			if t.Name.Local == "si" {
+				shared = append(shared, current.String())
+				current.Reset()
+			}
+		}
+		return nil
+	})
+	return shared, err
+}
+
+func readSheet(f *zip.File, shared []string, b *strings.Builder) error {
+	cellType := ""
+	cells := 0
+	return walkEntry(f, func(d *xml.Decoder, tok xml.Token) error {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "c":
+				cellType = ""
+				for _, a := range t.Attr {
+					if a.Name.Local == "t" {
+						cellType = a.Value
+					}
+				}
+			case "v", "t":
+				text, err := elementText(d)
+				if err != nil {
+					return err
+				}
+				if cellType == "s" {
+					i, err := strconv.Atoi(strings.TrimSpace(text))
+					if err != nil || i < 0 || i >= len(shared) {
+						return fmt.Errorf("invalid shared string %q", text)
+					}
+					text = shared[i]
+				}
+				if text == "" {
+					return nil
+				}
+				if cells > 0 {
+					b.WriteByte('\t')
+				}
+				b.WriteString(text)
+				cells++
+			}
+		case xml.EndElement:
+			if t.Name.Local == "row" && cells > 0 {
+				b.WriteByte('\n')
+				cells = 0
+			}
+		}
+		return nil
+	})
+}
+
+func findEntry(zr *zip.Reader, name string) *zip.File {
+	for _, f := range zr.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// walkEntry calls fn with every token of the XML entry f
+func walkEntry(f *zip.File, fn func(d *xml.Decoder, tok xml.Token) error) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	d := xml.NewDecoder(io.LimitReader(rc, maxEntryBytes))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(d, tok); err != nil {
+			return err
+		}
+	}
+}
+
+// elementText reads the character data up to the end of the current element
+func elementText(d *xml.Decoder) (string, error) {
+	b := strings.Builder{}
+	depth := 1
+	for depth > 0 {
+		tok, err := d.Token()
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			b.Write(t)
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,354 @@
+package extraction
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+var (
+	streamKeyword    = []byte("stream")
+	endstreamKeyword = []byte("endstream")
+)
+
+// PDFExtractor reads the text layer of a PDF from the text showing operators of
+// its content streams. The streams are read in the order of the file and only
+// the unfiltered and the FlateDecode ones are supported. The glyphs of the
+// fonts with custom encodings are not mapped, the text which does not decode to
+// printable characters is skipped.
+type PDFExtractor struct{}
+
+// Extract ...
+func (PDFExtractor) Extract(ctx context.Context, data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "", fmt.Errorf("Error reading PDF, header not found")
+	}
+	b := strings.Builder{}
+	pos := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		dict, content, next, ok := nextStream(data, pos)
+		if !ok {
+			break
+		}
+		pos = next
+		decoded, err := decodeStream(dict, content)
+		if err != nil || !bytes.Contains(decoded, []byte("BT")) {
+			continue
+		}
+		showText(decoded, &b)
+	}
+	return b.String(), nil
+}
+
+// nextStream finds the first stream after pos, it returns its dictionary, its
+// raw content and the position after it
+func nextStream(data []byte, pos int) ([]byte, []byte, int, bool) {
+	for {
+		i := bytes.Index(data[pos:], streamKeyword)
+		if i < 0 {
+			return nil, nil, 0, false
+		}
+		start := pos + i
+		pos = start + len(streamKeyword)
+		if start >= 3 && string(data[start-3:start]) == "end" {
+			continue
+		}
+		switch {
+		case bytes.HasPrefix(data[pos:], []byte("\r\n")):
+			pos += 2
+		case bytes.HasPrefix(data[pos:], []byte("\n")):
+			pos++
+		default:
+			continue
+		}
+		end := bytes.Index(data[pos:], endstreamKeyword)
+		if end < 0 {
+			return nil, nil, 0, false
+		}
+		dictStart := bytes.LastIndex(data[:start], []byte("obj"))
+		if dictStart < 0 {
+			dictStart = 0
+		}
+		return data[dictStart:start], data[pos : pos+end], pos + end + len(endstreamKeyword), true
+	}
+}
+
+func decodeStream(dict, content []byte) ([]byte, error) {
+	if !bytes.Contains(dict, []byte("/Filter")) {
+		return content, nil
+	}
+	if !bytes.Contains(dict, []byte("/FlateDecode")) || bytes.Count(dict, []byte("Decode")) > 1 {
+		return nil, fmt.Errorf("unsupported filter")
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	decoded, err := ioutil.ReadAll(io.LimitReader(zr, maxEntryBytes))
+	if err != nil && len(decoded) == 0 {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// showText writes the strings of the text showing operators of a content stream,
+// the moves to a new line and the ends of the text objects become newlines
+func showText(content []byte, b *strings.Builder) {
+	lex := &pdfLexer{data: content}
+	operands := []string{}
+	numbers := []float64{}
+	array := []string{}
+	inArray := false
+	newline := func() {
+		s := b.String()
+		if len(s) > 0 && !strings.HasSuffix(s, "\n") {
+			b.WriteByte('\n')
+		}
+	}
+	for {
+		kind, tok := lex.next()
+		switch kind {
+		case tokenEOF:
+			newline()
+			return
+		case tokenString:
+			if inArray {
+				array = append(array, tok)
+			} else {
+				operands = append(operands, tok)
+			}
+		case tokenNumber:
+			n, _ := strconv.ParseFloat(tok, 64)
+			if inArray {
+				// a large negative adjustment of TJ separates the words
+				if n < -200 {
+					array = append(array, " ")
+				}
+			} else {
+				numbers = append(numbers, n)
+			}
+		case tokenArrayStart:
+			inArray = true
+			array = array[:0]
+		case tokenArrayEnd:
+			inArray = false
+			operands = append(operands, strings.Join(array, ""))
+		case tokenOperator:
+			switch tok {
+			case "Tj", "TJ":
+				if len(operands) > 0 {
+					b.WriteString(operands[len(operands)-1])
+				}
+			case "'", "\"":
+				newline()
+				if len(operands) > 0 {
+					b.WriteString(operands[len(operands)-1])
+				}
+			case "T*", "Tm", "ET":
+				newline()
+			case "Td", "TD":
+				if len(numbers) >= 2 && numbers[len(numbers)-1] != 0 {
+					newline()
+				} else if s := b.String(); len(s) > 0 && !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+					b.WriteByte(' ')
+				}
+			}
+			operands = operands[:0]
+			numbers = numbers[:0]
+		}
+	}
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenString
+	tokenNumber
+	tokenArrayStart
+	tokenArrayEnd
+	tokenOperator
+	tokenOther
+)
+
+// pdfLexer splits a content stream into the tokens relevant for its text
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func (l *pdfLexer) next() (tokenKind, string) {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case c == '(':
+			return tokenString, l.literal()
+		case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+			l.pos += 2
+			return tokenOther, "<<"
+		case c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+			l.pos += 2
+			return tokenOther, ">>"
+		case c == '<':
+			return tokenString, l.hex()
+		case c == '[':
+			l.pos++
+			return tokenArrayStart, "["
+		case c == ']':
+			l.pos++
+			return tokenArrayEnd, "]"
+		case c == '/':
+			l.pos++
+			l.word()
+			return tokenOther, ""
+		case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			return tokenNumber, l.word()
+		case c == '\'' || c == '"':
+			l.pos++
+			return tokenOperator, string(c)
+		default:
+			w := l.word()
+			if w == "" {
+				l.pos++
+				continue
+			}
+			return tokenOperator, w
+		}
+	}
+	return tokenEOF, ""
+}
+
+func (l *pdfLexer) word() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+// literal reads a string in parentheses with its escapes and balanced parentheses
+func (l *pdfLexer) literal() string {
+	l.pos++
+	out := []byte{}
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return decodePDFString(out)
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				continue
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for k := 0; k < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; k++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return decodePDFString(out)
+}
+
+// hex reads a string of hexadecimal digits in angle brackets
+func (l *pdfLexer) hex() string {
+	l.pos++
+	digits := []byte{}
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; isHexDigit(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		v, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(v)
+	}
+	return decodePDFString(out)
+}
+
+// decodePDFString decodes the UTF-16 strings with a byte order mark and reads
+// the others as Latin-1, the strings with unprintable characters are dropped
+func decodePDFString(raw []byte) string {
+	runes := []rune{}
+	if len(raw) >= 2 && raw[0] == 0xfe && raw[1] == 0xff {
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+		}
+		runes = utf16.Decode(units)
+	} else {
+		for _, c := range raw {
+			runes = append(runes, rune(c))
+		}
+	}
+	for _, r := range runes {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return ""
+		}
+	}
+	return string(runes)
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
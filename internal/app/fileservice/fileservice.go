@@ -4,16 +4,21 @@ import (
 	"compress/flate"
 	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/heptiolabs/healthcheck"
 	"github.com/jmoiron/sqlx"
 	httpcli "github.com/unistack-org/micro-client-http/v3"
 	jsoncodec "github.com/unistack-org/micro-codec-json/v3"
+	protocodec "github.com/unistack-org/micro-codec-proto/v3"
 	envconfig "github.com/unistack-org/micro-config-env/v3"
 	fileconfig "github.com/unistack-org/micro-config-file/v3"
 	promwrapper "github.com/unistack-org/micro-metrics-prometheus/v3"
+	grpcsrv "github.com/unistack-org/micro-server-grpc/v3"
 	httpsrv "github.com/unistack-org/micro-server-http/v3"
 	s3store "github.com/unistack-org/micro-store-s3/v3"
 	idwrapper "github.com/unistack-org/micro-wrapper-requestid/v3"
@@ -21,33 +26,274 @@ import (
 	"github.com/unistack-org/micro/v3/client"
 	"github.com/unistack-org/micro/v3/config"
 	"github.com/unistack-org/micro/v3/logger"
+	"github.com/unistack-org/micro/v3/metadata"
 	"github.com/unistack-org/micro/v3/server"
 	"github.com/unistack-org/micro/v3/store"
 	"github.com/vielendanke/file-service/configs"
+	"github.com/vielendanke/file-service/internal/app/fileservice/access"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/archives"
+	"github.com/vielendanke/file-service/internal/app/fileservice/cache"
 	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/retry"
 	"github.com/vielendanke/file-service/internal/app/fileservice/commons/stats"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/tracer"
+	"github.com/vielendanke/file-service/internal/app/fileservice/events"
+	"github.com/vielendanke/file-service/internal/app/fileservice/expiry"
+	"github.com/vielendanke/file-service/internal/app/fileservice/extraction"
 	"github.com/vielendanke/file-service/internal/app/fileservice/handlers"
 	"github.com/vielendanke/file-service/internal/app/fileservice/middlewares"
+	"github.com/vielendanke/file-service/internal/app/fileservice/migrate"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
 	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
 	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+	"github.com/vielendanke/file-service/internal/app/fileservice/shutdown"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+	"github.com/vielendanke/file-service/internal/app/fileservice/thumbnails"
+	"github.com/vielendanke/file-service/internal/app/fileservice/webhooks"
+	"github.com/vielendanke/file-service/migrations"
 	pb "github.com/vielendanke/file-service/proto"
 )
 
-func initDB(name, url string, errCh chan<- error) <-chan *sqlx.DB {
-	dbCh := make(chan *sqlx.DB, 1)
-	go func() {
-		defer close(dbCh)
-		db, err := sqlx.Connect(name, url)
+// startupErrors collects the errors of the independent startup steps to report them at once
+type startupErrors []string
+
+func (se *startupErrors) add(step string, err error) {
+	if err != nil {
+		*se = append(*se, fmt.Sprintf("%s: %v", step, err))
+	}
+}
+
+func (se startupErrors) err() error {
+	if len(se) == 0 {
+		return nil
+	}
+	return fmt.Errorf("Error starting service, %s", strings.Join(se, "; "))
+}
+
+func newBackoff(cfg *configs.StartupConfig) retry.Backoff {
+	// the durations are checked by the config validation
+	initial, _ := time.ParseDuration(cfg.InitialBackoff)
+	max, _ := time.ParseDuration(cfg.MaxBackoff)
+	return retry.Backoff{Attempts: cfg.Attempts, Initial: initial, Max: max}
+}
+
+// newEventRelay returns the relay of the outbox of db to publisher and its interval
+func newEventRelay(cfg *configs.EventsConfig, db *sqlx.DB, publisher events.Publisher) (*events.Relay, time.Duration) {
+	// the durations are checked by the config validation
+	interval, _ := time.ParseDuration(cfg.Interval)
+	lease, _ := time.ParseDuration(cfg.Lease)
+	initial, _ := time.ParseDuration(cfg.InitialBackoff)
+	max, _ := time.ParseDuration(cfg.MaxBackoff)
+	relay := events.NewRelay(
+		repository.NewOutboxRepository(db),
+		publisher,
+		cfg.BatchSize,
+		lease,
+		retry.Backoff{Initial: initial, Max: max},
+	)
+	return relay, interval
+}
+
+func newWebhookDispatcher(cfg *configs.WebhooksConfig, repo repository.WebhookRepository) (*webhooks.Dispatcher, time.Duration) {
+	// the durations are checked by the config validation
+	interval, _ := time.ParseDuration(cfg.Interval)
+	lease, _ := time.ParseDuration(cfg.Lease)
+	timeout, _ := time.ParseDuration(cfg.Timeout)
+	initial, _ := time.ParseDuration(cfg.InitialBackoff)
+	max, _ := time.ParseDuration(cfg.MaxBackoff)
+	dispatcher := webhooks.NewDispatcher(
+		repo,
+		webhooks.NewClient(timeout),
+		cfg.BatchSize,
+		lease,
+		retry.Backoff{Initial: initial, Max: max},
+		cfg.MaxAttempts,
+	)
+	return dispatcher, interval
+}
+
+func newThumbnailGenerator(cfg *configs.ThumbnailsConfig, cleanStore store.Store, cleanOpener service.ObjectOpener, tenants *tenant.Registry) *thumbnails.Generator {
+	// the durations are checked by the config validation
+	renderTimeout, _ := time.ParseDuration(cfg.RenderTimeout)
+	images := &thumbnails.ImageRenderer{MaxPixels: cfg.MaxPixels}
+	renderers := map[string]thumbnails.Renderer{
+		"image/jpeg": images,
+		"image/png":  images,
+		"image/gif":  images,
+	}
+	if len(cfg.PDFRenderer) > 0 {
+		renderers["application/pdf"] = &thumbnails.CommandRenderer{Command: cfg.PDFRenderer, MaxPixels: cfg.MaxPixels}
+	}
+	return thumbnails.NewGenerator(cleanStore, cleanOpener, tenants, cfg.MaxBytes, cfg.Sizes, renderers, renderTimeout)
+}
+
+func newSearchIndexer(cfg *configs.SearchConfig, cleanOpener service.ObjectOpener, tenants *tenant.Registry, repo repository.SearchRepository) *extraction.Indexer {
+	// the durations are checked by the config validation
+	extractTimeout, _ := time.ParseDuration(cfg.ExtractTimeout)
+	extractors := map[string]extraction.Extractor{
+		"text/plain":        extraction.PlainTextExtractor{},
+		"application/pdf":   extraction.PDFExtractor{},
+		extraction.TypeDOCX: extraction.DOCXExtractor{},
+		extraction.TypeXLSX: extraction.XLSXExtractor{},
+	}
+	return extraction.NewIndexer(cleanOpener, tenants, repo, extractors, cfg.MaxFileBytes, cfg.MaxTextBytes, extractTimeout)
+}
+
+func newExpiryNotifier(cfg *configs.ExpiryConfig, repo repository.ExpiryRepository, tenants *tenant.Registry) (*expiry.Notifier, time.Duration) {
+	// the durations are checked by the config validation
+	interval, _ := time.ParseDuration(cfg.Interval)
+	notice, _ := time.ParseDuration(cfg.Notice)
+	return expiry.NewNotifier(repo, tenants, notice, cfg.BatchSize), interval
+}
+
+func newMetadataCache(cfg *configs.CacheConfig) (*cache.LRU, time.Duration) {
+	// the durations are checked by the config validation
+	ttl, _ := time.ParseDuration(cfg.TTL)
+	loadTimeout, _ := time.ParseDuration(cfg.LoadTimeout)
+	return cache.NewLRU(cfg.MaxEntries, ttl), loadTimeout
+}
+
+func newAccessWriter(cfg *configs.AccessLogConfig, repo repository.AccessRepository) (*access.Writer, time.Duration) {
+	// the durations are checked by the config validation
+	interval, _ := time.ParseDuration(cfg.Interval)
+	return access.NewWriter(repo, cfg.BufferSize, cfg.BatchSize), interval
+}
+
+func connectDB(ctx context.Context, name, url string, backoff retry.Backoff) (*sqlx.DB, error) {
+	var db *sqlx.DB
+	err := retry.Do(ctx, "connecting to db", backoff, func(ctx context.Context) error {
+		var err error
+		db, err = sqlx.ConnectContext(ctx, name, url)
+		return err
+	})
+	return db, err
+}
+
+func newStore(cfg *configs.AmazonConnectConfig) store.Store {
+	return s3store.NewStore(
+		store.Name(cfg.Name),
+		s3store.Region(cfg.Region),
+		s3store.AccessKey(cfg.AccessKey),
+		s3store.SecretKey(cfg.SecretKey),
+		s3store.Endpoint(cfg.Endpoint),
+	)
+}
+
+// newOpener streams the objects of the store created by newStore with the same cfg
+func newOpener(cfg *configs.AmazonConnectConfig) (service.ObjectOpener, error) {
+	return service.NewS3Opener(cfg.Endpoint, cfg.Region, cfg.AccessKey, cfg.SecretKey)
+}
+
+func newTenantRegistry(cfg *configs.TenancyConfig) *tenant.Registry {
+	tenants := make([]*tenant.Tenant, 0, len(cfg.Tenants))
+	for _, tc := range cfg.Tenants {
+		tenants = append(tenants, &tenant.Tenant{
+			ID:         tc.ID,
+			Bucket:     tc.Bucket,
+			Prefix:     tc.Prefix,
+			MaxObjects: tc.MaxObjects,
+			MaxBytes:   tc.MaxBytes,
+		})
+	}
+	return tenant.NewRegistry(cfg.Default, tenants)
+}
+
+func connectStore(ctx context.Context, s store.Store, backoff retry.Backoff) error {
+	if err := s.Init(); err != nil {
+		return fmt.Errorf("Error initializing store %s, %v", s.Name(), err)
+	}
+	return retry.Do(ctx, "connecting to store "+s.Name(), backoff, func(ctx context.Context) error {
+		return s.Connect(ctx)
+	})
+}
+
+func newLimiter(mode string, maxClients int, limit uint64, period string, burst uint64) (middleware.Limiter, error) {
+	d, err := time.ParseDuration(period)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing rate limit period, %v", err)
+	}
+	return middleware.NewLimiter(mode, maxClients, middleware.RateLimit{Limit: limit, Period: d, Burst: burst})
+}
+
+func newLoggerMiddleware(cfg *configs.LoggingConfig) (*middleware.LoggerMiddleware, error) {
+	redactor, err := middleware.NewRedactor(cfg.Redact)
+	if err != nil {
+		return nil, err
+	}
+	rule := middleware.LogRule{Level: cfg.Level, SampleRate: cfg.SampleRate, MaxBodyBytes: cfg.MaxBodyBytes, Redactor: redactor}
+	opts := []middleware.LoggerOption{middleware.WithDefaultLogRule(rule)}
+	for _, route := range cfg.Routes {
+		routeRule := rule
+		if route.Level != "" {
+			routeRule.Level = route.Level
+		}
+		if route.SampleRate != 0 {
+			routeRule.SampleRate = route.SampleRate
+		}
+		if route.MaxBodyBytes != 0 {
+			routeRule.MaxBodyBytes = route.MaxBodyBytes
+		}
+		if len(route.Redact) > 0 {
+			paths := append(append([]string{}, cfg.Redact...), route.Redact...)
+			if routeRule.Redactor, err = middleware.NewRedactor(paths); err != nil {
+				return nil, err
+			}
+		}
+		opts = append(opts, middleware.WithRouteLogRule(route.Method, route.Path, routeRule))
+	}
+	return middleware.NewLoggerMiddleware(opts...), nil
+}
+
+func newRateLimitMiddleware(cfg *configs.RateLimitConfig) (*middleware.RateLimitMiddleware, error) {
+	opts := []middleware.RateLimitOption{}
+	if cfg.TrustForwardedFor {
+		opts = append(opts, middleware.WithTrustedForwardedFor())
+	}
+	limits := []struct {
+		limit *configs.LimitConfig
+		opt   func(middleware.Limiter) middleware.RateLimitOption
+	}{
+		{cfg.Addresses, middleware.WithAddressLimiter},
+		{cfg.Requests, middleware.WithRequestLimiter},
+		{cfg.UploadBytes, middleware.WithUploadLimiter},
+		{cfg.DownloadBytes, middleware.WithDownloadLimiter},
+	}
+	for _, l := range limits {
+		if l.limit == nil || l.limit.Limit == 0 {
+			continue
+		}
+		limiter, err := newLimiter(cfg.Mode, cfg.MaxClients, l.limit.Limit, l.limit.Period, l.limit.Burst)
 		if err != nil {
-			errCh <- err
+			return nil, err
 		}
-		dbCh <- db
-	}()
-	return dbCh
+		opts = append(opts, l.opt(limiter))
+	}
+	for _, route := range cfg.Routes {
+		limiter, err := newLimiter(cfg.Mode, cfg.MaxClients, route.Limit, route.Period, route.Burst)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, middleware.WithRouteLimiter(route.Method, route.Path, limiter))
+	}
+	return middleware.NewRateLimitMiddleware(func(rw http.ResponseWriter, r *http.Request, d middleware.Decision) {
+		apperrors.WriteProblem(rw, r, apperrors.RateLimited(apperrors.CodeRateLimited, "Rate limit exceeded, retry in %s", d.Reset.Round(time.Second)))
+	}, opts...), nil
 }
 
-// StartFileService ...
-func StartFileService(ctx context.Context, errCh chan<- error) {
+func newReadinessChecks(timeout time.Duration, db *sqlx.DB, buckets []string, stores ...store.Store) map[string]healthcheck.Check {
+	checks := map[string]healthcheck.Check{
+		"postgres": healthcheck.DatabasePingCheck(db.DB, timeout),
+	}
+	for _, s := range stores {
+		for _, bucket := range buckets {
+			checks[s.Name()+":"+bucket] = service.NewStoreCheck(s, bucket, timeout)
+		}
+	}
+	return checks
+}
+
+func loadConfig(ctx context.Context) (*configs.Config, error) {
 	cfg := configs.NewConfig("file-service", "1.0")
 
 	if err := config.Load(ctx,
@@ -64,51 +310,63 @@ func StartFileService(ctx context.Context, errCh chan<- error) {
 			config.Struct(cfg), // pass config struct
 		),
 	); err != nil {
-		errCh <- err
+		return nil, fmt.Errorf("Error loading config, %v", err)
 	}
+	return cfg, nil
+}
 
-	s3Dirty := s3store.NewStore(
-		store.Name(cfg.Amazon.DirtyRegion.Name),
-		s3store.Region(cfg.Amazon.DirtyRegion.Region),
-		s3store.AccessKey(cfg.Amazon.DirtyRegion.AccessKey),
-		s3store.SecretKey(cfg.Amazon.DirtyRegion.SecretKey),
-		s3store.Endpoint(cfg.Amazon.DirtyRegion.Endpoint),
-	)
-	s3Clean := s3store.NewStore(
-		store.Name(cfg.Amazon.CleanRegion.Name),
-		s3store.Region(cfg.Amazon.CleanRegion.Region),
-		s3store.AccessKey(cfg.Amazon.CleanRegion.AccessKey),
-		s3store.SecretKey(cfg.Amazon.CleanRegion.SecretKey),
-		s3store.Endpoint(cfg.Amazon.CleanRegion.Endpoint),
-	)
-	if err := s3Dirty.Init(); err != nil {
-		errCh <- err
+// StartFileService validates the config, connects to the database and the stores and
+// serves until ctx is done, it returns the startup errors or the failure of the servers
+func StartFileService(ctx context.Context) error {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
 	}
-	if err := s3Dirty.Connect(ctx); err != nil {
-		errCh <- err
+	// the durations are checked by the config validation
+	reloadInterval, _ := time.ParseDuration(cfg.Policy.ReloadInterval)
+	checkInterval, _ := time.ParseDuration(cfg.Health.Interval)
+	checkTimeout, _ := time.ParseDuration(cfg.Health.Timeout)
+	drainTimeout, _ := time.ParseDuration(cfg.Shutdown.DrainTimeout)
+	workersTimeout, _ := time.ParseDuration(cfg.Shutdown.WorkersTimeout)
+	backoff := newBackoff(cfg.Startup)
+
+	// the servers and the workers outlive ctx until the requests in flight are drained
+	runCtx, stopRun := context.WithCancel(context.Background())
+	defer stopRun()
+	workers := shutdown.NewWorkers()
+	drainer := shutdown.NewDrainer()
+
+	s3Dirty := newStore(cfg.Amazon.DirtyRegion)
+	s3Clean := newStore(cfg.Amazon.CleanRegion)
+	if err := connectStore(ctx, s3Dirty, backoff); err != nil {
+		return err
 	}
 	defer func() {
-		err := s3Dirty.Disconnect(ctx)
+		err := s3Dirty.Disconnect(context.Background())
 		if err != nil {
 			logger.Errorf(ctx, "Error during disconnect from s3, %v", err)
 		}
 	}()
-	if err := s3Clean.Init(); err != nil {
-		errCh <- err
-	}
-	if err := s3Clean.Connect(ctx); err != nil {
-		errCh <- err
+	if err := connectStore(ctx, s3Clean, backoff); err != nil {
+		return err
 	}
 	defer func() {
-		err := s3Clean.Disconnect(ctx)
+		err := s3Clean.Disconnect(context.Background())
 		if err != nil {
 			logger.Errorf(ctx, "Error during disconnect from s3, %v", err)
 		}
 	}()
+	cleanOpener, err := newOpener(cfg.Amazon.CleanRegion)
+	if err != nil {
+		return err
+	}
 
 	options := append([]micro.Option{},
 		micro.Servers(httpsrv.NewServer()),
-		micro.Context(ctx),
+		micro.Context(runCtx),
 		micro.Name(cfg.Server.Name),
 		micro.Version(cfg.Server.Version),
 		micro.Stores(s3Clean, s3Dirty),
@@ -116,15 +374,99 @@ func StartFileService(ctx context.Context, errCh chan<- error) {
 	svc := micro.NewService(options...)
 
 	if err := svc.Init(); err != nil {
-		errCh <- err
+		return fmt.Errorf("Error initializing service, %v", err)
 	}
 
+	db, err := connectDB(ctx, "postgres", cfg.Database.URL, backoff)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			logger.Errorf(ctx, "Error during close of db, %v", err)
+		}
+	}()
+	if cfg.Database.AutoMigrate {
+		migrator, err := migrate.NewMigrator(db, migrations.FS)
+		if err != nil {
+			return err
+		}
+		if err := migrator.Up(ctx); err != nil {
+			return err
+		}
+	}
+	workers.Go("sql_pool_stats", func(ctx context.Context) {
+		repository.WatchPoolStats(ctx, db, 15*time.Second)
+	})
+
+	errs := startupErrors{}
+
+	tenants := newTenantRegistry(cfg.Tenancy)
+
+	// the HTTP and the grpc calls are limited by the same limiters
+	var rlm *middleware.RateLimitMiddleware
+	if cfg.RateLimit.Enabled {
+		if rlm, err = newRateLimitMiddleware(cfg.RateLimit); err != nil {
+			errs.add("rate limit", err)
+		}
+	}
+
+	// the HTTP and the grpc calls are authenticated by the same authenticators
+	var am *middleware.AuthMiddleware
+	if cfg.Auth.Enabled {
+		authenticators := []middleware.Authenticator{}
+		if cfg.Auth.JWKS != "" {
+			jwtAuth, err := middleware.NewJWTAuthenticator(ctx, cfg.Auth.JWKS, cfg.Auth.Issuer, cfg.Auth.Audience)
+			if err != nil {
+				errs.add("auth", err)
+			} else {
+				authenticators = append(authenticators, jwtAuth)
+			}
+		}
+		authenticators = append(authenticators, middleware.NewAPIKeyAuthenticator(repository.NewAPIKeyRepository(db)))
+		am = middleware.NewAuthMiddleware(func(rw http.ResponseWriter, r *http.Request, err error) {
+			logger.Infof(r.Context(), "Authentication failed, %v", err)
+			apperrors.WriteProblem(rw, r, middlewares.AuthError(err))
+		}, authenticators...)
+	}
+
+	grpcOptions := []server.Option{
+		server.Name(cfg.GRPC.Name),
+		server.Version(cfg.Server.Version),
+		server.Address(cfg.GRPC.Addr),
+		server.Context(runCtx),
+		server.Codec("application/grpc", protocodec.NewCodec()),
+		server.Codec("application/grpc+proto", protocodec.NewCodec()),
+		server.WrapHandler(promwrapper.NewHandlerWrapper(
+			promwrapper.ServiceName(svc.Server().Options().Name),
+			promwrapper.ServiceVersion(svc.Server().Options().Version),
+			promwrapper.ServiceID(svc.Server().Options().Id),
+		)),
+		server.WrapHandler(idwrapper.NewServerHandlerWrapper()),
+		server.WrapHandler(middlewares.NewDrainHandlerWrapper(drainer)),
+	}
+	if rlm != nil {
+		grpcOptions = append(grpcOptions, server.WrapHandler(middlewares.NewRateLimitAddressHandlerWrapper(rlm)))
+	}
+	// the principal is needed to resolve the tenant
+	if am != nil {
+		grpcOptions = append(grpcOptions, server.WrapHandler(middlewares.NewAuthHandlerWrapper(am)))
+	}
+	grpcOptions = append(grpcOptions,
+		server.WrapHandler(middlewares.NewTenantHandlerWrapper(tenants)),
+		server.WrapHandler(middlewares.NewAccessHandlerWrapper()),
+	)
+	if rlm != nil {
+		grpcOptions = append(grpcOptions, server.WrapHandler(middlewares.NewRateLimitHandlerWrapper(rlm)))
+	}
+	grpcServer := grpcsrv.NewServer(grpcOptions...)
+
 	if err := svc.Init(
 		micro.Servers(httpsrv.NewServer(
 			server.Name(cfg.Server.Name),
 			server.Version(cfg.Server.Version),
 			server.Address(cfg.Server.Addr),
-			server.Context(ctx),
+			server.Context(runCtx),
 			server.Codec("application/json", jsoncodec.NewCodec()),
 			server.WrapHandler(promwrapper.NewHandlerWrapper(
 				promwrapper.ServiceName(svc.Server().Options().Name),
@@ -132,7 +474,7 @@ func StartFileService(ctx context.Context, errCh chan<- error) {
 				promwrapper.ServiceID(svc.Server().Options().Id),
 			)),
 			server.WrapHandler(idwrapper.NewServerHandlerWrapper()),
-		)),
+		), grpcServer),
 		micro.Clients(httpcli.NewClient(
 			client.ContentType("application/json"),
 			client.Codec("application/json", jsoncodec.NewCodec()),
@@ -145,7 +487,7 @@ func StartFileService(ctx context.Context, errCh chan<- error) {
 		)),
 		micro.Stores(s3Clean, s3Dirty),
 	); err != nil {
-		errCh <- err
+		errs.add("servers", err)
 	}
 	router := mux.NewRouter()
 
@@ -154,52 +496,279 @@ func StartFileService(ctx context.Context, errCh chan<- error) {
 	router.Use(ctm.ContentTypeMiddleware)
 	router.Use(middleware.HttpMetricsWrapper)
 	router.Use(middleware.NewRequestIDMiddleware().Wrapper)
-	router.Use(middleware.NewLoggerMiddleware().Wrapper)
-	router.Use(middleware.NewNocacheMiddleware().Wrapper)
+	router.Use(middlewares.NewDrainMiddleware(drainer).Wrapper)
+	if cfg.Tracer.Enabled {
+		tr, closer, err := tracer.NewTracer(&tracer.TracerConfig{
+			Service:   cfg.Server.Name,
+			Collector: cfg.Tracer.Collector,
+			AgentHost: cfg.Tracer.AgentHost,
+			AgentPort: cfg.Tracer.AgentPort,
+			Metadata:  metadata.Metadata{"version": cfg.Server.Version},
+		})
+		if err != nil {
+			errs.add("tracer", err)
+		} else {
+			defer closer.Close()
+			router.Use(middleware.NewTracerMiddleware(router, tr))
+		}
+	}
+	// the logger is inside the compressor to see the bodies as they are written
 	router.Use(middleware.NewCompressMiddleware(flate.BestSpeed).Wrapper)
+	lm, err := newLoggerMiddleware(cfg.Logging)
+	if err != nil {
+		errs.add("logging", err)
+	} else {
+		router.Use(lm.Wrapper)
+	}
+	router.Use(middleware.NewNocacheMiddleware().Wrapper)
 
 	router.NotFoundHandler = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		logger.Infof(ctx, "Not found, %v/n", r.URL)
-		rw.Header().Set("Content-Type", "application/json")
-		rw.WriteHeader(http.StatusNotFound)
-		rw.Write([]byte(fmt.Sprintf("Not found. Path: %s, Method: %s", r.RequestURI, r.Method)))
+		apperrors.WriteProblem(rw, r, apperrors.NotFound(
+			apperrors.CodeRouteNotFound, "Not found. Path: %s, Method: %s", r.RequestURI, r.Method,
+		))
 	})
 	router.MethodNotAllowedHandler = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		logger.Infof(ctx, "Method not allowed, %v/n", r.URL)
-		rw.Header().Set("Content-Type", "application/json")
-		rw.WriteHeader(http.StatusMethodNotAllowed)
-		rw.Write([]byte(fmt.Sprintf("Method not allowed, %s", r.Method)))
+		p := apperrors.NewProblem(r, apperrors.Invalid(apperrors.CodeMethodNotAllowed, "Method not allowed, %s", r.Method))
+		p.Status = http.StatusMethodNotAllowed
+		p.Title = http.StatusText(p.Status)
+		p.Write(rw)
 	})
 	endpoints := pb.NewFileProcessingEndpoints()
 
-	db := <-initDB("postgres", cfg.Database.URL, errCh)
+	if rlm != nil {
+		// the addresses are limited before the authentication to throttle the guessed credentials
+		router.Use(rlm.AddressWrapper)
+	}
+	if am != nil {
+		router.Use(am.Wrapper)
+	}
+	router.Use(middlewares.NewTenantMiddleware(tenants).Wrapper)
+	router.Use(middlewares.NewAccessMiddleware(cfg.AccessLog.TrustForwardedFor).Wrapper)
+	if rlm != nil {
+		router.Use(rlm.Wrapper)
+	}
 
 	fr := repository.NewAWSFileRepository(db)
 
-	srv := service.NewAWSProcessingService(jsoncodec.NewCodec(), fr, svc.Store("clean_region"), svc.Store("dirty_region"))
+	policyEngine, err := policy.NewEngine(cfg.Policy.File)
+	if err != nil {
+		errs.add("policy", err)
+		return errs.err()
+	}
+	workers.Go("policy_reload", func(ctx context.Context) {
+		policyEngine.Watch(ctx, reloadInterval)
+	})
+	webhookRepository := repository.NewSQLWebhookRepository(db)
+	searchRepository := repository.NewSQLSearchRepository(db, cfg.Search.Language)
+	publishers := events.Publishers{}
+	if cfg.Events.Enabled {
+		publishers = append(publishers, svc.Broker())
+	}
+	if cfg.Webhooks.Enabled {
+		publishers = append(publishers, webhooks.NewFanout(webhookRepository))
+	}
+	if cfg.Thumbnails.Enabled {
+		publishers = append(publishers, newThumbnailGenerator(cfg.Thumbnails, svc.Store("clean_region"), cleanOpener, tenants))
+	}
+	if cfg.Search.Enabled {
+		publishers = append(publishers, newSearchIndexer(cfg.Search, cleanOpener, tenants, searchRepository))
+	}
+	if len(publishers) > 0 {
+		relay, interval := newEventRelay(cfg.Events, db, publishers)
+		workers.Go("event_relay", func(ctx context.Context) {
+			relay.Run(ctx, interval)
+		})
+	}
+	if cfg.Webhooks.Enabled {
+		dispatcher, interval := newWebhookDispatcher(cfg.Webhooks, webhookRepository)
+		workers.Go("webhook_dispatcher", func(ctx context.Context) {
+			dispatcher.Run(ctx, interval)
+		})
+	}
+
+	entryRepository := repository.NewSQLEntryRepository(db)
+	var processing service.FileProcessingService = service.NewAWSProcessingService(jsoncodec.NewCodec(), fr, svc.Store("clean_region"), svc.Store("dirty_region"), cleanOpener)
+	if cfg.Archives.Enabled {
+		processing = service.NewArchiveService(processing, entryRepository, archives.Limits{
+			MaxDepth:         cfg.Archives.MaxDepth,
+			MaxEntries:       cfg.Archives.MaxEntries,
+			MaxRatio:         cfg.Archives.MaxRatio,
+			MaxExpandedBytes: cfg.Archives.MaxExpandedBytes,
+		}, cfg.Archives.MaxBytes)
+	}
+	expiryRepository := repository.NewSQLExpiryRepository(db)
+	if cfg.Expiry.Enabled {
+		processing = service.NewExpiryService(processing, fr, expiryRepository, cfg.Expiry.Fields)
+		notifier, interval := newExpiryNotifier(cfg.Expiry, expiryRepository, tenants)
+		workers.Go("expiry_notifier", func(ctx context.Context) {
+			notifier.Run(ctx, interval)
+		})
+	}
+	if cfg.Cache.Enabled {
+		metadataCache, loadTimeout := newMetadataCache(cfg.Cache)
+		processing = service.NewCachingService(processing, metadataCache, loadTimeout)
+		workers.Go("file_changes_listener", func(ctx context.Context) {
+			if err := repository.ListenFileChanges(ctx, cfg.Database.URL, func(tenantID, id string) {
+				metadataCache.Invalidate(cache.Key{TenantID: tenantID, FileID: id})
+			}, metadataCache.Purge); err != nil {
+				logger.Errorf(ctx, "Error listening to file changes, %v", err)
+			}
+		})
+	}
+	relationRepository := repository.NewSQLRelationRepository(db)
+	srv := service.NewCascadingService(
+		service.NewAuthorizingService(
+			service.NewContentTypeService(processing, cfg.ContentTypes.Allow),
+			jsoncodec.NewCodec(),
+			fr,
+			policyEngine,
+		),
+		relationRepository,
+		policyEngine,
+	)
+	accessRepository := repository.NewSQLAccessRepository(db)
+	if cfg.AccessLog.Enabled {
+		// the recording wraps the authorization to log the granted accesses only
+		writer, interval := newAccessWriter(cfg.AccessLog, accessRepository)
+		srv = service.NewAccessRecordingService(srv, writer)
+		workers.Go("access_log_writer", func(ctx context.Context) {
+			writer.Run(ctx, interval)
+		})
+	}
 
 	handler := handlers.NewFileServiceHandler(srv, jsoncodec.NewCodec())
 
 	if err := pb.FileProcessingServiceRegister(router, handler, endpoints); err != nil {
-		errCh <- err
+		errs.add("http handlers", err)
+	}
+
+	searchHandler := handlers.NewSearchHandler(service.NewSearchService(fr, searchRepository, policyEngine), jsoncodec.NewCodec())
+	if err := configs.ConfigureHandlerToEndpoints(router, searchHandler, handlers.NewSearchEndpoints()); err != nil {
+		errs.add("search handlers", err)
+	}
+
+	relationHandler := handlers.NewRelationHandler(service.NewRelationService(fr, relationRepository, policyEngine), jsoncodec.NewCodec())
+	if err := configs.ConfigureHandlerToEndpoints(router, relationHandler, handlers.NewRelationEndpoints()); err != nil {
+		errs.add("relation handlers", err)
+	}
+	collectionHandler := handlers.NewCollectionHandler(service.NewCollectionService(fr, repository.NewSQLCollectionRepository(db), policyEngine), jsoncodec.NewCodec())
+	if err := configs.ConfigureHandlerToEndpoints(router, collectionHandler, handlers.NewCollectionEndpoints()); err != nil {
+		errs.add("collection handlers", err)
+	}
+
+	if cfg.Expiry.Enabled {
+		expiryHandler := handlers.NewExpiryHandler(service.NewExpiringService(expiryRepository, policyEngine), jsoncodec.NewCodec())
+		if err := configs.ConfigureHandlerToEndpoints(router, expiryHandler, handlers.NewExpiryEndpoints()); err != nil {
+			errs.add("expiry handlers", err)
+		}
+	}
+
+	if cfg.AccessLog.Enabled {
+		accessHandler := handlers.NewAccessHandler(service.NewAccessLogService(accessRepository, policyEngine), jsoncodec.NewCodec())
+		if err := configs.ConfigureHandlerToEndpoints(router, accessHandler, handlers.NewAccessEndpoints()); err != nil {
+			errs.add("access handlers", err)
+		}
+	}
+
+	if cfg.Webhooks.Enabled {
+		webhookHandler := handlers.NewWebhookHandler(service.NewWebhookService(webhookRepository, policyEngine, net.DefaultResolver), jsoncodec.NewCodec())
+		if err := configs.ConfigureHandlerToEndpoints(router, webhookHandler, handlers.NewWebhookEndpoints()); err != nil {
+			errs.add("webhook handlers", err)
+		}
+	}
+
+	if cfg.Thumbnails.Enabled {
+		thumbnailHandler := handlers.NewThumbnailHandler(service.NewThumbnailService(fr, svc.Store("clean_region"), policyEngine, cfg.Thumbnails.Sizes))
+		if err := configs.ConfigureHandlerToEndpoints(router, thumbnailHandler, handlers.NewThumbnailEndpoints()); err != nil {
+			errs.add("thumbnail handlers", err)
+		}
+	}
+
+	if cfg.Archives.Enabled {
+		entryHandler := handlers.NewEntryHandler(service.NewEntryService(fr, entryRepository, policyEngine), jsoncodec.NewCodec())
+		if err := configs.ConfigureHandlerToEndpoints(router, entryHandler, handlers.NewEntryEndpoints()); err != nil {
+			errs.add("entry handlers", err)
+		}
 	}
 
 	if err := svc.Server().Handle(svc.Server().NewHandler(router)); err != nil {
-		errCh <- err
+		errs.add("http server", err)
 	}
 
+	grpcHandler := handlers.NewFileServiceGRPCHandler(srv, jsoncodec.NewCodec())
+
+	if err := pb.RegisterFileProcessingHandler(grpcServer, grpcHandler); err != nil {
+		errs.add("grpc handlers", err)
+	}
+
+	if err := errs.err(); err != nil {
+		return err
+	}
+
+	checks := newReadinessChecks(checkTimeout, db, tenants.Buckets(), svc.Store("dirty_region"), svc.Store("clean_region"))
+	health := stats.NewHealth(workers.Context(), checkInterval, checks)
+	health.AddReadinessCheck("shutdown", drainer.Check)
+
 	statsOpts := append([]stats.Option{},
-		stats.WithDefaultHealth(),
+		stats.WithHealth(health),
 		stats.WithMetrics(),
 		stats.WithVersionDate(cfg.Server.Name, time.Now().String()),
 	)
 
 	healthServer := stats.NewServer(statsOpts...)
 	go func() {
-		logger.Fatal(ctx, healthServer.Serve(cfg.Metric.Addr))
+		if err := healthServer.Serve(cfg.Metric.Addr); err != http.ErrServerClosed {
+			logger.Fatal(ctx, err)
+		}
 	}()
 
-	if err := svc.Run(); err != nil {
-		errCh <- err
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- svc.Run()
+	}()
+	var failure error
+	select {
+	case <-ctx.Done():
+	case failure = <-runErr:
+		if failure != nil {
+			failure = fmt.Errorf("Error running service, %v", failure)
+		}
+		runErr <- nil
+	}
+
+	shutdownService(ctx, drainer, drainTimeout, workers, workersTimeout, func() error {
+		stopRun()
+		return <-runErr
+	})
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := healthServer.Shutdown(shutdownCtx); err != nil {
+		logger.Errorf(ctx, "Error during shutdown of health server, %v", err)
+	}
+	return failure
+}
+
+// shutdownService flips the readiness and rejects the new requests, drains the requests
+// in flight, stops the servers with stop and then the workers after their current item
+func shutdownService(ctx context.Context, drainer *shutdown.Drainer, drainTimeout time.Duration, workers *shutdown.Workers, workersTimeout time.Duration, stop func() error) {
+	logger.Infof(ctx, "Shutting down, draining requests in flight for up to %s", drainTimeout)
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancelDrain()
+	for _, r := range drainer.Drain(drainCtx) {
+		logger.Errorf(ctx, "Aborting request in flight %s %s, started %s ago", r.Method, r.Path, time.Since(r.Start).Round(time.Millisecond))
+	}
+
+	if err := stop(); err != nil {
+		logger.Errorf(ctx, "Error during stop of servers, %v", err)
+	}
+
+	workersCtx, cancelWorkers := context.WithTimeout(context.Background(), workersTimeout)
+	defer cancelWorkers()
+	for _, name := range workers.Stop(workersCtx) {
+		logger.Errorf(ctx, "Aborting worker %s, it did not stop in %s", name, workersTimeout)
 	}
+	logger.Infof(ctx, "Requests drained and workers stopped, closing connections")
 }
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/unistack-org/micro/v3/api"
+	"github.com/unistack-org/micro/v3/codec"
+	"github.com/unistack-org/micro/v3/logger"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+// accessColumns are the header of the csv export of the access log
+var accessColumns = []string{"id", "accessed_at", "file_id", "action", "principal", "principal_kind", "ip", "user_agent", "request_id"}
+
+// NewAccessEndpoints returns the endpoints of the access log, they are bound to
+// the methods of AccessHandler like the generated file processing endpoints
+func NewAccessEndpoints() []*api.Endpoint {
+	return []*api.Endpoint{
+		{Name: "Access.ListFileAccess", Path: []string{"/files/{file_id}/access"}, Method: []string{"GET"}, Handler: "rpc"},
+		{Name: "Access.ListAccess", Path: []string{"/access"}, Method: []string{"GET"}, Handler: "rpc"},
+	}
+}
+
+// AccessHandler ...
+type AccessHandler struct {
+	codec   codec.Codec
+	service *service.AccessLogService
+}
+
+// NewAccessHandler ...
+func NewAccessHandler(srv *service.AccessLogService, codec codec.Codec) *AccessHandler {
+	return &AccessHandler{
+		service: srv,
+		codec:   codec,
+	}
+}
+
+// ListFileAccess lists who accessed the file, the events are filtered like by ListAccess
+func (ah *AccessHandler) ListFileAccess(w http.ResponseWriter, r *http.Request) {
+	q, ok := accessQuery(w, r)
+	if !ok {
+		return
+	}
+	q.FileID = mux.Vars(r)["file_id"]
+	ah.list(w, r, q)
+}
+
+// ListAccess lists the access events of ?principal= accessed since ?from= and
+// before ?to=, the times are in RFC 3339. The pages are read by ?cursor= and
+// ?limit=, ?format=csv exports all of the events at once.
+func (ah *AccessHandler) ListAccess(w http.ResponseWriter, r *http.Request) {
+	q, ok := accessQuery(w, r)
+	if !ok {
+		return
+	}
+	ah.list(w, r, q)
+}
+
+func (ah *AccessHandler) list(w http.ResponseWriter, r *http.Request, q *model.AccessQuery) {
+	params := r.URL.Query()
+	switch format := params.Get("format"); format {
+	case "", "json":
+	case "csv":
+		ah.export(w, r, q)
+		return
+	default:
+		apperrors.WriteProblem(w, r, apperrors.Invalid(apperrors.CodeInvalidRequest, "Unknown format %q", format))
+		return
+	}
+	limit := 0
+	if l := params.Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil {
+			apperrors.WriteProblem(w, r, apperrors.Invalid(apperrors.CodeInvalidRequest, "Invalid limit %s", l))
+			return
+		}
+	}
+	list, err := ah.service.Events(r.Context(), q, params.Get("cursor"), limit)
+	if err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	ah.codec.Write(w, nil, list)
+}
+
+// export streams the events as csv, the status is sent with the first event so
+// the failures of the authorization and of the first page are reported as problems
+func (ah *AccessHandler) export(w http.ResponseWriter, r *http.Request, q *model.AccessQuery) {
+	cw := csv.NewWriter(w)
+	started := false
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="access.csv"`)
+		w.WriteHeader(http.StatusOK)
+		return cw.Write(accessColumns)
+	}
+	err := ah.service.Export(r.Context(), q, func(e *model.AccessEvent) error {
+		if err := start(); err != nil {
+			return err
+		}
+		return cw.Write([]string{
+			strconv.FormatInt(e.ID, 10),
+			e.AccessedAt.UTC().Format(time.RFC3339Nano),
+			csvCell(e.FileID),
+			csvCell(e.Action),
+			csvCell(e.Principal),
+			csvCell(e.PrincipalKind),
+			csvCell(e.IP),
+			csvCell(e.UserAgent),
+			csvCell(e.RequestID),
+		})
+	})
+	if err != nil && !started {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	if err == nil {
+		err = start()
+	}
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+	// the status is sent, a failure can only cut the export short
+	if err != nil {
+		logger.Errorf(r.Context(), "Error exporting access log, %v", err)
+	}
+}
+
+// csvCell quotes the values which the spreadsheets would read as formulas, like
+// a user agent sent by the client starting with =
+func csvCell(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// accessQuery reads the filters of the access log, it writes the problem and
+// returns false when they are invalid
+func accessQuery(w http.ResponseWriter, r *http.Request) (*model.AccessQuery, bool) {
+	params := r.URL.Query()
+	q := &model.AccessQuery{Principal: params.Get("principal")}
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{
+		{"from", &q.From},
+		{"to", &q.To},
+	} {
+		v := params.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			apperrors.WriteProblem(w, r, apperrors.Invalid(apperrors.CodeInvalidRequest, "Invalid %s %s, expected RFC 3339", p.name, v))
+			return nil, false
+		}
+		*p.t = t
+	}
+	return q, true
+}
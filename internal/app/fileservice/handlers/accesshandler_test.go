@@ -0,0 +1,95 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	jsoncodec "github.com/unistack-org/micro-codec-json/v3"
+	"github.com/vielendanke/file-service/configs"
+	"github.com/vielendanke/file-service/internal/app/fileservice/handlers"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+func prepareAccessRouter(t *testing.T, accessRepo *mocks.AccessRepository) *mux.Router {
+	engine, err := policy.NewEngine("")
+	if err != nil {
+		t.Fatalf("Error creating policy engine, %v", err)
+	}
+	handler := handlers.NewAccessHandler(service.NewAccessLogService(accessRepo, engine), jsoncodec.NewCodec())
+	router := mux.NewRouter()
+	if err := configs.ConfigureHandlerToEndpoints(router, handler, handlers.NewAccessEndpoints()); err != nil {
+		t.Fatalf("Unable to configure endpoints, %v", err)
+	}
+	return router
+}
+
+func TestAccessHandler_ListFileAccess(t *testing.T) {
+	accessRepo := new(mocks.AccessRepository)
+	from := time.Date(2030, 5, 1, 0, 0, 0, 0, time.UTC)
+	accessRepo.On("FindAccessEvents", mock.Anything, &model.AccessQuery{FileID: "f1", From: from, Limit: 10}).Return([]*model.AccessEvent{
+		{ID: 3, FileID: "f1", Action: model.AccessDownload, Principal: "bob", AccessedAt: from.Add(time.Hour)},
+	}, nil)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/files/f1/access?from=2030-05-01T00:00:00Z&limit=10", nil)
+
+	prepareAccessRouter(t, accessRepo).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := model.AccessList{}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Len(t, body.Events, 1)
+	assert.Equal(t, "bob", body.Events[0].Principal)
+	assert.Empty(t, body.Next)
+	accessRepo.AssertExpectations(t)
+}
+
+func TestAccessHandler_ListAccess_CSV(t *testing.T) {
+	accessRepo := new(mocks.AccessRepository)
+	at := time.Date(2030, 5, 1, 12, 0, 0, 0, time.UTC)
+	accessRepo.On("FindAccessEvents", mock.Anything, &model.AccessQuery{Principal: "bob", Limit: 100}).Return([]*model.AccessEvent{
+		{ID: 2, FileID: "f1", Action: model.AccessRead, Principal: "bob", PrincipalKind: "user", IP: "10.0.0.1", UserAgent: "curl/7.68.0, beta", RequestID: "req-1", AccessedAt: at},
+	}, nil)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/access?principal=bob&format=csv", nil)
+
+	prepareAccessRouter(t, accessRepo).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "id,accessed_at,file_id,action,principal,principal_kind,ip,user_agent,request_id\n"+
+		"2,2030-05-01T12:00:00Z,f1,read,bob,user,10.0.0.1,\"curl/7.68.0, beta\",req-1\n", rec.Body.String())
+}
+
+func TestAccessHandler_ListAccess_CSVFormulas(t *testing.T) {
+	accessRepo := new(mocks.AccessRepository)
+	at := time.Date(2030, 5, 1, 12, 0, 0, 0, time.UTC)
+	accessRepo.On("FindAccessEvents", mock.Anything, &model.AccessQuery{Limit: 100}).Return([]*model.AccessEvent{
+		{ID: 2, FileID: "f1", Action: model.AccessRead, Principal: "@bob", PrincipalKind: "user", IP: "10.0.0.1", UserAgent: "=HYPERLINK(\"http://evil\")", RequestID: "-1+2", AccessedAt: at},
+	}, nil)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/access?format=csv", nil)
+
+	prepareAccessRouter(t, accessRepo).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "id,accessed_at,file_id,action,principal,principal_kind,ip,user_agent,request_id\n"+
+		"2,2030-05-01T12:00:00Z,f1,read,'@bob,user,10.0.0.1,\"'=HYPERLINK(\"\"http://evil\"\")\",'-1+2\n", rec.Body.String())
+}
+
+func TestAccessHandler_ListAccess_InvalidTime(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/access?from=yesterday", nil)
+
+	prepareAccessRouter(t, new(mocks.AccessRepository)).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
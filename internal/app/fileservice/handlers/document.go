@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/unistack-org/micro/v3/codec"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+	"github.com/vielendanke/file-service/internal/app/fileservice/validations"
+)
+
+// parseDocumentBody ...
+func parseDocumentBody(c codec.Codec, jsonBody string) (map[string]interface{}, error) {
+	if jsonBody == "" {
+		return nil, apperrors.Invalid(apperrors.CodeInvalidRequest, "Bad request, body is empty")
+	}
+	properties := make(map[string]interface{})
+	if err := c.Unmarshal([]byte(jsonBody), &properties); err != nil {
+		return nil, apperrors.Invalid(apperrors.CodeInvalidRequest, "Error unmarshalling request, %v", err)
+	}
+	if err := validations.ValidateJSONDocumentRequest(properties); err != nil {
+		return nil, err
+	}
+	return properties, nil
+}
+
+// newAWSModel builds the model of an upload, declaredType is the content type
+// sent by the client and may be empty
+func newAWSModel(file io.Reader, filename, declaredType string, properties map[string]interface{}) *model.AWSModel {
+	return &model.AWSModel{
+		File:         file,
+		FileName:     filename,
+		DeclaredType: declaredType,
+		DocClass:     properties["class"].(string),
+		DocType:      properties["type"].(string),
+		DocNum:       properties["number"].(string),
+		Metadata:     properties,
+	}
+}
+
+// storeDocument saves the metadata and then the file itself, removing the metadata
+// again if the file could not be stored
+func storeDocument(ctx context.Context, srv service.FileProcessingService, awsFile *model.AWSModel) error {
+	if err := srv.SaveFileData(ctx, awsFile); err != nil {
+		return fmt.Errorf("Error saving file metadata to DB, %w", err)
+	}
+	if err := srv.StoreFile(ctx, awsFile); err != nil {
+		if deleteErr := srv.DeleteMetadataByID(policy.NewSystemContext(ctx), awsFile.GetFileID()); deleteErr != nil {
+			return fmt.Errorf("Error storing file to s3, %w, and deleting its metadata, %v", err, deleteErr)
+		}
+		return fmt.Errorf("Error storing file to s3, %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/unistack-org/micro/v3/api"
+	"github.com/unistack-org/micro/v3/codec"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+// NewEntryEndpoints returns the endpoints of the archive entries, they are bound
+// to the methods of EntryHandler like the generated file processing endpoints
+func NewEntryEndpoints() []*api.Endpoint {
+	return []*api.Endpoint{
+		{Name: "Entries.ListEntries", Path: []string{"/files/{file_id}/entries"}, Method: []string{"GET"}, Handler: "rpc"},
+	}
+}
+
+// EntryHandler ...
+type EntryHandler struct {
+	codec   codec.Codec
+	service *service.EntryService
+}
+
+// NewEntryHandler ...
+func NewEntryHandler(srv *service.EntryService, codec codec.Codec) *EntryHandler {
+	return &EntryHandler{
+		service: srv,
+		codec:   codec,
+	}
+}
+
+// ListEntries ...
+func (eh *EntryHandler) ListEntries(w http.ResponseWriter, r *http.Request) {
+	entries, err := eh.service.Entries(r.Context(), mux.Vars(r)["file_id"])
+	if err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	eh.codec.Write(w, nil, entries)
+}
@@ -0,0 +1,63 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	jsoncodec "github.com/unistack-org/micro-codec-json/v3"
+	"github.com/vielendanke/file-service/configs"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/handlers"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+func prepareEntryRouter(t *testing.T, fileRepo *mocks.FileRepository, entryRepo *mocks.EntryRepository) *mux.Router {
+	engine, err := policy.NewEngine("")
+	if err != nil {
+		t.Fatalf("Error creating policy engine, %v", err)
+	}
+	handler := handlers.NewEntryHandler(service.NewEntryService(fileRepo, entryRepo, engine), jsoncodec.NewCodec())
+	router := mux.NewRouter()
+	if err := configs.ConfigureHandlerToEndpoints(router, handler, handlers.NewEntryEndpoints()); err != nil {
+		t.Fatalf("Unable to configure endpoints, %v", err)
+	}
+	return router
+}
+
+func TestEntryHandler_ListEntries(t *testing.T) {
+	fileRepo := new(mocks.FileRepository)
+	entryRepo := new(mocks.EntryRepository)
+	fileRepo.On("FindFileByID", mock.Anything, "f1").Return(&model.FileRecord{ID: "f1", DocClass: "contract"}, nil)
+	entryRepo.On("FindFileEntries", mock.Anything, "f1").Return([]*model.ArchiveEntry{
+		{Path: "invoice.pdf", Size: 2048, ContentType: "application/pdf", Depth: 1},
+	}, nil)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/files/f1/entries", nil)
+
+	prepareEntryRouter(t, fileRepo, entryRepo).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := []*model.ArchiveEntry{}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Len(t, body, 1)
+	assert.Equal(t, "invoice.pdf", body[0].Path)
+}
+
+func TestEntryHandler_ListEntries_NotFound(t *testing.T) {
+	fileRepo := new(mocks.FileRepository)
+	fileRepo.On("FindFileByID", mock.Anything, "f1").Return(nil, apperrors.NotFound(apperrors.CodeFileNotFound, "File f1 not found"))
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/files/f1/entries", nil)
+
+	prepareEntryRouter(t, fileRepo, new(mocks.EntryRepository)).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/unistack-org/micro/v3/api"
+	"github.com/unistack-org/micro/v3/codec"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+// NewExpiryEndpoints returns the endpoints of the expiring files, they are bound
+// to the methods of ExpiryHandler like the generated file processing endpoints
+func NewExpiryEndpoints() []*api.Endpoint {
+	return []*api.Endpoint{
+		{Name: "Expiry.ListExpiring", Path: []string{"/expiring"}, Method: []string{"GET"}, Handler: "rpc"},
+	}
+}
+
+// ExpiryHandler ...
+type ExpiryHandler struct {
+	codec   codec.Codec
+	service *service.ExpiringService
+}
+
+// NewExpiryHandler ...
+func NewExpiryHandler(srv *service.ExpiringService, codec codec.Codec) *ExpiryHandler {
+	return &ExpiryHandler{
+		service: srv,
+		codec:   codec,
+	}
+}
+
+// ListExpiring lists the files expiring within ?days=, 30 by default. ?expired=true
+// adds the files which have already expired, the pages are read by ?cursor= and ?limit=
+func (eh *ExpiryHandler) ListExpiring(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	days, limit := 0, 0
+	if d := params.Get("days"); d != "" {
+		var err error
+		if days, err = strconv.Atoi(d); err != nil {
+			apperrors.WriteProblem(w, r, apperrors.Invalid(apperrors.CodeInvalidRequest, "Invalid days %s", d))
+			return
+		}
+	}
+	if l := params.Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil {
+			apperrors.WriteProblem(w, r, apperrors.Invalid(apperrors.CodeInvalidRequest, "Invalid limit %s", l))
+			return
+		}
+	}
+	expired := false
+	if e := params.Get("expired"); e != "" {
+		var err error
+		if expired, err = strconv.ParseBool(e); err != nil {
+			apperrors.WriteProblem(w, r, apperrors.Invalid(apperrors.CodeInvalidRequest, "Invalid expired %s", e))
+			return
+		}
+	}
+	list, err := eh.service.Expiring(r.Context(), days, expired, params.Get("cursor"), limit)
+	if err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	eh.codec.Write(w, nil, list)
+}
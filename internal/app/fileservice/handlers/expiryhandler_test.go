@@ -0,0 +1,60 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	jsoncodec "github.com/unistack-org/micro-codec-json/v3"
+	"github.com/vielendanke/file-service/configs"
+	"github.com/vielendanke/file-service/internal/app/fileservice/handlers"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+func prepareExpiryRouter(t *testing.T, expiryRepo *mocks.ExpiryRepository) *mux.Router {
+	engine, err := policy.NewEngine("")
+	if err != nil {
+		t.Fatalf("Error creating policy engine, %v", err)
+	}
+	handler := handlers.NewExpiryHandler(service.NewExpiringService(expiryRepo, engine), jsoncodec.NewCodec())
+	router := mux.NewRouter()
+	if err := configs.ConfigureHandlerToEndpoints(router, handler, handlers.NewExpiryEndpoints()); err != nil {
+		t.Fatalf("Unable to configure endpoints, %v", err)
+	}
+	return router
+}
+
+func TestExpiryHandler_ListExpiring(t *testing.T) {
+	expiryRepo := new(mocks.ExpiryRepository)
+	expiryRepo.On("FindExpiring", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time"), 0, 10).Return([]*model.ExpiringFile{
+		{ID: "f1", FileName: "passport.pdf", DocClass: "passport", Field: "valid_until", ExpiresAt: time.Now().Add(24 * time.Hour)},
+	}, nil)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/expiring?days=7&limit=10", nil)
+
+	prepareExpiryRouter(t, expiryRepo).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := model.ExpiringList{}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Len(t, body.Files, 1)
+	assert.Equal(t, "valid_until", body.Files[0].Field)
+	assert.False(t, body.Files[0].Expired)
+}
+
+func TestExpiryHandler_ListExpiring_InvalidDays(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/expiring?days=soon", nil)
+
+	prepareExpiryRouter(t, new(mocks.ExpiryRepository)).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/unistack-org/micro/v3/codec"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+	pb "github.com/vielendanke/file-service/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// chunkSize is the size of a single FileChunk sent by DownloadFileStream
+const chunkSize = 64 << 10
+
+// FileServiceGRPCHandler ...
+type FileServiceGRPCHandler struct {
+	codec   codec.Codec
+	service service.FileProcessingService
+}
+
+// NewFileServiceGRPCHandler ...
+func NewFileServiceGRPCHandler(srv service.FileProcessingService, codec codec.Codec) *FileServiceGRPCHandler {
+	return &FileServiceGRPCHandler{
+		service: srv,
+		codec:   codec,
+	}
+}
+
+// FileProcessing ...
+func (fh *FileServiceGRPCHandler) FileProcessing(ctx context.Context, req *pb.FileProcessingRequest, rsp *pb.FileProcessingResponse) error {
+	properties, err := parseDocumentBody(fh.codec, req.GetBody())
+	if err != nil {
+		return apperrors.GRPCError(ctx, err)
+	}
+	awsFile := newAWSModel(bytes.NewReader(req.GetFile()), req.GetFilename(), req.GetContentType(), properties)
+	if err := storeDocument(ctx, fh.service, awsFile); err != nil {
+		return apperrors.GRPCError(ctx, err)
+	}
+	rsp.Result = awsFile.GetFileID()
+	return nil
+}
+
+// UploadFile receives the filename and body in the first message of the stream,
+// the file itself is read from the chunks of all the messages
+func (fh *FileServiceGRPCHandler) UploadFile(ctx context.Context, stream pb.FileProcessing_UploadFileStream) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return apperrors.GRPCError(ctx, apperrors.Invalid(apperrors.CodeInvalidRequest, "Error reading upload stream, %v", err))
+	}
+	properties, err := parseDocumentBody(fh.codec, req.GetBody())
+	if err != nil {
+		return apperrors.GRPCError(ctx, err)
+	}
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		chunk := req.GetChunk()
+		for {
+			if len(chunk) > 0 {
+				if _, err := pw.Write(chunk); err != nil {
+					return
+				}
+			}
+			next, err := stream.Recv()
+			if err == io.EOF {
+				pw.Close()
+				return
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			chunk = next.GetChunk()
+		}
+	}()
+	file := &countingReader{r: pr}
+	awsFile := newAWSModel(file, req.GetFilename(), req.GetContentType(), properties)
+	if err := storeDocument(ctx, fh.service, awsFile); err != nil {
+		return apperrors.GRPCError(ctx, err)
+	}
+	return stream.Send(&pb.UploadFileResponse{
+		FileId: awsFile.GetFileID(),
+		Size:   file.n,
+	})
+}
+
+// GetFileMetadata ...
+func (fh *FileServiceGRPCHandler) GetFileMetadata(ctx context.Context, req *pb.GetMetadataRequest, rsp *pb.GetMetadataResponse) error {
+	metadata, err := fh.service.GetFileMetadata(ctx, req.GetMetadataId())
+	if err != nil {
+		return apperrors.GRPCError(ctx, err)
+	}
+	rsp.MetadataId = req.GetMetadataId()
+	rsp.DocClass, _ = metadata["class"].(string)
+	rsp.DocType, _ = metadata["type"].(string)
+	rsp.DocNum, _ = metadata["number"].(string)
+	st, err := structpb.NewStruct(metadata)
+	if err != nil {
+		return apperrors.GRPCError(ctx, fmt.Errorf("Error converting metadata, %v", err))
+	}
+	rsp.Metadata = st
+	return nil
+}
+
+// DownloadFile ...
+func (fh *FileServiceGRPCHandler) DownloadFile(ctx context.Context, req *pb.FileDownloadRequest, rsp *pb.FileDownloadResponse) error {
+	body, info, err := fh.service.DownloadFile(ctx, req.GetFileDownloadId())
+	if err != nil {
+		return apperrors.GRPCError(ctx, err)
+	}
+	defer body.Close()
+	// the unary response holds the whole file, DownloadFileStream does not
+	file, err := ioutil.ReadAll(body)
+	if err != nil {
+		return apperrors.GRPCError(ctx, err)
+	}
+	rsp.Filename = info.FileName
+	rsp.ContentType = info.ContentType
+	rsp.File = file
+	return nil
+}
+
+// DownloadFileStream sends the file in chunks, the first one also carries the filename
+// and the content type
+func (fh *FileServiceGRPCHandler) DownloadFileStream(ctx context.Context, req *pb.FileDownloadRequest, stream pb.FileProcessing_DownloadFileStreamStream) error {
+	body, info, err := fh.service.DownloadFile(ctx, req.GetFileDownloadId())
+	if err != nil {
+		return apperrors.GRPCError(ctx, err)
+	}
+	defer body.Close()
+	msg := &pb.FileChunk{
+		Filename:    info.FileName,
+		ContentType: info.ContentType,
+	}
+	for first := true; ; first = false {
+		// the streams may hold on to the sent chunks, every one gets its own buffer
+		buf := make([]byte, chunkSize)
+		n, err := io.ReadFull(body, buf)
+		if err == io.EOF && !first {
+			return nil
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return apperrors.GRPCError(ctx, err)
+		}
+		msg.Chunk = buf[:n]
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+		if n < chunkSize {
+			return nil
+		}
+		msg = &pb.FileChunk{}
+	}
+}
+
+// UpdateFileMetadata ...
+func (fh *FileServiceGRPCHandler) UpdateFileMetadata(ctx context.Context, req *pb.UpdateMetadataRequest, rsp *pb.UpdateMetadataResponse) error {
+	if req.GetMetadata() == nil {
+		return apperrors.GRPCError(ctx, apperrors.Invalid(apperrors.CodeInvalidMetadata, "Error, metadata is nil"))
+	}
+	if err := fh.service.UpdateFileMetadata(ctx, req.GetMetadata().AsMap(), req.GetUpdateMetadataId()); err != nil {
+		return apperrors.GRPCError(ctx, fmt.Errorf("Error updating metadata, %w", err))
+	}
+	rsp.Metadata = req.GetMetadata()
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
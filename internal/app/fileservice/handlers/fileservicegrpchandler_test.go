@@ -0,0 +1,228 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	jsoncodec "github.com/unistack-org/micro-codec-json/v3"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/handlers"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	pb "github.com/vielendanke/file-service/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type uploadStream struct {
+	ctx  context.Context
+	reqs []*pb.UploadFileRequest
+	rsp  *pb.UploadFileResponse
+}
+
+func (s *uploadStream) Context() context.Context      { return s.ctx }
+func (s *uploadStream) SendMsg(msg interface{}) error { return nil }
+func (s *uploadStream) RecvMsg(msg interface{}) error { return nil }
+func (s *uploadStream) Close() error                  { return nil }
+
+func (s *uploadStream) Recv() (*pb.UploadFileRequest, error) {
+	if len(s.reqs) == 0 {
+		return nil, io.EOF
+	}
+	req := s.reqs[0]
+	s.reqs = s.reqs[1:]
+	return req, nil
+}
+
+func (s *uploadStream) Send(msg *pb.UploadFileResponse) error {
+	s.rsp = msg
+	return nil
+}
+
+type downloadStream struct {
+	ctx    context.Context
+	chunks []*pb.FileChunk
+}
+
+func (s *downloadStream) Context() context.Context      { return s.ctx }
+func (s *downloadStream) SendMsg(msg interface{}) error { return nil }
+func (s *downloadStream) RecvMsg(msg interface{}) error { return nil }
+func (s *downloadStream) Close() error                  { return nil }
+
+func (s *downloadStream) Send(msg *pb.FileChunk) error {
+	s.chunks = append(s.chunks, msg)
+	return nil
+}
+
+func prepareDocumentBody(t *testing.T) string {
+	body, err := json.Marshal(map[string]interface{}{
+		"class":  "class",
+		"type":   "type",
+		"number": "number",
+	})
+	if err != nil {
+		t.Fatalf("Unable to marshal body, %v", err)
+	}
+	return string(body)
+}
+
+func TestFileServiceGRPCHandler_FileProcessing(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	handler := handlers.NewFileServiceGRPCHandler(mockService, jsoncodec.NewCodec())
+
+	mockService.On("SaveFileData", mock.Anything, mock.Anything).Return(nil)
+	mockService.On("StoreFile", mock.Anything, mock.Anything).Return(nil)
+
+	rsp := &pb.FileProcessingResponse{}
+	err := handler.FileProcessing(context.Background(), &pb.FileProcessingRequest{
+		Filename: "file.txt",
+		File:     []byte("testData"),
+		Body:     prepareDocumentBody(t),
+	}, rsp)
+
+	assert.Nil(t, err)
+	mockService.AssertExpectations(t)
+}
+
+func TestFileServiceGRPCHandler_FileProcessing_NotValidBody(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	handler := handlers.NewFileServiceGRPCHandler(mockService, jsoncodec.NewCodec())
+
+	err := handler.FileProcessing(context.Background(), &pb.FileProcessingRequest{
+		Filename: "file.txt",
+		Body:     `{"class":"class"}`,
+	}, &pb.FileProcessingResponse{})
+
+	assert.NotNil(t, err)
+	mockService.AssertExpectations(t)
+}
+
+func TestFileServiceGRPCHandler_UploadFile(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	handler := handlers.NewFileServiceGRPCHandler(mockService, jsoncodec.NewCodec())
+	stream := &uploadStream{
+		ctx: context.Background(),
+		reqs: []*pb.UploadFileRequest{
+			{Filename: "file.txt", Body: prepareDocumentBody(t), Chunk: []byte("test")},
+			{Chunk: []byte("Data")},
+		},
+	}
+	stored := []byte{}
+
+	mockService.On("SaveFileData", mock.Anything, mock.Anything).Return(nil)
+	mockService.On("StoreFile", mock.Anything, mock.Anything).Return(func(ctx context.Context, f model.FileModel) error {
+		var err error
+		stored, err = ioutil.ReadAll(f.GetFile())
+		return err
+	})
+
+	err := handler.UploadFile(context.Background(), stream)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "testData", string(stored))
+	assert.Equal(t, int64(len(stored)), stream.rsp.GetSize())
+	mockService.AssertExpectations(t)
+}
+
+func TestFileServiceGRPCHandler_UploadFile_ServiceSaveFileDataReturnError(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	handler := handlers.NewFileServiceGRPCHandler(mockService, jsoncodec.NewCodec())
+	stream := &uploadStream{
+		ctx: context.Background(),
+		reqs: []*pb.UploadFileRequest{
+			{Filename: "file.txt", Body: prepareDocumentBody(t), Chunk: []byte("testData")},
+		},
+	}
+
+	mockService.On("SaveFileData", mock.Anything, mock.Anything).Return(fmt.Errorf("Error"))
+
+	err := handler.UploadFile(context.Background(), stream)
+
+	assert.NotNil(t, err)
+	assert.Nil(t, stream.rsp)
+	mockService.AssertExpectations(t)
+}
+
+func TestFileServiceGRPCHandler_GetFileMetadata(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	handler := handlers.NewFileServiceGRPCHandler(mockService, jsoncodec.NewCodec())
+	testData := map[string]interface{}{
+		"class":  "class",
+		"type":   "type",
+		"number": "number",
+		"iin":    "iin",
+	}
+
+	mockService.On("GetFileMetadata", mock.Anything, "testID").Return(testData, nil)
+
+	rsp := &pb.GetMetadataResponse{}
+	err := handler.GetFileMetadata(context.Background(), &pb.GetMetadataRequest{MetadataId: "testID"}, rsp)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "class", rsp.GetDocClass())
+	assert.Equal(t, "iin", rsp.GetMetadata().AsMap()["iin"])
+	mockService.AssertExpectations(t)
+}
+
+func TestFileServiceGRPCHandler_GetFileMetadata_ServiceReturnsNotFound(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	handler := handlers.NewFileServiceGRPCHandler(mockService, jsoncodec.NewCodec())
+
+	mockService.On("GetFileMetadata", mock.Anything, "testID").Return(
+		nil, apperrors.NotFound(apperrors.CodeFileNotFound, "File not found"),
+	)
+
+	err := handler.GetFileMetadata(context.Background(), &pb.GetMetadataRequest{MetadataId: "testID"}, &pb.GetMetadataResponse{})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	mockService.AssertExpectations(t)
+}
+
+func TestFileServiceGRPCHandler_DownloadFileStream(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	handler := handlers.NewFileServiceGRPCHandler(mockService, jsoncodec.NewCodec())
+	file := bytes.Repeat([]byte("a"), 100<<10)
+	stream := &downloadStream{ctx: context.Background()}
+
+	mockService.On("DownloadFile", mock.Anything, "testID").Return(ioutil.NopCloser(bytes.NewReader(file)), &model.ObjectInfo{FileName: "file.txt", ContentType: "text/plain"}, nil)
+
+	err := handler.DownloadFileStream(context.Background(), &pb.FileDownloadRequest{FileDownloadId: "testID"}, stream)
+
+	assert.Nil(t, err)
+	assert.Len(t, stream.chunks, 2)
+	assert.Equal(t, "file.txt", stream.chunks[0].GetFilename())
+	assert.Equal(t, "text/plain", stream.chunks[0].GetContentType())
+	received := []byte{}
+	for _, c := range stream.chunks {
+		received = append(received, c.GetChunk()...)
+	}
+	assert.Equal(t, file, received)
+	mockService.AssertExpectations(t)
+}
+
+func TestFileServiceGRPCHandler_UpdateFileMetadata(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	handler := handlers.NewFileServiceGRPCHandler(mockService, jsoncodec.NewCodec())
+	metadata := map[string]interface{}{"iin": "iin"}
+	st, err := structpb.NewStruct(metadata)
+	if err != nil {
+		t.Fatalf("Error preparing metadata, %v", err)
+	}
+
+	mockService.On("UpdateFileMetadata", mock.Anything, metadata, "testID").Return(nil)
+
+	rsp := &pb.UpdateMetadataResponse{}
+	err = handler.UpdateFileMetadata(context.Background(), &pb.UpdateMetadataRequest{UpdateMetadataId: "testID", Metadata: st}, rsp)
+
+	assert.Nil(t, err)
+	assert.Equal(t, st, rsp.GetMetadata())
+	mockService.AssertExpectations(t)
+}
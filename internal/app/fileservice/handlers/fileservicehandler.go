@@ -2,13 +2,15 @@ package handlers
 
 import (
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	"github.com/unistack-org/micro/v3/codec"
-	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/unistack-org/micro/v3/logger"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
 	"github.com/vielendanke/file-service/internal/app/fileservice/service"
-	"github.com/vielendanke/file-service/internal/app/fileservice/validations"
 )
 
 // FileServiceHandler ...
@@ -30,48 +32,17 @@ func (fh *FileServiceHandler) FileProcessing(w http.ResponseWriter, r *http.Requ
 	r.ParseMultipartForm(10 << 20)
 	file, header, err := r.FormFile("file")
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fh.codec.Write(w, nil, fmt.Sprintf("Failed to parse request file, %v", err))
+		apperrors.WriteProblem(w, r, apperrors.Invalid(apperrors.CodeInvalidRequest, "Failed to parse request file, %v", err))
 		return
 	}
-	jsonBody := r.FormValue("body")
-	if jsonBody == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		fh.codec.Write(w, nil, fmt.Sprintf("Bad request, body is empty"))
-		return
-	}
-	properties := make(map[string]interface{})
-	if err := fh.codec.Unmarshal([]byte(jsonBody), &properties); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fh.codec.Write(w, nil, fmt.Sprintf("Error unmarshalling request, %v", err))
-		return
-	}
-	if err := validations.ValidateJSONDocumentRequest(properties); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fh.codec.Write(w, nil, err.Error())
-		return
-	}
-	awsFile := &model.AWSModel{
-		File:     file,
-		FileName: header.Filename,
-		DocClass: properties["class"].(string),
-		DocType:  properties["type"].(string),
-		DocNum:   properties["number"].(string),
-		Metadata: properties,
-	}
-	if saveErr := fh.service.SaveFileData(r.Context(), awsFile); saveErr != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		fh.codec.Write(w, nil, fmt.Sprintf("Error saving file metadata to DB, %v", saveErr))
+	properties, err := parseDocumentBody(fh.codec, r.FormValue("body"))
+	if err != nil {
+		apperrors.WriteProblem(w, r, err)
 		return
 	}
-	if err := fh.service.StoreFile(r.Context(), awsFile); err != nil {
-		if deleteErr := fh.service.DeleteMetadataByID(r.Context(), awsFile.GetFileID()); deleteErr != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			fh.codec.Write(w, nil, fmt.Sprintf("Error storing file %v and delete metadata %v", err, deleteErr))
-			return
-		}
-		w.WriteHeader(http.StatusInternalServerError)
-		fh.codec.Write(w, nil, fmt.Sprintf("Error storing file to s3, %v", err))
+	awsFile := newAWSModel(file, header.Filename, header.Header.Get("Content-Type"), properties)
+	if err := storeDocument(r.Context(), fh.service, awsFile); err != nil {
+		apperrors.WriteProblem(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
@@ -83,26 +54,42 @@ func (fh *FileServiceHandler) GetFileMetadata(w http.ResponseWriter, r *http.Req
 	id := mux.Vars(r)["metadata_id"]
 	metadata, err := fh.service.GetFileMetadata(r.Context(), id)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		fh.codec.Write(w, nil, err.Error())
+		apperrors.WriteProblem(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 	fh.codec.Write(w, nil, metadata)
 }
 
-// DownloadFile ...
+// DownloadFile serves the file with its detected content type, ?disposition=inline
+// lets browsers render it instead of saving it
 func (fh *FileServiceHandler) DownloadFile(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["file_download_id"]
-	file, filename, err := fh.service.DownloadFile(r.Context(), id)
+	disposition := r.URL.Query().Get("disposition")
+	switch disposition {
+	case "":
+		disposition = "attachment"
+	case "attachment", "inline":
+	default:
+		apperrors.WriteProblem(w, r, apperrors.Invalid(apperrors.CodeInvalidRequest, "Unknown disposition %q", disposition))
+		return
+	}
+	body, info, err := fh.service.DownloadFile(r.Context(), id)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		fh.codec.Write(w, nil, err.Error())
+		apperrors.WriteProblem(w, r, err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	w.Write(file)
+	defer body.Close()
+	w.Header().Set("Content-Type", info.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": info.FileName}))
+	// the stored type is served as is and inline content never runs with the origin of the service
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.WriteHeader(http.StatusOK)
+	// the status is sent, a failure can only cut the response short
+	if _, err := io.Copy(w, body); err != nil {
+		logger.Errorf(r.Context(), "Error streaming file %s, %v", id, err)
+	}
 }
 
 // UpdateFileMetadata ...
@@ -110,20 +97,17 @@ func (fh *FileServiceHandler) UpdateFileMetadata(w http.ResponseWriter, r *http.
 	id := mux.Vars(r)["update_metadata_id"]
 	properties := make(map[string]interface{})
 	if r.Body == nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fh.codec.Write(w, nil, "Error, body is nil")
+		apperrors.WriteProblem(w, r, apperrors.Invalid(apperrors.CodeInvalidMetadata, "Error, body is nil"))
 		return
 	}
 	err := fh.codec.ReadBody(r.Body, &properties)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fh.codec.Write(w, nil, fmt.Sprintf("Error reading body, %v", err))
+		apperrors.WriteProblem(w, r, apperrors.Invalid(apperrors.CodeInvalidMetadata, "Error reading body, %v", err))
 		return
 	}
 	defer r.Body.Close()
 	if uErr := fh.service.UpdateFileMetadata(r.Context(), properties, id); uErr != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		fh.codec.Write(w, nil, fmt.Sprintf("Error updating metadata, %v", uErr))
+		apperrors.WriteProblem(w, r, fmt.Errorf("Error updating metadata, %w", uErr))
 		return
 	}
 	w.WriteHeader(http.StatusOK)
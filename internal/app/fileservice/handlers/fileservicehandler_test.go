@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
@@ -16,9 +18,11 @@ import (
 	jsoncodec "github.com/unistack-org/micro-codec-json/v3"
 	"github.com/unistack-org/micro/v3/codec"
 	"github.com/vielendanke/file-service/configs"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
 	"github.com/vielendanke/file-service/internal/app/fileservice/handlers"
 	"github.com/vielendanke/file-service/internal/app/fileservice/middlewares"
 	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
 	"github.com/vielendanke/file-service/internal/app/fileservice/service"
 	pb "github.com/vielendanke/file-service/proto"
 )
@@ -127,7 +131,7 @@ func TestFileServiceHandler_FileProcessing_FileServiceStoreFileReturnError(t *te
 	router.ServeHTTP(rec, req)
 
 	assert.Equal(t, http.StatusInternalServerError, rec.Result().StatusCode)
-	assert.Equal(t, "application/json", rec.Header()["Content-Type"][0])
+	assert.Equal(t, "application/problem+json", rec.Header()["Content-Type"][0])
 	mockService.AssertExpectations(t)
 }
 
@@ -163,7 +167,41 @@ func TestFileServiceHandler_FileProcessing_FileServiceStoreFileAndDeleteMetadata
 	router.ServeHTTP(rec, req)
 
 	assert.Equal(t, http.StatusInternalServerError, rec.Result().StatusCode)
-	assert.Equal(t, "application/json", rec.Header()["Content-Type"][0])
+	assert.Equal(t, "application/problem+json", rec.Header()["Content-Type"][0])
+	mockService.AssertExpectations(t)
+}
+
+func TestFileServiceHandler_FileProcessing_StoreFileInvalidAndDeleteMetadataByIDReturnError(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	jsonBody := make(map[string]interface{})
+	jsonBody["class"] = "class"
+	jsonBody["type"] = "type"
+	jsonBody["number"] = "number"
+
+	writer, body, err := prepareMultipartRequest(jsonBody)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	router, routerErr := prepareRouter(mockService, jsoncodec.NewCodec())
+	if routerErr != nil {
+		t.Fatal(routerErr.Error())
+	}
+	rec := httptest.NewRecorder()
+
+	req, reqErr := http.NewRequest(http.MethodPost, "/files", body)
+	if reqErr != nil {
+		t.Fatalf("Error creating http request, %v", reqErr)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	mockService.On("SaveFileData", mock.Anything, mock.Anything).Return(nil)
+	mockService.On("StoreFile", mock.Anything, mock.Anything).Return(apperrors.Invalid(apperrors.CodeInvalidRequest, "File is empty"))
+	mockService.On("DeleteMetadataByID", mock.Anything, mock.AnythingOfType("string")).Return(fmt.Errorf("error message"))
+
+	router.ServeHTTP(rec, req)
+
+	// the failed cleanup does not hide the kind of the failure of the upload
+	assert.Equal(t, http.StatusBadRequest, rec.Result().StatusCode)
 	mockService.AssertExpectations(t)
 }
 
@@ -197,7 +235,41 @@ func TestFileServiceHandler_FileProcessing_FileServiceSaveFileDataReturnError(t
 	router.ServeHTTP(rec, req)
 
 	assert.Equal(t, http.StatusInternalServerError, rec.Result().StatusCode)
-	assert.Equal(t, "application/json", rec.Header()["Content-Type"][0])
+	assert.Equal(t, "application/problem+json", rec.Header()["Content-Type"][0])
+	mockService.AssertExpectations(t)
+}
+
+func TestFileServiceHandler_FileProcessing_FileServiceSaveFileDataReturnAlreadyExists(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	jsonBody := make(map[string]interface{})
+	jsonBody["class"] = "class"
+	jsonBody["type"] = "type"
+	jsonBody["number"] = "number"
+
+	writer, body, err := prepareMultipartRequest(jsonBody)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	router, routerErr := prepareRouter(mockService, jsoncodec.NewCodec())
+	if routerErr != nil {
+		t.Fatal(routerErr.Error())
+	}
+	rec := httptest.NewRecorder()
+
+	req, reqErr := http.NewRequest(http.MethodPost, "/files", body)
+	if reqErr != nil {
+		t.Fatalf("Error creating http request, %v", reqErr)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	mockService.On("SaveFileData", mock.Anything, mock.Anything).Return(
+		apperrors.AlreadyExists(apperrors.CodeFileAlreadyExists, "File already exists"),
+	)
+
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusConflict, rec.Result().StatusCode)
+	assert.Equal(t, "application/problem+json", rec.Header()["Content-Type"][0])
 	mockService.AssertExpectations(t)
 }
 
@@ -219,7 +291,7 @@ func TestFileServiceHandler_FileProcessing_NoFile(t *testing.T) {
 	router.ServeHTTP(rec, req)
 
 	assert.Equal(t, http.StatusBadRequest, rec.Result().StatusCode)
-	assert.Equal(t, "application/json", rec.Header()["Content-Type"][0])
+	assert.Equal(t, "application/problem+json", rec.Header()["Content-Type"][0])
 	mockService.AssertExpectations(t)
 }
 
@@ -246,7 +318,7 @@ func TestFileServiceHandler_FileProcessing_NoBody(t *testing.T) {
 	router.ServeHTTP(rec, req)
 
 	assert.Equal(t, http.StatusBadRequest, rec.Result().StatusCode)
-	assert.Equal(t, "application/json", rec.Header()["Content-Type"][0])
+	assert.Equal(t, "application/problem+json", rec.Header()["Content-Type"][0])
 	mockService.AssertExpectations(t)
 }
 
@@ -276,7 +348,7 @@ func TestFileServiceHandler_FileProcessing_NotValidBody(t *testing.T) {
 	router.ServeHTTP(rec, req)
 
 	assert.Equal(t, http.StatusBadRequest, rec.Result().StatusCode)
-	assert.Equal(t, "application/json", rec.Header()["Content-Type"][0])
+	assert.Equal(t, "application/problem+json", rec.Header()["Content-Type"][0])
 	mockService.AssertExpectations(t)
 }
 
@@ -330,6 +402,37 @@ func TestFileServiceHandler_GetFileMetadataByID_ServiceReturnsError(t *testing.T
 	mockService.AssertExpectations(t)
 }
 
+func TestFileServiceHandler_GetFileMetadataByID_ServiceReturnsNotFound(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+
+	router, err := prepareRouter(mockService, jsoncodec.NewCodec())
+	if err != nil {
+		t.Fatalf("Error preparing router, %v", err)
+	}
+	rec := httptest.NewRecorder()
+
+	req, reqErr := http.NewRequest(http.MethodGet, fmt.Sprintf("/metadata/%s", uuid.New().String()), nil)
+	if reqErr != nil {
+		t.Fatalf("Error creating request, %v", reqErr)
+	}
+
+	mockService.On("GetFileMetadata", mock.Anything, mock.AnythingOfType("string")).Return(
+		nil, apperrors.NotFound(apperrors.CodeFileNotFound, "File not found"),
+	)
+
+	router.ServeHTTP(rec, req)
+
+	problem := &apperrors.Problem{}
+	if err := json.NewDecoder(rec.Body).Decode(problem); err != nil {
+		t.Fatalf("Error decoding problem, %v", err)
+	}
+	assert.Equal(t, http.StatusNotFound, rec.Result().StatusCode)
+	assert.Equal(t, apperrors.ProblemContentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, apperrors.CodeFileNotFound, problem.Code)
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+	mockService.AssertExpectations(t)
+}
+
 func TestFileServiceHandler_GetFileMetadataByID_NoID(t *testing.T) {
 	mockService := new(mocks.FileProcessingService)
 
@@ -364,12 +467,14 @@ func TestFileServiceHandler_DownloadFile(t *testing.T) {
 	}
 	rec := httptest.NewRecorder()
 
-	mockService.On("DownloadFile", mock.Anything, mock.AnythingOfType("string")).Return([]byte(testData), testData, nil)
+	mockService.On("DownloadFile", mock.Anything, mock.AnythingOfType("string")).Return(ioutil.NopCloser(strings.NewReader(testData)), &model.ObjectInfo{FileName: testData, ContentType: "text/plain"}, nil)
 
 	router.ServeHTTP(rec, req)
 
-	assert.Contains(t, rec.Result().Header.Get("Content-Disposition"), testData)
-	assert.Equal(t, "application/octet-stream", rec.Result().Header.Get("Content-Type"))
+	assert.Equal(t, testData, rec.Body.String())
+	assert.Equal(t, "attachment; filename=file.txt", rec.Result().Header.Get("Content-Disposition"))
+	assert.Equal(t, "text/plain", rec.Result().Header.Get("Content-Type"))
+	assert.Equal(t, "nosniff", rec.Result().Header.Get("X-Content-Type-Options"))
 	assert.Equal(t, http.StatusOK, rec.Result().StatusCode)
 	mockService.AssertExpectations(t)
 }
@@ -387,7 +492,7 @@ func TestFileServiceHandler_DownloadFile_ServicReturnError(t *testing.T) {
 	}
 	rec := httptest.NewRecorder()
 
-	mockService.On("DownloadFile", mock.Anything, mock.AnythingOfType("string")).Return(nil, "", fmt.Errorf("Error"))
+	mockService.On("DownloadFile", mock.Anything, mock.AnythingOfType("string")).Return(nil, nil, fmt.Errorf("Error"))
 
 	router.ServeHTTP(rec, req)
 
@@ -395,6 +500,49 @@ func TestFileServiceHandler_DownloadFile_ServicReturnError(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestFileServiceHandler_DownloadFile_Inline(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+
+	router, err := prepareRouter(mockService, jsoncodec.NewCodec())
+	if err != nil {
+		t.Fatalf("Error preparing router, %v", err)
+	}
+	req, reqErr := http.NewRequest(http.MethodGet, fmt.Sprintf("/files/%s?disposition=inline", uuid.New().String()), nil)
+	if reqErr != nil {
+		t.Fatalf("Error creating request, %v", reqErr)
+	}
+	rec := httptest.NewRecorder()
+
+	mockService.On("DownloadFile", mock.Anything, mock.AnythingOfType("string")).Return(ioutil.NopCloser(strings.NewReader("%PDF-")), &model.ObjectInfo{FileName: "invoice 1.pdf", ContentType: "application/pdf"}, nil)
+
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Result().StatusCode)
+	assert.Equal(t, `inline; filename="invoice 1.pdf"`, rec.Result().Header.Get("Content-Disposition"))
+	assert.Equal(t, "application/pdf", rec.Result().Header.Get("Content-Type"))
+	assert.Equal(t, "sandbox", rec.Result().Header.Get("Content-Security-Policy"))
+	mockService.AssertExpectations(t)
+}
+
+func TestFileServiceHandler_DownloadFile_UnknownDisposition(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+
+	router, err := prepareRouter(mockService, jsoncodec.NewCodec())
+	if err != nil {
+		t.Fatalf("Error preparing router, %v", err)
+	}
+	req, reqErr := http.NewRequest(http.MethodGet, fmt.Sprintf("/files/%s?disposition=render", uuid.New().String()), nil)
+	if reqErr != nil {
+		t.Fatalf("Error creating request, %v", reqErr)
+	}
+	rec := httptest.NewRecorder()
+
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Result().StatusCode)
+	mockService.AssertExpectations(t)
+}
+
 func TestFileServiceHandler_DownloadFile_NoID(t *testing.T) {
 	mockService := new(mocks.FileProcessingService)
 
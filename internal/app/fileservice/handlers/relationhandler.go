@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/unistack-org/micro/v3/api"
+	"github.com/unistack-org/micro/v3/codec"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+// NewRelationEndpoints returns the endpoints of the relations and the legal holds
+// of the files, they are bound to the methods of RelationHandler
+func NewRelationEndpoints() []*api.Endpoint {
+	return []*api.Endpoint{
+		{Name: "Relations.CreateRelation", Path: []string{"/files/{file_id}/relations"}, Method: []string{"POST"}, Handler: "rpc"},
+		{Name: "Relations.ListRelations", Path: []string{"/files/{file_id}/relations"}, Method: []string{"GET"}, Handler: "rpc"},
+		{Name: "Relations.DeleteRelation", Path: []string{"/files/{file_id}/relations/{type}/{child_id}"}, Method: []string{"DELETE"}, Handler: "rpc"},
+		{Name: "Relations.SetHold", Path: []string{"/files/{file_id}/hold"}, Method: []string{"PUT"}, Handler: "rpc"},
+	}
+}
+
+// NewCollectionEndpoints returns the endpoints of the collections, they are bound
+// to the methods of CollectionHandler
+func NewCollectionEndpoints() []*api.Endpoint {
+	return []*api.Endpoint{
+		{Name: "Collections.CreateCollection", Path: []string{"/collections"}, Method: []string{"POST"}, Handler: "rpc"},
+		{Name: "Collections.ListCollections", Path: []string{"/collections"}, Method: []string{"GET"}, Handler: "rpc"},
+		{Name: "Collections.GetCollection", Path: []string{"/collections/{collection_id}"}, Method: []string{"GET"}, Handler: "rpc"},
+		{Name: "Collections.DeleteCollection", Path: []string{"/collections/{collection_id}"}, Method: []string{"DELETE"}, Handler: "rpc"},
+		{Name: "Collections.SetFiles", Path: []string{"/collections/{collection_id}/files"}, Method: []string{"PUT"}, Handler: "rpc"},
+	}
+}
+
+// RelationHandler ...
+type RelationHandler struct {
+	codec   codec.Codec
+	service *service.RelationService
+}
+
+// NewRelationHandler ...
+func NewRelationHandler(srv *service.RelationService, codec codec.Codec) *RelationHandler {
+	return &RelationHandler{
+		service: srv,
+		codec:   codec,
+	}
+}
+
+// CreateRelation links the file of the path as the parent of the child of the body
+func (rh *RelationHandler) CreateRelation(w http.ResponseWriter, r *http.Request) {
+	relation := &model.Relation{}
+	if err := readBody(rh.codec, r.Body, relation); err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	relation.ParentID = mux.Vars(r)["file_id"]
+	saved, err := rh.service.Link(r.Context(), relation)
+	if err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	rh.codec.Write(w, nil, saved)
+}
+
+// ListRelations traverses the relations by ?direction=outgoing|incoming and ?depth=
+func (rh *RelationHandler) ListRelations(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	depth := 0
+	if d := params.Get("depth"); d != "" {
+		var err error
+		if depth, err = strconv.Atoi(d); err != nil {
+			apperrors.WriteProblem(w, r, apperrors.Invalid(apperrors.CodeInvalidRequest, "Invalid depth %s", d))
+			return
+		}
+	}
+	related, err := rh.service.Related(r.Context(), mux.Vars(r)["file_id"], params.Get("direction"), depth)
+	if err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	rh.codec.Write(w, nil, related)
+}
+
+// DeleteRelation ...
+func (rh *RelationHandler) DeleteRelation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := rh.service.Unlink(r.Context(), vars["file_id"], vars["child_id"], vars["type"]); err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetHold answers with the ids of the files the hold was set or released for
+func (rh *RelationHandler) SetHold(w http.ResponseWriter, r *http.Request) {
+	req := &model.HoldRequest{}
+	if err := readBody(rh.codec, r.Body, req); err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	ids, err := rh.service.Hold(r.Context(), mux.Vars(r)["file_id"], req.Held)
+	if err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	rh.codec.Write(w, nil, map[string]interface{}{"held": req.Held, "file_ids": ids})
+}
+
+// CollectionHandler ...
+type CollectionHandler struct {
+	codec   codec.Codec
+	service *service.CollectionService
+}
+
+// NewCollectionHandler ...
+func NewCollectionHandler(srv *service.CollectionService, codec codec.Codec) *CollectionHandler {
+	return &CollectionHandler{
+		service: srv,
+		codec:   codec,
+	}
+}
+
+// CreateCollection ...
+func (ch *CollectionHandler) CreateCollection(w http.ResponseWriter, r *http.Request) {
+	req := &model.CollectionRequest{}
+	if err := readBody(ch.codec, r.Body, req); err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	c, err := ch.service.Create(r.Context(), req)
+	if err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	ch.codec.Write(w, nil, c)
+}
+
+// ListCollections ...
+func (ch *CollectionHandler) ListCollections(w http.ResponseWriter, r *http.Request) {
+	collections, err := ch.service.List(r.Context())
+	if err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	ch.codec.Write(w, nil, collections)
+}
+
+// GetCollection ...
+func (ch *CollectionHandler) GetCollection(w http.ResponseWriter, r *http.Request) {
+	c, err := ch.service.Get(r.Context(), mux.Vars(r)["collection_id"])
+	if err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	ch.codec.Write(w, nil, c)
+}
+
+// DeleteCollection ...
+func (ch *CollectionHandler) DeleteCollection(w http.ResponseWriter, r *http.Request) {
+	if err := ch.service.Delete(r.Context(), mux.Vars(r)["collection_id"]); err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetFiles replaces the files of the collection by the file_ids of the body in their order
+func (ch *CollectionHandler) SetFiles(w http.ResponseWriter, r *http.Request) {
+	req := &model.CollectionRequest{}
+	if err := readBody(ch.codec, r.Body, req); err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	c, err := ch.service.SetFiles(r.Context(), mux.Vars(r)["collection_id"], req)
+	if err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	ch.codec.Write(w, nil, c)
+}
+
+func readBody(c codec.Codec, body io.ReadCloser, v interface{}) error {
+	if body == nil {
+		return apperrors.Invalid(apperrors.CodeInvalidRequest, "Error, body is nil")
+	}
+	defer body.Close()
+	if err := c.ReadBody(body, v); err != nil {
+		return apperrors.Invalid(apperrors.CodeInvalidRequest, "Error reading body, %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	jsoncodec "github.com/unistack-org/micro-codec-json/v3"
+	"github.com/vielendanke/file-service/configs"
+	"github.com/vielendanke/file-service/internal/app/fileservice/handlers"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+func prepareRelationRouter(t *testing.T, fileRepo *mocks.FileRepository, relationRepo *mocks.RelationRepository, collectionRepo *mocks.CollectionRepository) *mux.Router {
+	engine, err := policy.NewEngine("")
+	if err != nil {
+		t.Fatalf("Error creating policy engine, %v", err)
+	}
+	router := mux.NewRouter()
+	relationHandler := handlers.NewRelationHandler(service.NewRelationService(fileRepo, relationRepo, engine), jsoncodec.NewCodec())
+	if err := configs.ConfigureHandlerToEndpoints(router, relationHandler, handlers.NewRelationEndpoints()); err != nil {
+		t.Fatalf("Unable to configure endpoints, %v", err)
+	}
+	collectionHandler := handlers.NewCollectionHandler(service.NewCollectionService(fileRepo, collectionRepo, engine), jsoncodec.NewCodec())
+	if err := configs.ConfigureHandlerToEndpoints(router, collectionHandler, handlers.NewCollectionEndpoints()); err != nil {
+		t.Fatalf("Unable to configure endpoints, %v", err)
+	}
+	return router
+}
+
+func TestRelationHandler_CreateRelation(t *testing.T) {
+	fileRepo := new(mocks.FileRepository)
+	relationRepo := new(mocks.RelationRepository)
+	fileRepo.On("FindFileByID", mock.Anything, "invoice").Return(&model.FileRecord{ID: "invoice", DocClass: "invoice"}, nil)
+	fileRepo.On("FindFileByID", mock.Anything, "order").Return(&model.FileRecord{ID: "order", DocClass: "order"}, nil)
+	relationRepo.On("SaveRelation", mock.Anything, &model.Relation{ParentID: "invoice", ChildID: "order", Type: model.RelationReferences}).Return(nil)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/files/invoice/relations", strings.NewReader(`{"child_id":"order","type":"references"}`))
+
+	prepareRelationRouter(t, fileRepo, relationRepo, new(mocks.CollectionRepository)).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	relationRepo.AssertExpectations(t)
+}
+
+func TestRelationHandler_SetHold(t *testing.T) {
+	fileRepo := new(mocks.FileRepository)
+	relationRepo := new(mocks.RelationRepository)
+	fileRepo.On("FindFileByID", mock.Anything, "contract").Return(&model.FileRecord{ID: "contract", DocClass: "contract"}, nil)
+	relationRepo.On("SetLegalHold", mock.Anything, "contract", true).Return([]string{"contract", "annex"}, nil)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/files/contract/hold", strings.NewReader(`{"held":true}`))
+
+	prepareRelationRouter(t, fileRepo, relationRepo, new(mocks.CollectionRepository)).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := struct {
+		Held    bool     `json:"held"`
+		FileIDs []string `json:"file_ids"`
+	}{}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.True(t, body.Held)
+	assert.Equal(t, []string{"contract", "annex"}, body.FileIDs)
+}
+
+func TestCollectionHandler_CreateCollection_InvalidBody(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/collections", strings.NewReader(`{"name":`))
+
+	prepareRelationRouter(t, new(mocks.FileRepository), new(mocks.RelationRepository), new(mocks.CollectionRepository)).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
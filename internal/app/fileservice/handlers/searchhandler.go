@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/unistack-org/micro/v3/api"
+	"github.com/unistack-org/micro/v3/codec"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+// NewSearchEndpoints returns the endpoints of the list of files, they are bound
+// to the methods of SearchHandler like the generated file processing endpoints
+func NewSearchEndpoints() []*api.Endpoint {
+	return []*api.Endpoint{
+		{Name: "Search.ListFiles", Path: []string{"/files"}, Method: []string{"GET"}, Handler: "rpc"},
+	}
+}
+
+// SearchHandler ...
+type SearchHandler struct {
+	codec   codec.Codec
+	service *service.SearchService
+}
+
+// NewSearchHandler ...
+func NewSearchHandler(srv *service.SearchService, codec codec.Codec) *SearchHandler {
+	return &SearchHandler{
+		service: srv,
+		codec:   codec,
+	}
+}
+
+// ListFiles lists the files by ?cursor= and ?limit=, ?q= searches them by their
+// text in the websearch syntax of postgres
+func (sh *SearchHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	limit := 0
+	if l := params.Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil {
+			apperrors.WriteProblem(w, r, apperrors.Invalid(apperrors.CodeInvalidRequest, "Invalid limit %s", l))
+			return
+		}
+	}
+	list, err := sh.service.List(r.Context(), params.Get("q"), params.Get("cursor"), limit)
+	if err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	sh.codec.Write(w, nil, list)
+}
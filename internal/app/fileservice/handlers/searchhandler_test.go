@@ -0,0 +1,61 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	jsoncodec "github.com/unistack-org/micro-codec-json/v3"
+	"github.com/vielendanke/file-service/configs"
+	"github.com/vielendanke/file-service/internal/app/fileservice/handlers"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+func prepareSearchRouter(t *testing.T, fileRepo *mocks.FileRepository, searchRepo *mocks.SearchRepository) *mux.Router {
+	engine, err := policy.NewEngine("")
+	if err != nil {
+		t.Fatalf("Error creating policy engine, %v", err)
+	}
+	handler := handlers.NewSearchHandler(service.NewSearchService(fileRepo, searchRepo, engine), jsoncodec.NewCodec())
+	router := mux.NewRouter()
+	if err := configs.ConfigureHandlerToEndpoints(router, handler, handlers.NewSearchEndpoints()); err != nil {
+		t.Fatalf("Unable to configure endpoints, %v", err)
+	}
+	return router
+}
+
+func TestSearchHandler_ListFiles(t *testing.T) {
+	fileRepo := new(mocks.FileRepository)
+	searchRepo := new(mocks.SearchRepository)
+	searchRepo.On("SearchFiles", mock.Anything, "supply -draft", 0, 10).Return([]*model.FileListItem{
+		{FileRecord: model.FileRecord{ID: "f1", FileName: "contract.docx", Metadata: []byte(`{}`)}, Rank: 0.8, Snippet: "<mark>supply</mark> agreement"},
+	}, nil)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/files?q=supply+-draft&limit=10", nil)
+
+	prepareSearchRouter(t, fileRepo, searchRepo).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := model.FileList{}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Len(t, body.Files, 1)
+	assert.Equal(t, "contract.docx", body.Files[0].FileName)
+	assert.Equal(t, "<mark>supply</mark> agreement", body.Files[0].Snippet)
+	searchRepo.AssertExpectations(t)
+}
+
+func TestSearchHandler_ListFiles_InvalidLimit(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/files?limit=many", nil)
+
+	prepareSearchRouter(t, new(mocks.FileRepository), new(mocks.SearchRepository)).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
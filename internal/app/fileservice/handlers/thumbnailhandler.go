@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/unistack-org/micro/v3/api"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+// NewThumbnailEndpoints returns the endpoints of the thumbnails, they are bound
+// to the methods of ThumbnailHandler like the generated file processing endpoints
+func NewThumbnailEndpoints() []*api.Endpoint {
+	return []*api.Endpoint{
+		{Name: "Thumbnails.GetThumbnail", Path: []string{"/files/{file_id}/thumbnail"}, Method: []string{"GET"}, Handler: "rpc"},
+	}
+}
+
+// ThumbnailHandler ...
+type ThumbnailHandler struct {
+	service *service.ThumbnailService
+}
+
+// NewThumbnailHandler ...
+func NewThumbnailHandler(srv *service.ThumbnailService) *ThumbnailHandler {
+	return &ThumbnailHandler{
+		service: srv,
+	}
+}
+
+// GetThumbnail ...
+func (th *ThumbnailHandler) GetThumbnail(w http.ResponseWriter, r *http.Request) {
+	size := 0
+	if s := r.URL.Query().Get("size"); s != "" {
+		var err error
+		if size, err = strconv.Atoi(s); err != nil {
+			apperrors.WriteProblem(w, r, apperrors.Invalid(apperrors.CodeInvalidRequest, "Invalid thumbnail size %s", s))
+			return
+		}
+	}
+	thumbnail, contentType, err := th.service.Thumbnail(r.Context(), mux.Vars(r)["file_id"], size)
+	if err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(thumbnail)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/unistack-org/micro/v3/api"
+	"github.com/unistack-org/micro/v3/codec"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+// NewWebhookEndpoints returns the endpoints of the webhook subscriptions, they are
+// bound to the methods of WebhookHandler like the generated file processing endpoints
+func NewWebhookEndpoints() []*api.Endpoint {
+	return []*api.Endpoint{
+		{Name: "Webhooks.CreateWebhook", Path: []string{"/webhooks"}, Method: []string{"POST"}, Handler: "rpc"},
+		{Name: "Webhooks.ListWebhooks", Path: []string{"/webhooks"}, Method: []string{"GET"}, Handler: "rpc"},
+		{Name: "Webhooks.GetWebhook", Path: []string{"/webhooks/{webhook_id}"}, Method: []string{"GET"}, Handler: "rpc"},
+		{Name: "Webhooks.UpdateWebhook", Path: []string{"/webhooks/{webhook_id}"}, Method: []string{"PUT"}, Handler: "rpc"},
+		{Name: "Webhooks.DeleteWebhook", Path: []string{"/webhooks/{webhook_id}"}, Method: []string{"DELETE"}, Handler: "rpc"},
+		{Name: "Webhooks.ListDeadLetters", Path: []string{"/webhooks/{webhook_id}/dead-letters"}, Method: []string{"GET"}, Handler: "rpc"},
+		{Name: "Webhooks.Redeliver", Path: []string{"/webhooks/{webhook_id}/dead-letters/{delivery_id}/redeliver"}, Method: []string{"POST"}, Handler: "rpc"},
+	}
+}
+
+// WebhookHandler ...
+type WebhookHandler struct {
+	codec   codec.Codec
+	service *service.WebhookService
+}
+
+// NewWebhookHandler ...
+func NewWebhookHandler(srv *service.WebhookService, codec codec.Codec) *WebhookHandler {
+	return &WebhookHandler{
+		service: srv,
+		codec:   codec,
+	}
+}
+
+// CreateWebhook answers with the secret of the new subscription, it is not returned later
+func (wh *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	req, err := wh.readRequest(r)
+	if err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	s, err := wh.service.Create(r.Context(), req)
+	if err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	wh.codec.Write(w, nil, s)
+}
+
+// ListWebhooks ...
+func (wh *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := wh.service.List(r.Context())
+	if err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	wh.codec.Write(w, nil, subscriptions)
+}
+
+// GetWebhook ...
+func (wh *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	s, err := wh.service.Get(r.Context(), mux.Vars(r)["webhook_id"])
+	if err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	wh.codec.Write(w, nil, s)
+}
+
+// UpdateWebhook ...
+func (wh *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	req, err := wh.readRequest(r)
+	if err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	s, err := wh.service.Update(r.Context(), mux.Vars(r)["webhook_id"], req)
+	if err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	wh.codec.Write(w, nil, s)
+}
+
+// DeleteWebhook ...
+func (wh *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := wh.service.Delete(r.Context(), mux.Vars(r)["webhook_id"]); err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeadLetters ...
+func (wh *WebhookHandler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := wh.service.ListDeadLetters(r.Context(), mux.Vars(r)["webhook_id"])
+	if err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	wh.codec.Write(w, nil, deliveries)
+}
+
+// Redeliver ...
+func (wh *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := wh.service.Redeliver(r.Context(), vars["webhook_id"], vars["delivery_id"]); err != nil {
+		apperrors.WriteProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (wh *WebhookHandler) readRequest(r *http.Request) (*model.WebhookRequest, error) {
+	req := &model.WebhookRequest{}
+	if r.Body == nil {
+		return nil, apperrors.Invalid(apperrors.CodeInvalidRequest, "Error, body is nil")
+	}
+	defer r.Body.Close()
+	if err := wh.codec.ReadBody(r.Body, req); err != nil {
+		return nil, apperrors.Invalid(apperrors.CodeInvalidRequest, "Error reading body, %v", err)
+	}
+	return req, nil
+}
@@ -0,0 +1,70 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	jsoncodec "github.com/unistack-org/micro-codec-json/v3"
+	"github.com/vielendanke/file-service/configs"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/handlers"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+// erpResolver resolves every host to a public address
+type erpResolver struct{}
+
+func (erpResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return []net.IPAddr{{IP: net.ParseIP("203.0.113.10")}}, nil
+}
+
+func prepareWebhookRouter(t *testing.T, repo *mocks.WebhookRepository) *mux.Router {
+	engine, err := policy.NewEngine("")
+	if err != nil {
+		t.Fatalf("Error creating policy engine, %v", err)
+	}
+	handler := handlers.NewWebhookHandler(service.NewWebhookService(repo, engine, erpResolver{}), jsoncodec.NewCodec())
+	router := mux.NewRouter()
+	if err := configs.ConfigureHandlerToEndpoints(router, handler, handlers.NewWebhookEndpoints()); err != nil {
+		t.Fatalf("Unable to configure endpoints, %v", err)
+	}
+	return router
+}
+
+func TestWebhookHandler_CreateWebhook(t *testing.T) {
+	repo := new(mocks.WebhookRepository)
+	repo.On("SaveSubscription", mock.Anything, mock.AnythingOfType("*model.WebhookSubscription")).Return(nil)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewBufferString(`{"url":"https://erp.example.com/hooks","event_types":["file.deleted"]}`))
+
+	prepareWebhookRouter(t, repo).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	body := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "https://erp.example.com/hooks", body["url"])
+	assert.NotEmpty(t, body["secret"])
+	repo.AssertExpectations(t)
+}
+
+func TestWebhookHandler_Redeliver_NotFound(t *testing.T) {
+	repo := new(mocks.WebhookRepository)
+	repo.On("Redeliver", mock.Anything, "w1", "d1").Return(apperrors.NotFound(apperrors.CodeDeliveryNotFound, "Dead delivery d1 of webhook w1 not found"))
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/w1/dead-letters/d1/redeliver", nil)
+
+	prepareWebhookRouter(t, repo).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), apperrors.CodeDeliveryNotFound)
+}
@@ -0,0 +1,75 @@
+package middlewares
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/unistack-org/micro/v3/metadata"
+	"github.com/unistack-org/micro/v3/server"
+	"github.com/vielendanke/file-service/internal/app/fileservice/access"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+)
+
+// AccessMiddleware binds the requests to their client for the access log, it
+// runs after the request id middleware to see the id of the request
+type AccessMiddleware struct {
+	trustForwardedFor bool
+}
+
+// NewAccessMiddleware returns a middleware taking the address of the client
+// from the first X-Forwarded-For address when trustForwardedFor is set
+func NewAccessMiddleware(trustForwardedFor bool) *AccessMiddleware {
+	return &AccessMiddleware{
+		trustForwardedFor: trustForwardedFor,
+	}
+}
+
+// Wrapper ...
+func (am *AccessMiddleware) Wrapper(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		requestID, _ := metadata.Get(r.Context(), middleware.MetadataKey)
+		c := &access.Client{
+			IP:        am.clientIP(r),
+			UserAgent: r.UserAgent(),
+			RequestID: requestID,
+		}
+		next.ServeHTTP(rw, r.WithContext(access.NewContext(r.Context(), c)))
+	})
+}
+
+func (am *AccessMiddleware) clientIP(r *http.Request) string {
+	if am.trustForwardedFor {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			return strings.TrimSpace(strings.Split(fwd, ",")[0])
+		}
+	}
+	return hostOf(r.RemoteAddr)
+}
+
+// NewAccessHandlerWrapper binds the grpc calls to their client, the address is
+// the peer address the grpc server puts in the Remote metadata
+func NewAccessHandlerWrapper() server.HandlerWrapper {
+	return func(fn server.HandlerFunc) server.HandlerFunc {
+		return func(ctx context.Context, req server.Request, rsp interface{}) error {
+			remote, _ := metadata.Get(ctx, "Remote")
+			userAgent, _ := metadata.Get(ctx, "User-Agent")
+			requestID, _ := metadata.Get(ctx, middleware.MetadataKey)
+			c := &access.Client{
+				IP:        hostOf(remote),
+				UserAgent: userAgent,
+				RequestID: requestID,
+			}
+			return fn(access.NewContext(ctx, c), req, rsp)
+		}
+	}
+}
+
+func hostOf(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/unistack-org/micro/v3/logger"
+	"github.com/unistack-org/micro/v3/metadata"
+	"github.com/unistack-org/micro/v3/server"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+)
+
+// authHeaders are the metadata keys carrying the credentials of the grpc calls
+var authHeaders = []string{"Authorization", middleware.APIKeyHeader}
+
+// AuthError returns the error reported for a failed authentication, the
+// missing and invalid credentials are unauthenticated and the failures of the
+// key stores are kept as they are
+func AuthError(err error) error {
+	if !errors.Is(err, middleware.ErrNoCredentials) && !errors.Is(err, middleware.ErrInvalidCredentials) {
+		return err
+	}
+	return apperrors.Unauthenticated(apperrors.CodeUnauthenticated, "Unauthenticated, %v", err)
+}
+
+// NewAuthHandlerWrapper authenticates the grpc calls with the authenticators of
+// am, the bearer token or the api key are read from the metadata like the
+// headers of the HTTP requests
+func NewAuthHandlerWrapper(am *middleware.AuthMiddleware) server.HandlerWrapper {
+	return func(fn server.HandlerFunc) server.HandlerFunc {
+		return func(ctx context.Context, req server.Request, rsp interface{}) error {
+			// the authenticators only see the headers of the request
+			r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", nil)
+			if err != nil {
+				return apperrors.GRPCError(ctx, err)
+			}
+			for _, key := range authHeaders {
+				if v, ok := incomingMetadata(ctx, key); ok {
+					r.Header.Set(key, v)
+				}
+			}
+			p, err := am.Authenticate(r)
+			if err != nil {
+				logger.Infof(ctx, "Authentication failed, %v", err)
+				return apperrors.GRPCError(ctx, AuthError(err))
+			}
+			ctx = middleware.NewPrincipalContext(ctx, p)
+			if log, ok := logger.FromContext(ctx); ok {
+				ctx = logger.NewContext(ctx, log.Fields(map[string]interface{}{"principal": p.Subject}))
+			}
+			return fn(ctx, req, rsp)
+		}
+	}
+}
+
+// incomingMetadata returns the value of key, the grpc metadata keys may come in
+// lower case
+func incomingMetadata(ctx context.Context, key string) (string, bool) {
+	if v, ok := metadata.Get(ctx, key); ok {
+		return v, true
+	}
+	return metadata.Get(ctx, strings.ToLower(key))
+}
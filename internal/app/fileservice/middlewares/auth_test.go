@@ -0,0 +1,60 @@
+package middlewares_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unistack-org/micro/v3/metadata"
+	"github.com/unistack-org/micro/v3/server"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+	"github.com/vielendanke/file-service/internal/app/fileservice/middlewares"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type apiKeyStore map[string]*middleware.Principal
+
+func (s apiKeyStore) FindAPIKey(ctx context.Context, hash string) (*middleware.Principal, error) {
+	p, ok := s[hash]
+	if !ok {
+		return nil, middleware.ErrInvalidCredentials
+	}
+	return &middleware.Principal{Subject: p.Subject}, nil
+}
+
+// callAuthenticated calls a handler behind the auth wrapper with md and
+// returns the principal the handler saw
+func callAuthenticated(md metadata.Metadata) (*middleware.Principal, error) {
+	store := apiKeyStore{middleware.HashAPIKey("secret"): {Subject: "billing"}}
+	am := middleware.NewAuthMiddleware(nil, middleware.NewAPIKeyAuthenticator(store))
+	var principal *middleware.Principal
+	handler := middlewares.NewAuthHandlerWrapper(am)(func(ctx context.Context, req server.Request, rsp interface{}) error {
+		principal, _ = middleware.PrincipalFromContext(ctx)
+		return nil
+	})
+	err := handler(metadata.NewContext(context.Background(), md), nil, nil)
+	return principal, err
+}
+
+func TestAuthHandlerWrapper_APIKey(t *testing.T) {
+	principal, err := callAuthenticated(metadata.Metadata{"x-api-key": "secret"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "billing", principal.Subject)
+	assert.Equal(t, middleware.PrincipalService, principal.Kind)
+}
+
+func TestAuthHandlerWrapper_NoCredentials(t *testing.T) {
+	principal, err := callAuthenticated(metadata.Metadata{})
+
+	assert.Nil(t, principal)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestAuthHandlerWrapper_UnknownAPIKey(t *testing.T) {
+	principal, err := callAuthenticated(metadata.Metadata{"Authorization": "ApiKey unknown"})
+
+	assert.Nil(t, principal)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,59 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/unistack-org/micro/v3/server"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/shutdown"
+)
+
+// DrainMiddleware tracks the requests in flight for the graceful shutdown and
+// rejects the new requests once the drain began
+type DrainMiddleware struct {
+	drainer *shutdown.Drainer
+}
+
+// NewDrainMiddleware ...
+func NewDrainMiddleware(drainer *shutdown.Drainer) *DrainMiddleware {
+	return &DrainMiddleware{
+		drainer: drainer,
+	}
+}
+
+// Wrapper ...
+func (dm *DrainMiddleware) Wrapper(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if route := mux.CurrentRoute(r); route != nil {
+			if tpl, err := route.GetPathTemplate(); err == nil {
+				path = tpl
+			}
+		}
+		done, ok := dm.drainer.Begin(r.Method, path)
+		if !ok {
+			rw.Header().Set("Connection", "close")
+			rw.Header().Set("Retry-After", "1")
+			apperrors.WriteProblem(rw, r, apperrors.Unavailable(apperrors.CodeShuttingDown, "Service is shutting down"))
+			return
+		}
+		defer done()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// NewDrainHandlerWrapper tracks the grpc calls in flight for the graceful shutdown
+func NewDrainHandlerWrapper(drainer *shutdown.Drainer) server.HandlerWrapper {
+	return func(fn server.HandlerFunc) server.HandlerFunc {
+		return func(ctx context.Context, req server.Request, rsp interface{}) error {
+			done, ok := drainer.Begin("GRPC", req.Endpoint())
+			if !ok {
+				return apperrors.GRPCError(ctx, apperrors.Unavailable(apperrors.CodeShuttingDown, "Service is shutting down"))
+			}
+			defer done()
+			return fn(ctx, req, rsp)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package middlewares
+
+import (
+	"context"
+	"time"
+
+	"github.com/unistack-org/micro/v3/metadata"
+	"github.com/unistack-org/micro/v3/server"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+	"google.golang.org/protobuf/proto"
+)
+
+// grpcMethod is the method of the grpc calls for the rate limits, the routes
+// of the grpc calls are their endpoints like FileProcessing.UploadFile
+const grpcMethod = "GRPC"
+
+// NewRateLimitAddressHandlerWrapper limits the grpc calls of every peer address
+// with the address limiter of rlm, it goes before the authentication like the
+// address limit of the HTTP requests
+func NewRateLimitAddressHandlerWrapper(rlm *middleware.RateLimitMiddleware) server.HandlerWrapper {
+	return func(fn server.HandlerFunc) server.HandlerFunc {
+		return func(ctx context.Context, req server.Request, rsp interface{}) error {
+			d := rlm.AllowAddress(ctx, grpcMethod, req.Endpoint(), remoteKey(ctx))
+			if d != nil && !d.Allowed {
+				return rateLimited(ctx, *d)
+			}
+			return fn(ctx, req, rsp)
+		}
+	}
+}
+
+// NewRateLimitHandlerWrapper limits the grpc calls and the bandwidth of every
+// client with the limiters of rlm, it goes after the authentication to see the
+// principal. The messages of the streams are throttled as they are received
+// and before they are sent, the unary calls wait for their request before the
+// handler and for their response after it.
+func NewRateLimitHandlerWrapper(rlm *middleware.RateLimitMiddleware) server.HandlerWrapper {
+	return func(fn server.HandlerFunc) server.HandlerFunc {
+		return func(ctx context.Context, req server.Request, rsp interface{}) error {
+			client := middleware.ClientKey(ctx, remoteKey(ctx))
+			if d := rlm.AllowCall(ctx, grpcMethod, req.Endpoint(), client); d != nil && !d.Allowed {
+				return rateLimited(ctx, *d)
+			}
+			upload := rlm.UploadThrottle(ctx, client)
+			download := rlm.DownloadThrottle(ctx, client)
+			if stream, ok := rsp.(server.Stream); ok {
+				if upload != nil || download != nil {
+					rsp = &throttledStream{Stream: stream, upload: upload, download: download}
+				}
+				return fn(ctx, req, rsp)
+			}
+			if err := wait(upload, req.Body()); err != nil {
+				return err
+			}
+			if err := fn(ctx, req, rsp); err != nil {
+				return err
+			}
+			return wait(download, rsp)
+		}
+	}
+}
+
+// throttledStream waits for the bandwidth of the messages of a stream
+type throttledStream struct {
+	server.Stream
+	upload   *middleware.Throttle
+	download *middleware.Throttle
+}
+
+func (ts *throttledStream) Recv(msg interface{}) error {
+	if err := ts.Stream.Recv(msg); err != nil {
+		return err
+	}
+	return wait(ts.upload, msg)
+}
+
+func (ts *throttledStream) Send(msg interface{}) error {
+	if err := wait(ts.download, msg); err != nil {
+		return err
+	}
+	return ts.Stream.Send(msg)
+}
+
+// wait blocks until t allows the encoded size of msg, the messages other than
+// the protobuf ones are not counted
+func wait(t *middleware.Throttle, msg interface{}) error {
+	if t == nil {
+		return nil
+	}
+	m, ok := msg.(proto.Message)
+	if !ok {
+		return nil
+	}
+	return t.Wait(proto.Size(m))
+}
+
+// remoteKey returns the rate limit key of the peer address of the call
+func remoteKey(ctx context.Context) string {
+	remote, _ := metadata.Get(ctx, "Remote")
+	return "ip:" + hostOf(remote)
+}
+
+func rateLimited(ctx context.Context, d middleware.Decision) error {
+	return apperrors.GRPCError(ctx, apperrors.RateLimited(apperrors.CodeRateLimited, "Rate limit exceeded, retry in %s", d.Reset.Round(time.Second)))
+}
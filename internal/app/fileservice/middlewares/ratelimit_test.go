@@ -0,0 +1,168 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unistack-org/micro/v3/metadata"
+	"github.com/unistack-org/micro/v3/server"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+	"github.com/vielendanke/file-service/internal/app/fileservice/middlewares"
+	pb "github.com/vielendanke/file-service/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testRequest is a grpc request of endpoint with body
+type testRequest struct {
+	server.Request
+	endpoint string
+	body     interface{}
+}
+
+func (r *testRequest) Endpoint() string {
+	return r.endpoint
+}
+
+func (r *testRequest) Body() interface{} {
+	return r.body
+}
+
+// testStream receives the chunks of recv and collects the sent chunks
+type testStream struct {
+	server.Stream
+	recv []*pb.UploadFileRequest
+	sent []interface{}
+}
+
+func (s *testStream) Recv(msg interface{}) error {
+	*msg.(*pb.UploadFileRequest) = pb.UploadFileRequest{Chunk: s.recv[0].Chunk}
+	s.recv = s.recv[1:]
+	return nil
+}
+
+func (s *testStream) Send(msg interface{}) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func prepareGRPCLimiter(t *testing.T, limit middleware.RateLimit) middleware.Limiter {
+	l, err := middleware.NewLimiter(middleware.RateLimitTokenBucket, 10, limit)
+	if err != nil {
+		t.Fatalf("Error creating limiter, %v", err)
+	}
+	return l
+}
+
+func remoteContext(addr string) context.Context {
+	return metadata.NewContext(context.Background(), metadata.Metadata{"Remote": addr})
+}
+
+func noop(ctx context.Context, req server.Request, rsp interface{}) error {
+	return nil
+}
+
+func TestRateLimitHandlerWrapper_Requests(t *testing.T) {
+	rlm := middleware.NewRateLimitMiddleware(nil,
+		middleware.WithRequestLimiter(prepareGRPCLimiter(t, middleware.RateLimit{Limit: 1, Period: time.Minute})),
+	)
+	handler := middlewares.NewRateLimitHandlerWrapper(rlm)(noop)
+	req := &testRequest{endpoint: "FileProcessing.GetFileMetadata", body: &pb.GetMetadataRequest{}}
+
+	assert.Nil(t, handler(remoteContext("10.0.0.1:5000"), req, nil))
+	err := handler(remoteContext("10.0.0.1:5001"), req, nil)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Nil(t, handler(remoteContext("10.0.0.2:5000"), req, nil))
+	ctx := middleware.NewPrincipalContext(remoteContext("10.0.0.1:5002"), &middleware.Principal{Subject: "billing", Kind: middleware.PrincipalService})
+	assert.Nil(t, handler(ctx, req, nil))
+}
+
+func TestRateLimitHandlerWrapper_Route(t *testing.T) {
+	rlm := middleware.NewRateLimitMiddleware(nil,
+		middleware.WithRouteLimiter("GRPC", "FileProcessing.UploadFile", prepareGRPCLimiter(t, middleware.RateLimit{Limit: 1, Period: time.Minute})),
+	)
+	handler := middlewares.NewRateLimitHandlerWrapper(rlm)(noop)
+	ctx := remoteContext("10.0.0.1:5000")
+
+	assert.Nil(t, handler(ctx, &testRequest{endpoint: "FileProcessing.UploadFile"}, &testStream{}))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(handler(ctx, &testRequest{endpoint: "FileProcessing.UploadFile"}, &testStream{})))
+	assert.Nil(t, handler(ctx, &testRequest{endpoint: "FileProcessing.GetFileMetadata"}, nil))
+}
+
+func TestRateLimitAddressHandlerWrapper(t *testing.T) {
+	rlm := middleware.NewRateLimitMiddleware(nil,
+		middleware.WithAddressLimiter(prepareGRPCLimiter(t, middleware.RateLimit{Limit: 1, Period: time.Minute})),
+	)
+	handler := middlewares.NewRateLimitAddressHandlerWrapper(rlm)(noop)
+	req := &testRequest{endpoint: "FileProcessing.GetFileMetadata"}
+
+	assert.Nil(t, handler(remoteContext("10.0.0.1:5000"), req, nil))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(handler(remoteContext("10.0.0.1:5001"), req, nil)))
+}
+
+func TestRateLimitHandlerWrapper_UploadStream(t *testing.T) {
+	rlm := middleware.NewRateLimitMiddleware(nil,
+		middleware.WithUploadLimiter(prepareGRPCLimiter(t, middleware.RateLimit{Limit: 1000, Period: time.Second, Burst: 10})),
+	)
+	received := 0
+	handler := middlewares.NewRateLimitHandlerWrapper(rlm)(func(ctx context.Context, req server.Request, rsp interface{}) error {
+		stream := rsp.(server.Stream)
+		for i := 0; i < 2; i++ {
+			msg := &pb.UploadFileRequest{}
+			if err := stream.Recv(msg); err != nil {
+				return err
+			}
+			received += len(msg.Chunk)
+		}
+		return nil
+	})
+	stream := &testStream{recv: []*pb.UploadFileRequest{
+		{Chunk: bytes.Repeat([]byte("a"), 28)},
+		{Chunk: bytes.Repeat([]byte("a"), 28)},
+	}}
+
+	start := time.Now()
+	err := handler(remoteContext("10.0.0.1:5000"), &testRequest{endpoint: "FileProcessing.UploadFile"}, stream)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 56, received)
+	// 60 encoded bytes with a bucket of 10 refilled by 1000 per second
+	assert.True(t, time.Since(start) >= 40*time.Millisecond)
+}
+
+func TestRateLimitHandlerWrapper_DownloadStream(t *testing.T) {
+	rlm := middleware.NewRateLimitMiddleware(nil,
+		middleware.WithDownloadLimiter(prepareGRPCLimiter(t, middleware.RateLimit{Limit: 1000, Period: time.Second, Burst: 10})),
+	)
+	handler := middlewares.NewRateLimitHandlerWrapper(rlm)(func(ctx context.Context, req server.Request, rsp interface{}) error {
+		return rsp.(server.Stream).Send(&pb.FileChunk{Chunk: bytes.Repeat([]byte("a"), 58)})
+	})
+	stream := &testStream{}
+
+	start := time.Now()
+	err := handler(remoteContext("10.0.0.1:5000"), &testRequest{endpoint: "FileProcessing.DownloadFileStream"}, stream)
+
+	assert.Nil(t, err)
+	assert.Len(t, stream.sent, 1)
+	assert.True(t, time.Since(start) >= 40*time.Millisecond)
+}
+
+func TestRateLimitHandlerWrapper_StreamCanceled(t *testing.T) {
+	rlm := middleware.NewRateLimitMiddleware(nil,
+		middleware.WithDownloadLimiter(prepareGRPCLimiter(t, middleware.RateLimit{Limit: 1, Period: time.Hour, Burst: 10})),
+	)
+	handler := middlewares.NewRateLimitHandlerWrapper(rlm)(func(ctx context.Context, req server.Request, rsp interface{}) error {
+		return rsp.(server.Stream).Send(&pb.FileChunk{Chunk: bytes.Repeat([]byte("a"), 58)})
+	})
+	ctx, cancel := context.WithTimeout(remoteContext("10.0.0.1:5000"), 20*time.Millisecond)
+	defer cancel()
+	stream := &testStream{}
+
+	err := handler(ctx, &testRequest{endpoint: "FileProcessing.DownloadFileStream"}, stream)
+
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Empty(t, stream.sent)
+}
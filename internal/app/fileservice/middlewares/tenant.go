@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/unistack-org/micro/v3/logger"
+	"github.com/unistack-org/micro/v3/metadata"
+	"github.com/unistack-org/micro/v3/server"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+// TenantMiddleware binds the requests to the tenant of the caller, it runs after
+// the authentication to see the principal
+type TenantMiddleware struct {
+	registry *tenant.Registry
+}
+
+// NewTenantMiddleware ...
+func NewTenantMiddleware(registry *tenant.Registry) *TenantMiddleware {
+	return &TenantMiddleware{
+		registry: registry,
+	}
+}
+
+// Wrapper ...
+func (tm *TenantMiddleware) Wrapper(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		principal, _ := middleware.PrincipalFromContext(r.Context())
+		t, err := tm.registry.Resolve(principal, r.Header.Get(tenant.Header))
+		if err != nil {
+			logger.Infof(r.Context(), "Tenant not resolved, %v", err)
+			apperrors.WriteProblem(rw, r, err)
+			return
+		}
+		next.ServeHTTP(rw, r.WithContext(tenant.NewContext(r.Context(), t)))
+	})
+}
+
+// NewTenantHandlerWrapper binds the grpc calls to the tenant of the x-tenant-id metadata
+func NewTenantHandlerWrapper(registry *tenant.Registry) server.HandlerWrapper {
+	return func(fn server.HandlerFunc) server.HandlerFunc {
+		return func(ctx context.Context, req server.Request, rsp interface{}) error {
+			principal, _ := middleware.PrincipalFromContext(ctx)
+			header, _ := metadata.Get(ctx, tenant.Header)
+			t, err := registry.Resolve(principal, header)
+			if err != nil {
+				return apperrors.GRPCError(ctx, err)
+			}
+			return fn(tenant.NewContext(ctx, t), req, rsp)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+// Package migrate applies the SQL migrations of the database schema, the applied
+// version is tracked in the schema_migrations table of golang-migrate
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/unistack-org/micro/v3/logger"
+)
+
+// lockID is the key of the advisory lock held while migrating, it keeps the
+// replicas starting at once from running the same migrations
+const lockID = 7240186104
+
+var fileRegexp = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a version of the schema
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is the state of the schema
+type Status struct {
+	// Version is the applied version, zero when no migration is applied
+	Version uint64
+	// Dirty is set when a migration failed half way, it must be fixed by hand
+	Dirty      bool
+	Migrations []*Migration
+}
+
+// Applied reports if the migration m is applied
+func (s *Status) Applied(m *Migration) bool {
+	return m.Version <= s.Version
+}
+
+// Load reads the migrations of fsys, every version needs an up migration
+func Load(fsys fs.FS) ([]*Migration, error) {
+	files, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("Error reading migrations, %v", err)
+	}
+	byVersion := make(map[uint64]*Migration)
+	for _, f := range files {
+		match := fileRegexp.FindStringSubmatch(f.Name())
+		if f.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("Error reading migration %s, invalid version", f.Name())
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("Error reading migration %s, version %d is named %s", f.Name(), version, m.Name)
+		}
+		body, err := fs.ReadFile(fsys, f.Name())
+		if err != nil {
+			return nil, fmt.Errorf("Error reading migration %s, %v", f.Name(), err)
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("Error reading migration %d_%s, up migration is missing", m.Version, m.Name)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies the migrations to the database
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []*Migration
+}
+
+// NewMigrator ...
+func NewMigrator(db *sqlx.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+// Latest returns the version of the last migration
+func (m *Migrator) Latest() uint64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies all of the pending migrations, a schema newer than the
+// migrations of this binary is left as is for the rolling updates
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sqlx.Conn) error {
+		current, err := m.current(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if current > m.Latest() {
+			logger.Infof(ctx, "Schema version %d is newer than the migrations up to %d, nothing to migrate", current, m.Latest())
+			return nil
+		}
+		return m.migrate(ctx, conn, current, m.Latest())
+	})
+}
+
+// Down reverts the last applied migration
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sqlx.Conn) error {
+		current, err := m.current(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if current == 0 {
+			logger.Infof(ctx, "No migration to revert")
+			return nil
+		}
+		if err := m.known(current); err != nil {
+			return err
+		}
+		return m.migrate(ctx, conn, current, m.previous(current))
+	})
+}
+
+// To migrates the schema up or down to version, zero reverts all of the migrations
+func (m *Migrator) To(ctx context.Context, version uint64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("Error migrating, unknown version %d", version)
+	}
+	return m.withLock(ctx, func(conn *sqlx.Conn) error {
+		current, err := m.current(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.known(current); err != nil {
+			return err
+		}
+		return m.migrate(ctx, conn, current, version)
+	})
+}
+
+// Status returns the applied version and the known migrations
+func (m *Migrator) Status(ctx context.Context) (*Status, error) {
+	status := &Status{Migrations: m.migrations}
+	if err := m.withLock(ctx, func(conn *sqlx.Conn) error {
+		var err error
+		status.Version, status.Dirty, err = m.version(ctx, conn)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (m *Migrator) find(version uint64) *Migration {
+	for _, mg := range m.migrations {
+		if mg.Version == version {
+			return mg
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) previous(version uint64) uint64 {
+	prev := uint64(0)
+	for _, mg := range m.migrations {
+		if mg.Version >= version {
+			break
+		}
+		prev = mg.Version
+	}
+	return prev
+}
+
+// withLock runs fn on a single connection holding the advisory lock, the lock
+// belongs to the session so it is released with the connection
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sqlx.Conn) error) error {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("Error getting connection, %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return fmt.Errorf("Error acquiring migration lock, %v", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockID); err != nil {
+			logger.Errorf(ctx, "Error releasing migration lock, %v", err)
+		}
+	}()
+	if _, err := conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations (version bigint not null primary key, dirty boolean not null)"); err != nil {
+		return fmt.Errorf("Error creating schema_migrations table, %v", err)
+	}
+	return fn(conn)
+}
+
+func (m *Migrator) version(ctx context.Context, conn *sqlx.Conn) (uint64, bool, error) {
+	version := uint64(0)
+	dirty := false
+	err := conn.QueryRowxContext(ctx, "SELECT VERSION, DIRTY FROM SCHEMA_MIGRATIONS LIMIT 1").Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("Error reading schema version, %v", err)
+	}
+	return version, dirty, nil
+}
+
+// current returns the applied version, it fails on a dirty schema
+func (m *Migrator) current(ctx context.Context, conn *sqlx.Conn) (uint64, error) {
+	version, dirty, err := m.version(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("Error migrating, schema is dirty at version %d, fix it by hand and reset the dirty flag", version)
+	}
+	return version, nil
+}
+
+// known fails if the applied version is not one of the migrations
+func (m *Migrator) known(version uint64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("Error migrating, applied version %d is unknown to this binary", version)
+	}
+	return nil
+}
+
+func (m *Migrator) migrate(ctx context.Context, conn *sqlx.Conn, from, to uint64) error {
+	if from == to {
+		logger.Infof(ctx, "Schema is at version %d, nothing to migrate", from)
+		return nil
+	}
+	if from < to {
+		for _, mg := range m.migrations {
+			if mg.Version <= from || mg.Version > to {
+				continue
+			}
+			if err := m.apply(ctx, conn, mg.Up, mg.Version); err != nil {
+				return fmt.Errorf("Error applying migration %d_%s, %v", mg.Version, mg.Name, err)
+			}
+			logger.Infof(ctx, "Applied migration %d_%s", mg.Version, mg.Name)
+		}
+		return nil
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mg := m.migrations[i]
+		if mg.Version > from || mg.Version <= to {
+			continue
+		}
+		if mg.Down == "" {
+			return fmt.Errorf("Error reverting migration %d_%s, down migration is missing", mg.Version, mg.Name)
+		}
+		if err := m.apply(ctx, conn, mg.Down, m.previous(mg.Version)); err != nil {
+			return fmt.Errorf("Error reverting migration %d_%s, %v", mg.Version, mg.Name, err)
+		}
+		logger.Infof(ctx, "Reverted migration %d_%s", mg.Version, mg.Name)
+	}
+	return nil
+}
+
+// apply runs the statements and records version in one transaction, a failed
+// migration leaves the schema untouched
+func (m *Migrator) apply(ctx context.Context, conn *sqlx.Conn, statements string, version uint64) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Error starting transaction, %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM SCHEMA_MIGRATIONS"); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error resetting schema version, %v", err)
+	}
+	if version != 0 {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO SCHEMA_MIGRATIONS(VERSION, DIRTY) VALUES($1, false)", version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("Error recording schema version, %v", err)
+		}
+	}
+	return tx.Commit()
+}
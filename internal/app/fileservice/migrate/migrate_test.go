@@ -0,0 +1,127 @@
+package migrate_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/migrate"
+)
+
+var migrationsFS = fstest.MapFS{
+	"000001_create_files.up.sql":   {Data: []byte("CREATE TABLE files (id varchar)")},
+	"000001_create_files.down.sql": {Data: []byte("DROP TABLE files")},
+	"000002_add_size.up.sql":       {Data: []byte("ALTER TABLE files ADD COLUMN size bigint")},
+	"000002_add_size.down.sql":     {Data: []byte("ALTER TABLE files DROP COLUMN size")},
+	"000003_add_tenants.up.sql":    {Data: []byte("ALTER TABLE files ADD COLUMN tenant_id varchar")},
+	"000003_add_tenants.down.sql":  {Data: []byte("ALTER TABLE files DROP COLUMN tenant_id")},
+	"migrations.go":                {Data: []byte("package migrations")},
+}
+
+func prepareMigrator(t *testing.T) (*migrate.Migrator, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error during initialization mock: %v", err)
+	}
+	m, err := migrate.NewMigrator(sqlx.NewDb(db, "sqlmock"), migrationsFS)
+	if err != nil {
+		t.Fatalf("Error creating migrator: %v", err)
+	}
+	return m, mock
+}
+
+func expectLock(mock sqlmock.Sqlmock, rows *sqlmock.Rows) {
+	mock.ExpectExec("pg_advisory_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT VERSION, DIRTY FROM SCHEMA_MIGRATIONS").WillReturnRows(rows)
+}
+
+func expectApply(mock sqlmock.Sqlmock, statement string, version int64) {
+	mock.ExpectBegin()
+	mock.ExpectExec(statement).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM SCHEMA_MIGRATIONS").WillReturnResult(sqlmock.NewResult(0, 1))
+	if version != 0 {
+		mock.ExpectExec("INSERT INTO SCHEMA_MIGRATIONS").WithArgs(version).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := migrate.Load(migrationsFS)
+
+	assert.Nil(t, err)
+	assert.Len(t, migrations, 3)
+	assert.Equal(t, uint64(2), migrations[1].Version)
+	assert.Equal(t, "add_size", migrations[1].Name)
+	assert.Equal(t, "ALTER TABLE files DROP COLUMN size", migrations[1].Down)
+}
+
+func TestLoad_MissingUp(t *testing.T) {
+	_, err := migrate.Load(fstest.MapFS{
+		"000001_create_files.down.sql": {Data: []byte("DROP TABLE files")},
+	})
+
+	assert.EqualError(t, err, "Error reading migration 1_create_files, up migration is missing")
+}
+
+func TestMigrator_Up(t *testing.T) {
+	m, mock := prepareMigrator(t)
+
+	expectLock(mock, sqlmock.NewRows([]string{"version", "dirty"}).AddRow(1, false))
+	expectApply(mock, "ADD COLUMN size", 2)
+	expectApply(mock, "ADD COLUMN tenant_id", 3)
+	mock.ExpectExec("pg_advisory_unlock").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.Nil(t, m.Up(context.Background()))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_UpNewerSchema(t *testing.T) {
+	m, mock := prepareMigrator(t)
+
+	expectLock(mock, sqlmock.NewRows([]string{"version", "dirty"}).AddRow(5, false))
+	mock.ExpectExec("pg_advisory_unlock").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.Nil(t, m.Up(context.Background()))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_ToDown(t *testing.T) {
+	m, mock := prepareMigrator(t)
+
+	expectLock(mock, sqlmock.NewRows([]string{"version", "dirty"}).AddRow(3, false))
+	expectApply(mock, "DROP COLUMN tenant_id", 2)
+	expectApply(mock, "DROP COLUMN size", 1)
+	mock.ExpectExec("pg_advisory_unlock").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.Nil(t, m.To(context.Background(), 1))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_DownDirty(t *testing.T) {
+	m, mock := prepareMigrator(t)
+
+	expectLock(mock, sqlmock.NewRows([]string{"version", "dirty"}).AddRow(2, true))
+	mock.ExpectExec("pg_advisory_unlock").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := m.Down(context.Background())
+
+	assert.EqualError(t, err, "Error migrating, schema is dirty at version 2, fix it by hand and reset the dirty flag")
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Status(t *testing.T) {
+	m, mock := prepareMigrator(t)
+
+	expectLock(mock, sqlmock.NewRows([]string{"version", "dirty"}))
+	mock.ExpectExec("pg_advisory_unlock").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	status, err := m.Status(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), status.Version)
+	assert.False(t, status.Applied(status.Migrations[0]))
+}
@@ -0,0 +1,81 @@
+package fileservice
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/vielendanke/file-service/internal/app/fileservice/migrate"
+	"github.com/vielendanke/file-service/migrations"
+)
+
+// MigrateUsage describes the arguments of RunMigrations
+const MigrateUsage = "migrate up|down|status|to <version>"
+
+// RunMigrations runs the migrate command with args against the configured database:
+// up applies the pending migrations, down reverts the last one, to migrates up or
+// down to the version and status writes the applied and the pending migrations to out
+func RunMigrations(ctx context.Context, args []string, out io.Writer) error {
+	target := uint64(0)
+	switch {
+	case len(args) == 1 && (args[0] == "up" || args[0] == "down" || args[0] == "status"):
+	case len(args) == 2 && args[0] == "to":
+		v, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("Error parsing version %s, %v", args[1], err)
+		}
+		target = v
+	default:
+		return fmt.Errorf("Error parsing arguments, usage: %s", MigrateUsage)
+	}
+
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if cfg.Database.URL == "" {
+		return fmt.Errorf("Error migrating, database.url is required")
+	}
+	db, err := connectDB(ctx, "postgres", cfg.Database.URL, newBackoff(cfg.Startup))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	migrator, err := migrate.NewMigrator(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		return migrator.Down(ctx)
+	case "to":
+		return migrator.To(ctx, target)
+	}
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	return writeStatus(out, status)
+}
+
+func writeStatus(out io.Writer, status *migrate.Status) error {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATE")
+	for _, m := range status.Migrations {
+		state := "pending"
+		if status.Applied(m) {
+			state = "applied"
+		}
+		if m.Version == status.Version && status.Dirty {
+			state = "dirty"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", m.Version, m.Name, state)
+	}
+	fmt.Fprintf(tw, "\nschema version: %d\n", status.Version)
+	return tw.Flush()
+}
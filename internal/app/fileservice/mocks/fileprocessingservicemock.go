@@ -4,6 +4,7 @@ package mocks
 
 import (
 	context "context"
+	io "io"
 
 	mock "github.com/stretchr/testify/mock"
 	model "github.com/vielendanke/file-service/internal/app/fileservice/model"
@@ -29,23 +30,25 @@ func (_m *FileProcessingService) DeleteMetadataByID(ctx context.Context, id stri
 }
 
 // DownloadFile provides a mock function with given fields: ctx, id
-func (_m *FileProcessingService) DownloadFile(ctx context.Context, id string) ([]byte, string, error) {
+func (_m *FileProcessingService) DownloadFile(ctx context.Context, id string) (io.ReadCloser, *model.ObjectInfo, error) {
 	ret := _m.Called(ctx, id)
 
-	var r0 []byte
-	if rf, ok := ret.Get(0).(func(context.Context, string) []byte); ok {
+	var r0 io.ReadCloser
+	if rf, ok := ret.Get(0).(func(context.Context, string) io.ReadCloser); ok {
 		r0 = rf(ctx, id)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]byte)
+			r0 = ret.Get(0).(io.ReadCloser)
 		}
 	}
 
-	var r1 string
-	if rf, ok := ret.Get(1).(func(context.Context, string) string); ok {
+	var r1 *model.ObjectInfo
+	if rf, ok := ret.Get(1).(func(context.Context, string) *model.ObjectInfo); ok {
 		r1 = rf(ctx, id)
 	} else {
-		r1 = ret.Get(1).(string)
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*model.ObjectInfo)
+		}
 	}
 
 	var r2 error
@@ -42,6 +42,29 @@ func (_m *FileRepository) DeleteMetadataByID(ctx context.Context, id string) err
 	return r0
 }
 
+// FindFileByID provides a mock function with given fields: ctx, id
+func (_m *FileRepository) FindFileByID(ctx context.Context, id string) (*model.FileRecord, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *model.FileRecord
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.FileRecord); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.FileRecord)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // FindFileMetadataByID provides a mock function with given fields: ctx, id
 func (_m *FileRepository) FindFileMetadataByID(ctx context.Context, id string) (map[string]string, error) {
 	ret := _m.Called(ctx, id)
@@ -86,6 +109,71 @@ func (_m *FileRepository) FindFileNameByID(ctx context.Context, id string) (stri
 	return r0, r1
 }
 
+// ListFiles provides a mock function with given fields: ctx, afterID, limit
+func (_m *FileRepository) ListFiles(ctx context.Context, afterID string, limit int) ([]*model.FileRecord, error) {
+	ret := _m.Called(ctx, afterID, limit)
+
+	var r0 []*model.FileRecord
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) []*model.FileRecord); ok {
+		r0 = rf(ctx, afterID, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.FileRecord)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
+		r1 = rf(ctx, afterID, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkFileClean provides a mock function with given fields: ctx, id
+func (_m *FileRepository) MarkFileClean(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReleaseBytes provides a mock function with given fields: ctx, id
+func (_m *FileRepository) ReleaseBytes(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReserveBytes provides a mock function with given fields: ctx, id, n
+func (_m *FileRepository) ReserveBytes(ctx context.Context, id string, n int64) error {
+	ret := _m.Called(ctx, id, n)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64) error); ok {
+		r0 = rf(ctx, id, n)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SaveFileMetadata provides a mock function with given fields: ctx, f, metadata
 func (_m *FileRepository) SaveFileMetadata(ctx context.Context, f model.FileModel, metadata string) error {
 	ret := _m.Called(ctx, f, metadata)
@@ -100,6 +188,20 @@ func (_m *FileRepository) SaveFileMetadata(ctx context.Context, f model.FileMode
 	return r0
 }
 
+// UpdateFileContentByID provides a mock function with given fields: ctx, size, contentType, id
+func (_m *FileRepository) UpdateFileContentByID(ctx context.Context, size int64, contentType string, id string) error {
+	ret := _m.Called(ctx, size, contentType, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string, string) error); ok {
+		r0 = rf(ctx, size, contentType, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateFileMetadataByID provides a mock function with given fields: ctx, metadata, id
 func (_m *FileRepository) UpdateFileMetadataByID(ctx context.Context, metadata string, id string) error {
 	ret := _m.Called(ctx, metadata, id)
@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// Actions of the access log
+const (
+	AccessRead     = "read"
+	AccessDownload = "download"
+)
+
+// AccessEvent records that the principal read or downloaded a file, the client
+// is identified by its address, user agent and the id of the request
+type AccessEvent struct {
+	ID            int64     `json:"id"`
+	TenantID      string    `json:"-"`
+	FileID        string    `json:"file_id"`
+	Action        string    `json:"action"`
+	Principal     string    `json:"principal"`
+	PrincipalKind string    `json:"principal_kind"`
+	IP            string    `json:"ip"`
+	UserAgent     string    `json:"user_agent"`
+	RequestID     string    `json:"request_id"`
+	AccessedAt    time.Time `json:"accessed_at"`
+}
+
+// AccessQuery selects the access events of FileID and of Principal when they
+// are set, accessed since From and before To when they are not zero. The events
+// are returned newest first, after the event Before when it is set.
+type AccessQuery struct {
+	FileID    string
+	Principal string
+	From      time.Time
+	To        time.Time
+	Before    int64
+	Limit     int
+}
+
+// AccessList is a page of the access events, Next is the cursor of the next
+// page and is empty on the last one
+type AccessList struct {
+	Events []*AccessEvent `json:"events"`
+	Next   string         `json:"next,omitempty"`
+}
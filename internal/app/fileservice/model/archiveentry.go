@@ -0,0 +1,10 @@
+package model
+
+// ArchiveEntry is a file inside an uploaded archive, the path of an entry of a
+// nested archive is prefixed with the path of the archive and a slash
+type ArchiveEntry struct {
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	Depth       int    `json:"depth"`
+}
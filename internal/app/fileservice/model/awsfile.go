@@ -11,6 +11,10 @@ type AWSModel struct {
 	DocNum   string
 	DocClass string
 	Metadata map[string]interface{}
+	// DeclaredType is the type sent by the client, ContentType is the type
+	// detected from the content which is stored with the file
+	DeclaredType string
+	ContentType  string
 }
 
 // GetFile ...
@@ -0,0 +1,13 @@
+package model
+
+// Event is a domain event of a file waiting in the outbox to be published,
+// Payload is the versioned protobuf message of Topic
+type Event struct {
+	ID       string
+	Topic    string
+	TenantID string
+	FileID   string
+	DocClass string
+	Payload  []byte
+	Attempts int
+}
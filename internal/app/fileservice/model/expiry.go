@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// Expiry notices recorded for a file, a file gets each of them once per validity end date
+const (
+	ExpiryNoticeExpiring = "expiring"
+	ExpiryNoticeExpired  = "expired"
+)
+
+// ExpiringFile is a file whose validity end date, read from Field of its
+// metadata, is within the queried period or has passed
+type ExpiringFile struct {
+	ID        string    `json:"id"`
+	FileName  string    `json:"file_name"`
+	DocClass  string    `json:"doc_class"`
+	DocType   string    `json:"doc_type"`
+	DocNum    string    `json:"doc_num"`
+	Field     string    `json:"field"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Expired   bool      `json:"expired"`
+}
+
+// ExpiringList is a page of the expiring files, Next is the cursor of the next
+// page and is empty on the last one
+type ExpiringList struct {
+	Files []*ExpiringFile `json:"files"`
+	Next  string          `json:"next,omitempty"`
+}
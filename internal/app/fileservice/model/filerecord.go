@@ -0,0 +1,22 @@
+package model
+
+import "encoding/json"
+
+// FileRecord is the stored row of a file
+type FileRecord struct {
+	ID          string          `json:"id"`
+	TenantID    string          `json:"tenant_id"`
+	FileName    string          `json:"file_name"`
+	DocClass    string          `json:"doc_class"`
+	DocType     string          `json:"doc_type"`
+	DocNum      string          `json:"doc_num"`
+	Size        int64           `json:"size"`
+	ContentType string          `json:"content_type"`
+	Metadata    json.RawMessage `json:"metadata"`
+}
+
+// ObjectInfo describes a downloaded file by its stored name and detected type
+type ObjectInfo struct {
+	FileName    string
+	ContentType string
+}
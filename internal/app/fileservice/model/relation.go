@@ -0,0 +1,79 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Types of the links between the files, the parent of a link is its source
+const (
+	// RelationChild links a document to its part, like a contract to its annex
+	RelationChild = "child"
+	// RelationReferences links a document to one it refers to, like an invoice to its purchase order
+	RelationReferences = "references"
+	// RelationSupersedes links a document to the older version it replaces
+	RelationSupersedes = "supersedes"
+)
+
+// Directions of the traversal of the relations
+const (
+	DirectionOutgoing = "outgoing"
+	DirectionIncoming = "incoming"
+)
+
+// Relation is a typed link from the parent file to the child file. The deletes
+// and the legal holds of the parent propagate to the child when the link
+// cascades them.
+type Relation struct {
+	ParentID      string    `json:"parent_id"`
+	ChildID       string    `json:"child_id"`
+	Type          string    `json:"type"`
+	CascadeDelete bool      `json:"cascade_delete"`
+	CascadeHold   bool      `json:"cascade_hold"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// RelatedFile is a file reached by the traversal of the relations, Depth is the
+// number of links from the start of the traversal and Via the file it was
+// reached from. Metadata is read for the delete cascade only, to authorize it.
+type RelatedFile struct {
+	FileID   string          `json:"file_id"`
+	Via      string          `json:"via"`
+	Type     string          `json:"type"`
+	Depth    int             `json:"depth"`
+	FileName string          `json:"file_name"`
+	DocClass string          `json:"doc_class"`
+	DocType  string          `json:"doc_type"`
+	Held     bool            `json:"legal_hold"`
+	Metadata json.RawMessage `json:"-"`
+}
+
+// Collection is a named folder of files of a tenant, Files are its members in order
+type Collection struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Files       []*CollectionFile `json:"files,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// CollectionFile is a member of a collection
+type CollectionFile struct {
+	FileID   string `json:"file_id"`
+	Position int    `json:"position"`
+	FileName string `json:"file_name"`
+	DocClass string `json:"doc_class"`
+	DocType  string `json:"doc_type"`
+}
+
+// CollectionRequest creates a collection or replaces its files
+type CollectionRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	FileIDs     []string `json:"file_ids"`
+}
+
+// HoldRequest sets or releases the legal hold of a file
+type HoldRequest struct {
+	Held bool `json:"held"`
+}
@@ -0,0 +1,16 @@
+package model
+
+// FileListItem is a file of the list of files, the rank and the snippet of its
+// text are set when the list is a search
+type FileListItem struct {
+	FileRecord
+	Rank    float64 `json:"rank,omitempty"`
+	Snippet string  `json:"snippet,omitempty"`
+}
+
+// FileList is a page of the list of files, Next is the cursor of the next page
+// and is empty on the last one
+type FileList struct {
+	Files []*FileListItem `json:"files"`
+	Next  string          `json:"next,omitempty"`
+}
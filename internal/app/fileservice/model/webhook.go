@@ -0,0 +1,77 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookSubscription is an http endpoint of a tenant receiving the events of
+// its files, the empty DocClasses and EventTypes match all of them
+type WebhookSubscription struct {
+	ID         string    `json:"id"`
+	TenantID   string    `json:"tenant_id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	DocClasses []string  `json:"doc_classes"`
+	EventTypes []string  `json:"event_types"`
+	Enabled    bool      `json:"enabled"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Matches reports if the event of eventType about a file of docClass is sent to
+// the subscription, an event type without the version matches all of its versions
+func (ws *WebhookSubscription) Matches(eventType, docClass string) bool {
+	return ws.Enabled && matchesAny(ws.DocClasses, docClass) && (matchesAny(ws.EventTypes, eventType) || matchesAny(ws.EventTypes, unversioned(eventType)))
+}
+
+func matchesAny(values []string, v string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// unversioned strips the version suffix of an event type like file.clean.v1
+func unversioned(eventType string) string {
+	for i := len(eventType) - 1; i >= 0; i-- {
+		if eventType[i] == '.' {
+			if i+1 < len(eventType) && eventType[i+1] == 'v' {
+				return eventType[:i]
+			}
+			break
+		}
+	}
+	return eventType
+}
+
+// WebhookDelivery is the delivery of an event to a subscription, the dead
+// deliveries exhausted their attempts and wait for a manual redelivery
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	TenantID       string          `json:"tenant_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Attempts       int             `json:"attempts"`
+	LastStatus     int             `json:"last_status"`
+	LastError      string          `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	// URL and Secret are the endpoint of the subscription for the dispatch
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// WebhookRequest is the body of the create and update requests of a subscription,
+// a nil Enabled keeps the subscription enabled on create and unchanged on update
+type WebhookRequest struct {
+	URL        string   `json:"url"`
+	DocClasses []string `json:"doc_classes"`
+	EventTypes []string `json:"event_types"`
+	Enabled    *bool    `json:"enabled"`
+}
@@ -0,0 +1,107 @@
+package policy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/unistack-org/micro/v3/logger"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+)
+
+// anonymous is evaluated for the requests without a principal
+var anonymous = &middleware.Principal{}
+
+// Engine evaluates the policy loaded from a file, the file is reloaded by Watch
+// when it changes
+type Engine struct {
+	path    string
+	modTime time.Time
+	policy  *Policy
+
+	lock sync.RWMutex
+}
+
+// NewEngine loads the policy from path, an empty path allows everything
+func NewEngine(path string) (*Engine, error) {
+	e := &Engine{
+		path:   path,
+		policy: &Policy{DefaultEffect: EffectAllow},
+	}
+	if path == "" {
+		return e, nil
+	}
+	if _, err := e.Reload(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Authorize evaluates the policy for the principal of ctx
+func (e *Engine) Authorize(ctx context.Context, action Action, res *Resource) error {
+	if isSystem(ctx) {
+		return nil
+	}
+	principal, ok := middleware.PrincipalFromContext(ctx)
+	if !ok {
+		principal = anonymous
+	}
+	e.lock.RLock()
+	p := e.policy
+	e.lock.RUnlock()
+	return p.Evaluate(principal, action, res)
+}
+
+// Reload reads the policy file if it was modified since the last load, the
+// current policy is kept if the new one is not valid
+func (e *Engine) Reload() (bool, error) {
+	info, err := os.Stat(e.path)
+	if err != nil {
+		return false, err
+	}
+	e.lock.RLock()
+	modified := !info.ModTime().Equal(e.modTime)
+	e.lock.RUnlock()
+	if !modified {
+		return false, nil
+	}
+	data, err := ioutil.ReadFile(e.path)
+	if err != nil {
+		return false, err
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return false, err
+	}
+	e.lock.Lock()
+	e.policy = p
+	e.modTime = info.ModTime()
+	e.lock.Unlock()
+	return true, nil
+}
+
+// Watch reloads the policy file every interval until ctx is done
+func (e *Engine) Watch(ctx context.Context, interval time.Duration) {
+	if e.path == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := e.Reload()
+			if err != nil {
+				logger.Errorf(ctx, "Error reloading policy %s, %v", e.path, err)
+				continue
+			}
+			if reloaded {
+				logger.Infof(ctx, "Policy %s reloaded", e.path)
+			}
+		}
+	}
+}
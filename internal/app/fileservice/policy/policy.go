@@ -0,0 +1,207 @@
+// Package policy evaluates the access rules over the principal of the request
+// and the attributes of the document it works with.
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+)
+
+// Action ...
+type Action string
+
+// Actions which are authorized by the policy
+const (
+	ActionUpload   Action = "upload"
+	ActionRead     Action = "read"
+	ActionUpdate   Action = "update"
+	ActionDownload Action = "download"
+	ActionSearch   Action = "search"
+	ActionDelete   Action = "delete"
+	// ActionManageWebhooks is authorized for the webhook subscriptions of the tenant
+	ActionManageWebhooks Action = "manage_webhooks"
+	// ActionHold is authorized for setting and releasing the legal hold of a file
+	ActionHold Action = "hold"
+	// ActionManageCollections is authorized for the collections of the tenant
+	ActionManageCollections Action = "manage_collections"
+	// ActionAudit is authorized for the access log of the files of the tenant
+	ActionAudit Action = "audit"
+)
+
+// Effects of the rules
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Resource holds the attributes of the document the action is applied to,
+// Fields are the metadata fields written by an update
+type Resource struct {
+	ID       string
+	DocClass string
+	DocType  string
+	Metadata map[string]interface{}
+	Fields   []string
+}
+
+// Authorizer ...
+type Authorizer interface {
+	Authorize(ctx context.Context, action Action, res *Resource) error
+}
+
+type systemKey struct{}
+
+// NewSystemContext marks ctx as an internal operation of the service, like the
+// rollback of a failed upload, which is not subject to the policy
+func NewSystemContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, systemKey{}, true)
+}
+
+func isSystem(ctx context.Context) bool {
+	v, _ := ctx.Value(systemKey{}).(bool)
+	return v
+}
+
+// Rule matches when all of its non empty conditions hold, roles and not_roles
+// are checked against the roles of the principal, claims against its claims
+type Rule struct {
+	Name       string                 `json:"name"`
+	Effect     string                 `json:"effect"`
+	Actions    []Action               `json:"actions"`
+	DocClasses []string               `json:"doc_classes"`
+	DocTypes   []string               `json:"doc_types"`
+	Metadata   map[string]interface{} `json:"metadata"`
+	Fields     []string               `json:"fields"`
+	Roles      []string               `json:"roles"`
+	NotRoles   []string               `json:"not_roles"`
+	Claims     map[string]interface{} `json:"claims"`
+}
+
+// Policy is a set of rules, a matching deny rule wins over the allow rules and
+// DefaultEffect applies when no rule matches
+type Policy struct {
+	DefaultEffect string  `json:"default_effect"`
+	Rules         []*Rule `json:"rules"`
+}
+
+// Parse parses and validates a policy document
+func Parse(data []byte) (*Policy, error) {
+	p := &Policy{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("Error parsing policy, %v", err)
+	}
+	if p.DefaultEffect == "" {
+		p.DefaultEffect = EffectDeny
+	}
+	if p.DefaultEffect != EffectAllow && p.DefaultEffect != EffectDeny {
+		return nil, fmt.Errorf("Unknown default effect %s", p.DefaultEffect)
+	}
+	for i, r := range p.Rules {
+		if r.Effect != EffectAllow && r.Effect != EffectDeny {
+			return nil, fmt.Errorf("Unknown effect %s of rule %d", r.Effect, i)
+		}
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule-%d", i)
+		}
+	}
+	return p, nil
+}
+
+// Evaluate returns a forbidden error if p denies the action of principal on res
+func (p *Policy) Evaluate(principal *middleware.Principal, action Action, res *Resource) error {
+	allowed := p.DefaultEffect == EffectAllow
+	for _, r := range p.Rules {
+		if !r.matches(principal, action, res) {
+			continue
+		}
+		if r.Effect == EffectDeny {
+			return apperrors.Forbidden(apperrors.CodeForbidden, "Access to %s denied by rule %s", action, r.Name)
+		}
+		allowed = true
+	}
+	if !allowed {
+		return apperrors.Forbidden(apperrors.CodeForbidden, "Access to %s denied", action)
+	}
+	return nil
+}
+
+func (r *Rule) matches(principal *middleware.Principal, action Action, res *Resource) bool {
+	if len(r.Actions) > 0 && !containsAction(r.Actions, action) {
+		return false
+	}
+	if len(r.DocClasses) > 0 && !contains(r.DocClasses, res.DocClass) {
+		return false
+	}
+	if len(r.DocTypes) > 0 && !contains(r.DocTypes, res.DocType) {
+		return false
+	}
+	for k, v := range r.Metadata {
+		if !equal(res.Metadata[k], v) {
+			return false
+		}
+	}
+	if len(r.Fields) > 0 && !containsAny(r.Fields, res.Fields) {
+		return false
+	}
+	if len(r.Roles) > 0 && !containsAny(r.Roles, principal.Roles) {
+		return false
+	}
+	if len(r.NotRoles) > 0 && containsAny(r.NotRoles, principal.Roles) {
+		return false
+	}
+	for k, v := range r.Claims {
+		if !claimMatches(principal.Claims[k], v) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAction(actions []Action, action Action) bool {
+	for _, a := range actions {
+		if a == action || a == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(values, others []string) bool {
+	for _, v := range others {
+		if contains(values, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// claimMatches also accepts a list claim containing the expected value
+func claimMatches(claim, expected interface{}) bool {
+	if l, ok := claim.([]interface{}); ok {
+		for _, c := range l {
+			if equal(c, expected) {
+				return true
+			}
+		}
+		return false
+	}
+	return equal(claim, expected)
+}
+
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b) || (a != nil && b != nil && fmt.Sprint(a) == fmt.Sprint(b))
+}
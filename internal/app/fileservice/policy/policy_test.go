@@ -0,0 +1,124 @@
+package policy_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+)
+
+const testPolicy = `{
+	"default_effect": "allow",
+	"rules": [
+		{
+			"name": "hr-documents-only-for-hr",
+			"effect": "deny",
+			"actions": ["*"],
+			"doc_classes": ["HR"],
+			"not_roles": ["hr"]
+		},
+		{
+			"name": "back-office-fields",
+			"effect": "deny",
+			"actions": ["update"],
+			"fields": ["status"],
+			"not_roles": ["back_office"]
+		}
+	]
+}`
+
+func preparePolicy(t *testing.T) *policy.Policy {
+	p, err := policy.Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("Error parsing policy, %v", err)
+	}
+	return p
+}
+
+func TestPolicy_Evaluate_DocClass(t *testing.T) {
+	p := preparePolicy(t)
+	hr := &middleware.Principal{Subject: "hr", Roles: []string{"hr"}}
+	other := &middleware.Principal{Subject: "other", Roles: []string{"sales"}}
+	res := &policy.Resource{DocClass: "HR"}
+
+	assert.Nil(t, p.Evaluate(hr, policy.ActionRead, res))
+	err := p.Evaluate(other, policy.ActionDownload, res)
+	assert.True(t, apperrors.Is(err, apperrors.KindForbidden))
+	assert.Nil(t, p.Evaluate(other, policy.ActionDownload, &policy.Resource{DocClass: "SALES"}))
+}
+
+func TestPolicy_Evaluate_Fields(t *testing.T) {
+	p := preparePolicy(t)
+	backOffice := &middleware.Principal{Subject: "bo", Roles: []string{"back_office"}}
+	other := &middleware.Principal{Subject: "other"}
+
+	assert.Nil(t, p.Evaluate(backOffice, policy.ActionUpdate, &policy.Resource{Fields: []string{"status"}}))
+	assert.NotNil(t, p.Evaluate(other, policy.ActionUpdate, &policy.Resource{Fields: []string{"iin", "status"}}))
+	assert.Nil(t, p.Evaluate(other, policy.ActionUpdate, &policy.Resource{Fields: []string{"iin"}}))
+}
+
+func TestPolicy_Evaluate_DefaultDeny(t *testing.T) {
+	p, err := policy.Parse([]byte(`{"rules": [{"effect": "allow", "actions": ["read"], "claims": {"department": "finance"}}]}`))
+	if err != nil {
+		t.Fatalf("Error parsing policy, %v", err)
+	}
+	finance := &middleware.Principal{Claims: map[string]interface{}{"department": []interface{}{"finance"}}}
+
+	assert.Nil(t, p.Evaluate(finance, policy.ActionRead, &policy.Resource{}))
+	assert.NotNil(t, p.Evaluate(finance, policy.ActionDelete, &policy.Resource{}))
+	assert.NotNil(t, p.Evaluate(&middleware.Principal{}, policy.ActionRead, &policy.Resource{}))
+}
+
+func TestParse_UnknownEffect(t *testing.T) {
+	_, err := policy.Parse([]byte(`{"rules": [{"effect": "maybe"}]}`))
+
+	assert.NotNil(t, err)
+}
+
+func TestEngine_Reload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policy")
+	if err != nil {
+		t.Fatalf("Error creating temp dir, %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "policy.json")
+	if err := ioutil.WriteFile(path, []byte(`{"default_effect": "allow"}`), 0600); err != nil {
+		t.Fatalf("Error writing policy, %v", err)
+	}
+	engine, err := policy.NewEngine(path)
+	if err != nil {
+		t.Fatalf("Error creating engine, %v", err)
+	}
+	ctx := middleware.NewPrincipalContext(context.Background(), &middleware.Principal{Subject: "user"})
+
+	assert.Nil(t, engine.Authorize(ctx, policy.ActionDelete, &policy.Resource{}))
+
+	if err := ioutil.WriteFile(path, []byte(`{"default_effect": "deny"}`), 0600); err != nil {
+		t.Fatalf("Error writing policy, %v", err)
+	}
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	reloaded, err := engine.Reload()
+
+	assert.Nil(t, err)
+	assert.True(t, reloaded)
+	assert.NotNil(t, engine.Authorize(ctx, policy.ActionDelete, &policy.Resource{}))
+	assert.Nil(t, engine.Authorize(policy.NewSystemContext(ctx), policy.ActionDelete, &policy.Resource{}))
+
+	if err := ioutil.WriteFile(path, []byte(`{`), 0600); err != nil {
+		t.Fatalf("Error writing policy, %v", err)
+	}
+	future = future.Add(time.Minute)
+	os.Chtimes(path, future, future)
+	_, err = engine.Reload()
+
+	assert.NotNil(t, err)
+	assert.NotNil(t, engine.Authorize(ctx, policy.ActionDelete, &policy.Resource{}))
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/tracer"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+)
+
+// AccessRepository keeps the access log of the files, the events outlive the
+// files they are about
+type AccessRepository interface {
+	// SaveAccessEvents writes the events of the tenant of ctx in one statement
+	SaveAccessEvents(ctx context.Context, events []*model.AccessEvent) error
+	// FindAccessEvents returns the events selected by q newest first
+	FindAccessEvents(ctx context.Context, q *model.AccessQuery) ([]*model.AccessEvent, error)
+}
+
+// SQLAccessRepository ...
+type SQLAccessRepository struct {
+	db *sqlx.DB
+}
+
+// NewSQLAccessRepository ...
+func NewSQLAccessRepository(db *sqlx.DB) *SQLAccessRepository {
+	return &SQLAccessRepository{
+		db: db,
+	}
+}
+
+// accessRow is a row of the access_log table
+type accessRow struct {
+	ID            int64     `db:"id"`
+	FileID        string    `db:"file_id"`
+	Action        string    `db:"action"`
+	Principal     string    `db:"principal"`
+	PrincipalKind string    `db:"principal_kind"`
+	IP            string    `db:"ip"`
+	UserAgent     string    `db:"user_agent"`
+	RequestID     string    `db:"request_id"`
+	AccessedAt    time.Time `db:"accessed_at"`
+}
+
+// SaveAccessEvents passes the columns of the events as arrays so that the
+// statement is the same for all the sizes of the batch
+func (ar *SQLAccessRepository) SaveAccessEvents(ctx context.Context, events []*model.AccessEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	tx, tenantID, err := beginTenantTx(ctx, ar.db)
+	if err != nil {
+		return err
+	}
+	var fileIDs, actions, principals, principalKinds, ips, userAgents, requestIDs, accessedAt []string
+	for _, e := range events {
+		fileIDs = append(fileIDs, e.FileID)
+		actions = append(actions, e.Action)
+		principals = append(principals, e.Principal)
+		principalKinds = append(principalKinds, e.PrincipalKind)
+		ips = append(ips, e.IP)
+		userAgents = append(userAgents, e.UserAgent)
+		requestIDs = append(requestIDs, e.RequestID)
+		accessedAt = append(accessedAt, e.AccessedAt.Format(time.RFC3339Nano))
+	}
+	if _, err := execContext(
+		ctx,
+		tx,
+		"save_access_events",
+		opentracing.Tags{tracer.TagTenant: tenantID},
+		`INSERT INTO ACCESS_LOG(TENANT_ID, FILE_ID, ACTION, PRINCIPAL, PRINCIPAL_KIND, IP, USER_AGENT, REQUEST_ID, ACCESSED_AT)
+SELECT $1, * FROM unnest($2::varchar[], $3::varchar[], $4::varchar[], $5::varchar[], $6::varchar[], $7::varchar[], $8::varchar[], $9::timestamptz[])`,
+		tenantID,
+		pq.Array(fileIDs),
+		pq.Array(actions),
+		pq.Array(principals),
+		pq.Array(principalKinds),
+		pq.Array(ips),
+		pq.Array(userAgents),
+		pq.Array(requestIDs),
+		pq.Array(accessedAt),
+	); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error saving access events, %v", err)
+	}
+	return tx.Commit()
+}
+
+// FindAccessEvents orders the events by the order they were written in
+func (ar *SQLAccessRepository) FindAccessEvents(ctx context.Context, q *model.AccessQuery) ([]*model.AccessEvent, error) {
+	rows := []accessRow{}
+	tx, tenantID, err := beginTenantTx(ctx, ar.db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	conditions := []string{"TENANT_ID=$1"}
+	args := []interface{}{tenantID}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if q.FileID != "" {
+		where("FILE_ID=$%d", q.FileID)
+	}
+	if q.Principal != "" {
+		where("PRINCIPAL=$%d", q.Principal)
+	}
+	if !q.From.IsZero() {
+		where("ACCESSED_AT>=$%d", q.From)
+	}
+	if !q.To.IsZero() {
+		where("ACCESSED_AT<$%d", q.To)
+	}
+	if q.Before != 0 {
+		where("ID<$%d", q.Before)
+	}
+	args = append(args, q.Limit)
+	if err := selectContext(
+		ctx,
+		tx,
+		"find_access_events",
+		opentracing.Tags{tracer.TagFileID: q.FileID, tracer.TagTenant: tenantID},
+		&rows,
+		fmt.Sprintf("SELECT ID, FILE_ID, ACTION, PRINCIPAL, PRINCIPAL_KIND, IP, USER_AGENT, REQUEST_ID, ACCESSED_AT FROM ACCESS_LOG WHERE %s ORDER BY ID DESC LIMIT $%d", strings.Join(conditions, " AND "), len(args)),
+		args...,
+	); err != nil {
+		return nil, fmt.Errorf("Error reading access events, %v", err)
+	}
+	events := make([]*model.AccessEvent, 0, len(rows))
+	for _, r := range rows {
+		events = append(events, &model.AccessEvent{
+			ID:            r.ID,
+			TenantID:      tenantID,
+			FileID:        r.FileID,
+			Action:        r.Action,
+			Principal:     r.Principal,
+			PrincipalKind: r.PrincipalKind,
+			IP:            r.IP,
+			UserAgent:     r.UserAgent,
+			RequestID:     r.RequestID,
+			AccessedAt:    r.AccessedAt,
+		})
+	}
+	return events, nil
+}
@@ -0,0 +1,69 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+func TestAccessRepository_SaveAccessEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLAccessRepository(sqlx.NewDb(db, "sqlmock"))
+	at := time.Date(2030, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO ACCESS_LOG\(.*\)\s+SELECT \$1, \* FROM unnest\(\$2::varchar\[\], .*\$9::timestamptz\[\]\)$`).WithArgs(
+		tenant.DefaultID,
+		pq.Array([]string{"f1", "f2"}),
+		pq.Array([]string{model.AccessRead, model.AccessDownload}),
+		pq.Array([]string{"bob", "bob"}),
+		pq.Array([]string{"user", "user"}),
+		pq.Array([]string{"10.0.0.1", "10.0.0.1"}),
+		pq.Array([]string{"curl/7.68.0", "curl/7.68.0"}),
+		pq.Array([]string{"req-1", "req-2"}),
+		pq.Array([]string{"2030-05-01T12:00:00Z", "2030-05-01T12:00:00Z"}),
+	).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	err = repo.SaveAccessEvents(context.Background(), []*model.AccessEvent{
+		{FileID: "f1", Action: model.AccessRead, Principal: "bob", PrincipalKind: "user", IP: "10.0.0.1", UserAgent: "curl/7.68.0", RequestID: "req-1", AccessedAt: at},
+		{FileID: "f2", Action: model.AccessDownload, Principal: "bob", PrincipalKind: "user", IP: "10.0.0.1", UserAgent: "curl/7.68.0", RequestID: "req-2", AccessedAt: at},
+	})
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestAccessRepository_FindAccessEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLAccessRepository(sqlx.NewDb(db, "sqlmock"))
+	from := time.Date(2030, 5, 1, 0, 0, 0, 0, time.UTC)
+	columns := []string{"id", "file_id", "action", "principal", "principal_kind", "ip", "user_agent", "request_id", "accessed_at"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`WHERE TENANT_ID=\$1 AND PRINCIPAL=\$2 AND ACCESSED_AT>=\$3 AND ID<\$4 ORDER BY ID DESC LIMIT \$5`).
+		WithArgs(tenant.DefaultID, "bob", from, int64(40), 2).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(39, "f1", "read", "bob", "user", "10.0.0.1", "curl/7.68.0", "req-1", from.Add(time.Hour)))
+	mock.ExpectRollback()
+
+	events, err := repo.FindAccessEvents(context.Background(), &model.AccessQuery{Principal: "bob", From: from, Before: 40, Limit: 2})
+
+	assert.Nil(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, int64(39), events[0].ID)
+	assert.Equal(t, "f1", events[0].FileID)
+	assert.Equal(t, tenant.DefaultID, events[0].TenantID)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+)
+
+// APIKeyRepository keeps the hashed api keys of the service to service callers
+type APIKeyRepository struct {
+	db *sqlx.DB
+}
+
+// NewAPIKeyRepository ...
+func NewAPIKeyRepository(db *sqlx.DB) *APIKeyRepository {
+	return &APIKeyRepository{
+		db: db,
+	}
+}
+
+// FindAPIKey ...
+func (akr *APIKeyRepository) FindAPIKey(ctx context.Context, hash string) (*middleware.Principal, error) {
+	name := ""
+	tenant := ""
+	roles := ""
+	scopes := ""
+	if err := akr.db.QueryRowContext(
+		ctx,
+		"SELECT NAME, COALESCE(TENANT_ID, ''), ROLES, SCOPES FROM API_KEYS WHERE KEY_HASH=$1 AND REVOKED_AT IS NULL",
+		hash,
+	).Scan(&name, &tenant, &roles, &scopes); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, middleware.ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("Error reading api key from DB, %v", err)
+	}
+	return &middleware.Principal{
+		Subject: name,
+		Kind:    middleware.PrincipalService,
+		Tenant:  tenant,
+		Roles:   strings.Fields(roles),
+		Scopes:  strings.Fields(scopes),
+	}, nil
+}
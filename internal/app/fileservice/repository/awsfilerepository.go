@@ -3,12 +3,30 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/tracer"
+	"github.com/vielendanke/file-service/internal/app/fileservice/events"
 	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
 )
 
+// uniqueViolation is the postgres error code of a unique constraint violation
+const uniqueViolation = "23505"
+
+// quotaLockClass is the first key of the advisory locks of the tenant quotas
+const quotaLockClass = 1
+
+// reservationTTL is how long the bytes reserved by an upload count against the
+// quota unless the upload reserves more, the reservations of the uploads of a
+// crashed instance expire
+const reservationTTL = "1 hour"
+
 // AWSFileRepository ...
 type AWSFileRepository struct {
 	db *sqlx.DB
@@ -21,24 +39,81 @@ func NewAWSFileRepository(db *sqlx.DB) FileRepository {
 	}
 }
 
+// fileRow is the row of the files table
+type fileRow struct {
+	ID          string `db:"id"`
+	TenantID    string `db:"tenant_id"`
+	FileName    string `db:"file_name"`
+	DocClass    string `db:"doc_class"`
+	DocType     string `db:"doc_type"`
+	DocNum      string `db:"doc_num"`
+	Size        int64  `db:"size"`
+	ContentType string `db:"content_type"`
+	Metadata    string `db:"metadata"`
+}
+
+func (fr *fileRow) record() *model.FileRecord {
+	return &model.FileRecord{
+		ID:          fr.ID,
+		TenantID:    fr.TenantID,
+		FileName:    fr.FileName,
+		DocClass:    fr.DocClass,
+		DocType:     fr.DocType,
+		DocNum:      fr.DocNum,
+		Size:        fr.Size,
+		ContentType: fr.ContentType,
+		Metadata:    json.RawMessage(fr.Metadata),
+	}
+}
+
+// beginTenantTx starts a transaction bound to the tenant of ctx, the row level
+// security policies of the files table read the tenant from app.tenant_id
+func beginTenantTx(ctx context.Context, db *sqlx.DB) (*sqlx.Tx, string, error) {
+	tenantID := tenant.FromContext(ctx).ID
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error starting transaction, %v", err)
+	}
+	if _, err := execContext(ctx, tx, "set_tenant", opentracing.Tags{tracer.TagTenant: tenantID}, "SELECT set_config('app.tenant_id', $1, true)", tenantID); err != nil {
+		tx.Rollback()
+		return nil, "", fmt.Errorf("Error setting tenant of transaction, %v", err)
+	}
+	return tx, tenantID, nil
+}
+
 // DeleteMetadataByID ...
 func (afr *AWSFileRepository) DeleteMetadataByID(ctx context.Context, id string) error {
-	tx := afr.db.MustBegin()
-	res, err := tx.ExecContext(ctx, "DELETE FROM FILES WHERE ID=$1", id)
+	tx, tenantID, err := beginTenantTx(ctx, afr.db)
 	if err != nil {
+		return err
+	}
+	docClass := ""
+	if err := queryRowContext(
+		ctx,
+		tx,
+		"delete_file_metadata",
+		opentracing.Tags{tracer.TagFileID: id, tracer.TagTenant: tenantID},
+		"DELETE FROM FILES WHERE ID=$1 AND TENANT_ID=$2 RETURNING DOC_CLASS",
+		[]interface{}{id, tenantID},
+		&docClass,
+	); err != nil {
 		rollbackErr := tx.Rollback()
 		if rollbackErr != nil {
 			return fmt.Errorf("Error during rollback, %v", rollbackErr)
 		}
+		if err == sql.ErrNoRows {
+			return apperrors.NotFound(apperrors.CodeFileNotFound, "File %s not found", id)
+		}
 		return fmt.Errorf("Error during delete the file metadata, %v", err)
 	}
-	num, rowsErr := res.RowsAffected()
-	if rowsErr != nil {
+	event, err := events.FileDeleted(tenantID, id, docClass)
+	if err != nil {
 		tx.Rollback()
-		return fmt.Errorf("Error during count rows affected, %v", err)
+		return err
 	}
-	if num == 0 {
-		return fmt.Errorf("Error, affected rows is 0")
+	if err := insertEvent(ctx, tx, event); err != nil {
+		tx.Rollback()
+		return err
 	}
 	commitErr := tx.Commit()
 	if commitErr != nil {
@@ -50,28 +125,52 @@ func (afr *AWSFileRepository) DeleteMetadataByID(ctx context.Context, id string)
 // CheckIfExists ...
 func (afr *AWSFileRepository) CheckIfExists(ctx context.Context, f model.FileModel) error {
 	awsModel := f.(*model.AWSModel)
-	rows := afr.db.QueryRowContext(
+	tx, tenantID, err := beginTenantTx(ctx, afr.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	id := ""
+	if err := queryRowContext(
 		ctx,
-		"SELECT ID FROM FILES WHERE DOC_CLASS=$1 AND DOC_TYPE=$2 AND DOC_NUM=$3",
-		awsModel.GetDocClass(),
-		awsModel.GetDocType(),
-		awsModel.GetDocNum(),
-	)
-	if rows.Scan() == sql.ErrNoRows {
-		return nil
+		tx,
+		"find_file_by_doc_num",
+		opentracing.Tags{tracer.TagDocClass: awsModel.GetDocClass(), tracer.TagTenant: tenantID},
+		"SELECT ID FROM FILES WHERE DOC_CLASS=$1 AND DOC_TYPE=$2 AND DOC_NUM=$3 AND TENANT_ID=$4",
+		[]interface{}{awsModel.GetDocClass(), awsModel.GetDocType(), awsModel.GetDocNum(), tenantID},
+		&id,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("Error checking if file exists, %v", err)
 	}
-	return fmt.Errorf("File alredy exists in the system, to update - use update enpdoint, or for new document save as new")
+	return apperrors.AlreadyExists(
+		apperrors.CodeFileAlreadyExists,
+		"File alredy exists in the system, to update - use update enpdoint, or for new document save as new",
+	)
 }
 
 // SaveFileMetadata ...
 func (afr *AWSFileRepository) SaveFileMetadata(ctx context.Context, f model.FileModel, metadata string) error {
 	awsFile := f.(*model.AWSModel)
-	tx := afr.db.MustBegin()
-	res, err := tx.ExecContext(ctx, "INSERT INTO FILES(ID, FILE_NAME, DOC_CLASS, DOC_TYPE, DOC_NUM, METADATA) VALUES($1, $2, $3, $4, $5, $6)",
-		awsFile.GetFileID(), awsFile.GetFileName(), awsFile.GetDocClass(), awsFile.GetDocType(), awsFile.GetDocNum(), metadata,
+	tx, tenantID, err := beginTenantTx(ctx, afr.db)
+	if err != nil {
+		return err
+	}
+	if err := checkObjectQuota(ctx, tx, tenantID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	tags := opentracing.Tags{tracer.TagFileID: awsFile.GetFileID(), tracer.TagDocClass: awsFile.GetDocClass(), tracer.TagTenant: tenantID}
+	res, err := execContext(ctx, tx, "save_file_metadata", tags, "INSERT INTO FILES(ID, FILE_NAME, DOC_CLASS, DOC_TYPE, DOC_NUM, METADATA, TENANT_ID) VALUES($1, $2, $3, $4, $5, $6, $7)",
+		awsFile.GetFileID(), awsFile.GetFileName(), awsFile.GetDocClass(), awsFile.GetDocType(), awsFile.GetDocNum(), metadata, tenantID,
 	)
 	if err != nil {
 		tx.Rollback()
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == uniqueViolation {
+			return apperrors.Wrap(err, apperrors.KindAlreadyExists, apperrors.CodeFileAlreadyExists, "File already exists in the system")
+		}
 		return fmt.Errorf("Error inserting new file to DB, %v", err)
 	}
 	rows, rowsErr := res.RowsAffected()
@@ -83,8 +182,16 @@ func (afr *AWSFileRepository) SaveFileMetadata(ctx context.Context, f model.File
 		tx.Rollback()
 		return fmt.Errorf("No insertions found, %d", rows)
 	}
-	tx.Commit()
-	return nil
+	event, err := events.FileCreated(tenantID, awsFile, metadata)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := insertEvent(ctx, tx, event); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
 }
 
 // FindFileMetadataByID ...
@@ -93,11 +200,23 @@ func (afr *AWSFileRepository) FindFileMetadataByID(ctx context.Context, id strin
 	docType := ""
 	docClass := ""
 	docNum := ""
-	if err := afr.db.QueryRowContext(
+	tx, tenantID, err := beginTenantTx(ctx, afr.db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := queryRowContext(
 		ctx,
-		"SELECT DOC_CLASS, DOC_TYPE, DOC_NUM, METADATA FROM FILES WHERE ID=$1",
-		id,
-	).Scan(&docClass, &docType, &docNum, &jsonMetadata); err != nil {
+		tx,
+		"find_file_metadata",
+		opentracing.Tags{tracer.TagFileID: id, tracer.TagTenant: tenantID},
+		"SELECT DOC_CLASS, DOC_TYPE, DOC_NUM, METADATA FROM FILES WHERE ID=$1 AND TENANT_ID=$2",
+		[]interface{}{id, tenantID},
+		&docClass, &docType, &docNum, &jsonMetadata,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperrors.NotFound(apperrors.CodeFileNotFound, "File %s not found", id)
+		}
 		return nil, fmt.Errorf("Error while reading from DB, %v", err)
 	}
 	properties := make(map[string]string)
@@ -111,7 +230,23 @@ func (afr *AWSFileRepository) FindFileMetadataByID(ctx context.Context, id strin
 // FindFileNameByID ...
 func (afr *AWSFileRepository) FindFileNameByID(ctx context.Context, id string) (string, error) {
 	filename := ""
-	if row := afr.db.QueryRowContext(ctx, "SELECT FILE_NAME FROM FILES WHERE ID=$1", id).Scan(&filename); row != nil {
+	tx, tenantID, err := beginTenantTx(ctx, afr.db)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	if row := queryRowContext(
+		ctx,
+		tx,
+		"find_file_name",
+		opentracing.Tags{tracer.TagFileID: id, tracer.TagTenant: tenantID},
+		"SELECT FILE_NAME FROM FILES WHERE ID=$1 AND TENANT_ID=$2",
+		[]interface{}{id, tenantID},
+		&filename,
+	); row != nil {
+		if row == sql.ErrNoRows {
+			return "", apperrors.NotFound(apperrors.CodeFileNotFound, "File %s not found", id)
+		}
 		return "", fmt.Errorf("Error fetching filename from DB, %v", row)
 	}
 	return filename, nil
@@ -119,21 +254,257 @@ func (afr *AWSFileRepository) FindFileNameByID(ctx context.Context, id string) (
 
 // UpdateFileMetadataByID ...
 func (afr *AWSFileRepository) UpdateFileMetadataByID(ctx context.Context, metadata, id string) error {
-	tx := afr.db.MustBegin()
-	res, err := tx.ExecContext(ctx, "UPDATE FILES SET METADATA=$1 WHERE ID=$2", metadata, id)
+	tx, tenantID, err := beginTenantTx(ctx, afr.db)
 	if err != nil {
+		return err
+	}
+	docClass := ""
+	if err := queryRowContext(
+		ctx,
+		tx,
+		"update_file_metadata",
+		opentracing.Tags{tracer.TagFileID: id, tracer.TagTenant: tenantID},
+		"UPDATE FILES SET METADATA=$1 WHERE ID=$2 AND TENANT_ID=$3 RETURNING DOC_CLASS",
+		[]interface{}{metadata, id, tenantID},
+		&docClass,
+	); err != nil {
 		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return apperrors.NotFound(apperrors.CodeFileNotFound, "File %s not found", id)
+		}
 		return fmt.Errorf("Error updating metadata, %v", err)
 	}
-	rows, rowsErr := res.RowsAffected()
-	if rowsErr != nil {
+	event, err := events.FileMetadataChanged(tenantID, id, docClass, metadata)
+	if err != nil {
 		tx.Rollback()
-		return fmt.Errorf("Error inserting data in DB, %v", rowsErr)
+		return err
 	}
-	if rows == 0 {
+	if err := insertEvent(ctx, tx, event); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateFileContentByID records the size and the detected type of the stored file
+func (afr *AWSFileRepository) UpdateFileContentByID(ctx context.Context, size int64, contentType, id string) error {
+	tx, tenantID, err := beginTenantTx(ctx, afr.db)
+	if err != nil {
+		return err
+	}
+	tags := opentracing.Tags{tracer.TagFileID: id, tracer.TagBytes: size, tracer.TagTenant: tenantID}
+	if _, err := execContext(ctx, tx, "update_file_content", tags, "UPDATE FILES SET SIZE=$1, CONTENT_TYPE=$2 WHERE ID=$3 AND TENANT_ID=$4", size, contentType, id, tenantID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error updating file size, %v", err)
+	}
+	// the stored size replaces the bytes reserved by the upload
+	if err := deleteReservation(ctx, tx, tenantID, id); err != nil {
 		tx.Rollback()
-		return fmt.Errorf("No insertions found")
+		return err
+	}
+	return tx.Commit()
+}
+
+// MarkFileClean records that the file was moved to the clean store
+func (afr *AWSFileRepository) MarkFileClean(ctx context.Context, id string) error {
+	size := int64(0)
+	docClass := ""
+	tx, tenantID, err := beginTenantTx(ctx, afr.db)
+	if err != nil {
+		return err
+	}
+	if err := queryRowContext(
+		ctx,
+		tx,
+		"mark_file_clean",
+		opentracing.Tags{tracer.TagFileID: id, tracer.TagTenant: tenantID},
+		"SELECT SIZE, DOC_CLASS FROM FILES WHERE ID=$1 AND TENANT_ID=$2",
+		[]interface{}{id, tenantID},
+		&size, &docClass,
+	); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return apperrors.NotFound(apperrors.CodeFileNotFound, "File %s not found", id)
+		}
+		return fmt.Errorf("Error reading file from DB, %v", err)
+	}
+	event, err := events.FileClean(tenantID, id, docClass, size)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := insertEvent(ctx, tx, event); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReserveBytes adds n bytes to the quota reservation of the upload of the file
+// id, it fails once the stored and the reserved bytes of the tenant of ctx would
+// exceed its bytes quota. UpdateFileContentByID or ReleaseBytes end the
+// reservation
+func (afr *AWSFileRepository) ReserveBytes(ctx context.Context, id string, n int64) error {
+	maxBytes := tenant.FromContext(ctx).MaxBytes
+	tx, tenantID, err := beginTenantTx(ctx, afr.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := lockTenantQuota(ctx, tx, tenantID); err != nil {
+		return err
+	}
+	tags := opentracing.Tags{tracer.TagFileID: id, tracer.TagBytes: n, tracer.TagTenant: tenantID}
+	if _, err := execContext(ctx, tx, "delete_expired_reservations", tags, "DELETE FROM QUOTA_RESERVATIONS WHERE TENANT_ID=$1 AND EXPIRES_AT <= NOW()", tenantID); err != nil {
+		return fmt.Errorf("Error deleting expired quota reservations, %v", err)
+	}
+	_, bytes, err := tenantUsage(ctx, tx, tenantID)
+	if err != nil {
+		return err
+	}
+	if maxBytes > 0 && bytes+n > maxBytes {
+		return bytesQuotaError()
+	}
+	if _, err := execContext(
+		ctx,
+		tx,
+		"reserve_bytes",
+		tags,
+		"INSERT INTO QUOTA_RESERVATIONS(FILE_ID, TENANT_ID, BYTES, EXPIRES_AT) VALUES($1, $2, $3, NOW() + $4::interval) "+
+			"ON CONFLICT (FILE_ID) DO UPDATE SET BYTES=QUOTA_RESERVATIONS.BYTES+EXCLUDED.BYTES, EXPIRES_AT=EXCLUDED.EXPIRES_AT",
+		id, tenantID, n, reservationTTL,
+	); err != nil {
+		return fmt.Errorf("Error reserving quota, %v", err)
+	}
+	return tx.Commit()
+}
+
+// ReleaseBytes ends the quota reservation of the upload of the file id which
+// did not complete
+func (afr *AWSFileRepository) ReleaseBytes(ctx context.Context, id string) error {
+	tx, tenantID, err := beginTenantTx(ctx, afr.db)
+	if err != nil {
+		return err
+	}
+	if err := deleteReservation(ctx, tx, tenantID, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkObjectQuota rejects a new file of the tenant of ctx which reached its
+// object or bytes quota, the lock is held until tx ends so that the concurrent
+// inserts wait for the count to include this one
+func checkObjectQuota(ctx context.Context, tx *sqlx.Tx, tenantID string) error {
+	t := tenant.FromContext(ctx)
+	if t.MaxObjects <= 0 && t.MaxBytes <= 0 {
+		return nil
+	}
+	if err := lockTenantQuota(ctx, tx, tenantID); err != nil {
+		return err
+	}
+	objects, bytes, err := tenantUsage(ctx, tx, tenantID)
+	if err != nil {
+		return err
+	}
+	if t.MaxObjects > 0 && objects >= t.MaxObjects {
+		return apperrors.QuotaExceeded(apperrors.CodeQuotaExceeded, "Object quota of the tenant exceeded, %d files stored", objects)
+	}
+	if t.MaxBytes > 0 && bytes >= t.MaxBytes {
+		return bytesQuotaError()
+	}
+	return nil
+}
+
+// lockTenantQuota serializes the quota checks of the tenant until tx ends
+func lockTenantQuota(ctx context.Context, tx *sqlx.Tx, tenantID string) error {
+	if _, err := execContext(ctx, tx, "lock_tenant_quota", opentracing.Tags{tracer.TagTenant: tenantID}, "SELECT pg_advisory_xact_lock($1, hashtext($2))", quotaLockClass, tenantID); err != nil {
+		return fmt.Errorf("Error locking tenant quota, %v", err)
+	}
+	return nil
+}
+
+// tenantUsage returns the number of files of the tenant and their bytes
+// together with the bytes reserved by the uploads in progress
+func tenantUsage(ctx context.Context, tx *sqlx.Tx, tenantID string) (int64, int64, error) {
+	objects := int64(0)
+	bytes := int64(0)
+	if err := queryRowContext(
+		ctx,
+		tx,
+		"tenant_usage",
+		opentracing.Tags{tracer.TagTenant: tenantID},
+		"SELECT COUNT(*), COALESCE(SUM(SIZE), 0) + "+
+			"(SELECT COALESCE(SUM(BYTES), 0) FROM QUOTA_RESERVATIONS WHERE TENANT_ID=$1 AND EXPIRES_AT > NOW()) "+
+			"FROM FILES WHERE TENANT_ID=$1",
+		[]interface{}{tenantID},
+		&objects, &bytes,
+	); err != nil {
+		return 0, 0, fmt.Errorf("Error reading tenant usage, %v", err)
+	}
+	return objects, bytes, nil
+}
+
+func deleteReservation(ctx context.Context, tx *sqlx.Tx, tenantID, id string) error {
+	tags := opentracing.Tags{tracer.TagFileID: id, tracer.TagTenant: tenantID}
+	if _, err := execContext(ctx, tx, "delete_reservation", tags, "DELETE FROM QUOTA_RESERVATIONS WHERE FILE_ID=$1 AND TENANT_ID=$2", id, tenantID); err != nil {
+		return fmt.Errorf("Error releasing quota reservation, %v", err)
 	}
-	tx.Commit()
 	return nil
 }
+
+func bytesQuotaError() error {
+	return apperrors.QuotaExceeded(apperrors.CodeQuotaExceeded, "Storage quota of the tenant exceeded")
+}
+
+// FindFileByID returns the whole row of the file
+func (afr *AWSFileRepository) FindFileByID(ctx context.Context, id string) (*model.FileRecord, error) {
+	row := fileRow{}
+	tx, tenantID, err := beginTenantTx(ctx, afr.db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := queryRowContext(
+		ctx,
+		tx,
+		"find_file",
+		opentracing.Tags{tracer.TagFileID: id, tracer.TagTenant: tenantID},
+		"SELECT ID, TENANT_ID, FILE_NAME, DOC_CLASS, DOC_TYPE, DOC_NUM, SIZE, CONTENT_TYPE, METADATA FROM FILES WHERE ID=$1 AND TENANT_ID=$2",
+		[]interface{}{id, tenantID},
+		&row.ID, &row.TenantID, &row.FileName, &row.DocClass, &row.DocType, &row.DocNum, &row.Size, &row.ContentType, &row.Metadata,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperrors.NotFound(apperrors.CodeFileNotFound, "File %s not found", id)
+		}
+		return nil, fmt.Errorf("Error reading file from DB, %v", err)
+	}
+	return row.record(), nil
+}
+
+// ListFiles returns up to limit files of the tenant ordered by id after afterID
+func (afr *AWSFileRepository) ListFiles(ctx context.Context, afterID string, limit int) ([]*model.FileRecord, error) {
+	rows := []fileRow{}
+	tx, tenantID, err := beginTenantTx(ctx, afr.db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := selectContext(
+		ctx,
+		tx,
+		"list_files",
+		opentracing.Tags{tracer.TagTenant: tenantID},
+		&rows,
+		"SELECT ID, TENANT_ID, FILE_NAME, DOC_CLASS, DOC_TYPE, DOC_NUM, SIZE, CONTENT_TYPE, METADATA FROM FILES WHERE TENANT_ID=$1 AND ID>$2 ORDER BY ID LIMIT $3",
+		tenantID, afterID, limit,
+	); err != nil {
+		return nil, fmt.Errorf("Error listing files, %v", err)
+	}
+	records := make([]*model.FileRecord, 0, len(rows))
+	for i := range rows {
+		records = append(records, rows[i].record())
+	}
+	return records, nil
+}
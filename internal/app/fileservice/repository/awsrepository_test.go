@@ -8,9 +8,15 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
 	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/events"
 	"github.com/vielendanke/file-service/internal/app/fileservice/model"
 	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
 )
 
 var awsRepo repository.FileRepository
@@ -28,31 +34,44 @@ func setupDB() {
 	awsRepo = repository.NewAWSFileRepository(mockDB)
 }
 
+// expectTenantTx expects the transaction bound to the default tenant
+func expectTenantTx() {
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// expectEvent expects the event of the file written to the outbox
+func expectEvent(topic, fileID, docClass string) {
+	mock.ExpectExec("INSERT INTO OUTBOX").WithArgs(sqlmock.AnyArg(), topic, tenant.DefaultID, fileID, docClass, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
 func TestDeleteMetadataByID(t *testing.T) {
 	setupDB()
 	testData := "testData"
 
-	mock.ExpectBegin()
-	mock.ExpectExec("DELETE").WithArgs(testData).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectTenantTx()
+	mock.ExpectQuery("DELETE").WithArgs(testData, tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"DOC_CLASS"}).AddRow("invoice"))
+	expectEvent(events.TopicFileDeleted, testData, "invoice")
 	mock.ExpectCommit()
 
 	err := awsRepo.DeleteMetadataByID(context.Background(), testData)
 
 	assert.Nil(t, err)
-	mock.ExpectationsWereMet()
+	assert.Nil(t, mock.ExpectationsWereMet())
 }
 
 func TestDeleteMetadataByID_NoChangesDetected(t *testing.T) {
 	setupDB()
 	testData := "testData"
 
-	mock.ExpectBegin()
-	mock.ExpectExec("DELETE").WithArgs(testData).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectTenantTx()
+	mock.ExpectQuery("DELETE").WithArgs(testData, tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"DOC_CLASS"}))
 	mock.ExpectRollback()
 
 	err := awsRepo.DeleteMetadataByID(context.Background(), testData)
 
 	assert.NotNil(t, err)
+	assert.True(t, apperrors.Is(err, apperrors.KindNotFound))
 	mock.ExpectationsWereMet()
 }
 
@@ -60,8 +79,8 @@ func TestDeleteMetadataByID_QueryReturnError(t *testing.T) {
 	setupDB()
 	testData := "testData"
 
-	mock.ExpectBegin()
-	mock.ExpectExec("DELETE").WithArgs(testData).WillReturnError(fmt.Errorf(testData))
+	expectTenantTx()
+	mock.ExpectQuery("DELETE").WithArgs(testData, tenant.DefaultID).WillReturnError(fmt.Errorf(testData))
 	mock.ExpectRollback()
 
 	err := awsRepo.DeleteMetadataByID(context.Background(), testData)
@@ -79,7 +98,9 @@ func TestCheckIfExists(t *testing.T) {
 		DocNum:   testData,
 	}
 
-	mock.ExpectQuery("SELECT").WithArgs(testData, testData, testData).WillReturnRows(sqlmock.NewRows([]string{}))
+	expectTenantTx()
+	mock.ExpectQuery("SELECT").WithArgs(testData, testData, testData, tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{}))
+	mock.ExpectRollback()
 
 	err := awsRepo.CheckIfExists(context.Background(), awsModel)
 
@@ -96,17 +117,21 @@ func TestCheckIfExists_NoUniqueResult(t *testing.T) {
 		DocNum:   testData,
 	}
 
-	mock.ExpectQuery("SELECT").WithArgs(testData, testData, testData).WillReturnRows(sqlmock.NewRows([]string{"ID"}).AddRow(testData))
+	expectTenantTx()
+	mock.ExpectQuery("SELECT").WithArgs(testData, testData, testData, tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"ID"}).AddRow(testData))
+	mock.ExpectRollback()
 
 	err := awsRepo.CheckIfExists(context.Background(), awsModel)
 
 	assert.NotNil(t, err)
+	assert.True(t, apperrors.Is(err, apperrors.KindAlreadyExists))
 	mock.ExpectationsWereMet()
 }
 
 func TestSaveFileMetadata(t *testing.T) {
 	setupDB()
 	testData := "testData"
+	testMetadata := `{"owner":"bob"}`
 	fModel := &model.AWSModel{
 		FileID:   testData,
 		FileName: testData,
@@ -115,13 +140,14 @@ func TestSaveFileMetadata(t *testing.T) {
 		DocNum:   testData,
 	}
 
-	mock.ExpectBegin()
+	expectTenantTx()
 	mock.ExpectExec("INSERT INTO FILES").WithArgs(
-		testData, testData, testData, testData, testData, testData,
+		testData, testData, testData, testData, testData, testMetadata, tenant.DefaultID,
 	).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectEvent(events.TopicFileCreated, testData, testData)
 	mock.ExpectCommit()
 
-	if err := awsRepo.SaveFileMetadata(context.Background(), fModel, testData); err != nil {
+	if err := awsRepo.SaveFileMetadata(context.Background(), fModel, testMetadata); err != nil {
 		t.Fatalf("Error was not expected while saving file: %v", err)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -140,9 +166,9 @@ func TestSaveFileMetadata_InsertNotHappened(t *testing.T) {
 		DocNum:   testData,
 	}
 
-	mock.ExpectBegin()
+	expectTenantTx()
 	mock.ExpectExec("INSERT INTO FILES").WithArgs(
-		testData, testData, testData, testData, testData, testData,
+		testData, testData, testData, testData, testData, testData, tenant.DefaultID,
 	).WillReturnResult(sqlmock.NewResult(0, 0))
 	mock.ExpectRollback()
 
@@ -167,9 +193,9 @@ func TestSaveFileMetadata_ReturnError(t *testing.T) {
 		DocNum:   testData,
 	}
 
-	mock.ExpectBegin()
+	expectTenantTx()
 	mock.ExpectExec("INSERT INTO FILES").WithArgs(
-		testData, testData, testData, testData, testData, testData,
+		testData, testData, testData, testData, testData, testData, tenant.DefaultID,
 	).WillReturnError(fmt.Errorf(errMessage))
 	mock.ExpectRollback()
 
@@ -188,10 +214,12 @@ func TestFindFileMetadataByID(t *testing.T) {
 	testID := "testID"
 	testData := "testData"
 
-	mock.ExpectQuery("SELECT").WithArgs(testID).WillReturnRows(
+	expectTenantTx()
+	mock.ExpectQuery("SELECT").WithArgs(testID, tenant.DefaultID).WillReturnRows(
 		sqlmock.NewRows(
 			[]string{"DOC_CLASS", "DOC_TYPE", "DOC_NUM", "METADATA"},
 		).AddRow(testData, testData, testData, testData))
+	mock.ExpectRollback()
 
 	res, err := awsRepo.FindFileMetadataByID(context.Background(), testID)
 	if err != nil {
@@ -210,23 +238,54 @@ func TestFindFileMetadataByID_NoRowsFound(t *testing.T) {
 	setupDB()
 	testID := "testID"
 
-	mock.ExpectQuery("SELECT").WithArgs(testID).WillReturnError(sql.ErrNoRows)
+	expectTenantTx()
+	mock.ExpectQuery("SELECT").WithArgs(testID, tenant.DefaultID).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
 
 	_, err := awsRepo.FindFileMetadataByID(context.Background(), testID)
 
 	assert.NotNil(t, err)
+	assert.True(t, apperrors.Is(err, apperrors.KindNotFound))
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("Results are not expected: %v", err)
 	}
 }
 
+func TestSaveFileMetadata_UniqueViolation(t *testing.T) {
+	setupDB()
+	testData := "testData"
+	fModel := &model.AWSModel{
+		FileID:   testData,
+		FileName: testData,
+		DocClass: testData,
+		DocType:  testData,
+		DocNum:   testData,
+	}
+
+	expectTenantTx()
+	mock.ExpectExec("INSERT INTO FILES").WithArgs(
+		testData, testData, testData, testData, testData, testData, tenant.DefaultID,
+	).WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectRollback()
+
+	err := awsRepo.SaveFileMetadata(context.Background(), fModel, testData)
+
+	assert.True(t, apperrors.Is(err, apperrors.KindAlreadyExists))
+
+	if expectedErr := mock.ExpectationsWereMet(); expectedErr != nil {
+		t.Fatalf("Results are not expected: %v", expectedErr)
+	}
+}
+
 func TestFindFileNameByID(t *testing.T) {
 	setupDB()
 	testID := "testID"
 	testData := "testData"
 
-	mock.ExpectQuery("SELECT FILE_NAME FROM FILES").WithArgs(testID).WillReturnRows(sqlmock.NewRows([]string{"FILE_NAME"}).AddRow(testData))
+	expectTenantTx()
+	mock.ExpectQuery("SELECT FILE_NAME FROM FILES").WithArgs(testID, tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"FILE_NAME"}).AddRow(testData))
+	mock.ExpectRollback()
 
 	res, err := awsRepo.FindFileNameByID(context.Background(), testID)
 	if err != nil {
@@ -244,7 +303,9 @@ func TestFindFileNameByID_NoRowsFound(t *testing.T) {
 	setupDB()
 	testID := "testID"
 
-	mock.ExpectQuery("SELECT FILE_NAME FROM FILES").WithArgs(testID).WillReturnError(sql.ErrNoRows)
+	expectTenantTx()
+	mock.ExpectQuery("SELECT FILE_NAME FROM FILES").WithArgs(testID, tenant.DefaultID).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
 
 	_, err := awsRepo.FindFileNameByID(context.Background(), testID)
 
@@ -258,10 +319,11 @@ func TestFindFileNameByID_NoRowsFound(t *testing.T) {
 func TestUpdateFileMetadataByID(t *testing.T) {
 	setupDB()
 	testID := "testID"
-	testData := "testData"
+	testData := `{"owner":"bob"}`
 
-	mock.ExpectBegin()
-	mock.ExpectExec("UPDATE FILES").WithArgs(testData, testID).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectTenantTx()
+	mock.ExpectQuery("UPDATE FILES").WithArgs(testData, testID, tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"DOC_CLASS"}).AddRow("invoice"))
+	expectEvent(events.TopicFileMetadataChanged, testID, "invoice")
 	mock.ExpectCommit()
 
 	if err := awsRepo.UpdateFileMetadataByID(context.Background(), testData, testID); err != nil {
@@ -278,8 +340,8 @@ func TestUpdateFileMetadataByID_NoRowsAffected(t *testing.T) {
 	testID := "testID"
 	testData := "testData"
 
-	mock.ExpectBegin()
-	mock.ExpectExec("UPDATE FILES").WithArgs(testData, testID).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectTenantTx()
+	mock.ExpectQuery("UPDATE FILES").WithArgs(testData, testID, tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"DOC_CLASS"}))
 	mock.ExpectRollback()
 
 	err := awsRepo.UpdateFileMetadataByID(context.Background(), testData, testID)
@@ -297,8 +359,8 @@ func TestUpdateFileMetadataByID_ReturnError(t *testing.T) {
 	testData := "testData"
 	errMessage := "My custom error message"
 
-	mock.ExpectBegin()
-	mock.ExpectExec("UPDATE FILES").WithArgs(testData, testID).WillReturnError(fmt.Errorf(errMessage))
+	expectTenantTx()
+	mock.ExpectQuery("UPDATE FILES").WithArgs(testData, testID, tenant.DefaultID).WillReturnError(fmt.Errorf(errMessage))
 	mock.ExpectRollback()
 
 	err := awsRepo.UpdateFileMetadataByID(context.Background(), testData, testID)
@@ -310,3 +372,205 @@ func TestUpdateFileMetadataByID_ReturnError(t *testing.T) {
 		t.Fatalf("Results are not expected: %v", expectedErr)
 	}
 }
+
+func TestDeleteMetadataByID_OtherTenant(t *testing.T) {
+	setupDB()
+	testData := "testData"
+	ctx := tenant.NewContext(context.Background(), &tenant.Tenant{ID: "hr"})
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs("hr").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("DELETE").WithArgs(testData, "hr").WillReturnRows(sqlmock.NewRows([]string{"DOC_CLASS"}))
+	mock.ExpectRollback()
+
+	err := awsRepo.DeleteMetadataByID(ctx, testData)
+
+	assert.True(t, apperrors.Is(err, apperrors.KindNotFound))
+	if expectedErr := mock.ExpectationsWereMet(); expectedErr != nil {
+		t.Fatalf("Results are not expected: %v", expectedErr)
+	}
+}
+
+// quotaContext is the context of the default tenant with quotas
+func quotaContext(maxObjects, maxBytes int64) context.Context {
+	return tenant.NewContext(context.Background(), &tenant.Tenant{ID: tenant.DefaultID, MaxObjects: maxObjects, MaxBytes: maxBytes})
+}
+
+// expectUsage expects the quota lock of the default tenant and its usage
+func expectUsage(objects, bytes int64) {
+	mock.ExpectExec("pg_advisory_xact_lock").WithArgs(1, tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT COUNT").WithArgs(tenant.DefaultID).WillReturnRows(
+		sqlmock.NewRows([]string{"COUNT", "SUM"}).AddRow(objects, bytes),
+	)
+}
+
+func TestSaveFileMetadata_ObjectQuotaExceeded(t *testing.T) {
+	setupDB()
+
+	expectTenantTx()
+	expectUsage(2, 0)
+	mock.ExpectRollback()
+
+	err := awsRepo.SaveFileMetadata(quotaContext(2, 0), &model.AWSModel{FileID: "testID"}, "{}")
+
+	assert.True(t, apperrors.Is(err, apperrors.KindQuotaExceeded))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestReserveBytes(t *testing.T) {
+	setupDB()
+	testID := "testID"
+
+	expectTenantTx()
+	mock.ExpectExec("pg_advisory_xact_lock").WithArgs(1, tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM QUOTA_RESERVATIONS WHERE TENANT_ID").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT COUNT").WithArgs(tenant.DefaultID).WillReturnRows(
+		sqlmock.NewRows([]string{"COUNT", "SUM"}).AddRow(1, 60),
+	)
+	mock.ExpectExec("INSERT INTO QUOTA_RESERVATIONS").WithArgs(testID, tenant.DefaultID, int64(40), "1 hour").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.Nil(t, awsRepo.ReserveBytes(quotaContext(0, 100), testID, 40))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestReserveBytes_QuotaExceeded(t *testing.T) {
+	setupDB()
+	testID := "testID"
+
+	expectTenantTx()
+	mock.ExpectExec("pg_advisory_xact_lock").WithArgs(1, tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM QUOTA_RESERVATIONS WHERE TENANT_ID").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT COUNT").WithArgs(tenant.DefaultID).WillReturnRows(
+		sqlmock.NewRows([]string{"COUNT", "SUM"}).AddRow(1, 61),
+	)
+	mock.ExpectRollback()
+
+	err := awsRepo.ReserveBytes(quotaContext(0, 100), testID, 40)
+
+	assert.True(t, apperrors.Is(err, apperrors.KindQuotaExceeded))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestReleaseBytes(t *testing.T) {
+	setupDB()
+	testID := "testID"
+
+	expectTenantTx()
+	mock.ExpectExec("DELETE FROM QUOTA_RESERVATIONS WHERE FILE_ID").WithArgs(testID, tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.Nil(t, awsRepo.ReleaseBytes(context.Background(), testID))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateFileContentByID(t *testing.T) {
+	setupDB()
+	testID := "testID"
+
+	expectTenantTx()
+	mock.ExpectExec("UPDATE FILES SET SIZE").WithArgs(int64(42), "application/pdf", testID, tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM QUOTA_RESERVATIONS WHERE FILE_ID").WithArgs(testID, tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.Nil(t, awsRepo.UpdateFileContentByID(context.Background(), 42, "application/pdf", testID))
+	if expectedErr := mock.ExpectationsWereMet(); expectedErr != nil {
+		t.Fatalf("Results are not expected: %v", expectedErr)
+	}
+}
+
+func TestDeleteMetadataByID_Spans(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+	setupDB()
+	testData := "testData"
+
+	expectTenantTx()
+	mock.ExpectQuery("DELETE").WithArgs(testData, tenant.DefaultID).WillReturnError(fmt.Errorf(testData))
+	mock.ExpectRollback()
+
+	assert.NotNil(t, awsRepo.DeleteMetadataByID(context.Background(), testData))
+
+	spans := tracer.FinishedSpans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "sql set_tenant", spans[0].OperationName)
+	assert.Equal(t, "sql delete_file_metadata", spans[1].OperationName)
+	assert.Equal(t, "DELETE FROM FILES WHERE ID=$1 AND TENANT_ID=$2 RETURNING DOC_CLASS", spans[1].Tag("db.statement"))
+	assert.Equal(t, testData, spans[1].Tag("file.id"))
+	assert.Equal(t, true, spans[1].Tag("error"))
+}
+
+func TestListFiles(t *testing.T) {
+	setupDB()
+
+	expectTenantTx()
+	mock.ExpectQuery("SELECT ID, TENANT_ID, FILE_NAME").WithArgs(tenant.DefaultID, "a", 2).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "tenant_id", "file_name", "doc_class", "doc_type", "doc_num", "size", "content_type", "metadata"}).
+			AddRow("b", tenant.DefaultID, "b.pdf", "class", "type", "1", 10, "application/pdf", `{"k":"v"}`),
+	)
+	mock.ExpectRollback()
+
+	files, err := awsRepo.ListFiles(context.Background(), "a", 2)
+
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, "b.pdf", files[0].FileName)
+	assert.Equal(t, int64(10), files[0].Size)
+	assert.Equal(t, "application/pdf", files[0].ContentType)
+	assert.JSONEq(t, `{"k":"v"}`, string(files[0].Metadata))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveFileMetadata_EventNotWritten(t *testing.T) {
+	setupDB()
+	testData := "testData"
+	testMetadata := `{"owner":"bob"}`
+	fModel := &model.AWSModel{
+		FileID:   testData,
+		FileName: testData,
+		DocClass: testData,
+		DocType:  testData,
+		DocNum:   testData,
+	}
+
+	expectTenantTx()
+	mock.ExpectExec("INSERT INTO FILES").WithArgs(
+		testData, testData, testData, testData, testData, testMetadata, tenant.DefaultID,
+	).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO OUTBOX").WillReturnError(fmt.Errorf("outbox unavailable"))
+	mock.ExpectRollback()
+
+	err := awsRepo.SaveFileMetadata(context.Background(), fModel, testMetadata)
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "outbox unavailable")
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkFileClean(t *testing.T) {
+	setupDB()
+	testID := "testID"
+
+	expectTenantTx()
+	mock.ExpectQuery("SELECT SIZE, DOC_CLASS FROM FILES").WithArgs(testID, tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"SIZE", "DOC_CLASS"}).AddRow(42, "invoice"))
+	expectEvent(events.TopicFileClean, testID, "invoice")
+	mock.ExpectCommit()
+
+	assert.Nil(t, awsRepo.MarkFileClean(context.Background(), testID))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkFileClean_NotFound(t *testing.T) {
+	setupDB()
+	testID := "testID"
+
+	expectTenantTx()
+	mock.ExpectQuery("SELECT SIZE, DOC_CLASS FROM FILES").WithArgs(testID, tenant.DefaultID).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	err := awsRepo.MarkFileClean(context.Background(), testID)
+
+	assert.True(t, apperrors.Is(err, apperrors.KindNotFound))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/unistack-org/micro/v3/logger"
+)
+
+// FileChangesChannel is notified by the files trigger on the changes of the
+// metadata and the deletes, the payload holds the tenant and the id of the file
+const FileChangesChannel = "file_changes"
+
+// listenerPing is the interval of the checks of the listener connection
+const listenerPing = 90 * time.Second
+
+type fileChange struct {
+	TenantID string `json:"tenant_id"`
+	ID       string `json:"id"`
+}
+
+// ListenFileChanges calls changed for the files changed by any replica until
+// ctx is done. The notifications sent while the connection is lost are missed,
+// reconnected is called once it is restored.
+func ListenFileChanges(ctx context.Context, url string, changed func(tenantID, id string), reconnected func()) error {
+	listener := pq.NewListener(url, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Errorf(ctx, "Error in %s listener, %v", FileChangesChannel, err)
+		}
+	})
+	defer listener.Close()
+	if err := listener.Listen(FileChangesChannel); err != nil {
+		return fmt.Errorf("Error listening to %s, %v", FileChangesChannel, err)
+	}
+	ticker := time.NewTicker(listenerPing)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			// a nil notification follows a reconnect
+			if n == nil {
+				reconnected()
+				continue
+			}
+			c := fileChange{}
+			if err := json.Unmarshal([]byte(n.Extra), &c); err != nil {
+				logger.Errorf(ctx, "Error unmarshalling %s notification %s, %v", FileChangesChannel, n.Extra, err)
+				continue
+			}
+			changed(c.TenantID, c.ID)
+		case <-ticker.C:
+			if err := listener.Ping(); err != nil {
+				logger.Errorf(ctx, "Error pinging %s listener, %v", FileChangesChannel, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/tracer"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+)
+
+// CollectionRepository keeps the named collections of the tenants with their ordered files
+type CollectionRepository interface {
+	SaveCollection(ctx context.Context, c *model.Collection) error
+	// FindCollections returns the collections of the tenant without their files
+	FindCollections(ctx context.Context) ([]*model.Collection, error)
+	FindCollectionByID(ctx context.Context, id string) (*model.Collection, error)
+	DeleteCollection(ctx context.Context, id string) error
+	// SaveCollectionFiles replaces the files of the collection in the order of fileIDs
+	SaveCollectionFiles(ctx context.Context, id string, fileIDs []string) error
+}
+
+// SQLCollectionRepository ...
+type SQLCollectionRepository struct {
+	db *sqlx.DB
+}
+
+// NewSQLCollectionRepository ...
+func NewSQLCollectionRepository(db *sqlx.DB) *SQLCollectionRepository {
+	return &SQLCollectionRepository{
+		db: db,
+	}
+}
+
+// collectionRow is the row of the collections table
+type collectionRow struct {
+	ID          string    `db:"id"`
+	Name        string    `db:"name"`
+	Description string    `db:"description"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+func (r *collectionRow) toModel() *model.Collection {
+	return &model.Collection{
+		ID:          r.ID,
+		Name:        r.Name,
+		Description: r.Description,
+		CreatedAt:   r.CreatedAt,
+	}
+}
+
+// collectionFileRow is the row of the collection_files table joined with its file
+type collectionFileRow struct {
+	FileID   string `db:"file_id"`
+	Position int    `db:"position"`
+	FileName string `db:"file_name"`
+	DocClass string `db:"doc_class"`
+	DocType  string `db:"doc_type"`
+}
+
+// SaveCollection ...
+func (cr *SQLCollectionRepository) SaveCollection(ctx context.Context, c *model.Collection) error {
+	tx, tenantID, err := beginTenantTx(ctx, cr.db)
+	if err != nil {
+		return err
+	}
+	if err := queryRowContext(
+		ctx,
+		tx,
+		"save_collection",
+		opentracing.Tags{tracer.TagTenant: tenantID},
+		"INSERT INTO COLLECTIONS(ID, TENANT_ID, NAME, DESCRIPTION) VALUES($1, $2, $3, $4) ON CONFLICT (TENANT_ID, NAME) DO NOTHING RETURNING CREATED_AT",
+		[]interface{}{c.ID, tenantID, c.Name, c.Description},
+		&c.CreatedAt,
+	); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return apperrors.AlreadyExists(apperrors.CodeCollectionExists, "Collection %s already exists", c.Name)
+		}
+		return fmt.Errorf("Error saving collection, %v", err)
+	}
+	return tx.Commit()
+}
+
+// FindCollections ...
+func (cr *SQLCollectionRepository) FindCollections(ctx context.Context) ([]*model.Collection, error) {
+	rows := []collectionRow{}
+	tx, tenantID, err := beginTenantTx(ctx, cr.db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := selectContext(
+		ctx,
+		tx,
+		"find_collections",
+		opentracing.Tags{tracer.TagTenant: tenantID},
+		&rows,
+		"SELECT ID, NAME, DESCRIPTION, CREATED_AT FROM COLLECTIONS WHERE TENANT_ID=$1 ORDER BY NAME",
+		tenantID,
+	); err != nil {
+		return nil, fmt.Errorf("Error reading collections, %v", err)
+	}
+	collections := make([]*model.Collection, 0, len(rows))
+	for i := range rows {
+		collections = append(collections, rows[i].toModel())
+	}
+	return collections, nil
+}
+
+// FindCollectionByID returns the collection with its files in order
+func (cr *SQLCollectionRepository) FindCollectionByID(ctx context.Context, id string) (*model.Collection, error) {
+	tx, tenantID, err := beginTenantTx(ctx, cr.db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	tags := opentracing.Tags{tracer.TagTenant: tenantID}
+	rows := []collectionRow{}
+	if err := selectContext(
+		ctx,
+		tx,
+		"find_collection",
+		tags,
+		&rows,
+		"SELECT ID, NAME, DESCRIPTION, CREATED_AT FROM COLLECTIONS WHERE ID=$1 AND TENANT_ID=$2",
+		id, tenantID,
+	); err != nil {
+		return nil, fmt.Errorf("Error reading collection, %v", err)
+	}
+	if len(rows) == 0 {
+		return nil, apperrors.NotFound(apperrors.CodeCollectionNotFound, "Collection %s not found", id)
+	}
+	files := []collectionFileRow{}
+	if err := selectContext(
+		ctx,
+		tx,
+		"find_collection_files",
+		tags,
+		&files,
+		"SELECT C.FILE_ID, C.POSITION, F.FILE_NAME, F.DOC_CLASS, F.DOC_TYPE FROM COLLECTION_FILES C JOIN FILES F ON F.ID=C.FILE_ID AND F.TENANT_ID=$2 WHERE C.COLLECTION_ID=$1 AND C.TENANT_ID=$2 ORDER BY C.POSITION",
+		id, tenantID,
+	); err != nil {
+		return nil, fmt.Errorf("Error reading collection files, %v", err)
+	}
+	c := rows[0].toModel()
+	c.Files = make([]*model.CollectionFile, 0, len(files))
+	for _, f := range files {
+		c.Files = append(c.Files, &model.CollectionFile{
+			FileID:   f.FileID,
+			Position: f.Position,
+			FileName: f.FileName,
+			DocClass: f.DocClass,
+			DocType:  f.DocType,
+		})
+	}
+	return c, nil
+}
+
+// DeleteCollection removes the collection, its files are kept
+func (cr *SQLCollectionRepository) DeleteCollection(ctx context.Context, id string) error {
+	tx, tenantID, err := beginTenantTx(ctx, cr.db)
+	if err != nil {
+		return err
+	}
+	res, err := execContext(ctx, tx, "delete_collection", opentracing.Tags{tracer.TagTenant: tenantID}, "DELETE FROM COLLECTIONS WHERE ID=$1 AND TENANT_ID=$2", id, tenantID)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error deleting collection, %v", err)
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		tx.Rollback()
+		if err != nil {
+			return fmt.Errorf("Error deleting collection, %v", err)
+		}
+		return apperrors.NotFound(apperrors.CodeCollectionNotFound, "Collection %s not found", id)
+	}
+	return tx.Commit()
+}
+
+// SaveCollectionFiles ...
+func (cr *SQLCollectionRepository) SaveCollectionFiles(ctx context.Context, id string, fileIDs []string) error {
+	tx, tenantID, err := beginTenantTx(ctx, cr.db)
+	if err != nil {
+		return err
+	}
+	tags := opentracing.Tags{tracer.TagTenant: tenantID}
+	exists := false
+	if err := queryRowContext(
+		ctx,
+		tx,
+		"check_collection",
+		tags,
+		"SELECT EXISTS(SELECT 1 FROM COLLECTIONS WHERE ID=$1 AND TENANT_ID=$2)",
+		[]interface{}{id, tenantID},
+		&exists,
+	); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error reading collection, %v", err)
+	}
+	if !exists {
+		tx.Rollback()
+		return apperrors.NotFound(apperrors.CodeCollectionNotFound, "Collection %s not found", id)
+	}
+	if _, err := execContext(ctx, tx, "delete_collection_files", tags, "DELETE FROM COLLECTION_FILES WHERE COLLECTION_ID=$1 AND TENANT_ID=$2", id, tenantID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error deleting collection files, %v", err)
+	}
+	for i, fileID := range fileIDs {
+		if _, err := execContext(
+			ctx,
+			tx,
+			"save_collection_file",
+			tags,
+			"INSERT INTO COLLECTION_FILES(COLLECTION_ID, FILE_ID, TENANT_ID, POSITION) VALUES($1, $2, $3, $4)",
+			id, fileID, tenantID, i,
+		); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("Error saving collection file, %v", err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,75 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+func TestCollectionRepository_SaveCollection_Exists(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLCollectionRepository(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO COLLECTIONS").WithArgs("c1", tenant.DefaultID, "Case 42", "").WillReturnRows(sqlmock.NewRows([]string{"created_at"}))
+	mock.ExpectRollback()
+
+	err = repo.SaveCollection(context.Background(), &model.Collection{ID: "c1", Name: "Case 42"})
+
+	assert.True(t, apperrors.Is(err, apperrors.KindAlreadyExists))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestCollectionRepository_FindCollectionByID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLCollectionRepository(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT ID, NAME, DESCRIPTION, CREATED_AT FROM COLLECTIONS").WithArgs("c1", tenant.DefaultID).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "description", "created_at"}).AddRow("c1", "Case 42", "", time.Now()),
+	)
+	mock.ExpectQuery("FROM COLLECTION_FILES").WithArgs("c1", tenant.DefaultID).WillReturnRows(
+		sqlmock.NewRows([]string{"file_id", "position", "file_name", "doc_class", "doc_type"}).
+			AddRow("f2", 0, "claim.pdf", "claim", "").
+			AddRow("f1", 1, "invoice.pdf", "invoice", ""),
+	)
+	mock.ExpectRollback()
+
+	c, err := repo.FindCollectionByID(context.Background(), "c1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Case 42", c.Name)
+	assert.Len(t, c.Files, 2)
+	assert.Equal(t, "f2", c.Files[0].FileID)
+	assert.Equal(t, 1, c.Files[1].Position)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestCollectionRepository_SaveCollectionFiles(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLCollectionRepository(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("c1", tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec("DELETE FROM COLLECTION_FILES").WithArgs("c1", tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO COLLECTION_FILES").WithArgs("c1", "f2", tenant.DefaultID, 0).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO COLLECTION_FILES").WithArgs("c1", "f1", tenant.DefaultID, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.Nil(t, repo.SaveCollectionFiles(context.Background(), "c1", []string{"f2", "f1"}))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
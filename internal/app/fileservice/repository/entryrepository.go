@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/tracer"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+)
+
+// EntryRepository keeps the listings of the entries of the uploaded archives
+type EntryRepository interface {
+	// SaveFileEntries replaces the entries of the file
+	SaveFileEntries(ctx context.Context, id string, entries []*model.ArchiveEntry) error
+	FindFileEntries(ctx context.Context, id string) ([]*model.ArchiveEntry, error)
+}
+
+// SQLEntryRepository ...
+type SQLEntryRepository struct {
+	db *sqlx.DB
+}
+
+// NewSQLEntryRepository ...
+func NewSQLEntryRepository(db *sqlx.DB) *SQLEntryRepository {
+	return &SQLEntryRepository{
+		db: db,
+	}
+}
+
+// entryRow is the row of the file_entries table
+type entryRow struct {
+	Path        string `db:"path"`
+	Size        int64  `db:"size"`
+	ContentType string `db:"content_type"`
+	Depth       int    `db:"depth"`
+}
+
+// SaveFileEntries ...
+func (er *SQLEntryRepository) SaveFileEntries(ctx context.Context, id string, entries []*model.ArchiveEntry) error {
+	tx, tenantID, err := beginTenantTx(ctx, er.db)
+	if err != nil {
+		return err
+	}
+	tags := opentracing.Tags{tracer.TagFileID: id, tracer.TagTenant: tenantID}
+	if _, err := execContext(ctx, tx, "delete_file_entries", tags, "DELETE FROM FILE_ENTRIES WHERE FILE_ID=$1 AND TENANT_ID=$2", id, tenantID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error deleting file entries, %v", err)
+	}
+	for i, e := range entries {
+		if _, err := execContext(
+			ctx,
+			tx,
+			"save_file_entry",
+			tags,
+			"INSERT INTO FILE_ENTRIES(FILE_ID, POSITION, TENANT_ID, PATH, SIZE, CONTENT_TYPE, DEPTH) VALUES($1, $2, $3, $4, $5, $6, $7)",
+			id, i, tenantID, e.Path, e.Size, e.ContentType, e.Depth,
+		); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("Error saving file entry, %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// FindFileEntries returns the entries of the file in the order of the archive
+func (er *SQLEntryRepository) FindFileEntries(ctx context.Context, id string) ([]*model.ArchiveEntry, error) {
+	rows := []entryRow{}
+	tx, tenantID, err := beginTenantTx(ctx, er.db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := selectContext(
+		ctx,
+		tx,
+		"find_file_entries",
+		opentracing.Tags{tracer.TagFileID: id, tracer.TagTenant: tenantID},
+		&rows,
+		"SELECT PATH, SIZE, CONTENT_TYPE, DEPTH FROM FILE_ENTRIES WHERE FILE_ID=$1 AND TENANT_ID=$2 ORDER BY POSITION",
+		id, tenantID,
+	); err != nil {
+		return nil, fmt.Errorf("Error reading file entries, %v", err)
+	}
+	entries := make([]*model.ArchiveEntry, 0, len(rows))
+	for _, r := range rows {
+		entries = append(entries, &model.ArchiveEntry{
+			Path:        r.Path,
+			Size:        r.Size,
+			ContentType: r.ContentType,
+			Depth:       r.Depth,
+		})
+	}
+	return entries, nil
+}
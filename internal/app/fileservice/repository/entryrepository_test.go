@@ -0,0 +1,57 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+func TestEntryRepository_SaveFileEntries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLEntryRepository(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM FILE_ENTRIES").WithArgs("f1", tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO FILE_ENTRIES").WithArgs("f1", 0, tenant.DefaultID, "a.txt", 1, "text/plain", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO FILE_ENTRIES").WithArgs("f1", 1, tenant.DefaultID, "b.zip", 22, "application/zip", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.SaveFileEntries(context.Background(), "f1", []*model.ArchiveEntry{
+		{Path: "a.txt", Size: 1, ContentType: "text/plain", Depth: 1},
+		{Path: "b.zip", Size: 22, ContentType: "application/zip", Depth: 1},
+	})
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestEntryRepository_FindFileEntries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLEntryRepository(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT PATH, SIZE, CONTENT_TYPE, DEPTH FROM FILE_ENTRIES").WithArgs("f1", tenant.DefaultID).WillReturnRows(
+		sqlmock.NewRows([]string{"path", "size", "content_type", "depth"}).
+			AddRow("b.zip", 22, "application/zip", 1).
+			AddRow("b.zip/c.txt", 3, "text/plain", 2),
+	)
+	mock.ExpectRollback()
+
+	entries, err := repo.FindFileEntries(context.Background(), "f1")
+
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "b.zip/c.txt", entries[1].Path)
+	assert.Equal(t, 2, entries[1].Depth)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/tracer"
+	"github.com/vielendanke/file-service/internal/app/fileservice/events"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+)
+
+// ExpiryRepository keeps the validity end dates of the files and the notices
+// sent for them
+type ExpiryRepository interface {
+	// SaveFileExpiry sets the end date read from field of the metadata, nil clears it.
+	// The notices are sent again for a changed date.
+	SaveFileExpiry(ctx context.Context, id, field string, expiresAt *time.Time) error
+	// FindExpiring returns the files whose end date is after from and not after to
+	FindExpiring(ctx context.Context, from, to time.Time, offset, limit int) ([]*model.ExpiringFile, error)
+	// NotifyExpiry writes the events of up to limit files which expired by now or
+	// expire within notice to the outbox, it returns the number of events
+	NotifyExpiry(ctx context.Context, now time.Time, notice time.Duration, limit int) (int, error)
+}
+
+// SQLExpiryRepository ...
+type SQLExpiryRepository struct {
+	db *sqlx.DB
+}
+
+// NewSQLExpiryRepository ...
+func NewSQLExpiryRepository(db *sqlx.DB) *SQLExpiryRepository {
+	return &SQLExpiryRepository{
+		db: db,
+	}
+}
+
+// expiringRow is a row of the files table with an end date
+type expiringRow struct {
+	ID        string    `db:"id"`
+	FileName  string    `db:"file_name"`
+	DocClass  string    `db:"doc_class"`
+	DocType   string    `db:"doc_type"`
+	DocNum    string    `db:"doc_num"`
+	Field     string    `db:"expiry_field"`
+	ExpiresAt time.Time `db:"expires_at"`
+	Notice    string    `db:"expiry_notice"`
+}
+
+// SaveFileExpiry ...
+func (er *SQLExpiryRepository) SaveFileExpiry(ctx context.Context, id, field string, expiresAt *time.Time) error {
+	tx, tenantID, err := beginTenantTx(ctx, er.db)
+	if err != nil {
+		return err
+	}
+	res, err := execContext(
+		ctx,
+		tx,
+		"save_file_expiry",
+		opentracing.Tags{tracer.TagFileID: id, tracer.TagTenant: tenantID},
+		"UPDATE FILES SET EXPIRY_NOTICE=CASE WHEN EXPIRES_AT IS DISTINCT FROM $1 THEN '' ELSE EXPIRY_NOTICE END, EXPIRES_AT=$1, EXPIRY_FIELD=$2 WHERE ID=$3 AND TENANT_ID=$4",
+		expiresAt, field, id, tenantID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error saving file expiry, %v", err)
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		tx.Rollback()
+		if err != nil {
+			return fmt.Errorf("Error saving file expiry, %v", err)
+		}
+		return apperrors.NotFound(apperrors.CodeFileNotFound, "File %s not found", id)
+	}
+	return tx.Commit()
+}
+
+// FindExpiring orders the files by their end date
+func (er *SQLExpiryRepository) FindExpiring(ctx context.Context, from, to time.Time, offset, limit int) ([]*model.ExpiringFile, error) {
+	rows := []expiringRow{}
+	tx, tenantID, err := beginTenantTx(ctx, er.db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := selectContext(
+		ctx,
+		tx,
+		"find_expiring",
+		opentracing.Tags{tracer.TagTenant: tenantID},
+		&rows,
+		"SELECT ID, FILE_NAME, DOC_CLASS, DOC_TYPE, DOC_NUM, EXPIRY_FIELD, EXPIRES_AT, EXPIRY_NOTICE FROM FILES WHERE TENANT_ID=$1 AND EXPIRES_AT>$2 AND EXPIRES_AT<=$3 ORDER BY EXPIRES_AT, ID OFFSET $4 LIMIT $5",
+		tenantID, from, to, offset, limit,
+	); err != nil {
+		return nil, fmt.Errorf("Error reading expiring files, %v", err)
+	}
+	files := make([]*model.ExpiringFile, 0, len(rows))
+	for _, r := range rows {
+		files = append(files, &model.ExpiringFile{
+			ID:        r.ID,
+			FileName:  r.FileName,
+			DocClass:  r.DocClass,
+			DocType:   r.DocType,
+			DocNum:    r.DocNum,
+			Field:     r.Field,
+			ExpiresAt: r.ExpiresAt,
+		})
+	}
+	return files, nil
+}
+
+// NotifyExpiry locks the files it notifies so the replicas running the job
+// notify distinct files, a file which expired without its expiring notice
+// only gets the expired one
+func (er *SQLExpiryRepository) NotifyExpiry(ctx context.Context, now time.Time, notice time.Duration, limit int) (int, error) {
+	rows := []expiringRow{}
+	tx, tenantID, err := beginTenantTx(ctx, er.db)
+	if err != nil {
+		return 0, err
+	}
+	tags := opentracing.Tags{tracer.TagTenant: tenantID}
+	if err := selectContext(
+		ctx,
+		tx,
+		"find_expiry_notices",
+		tags,
+		&rows,
+		`SELECT ID, FILE_NAME, DOC_CLASS, DOC_TYPE, DOC_NUM, EXPIRY_FIELD, EXPIRES_AT, EXPIRY_NOTICE FROM FILES
+		WHERE TENANT_ID=$1 AND ((EXPIRES_AT<=$2 AND EXPIRY_NOTICE<>$3) OR (EXPIRES_AT<=$4 AND EXPIRY_NOTICE=''))
+		ORDER BY EXPIRES_AT, ID LIMIT $5 FOR UPDATE SKIP LOCKED`,
+		tenantID, now, model.ExpiryNoticeExpired, now.Add(notice), limit,
+	); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("Error reading expiring files, %v", err)
+	}
+	for _, r := range rows {
+		build, sent := events.FileExpiring, model.ExpiryNoticeExpiring
+		if !r.ExpiresAt.After(now) {
+			build, sent = events.FileExpired, model.ExpiryNoticeExpired
+		}
+		event, err := build(tenantID, r.ID, r.DocClass, r.Field, r.ExpiresAt)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		if err := insertEvent(ctx, tx, event); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		if _, err := execContext(ctx, tx, "save_expiry_notice", tags, "UPDATE FILES SET EXPIRY_NOTICE=$1 WHERE ID=$2 AND TENANT_ID=$3", sent, r.ID, tenantID); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("Error saving expiry notice, %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("Error committing expiry notices, %v", err)
+	}
+	return len(rows), nil
+}
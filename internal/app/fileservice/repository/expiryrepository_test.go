@@ -0,0 +1,60 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/events"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+func TestExpiryRepository_SaveFileExpiry_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLExpiryRepository(sqlx.NewDb(db, "sqlmock"))
+	expiresAt := time.Date(2030, 5, 2, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE FILES SET EXPIRY_NOTICE").WithArgs(expiresAt, "valid_until", "f1", tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = repo.SaveFileExpiry(context.Background(), "f1", "valid_until", &expiresAt)
+
+	assert.True(t, apperrors.Is(err, apperrors.KindNotFound))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestExpiryRepository_NotifyExpiry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLExpiryRepository(sqlx.NewDb(db, "sqlmock"))
+	now := time.Date(2030, 5, 1, 0, 0, 0, 0, time.UTC)
+	columns := []string{"id", "file_name", "doc_class", "doc_type", "doc_num", "expiry_field", "expires_at", "expiry_notice"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FOR UPDATE SKIP LOCKED").WithArgs(tenant.DefaultID, now, model.ExpiryNoticeExpired, now.Add(24*time.Hour), 10).WillReturnRows(
+		sqlmock.NewRows(columns).
+			AddRow("f1", "a.pdf", "passport", "id", "1", "valid_until", now.Add(-time.Hour), model.ExpiryNoticeExpiring).
+			AddRow("f2", "b.pdf", "passport", "id", "2", "valid_until", now.Add(time.Hour), ""),
+	)
+	mock.ExpectExec("INSERT INTO OUTBOX").WithArgs(sqlmock.AnyArg(), events.TopicFileExpired, tenant.DefaultID, "f1", "passport", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE FILES SET EXPIRY_NOTICE").WithArgs(model.ExpiryNoticeExpired, "f1", tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO OUTBOX").WithArgs(sqlmock.AnyArg(), events.TopicFileExpiring, tenant.DefaultID, "f2", "passport", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE FILES SET EXPIRY_NOTICE").WithArgs(model.ExpiryNoticeExpiring, "f2", tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := repo.NotifyExpiry(context.Background(), now, 24*time.Hour, 10)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
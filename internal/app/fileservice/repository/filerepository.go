@@ -14,4 +14,10 @@ type FileRepository interface {
 	UpdateFileMetadataByID(ctx context.Context, metadata, id string) error
 	CheckIfExists(ctx context.Context, f model.FileModel) error
 	DeleteMetadataByID(ctx context.Context, id string) error
+	UpdateFileContentByID(ctx context.Context, size int64, contentType, id string) error
+	ReserveBytes(ctx context.Context, id string, n int64) error
+	ReleaseBytes(ctx context.Context, id string) error
+	MarkFileClean(ctx context.Context, id string) error
+	FindFileByID(ctx context.Context, id string) (*model.FileRecord, error)
+	ListFiles(ctx context.Context, afterID string, limit int) ([]*model.FileRecord, error)
 }
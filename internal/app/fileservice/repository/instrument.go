@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/prometheus/client_golang/prometheus"
+	metrics "github.com/vielendanke/file-service/internal/app/fileservice/commons/metrics"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/tracer"
+)
+
+var (
+	queryDuration = metrics.GetOrMakeHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.NS,
+			Name:      "sql_query_duration_seconds",
+			Help:      "Duration of the SQL statements.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		},
+		[]string{"statement"},
+	)
+	queryErrors = metrics.GetOrMakeCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NS,
+			Name:      "sql_query_errors_total",
+			Help:      "Total number of failed SQL statements.",
+		},
+		[]string{"statement"},
+	)
+	poolConnections = metrics.GetOrMakeGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.NS,
+			Name:      "sql_pool_connections",
+			Help:      "Number of the connections of the database pool by state.",
+		},
+		[]string{"state"},
+	)
+	poolWaits = metrics.GetOrMakeGauge(
+		prometheus.GaugeOpts{
+			Namespace: metrics.NS,
+			Name:      "sql_pool_wait_count",
+			Help:      "Total number of connections waited for.",
+		},
+	)
+	poolWaitDuration = metrics.GetOrMakeGauge(
+		prometheus.GaugeOpts{
+			Namespace: metrics.NS,
+			Name:      "sql_pool_wait_duration_seconds",
+			Help:      "Total time blocked waiting for a new connection.",
+		},
+	)
+)
+
+// startStatementSpan starts the span of a single SQL statement, name is the
+// static name of the statement, like save_file_metadata, which labels its
+// metrics in place of the query whose text may vary
+func startStatementSpan(ctx context.Context, name, query string, tags opentracing.Tags) (opentracing.Span, context.Context) {
+	span, ctx := tracer.StartSpan(ctx, "sql "+name, tags)
+	ext.SpanKindRPCClient.Set(span)
+	ext.DBType.Set(span, "postgresql")
+	ext.DBStatement.Set(span, query)
+	return span, ctx
+}
+
+// finishStatement records the duration and the error of the statement name
+func finishStatement(span opentracing.Span, name string, start time.Time, err error) {
+	queryDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		queryErrors.WithLabelValues(name).Inc()
+	}
+	tracer.FinishSpan(span, err)
+}
+
+// execContext runs the statement name in tx within a child span of ctx
+func execContext(ctx context.Context, tx *sqlx.Tx, name string, tags opentracing.Tags, query string, args ...interface{}) (sql.Result, error) {
+	span, ctx := startStatementSpan(ctx, name, query, tags)
+	start := time.Now()
+	res, err := tx.ExecContext(ctx, query, args...)
+	finishStatement(span, name, start, err)
+	return res, err
+}
+
+// queryRowContext scans the single row of the query name in tx within a child span of ctx,
+// a missing row is not reported as a failure of the statement
+func queryRowContext(ctx context.Context, tx *sqlx.Tx, name string, tags opentracing.Tags, query string, args []interface{}, dest ...interface{}) error {
+	span, ctx := startStatementSpan(ctx, name, query, tags)
+	start := time.Now()
+	err := tx.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err == sql.ErrNoRows {
+		finishStatement(span, name, start, nil)
+	} else {
+		finishStatement(span, name, start, err)
+	}
+	return err
+}
+
+// selectContext scans the rows of the query name into dest in tx within a child span of ctx
+func selectContext(ctx context.Context, tx *sqlx.Tx, name string, tags opentracing.Tags, dest interface{}, query string, args ...interface{}) error {
+	span, ctx := startStatementSpan(ctx, name, query, tags)
+	start := time.Now()
+	err := tx.SelectContext(ctx, dest, query, args...)
+	finishStatement(span, name, start, err)
+	return err
+}
+
+// WatchPoolStats exports the statistics of the connection pool of db every interval until ctx is done
+func WatchPoolStats(ctx context.Context, db *sqlx.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		observePoolStats(db.Stats())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func observePoolStats(stats sql.DBStats) {
+	poolConnections.WithLabelValues("open").Set(float64(stats.OpenConnections))
+	poolConnections.WithLabelValues("in_use").Set(float64(stats.InUse))
+	poolConnections.WithLabelValues("idle").Set(float64(stats.Idle))
+	poolConnections.WithLabelValues("max_open").Set(float64(stats.MaxOpenConnections))
+	poolWaits.Set(float64(stats.WaitCount))
+	poolWaitDuration.Set(stats.WaitDuration.Seconds())
+}
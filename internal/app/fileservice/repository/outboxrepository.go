@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/tracer"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+)
+
+// OutboxRepository reads the events of the outbox table for the relay, the
+// events are written by the file repository with the change they describe
+type OutboxRepository struct {
+	db *sqlx.DB
+}
+
+// NewOutboxRepository ...
+func NewOutboxRepository(db *sqlx.DB) *OutboxRepository {
+	return &OutboxRepository{
+		db: db,
+	}
+}
+
+// outboxRow is the row of the outbox table
+type outboxRow struct {
+	Seq      int64  `db:"seq"`
+	EventID  string `db:"event_id"`
+	Topic    string `db:"topic"`
+	TenantID string `db:"tenant_id"`
+	FileID   string `db:"file_id"`
+	DocClass string `db:"doc_class"`
+	Payload  []byte `db:"payload"`
+	Attempts int    `db:"attempts"`
+}
+
+// insertEvent writes e to the outbox in tx
+func insertEvent(ctx context.Context, tx *sqlx.Tx, e *model.Event) error {
+	tags := opentracing.Tags{tracer.TagFileID: e.FileID, tracer.TagTenant: e.TenantID}
+	if _, err := execContext(ctx, tx, "insert_event", tags, "INSERT INTO OUTBOX(EVENT_ID, TOPIC, TENANT_ID, FILE_ID, DOC_CLASS, PAYLOAD) VALUES($1, $2, $3, $4, $5, $6)",
+		e.ID, e.Topic, e.TenantID, e.FileID, e.DocClass, e.Payload,
+	); err != nil {
+		return fmt.Errorf("Error writing event %s to outbox, %v", e.Topic, err)
+	}
+	return nil
+}
+
+// Claim leases up to limit due events in the order they were written, the
+// claimed events are skipped by the other replicas until the lease ends
+func (obr *OutboxRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]*model.Event, error) {
+	rows := []outboxRow{}
+	tx, err := obr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error starting transaction, %v", err)
+	}
+	defer tx.Rollback()
+	if err := selectContext(
+		ctx,
+		tx,
+		"claim_events",
+		opentracing.Tags{},
+		&rows,
+		`WITH DUE AS (SELECT SEQ FROM OUTBOX WHERE NEXT_ATTEMPT_AT<=NOW() ORDER BY SEQ LIMIT $1 FOR UPDATE SKIP LOCKED)
+UPDATE OUTBOX SET NEXT_ATTEMPT_AT=NOW()+$2*INTERVAL '1 second' FROM DUE WHERE OUTBOX.SEQ=DUE.SEQ
+RETURNING OUTBOX.SEQ, EVENT_ID, TOPIC, TENANT_ID, FILE_ID, DOC_CLASS, PAYLOAD, ATTEMPTS`,
+		limit, lease.Seconds(),
+	); err != nil {
+		return nil, fmt.Errorf("Error claiming events, %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("Error claiming events, %v", err)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Seq < rows[j].Seq })
+	events := make([]*model.Event, 0, len(rows))
+	for _, r := range rows {
+		events = append(events, &model.Event{
+			ID:       r.EventID,
+			Topic:    r.Topic,
+			TenantID: r.TenantID,
+			FileID:   r.FileID,
+			DocClass: r.DocClass,
+			Payload:  r.Payload,
+			Attempts: r.Attempts,
+		})
+	}
+	return events, nil
+}
+
+// Delete removes the published event
+func (obr *OutboxRepository) Delete(ctx context.Context, id string) error {
+	return obr.exec(ctx, "delete_event", "DELETE FROM OUTBOX WHERE EVENT_ID=$1", id)
+}
+
+// Retry records the failed attempt of the event and schedules the next one after delay
+func (obr *OutboxRepository) Retry(ctx context.Context, id string, delay time.Duration, cause string) error {
+	return obr.exec(
+		ctx,
+		"retry_event",
+		"UPDATE OUTBOX SET ATTEMPTS=ATTEMPTS+1, LAST_ERROR=$1, NEXT_ATTEMPT_AT=NOW()+$2*INTERVAL '1 second' WHERE EVENT_ID=$3",
+		cause, delay.Seconds(), id,
+	)
+}
+
+func (obr *OutboxRepository) exec(ctx context.Context, name, query string, args ...interface{}) error {
+	tx, err := obr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Error starting transaction, %v", err)
+	}
+	if _, err := execContext(ctx, tx, name, opentracing.Tags{}, query, args...); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error updating outbox, %v", err)
+	}
+	return tx.Commit()
+}
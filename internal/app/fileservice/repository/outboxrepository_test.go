@@ -0,0 +1,49 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+)
+
+func TestOutboxRepository_Claim(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	outbox := repository.NewOutboxRepository(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE SKIP LOCKED").WithArgs(10, float64(30)).WillReturnRows(
+		sqlmock.NewRows([]string{"seq", "event_id", "topic", "tenant_id", "file_id", "doc_class", "payload", "attempts"}).
+			AddRow(2, "e2", "file.deleted.v1", "sales", "f1", "invoice", []byte("deleted"), 0).
+			AddRow(1, "e1", "file.created.v1", "sales", "f1", "invoice", []byte("created"), 3),
+	)
+	mock.ExpectCommit()
+
+	events, err := outbox.Claim(context.Background(), 10, 30*time.Second)
+
+	assert.Nil(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, "e1", events[0].ID)
+	assert.Equal(t, 3, events[0].Attempts)
+	assert.Equal(t, []byte("created"), events[0].Payload)
+	assert.Equal(t, "e2", events[1].ID)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestOutboxRepository_Retry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	outbox := repository.NewOutboxRepository(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE OUTBOX SET ATTEMPTS").WithArgs("broker unavailable", float64(4), "e1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.Nil(t, outbox.Retry(context.Background(), "e1", 4*time.Second, "broker unavailable"))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
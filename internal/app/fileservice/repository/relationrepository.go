@@ -0,0 +1,289 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/tracer"
+	"github.com/vielendanke/file-service/internal/app/fileservice/events"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+)
+
+// RelationRepository keeps the typed links between the files and their legal holds
+type RelationRepository interface {
+	// SaveRelation refuses the links which close a cycle
+	SaveRelation(ctx context.Context, r *model.Relation) error
+	DeleteRelation(ctx context.Context, parentID, childID, relationType string) error
+	// FindRelated walks the links of the file in direction up to depth links away
+	FindRelated(ctx context.Context, id, direction string, depth int) ([]*model.RelatedFile, error)
+	// DeleteCascade deletes the file with the files its delete cascades to in one
+	// transaction, check gets the locked files, the file at depth 0 first, and
+	// nothing is deleted when it fails
+	DeleteCascade(ctx context.Context, id string, check func(cascade []*model.RelatedFile) error) error
+	// SetLegalHold sets the hold of the file and the files its hold cascades to, it returns their ids
+	SetLegalHold(ctx context.Context, id string, held bool) ([]string, error)
+}
+
+// SQLRelationRepository ...
+type SQLRelationRepository struct {
+	db *sqlx.DB
+}
+
+// NewSQLRelationRepository ...
+func NewSQLRelationRepository(db *sqlx.DB) *SQLRelationRepository {
+	return &SQLRelationRepository{
+		db: db,
+	}
+}
+
+// relatedRow is a file reached by the recursive queries over the file_relations table
+type relatedRow struct {
+	FileID   string `db:"file_id"`
+	Via      string `db:"via"`
+	Type     string `db:"type"`
+	Depth    int    `db:"depth"`
+	FileName string `db:"file_name"`
+	DocClass string `db:"doc_class"`
+	DocType  string `db:"doc_type"`
+	Held     bool   `db:"legal_hold"`
+	Metadata string `db:"metadata"`
+}
+
+func (r *relatedRow) toModel() *model.RelatedFile {
+	return &model.RelatedFile{
+		FileID:   r.FileID,
+		Via:      r.Via,
+		Type:     r.Type,
+		Depth:    r.Depth,
+		FileName: r.FileName,
+		DocClass: r.DocClass,
+		DocType:  r.DocType,
+		Held:     r.Held,
+		Metadata: json.RawMessage(r.Metadata),
+	}
+}
+
+// The recursive queries reach the files from $1 over the links of the tenant $2,
+// a file reached on several paths is returned once per link at its lowest depth
+const (
+	relatedOutgoingQuery = `WITH RECURSIVE RELATED(FILE_ID, VIA, TYPE, DEPTH) AS (
+		SELECT CHILD_ID, PARENT_ID, TYPE, 1 FROM FILE_RELATIONS WHERE PARENT_ID=$1 AND TENANT_ID=$2
+		UNION
+		SELECT R.CHILD_ID, R.PARENT_ID, R.TYPE, RELATED.DEPTH+1 FROM FILE_RELATIONS R JOIN RELATED ON R.PARENT_ID=RELATED.FILE_ID WHERE R.TENANT_ID=$2 AND RELATED.DEPTH<$3
+	)`
+	relatedIncomingQuery = `WITH RECURSIVE RELATED(FILE_ID, VIA, TYPE, DEPTH) AS (
+		SELECT PARENT_ID, CHILD_ID, TYPE, 1 FROM FILE_RELATIONS WHERE CHILD_ID=$1 AND TENANT_ID=$2
+		UNION
+		SELECT R.PARENT_ID, R.CHILD_ID, R.TYPE, RELATED.DEPTH+1 FROM FILE_RELATIONS R JOIN RELATED ON R.CHILD_ID=RELATED.FILE_ID WHERE R.TENANT_ID=$2 AND RELATED.DEPTH<$3
+	)`
+	relatedSelect = ` SELECT RELATED.FILE_ID, RELATED.VIA, RELATED.TYPE, MIN(RELATED.DEPTH) AS DEPTH, F.FILE_NAME, F.DOC_CLASS, F.DOC_TYPE, F.LEGAL_HOLD
+		FROM RELATED JOIN FILES F ON F.ID=RELATED.FILE_ID AND F.TENANT_ID=$2 WHERE RELATED.FILE_ID<>$1
+		GROUP BY RELATED.FILE_ID, RELATED.VIA, RELATED.TYPE, F.FILE_NAME, F.DOC_CLASS, F.DOC_TYPE, F.LEGAL_HOLD
+		ORDER BY DEPTH, RELATED.FILE_ID`
+	// the cycles of the cascading links are impossible as SaveRelation refuses them,
+	// a file reached on several paths is returned once at its highest depth
+	deleteCascadeWith = `WITH RECURSIVE RELATED(FILE_ID, VIA, TYPE, DEPTH) AS (
+		SELECT ID, ''::VARCHAR, ''::VARCHAR, 0 FROM FILES WHERE ID=$1 AND TENANT_ID=$2
+		UNION
+		SELECT R.CHILD_ID, R.PARENT_ID, R.TYPE, RELATED.DEPTH+1 FROM FILE_RELATIONS R JOIN RELATED ON R.PARENT_ID=RELATED.FILE_ID WHERE R.TENANT_ID=$2 AND R.CASCADE_DELETE
+	)`
+	lockCascadeQuery   = deleteCascadeWith + ` SELECT F.ID FROM FILES F WHERE F.TENANT_ID=$2 AND F.ID IN (SELECT FILE_ID FROM RELATED) ORDER BY F.ID FOR UPDATE`
+	deleteCascadeQuery = deleteCascadeWith + ` SELECT RELATED.FILE_ID, MIN(RELATED.VIA) AS VIA, MIN(RELATED.TYPE) AS TYPE, MAX(RELATED.DEPTH) AS DEPTH, F.FILE_NAME, F.DOC_CLASS, F.DOC_TYPE, F.LEGAL_HOLD, F.METADATA
+		FROM RELATED JOIN FILES F ON F.ID=RELATED.FILE_ID AND F.TENANT_ID=$2
+		GROUP BY RELATED.FILE_ID, F.FILE_NAME, F.DOC_CLASS, F.DOC_TYPE, F.LEGAL_HOLD, F.METADATA
+		ORDER BY DEPTH, RELATED.FILE_ID`
+	holdQuery = `WITH RECURSIVE HELD(ID) AS (
+		SELECT ID FROM FILES WHERE ID=$1 AND TENANT_ID=$2
+		UNION
+		SELECT R.CHILD_ID FROM FILE_RELATIONS R JOIN HELD ON R.PARENT_ID=HELD.ID WHERE R.TENANT_ID=$2 AND R.CASCADE_HOLD
+	) UPDATE FILES SET LEGAL_HOLD=$3 WHERE TENANT_ID=$2 AND ID IN (SELECT ID FROM HELD) RETURNING ID`
+	cycleQuery = `WITH RECURSIVE REACHED(ID) AS (
+		SELECT $1::VARCHAR
+		UNION
+		SELECT R.CHILD_ID FROM FILE_RELATIONS R JOIN REACHED ON R.PARENT_ID=REACHED.ID WHERE R.TENANT_ID=$3
+	) SELECT EXISTS(SELECT 1 FROM REACHED WHERE ID=$2)`
+)
+
+// SaveRelation ...
+func (rr *SQLRelationRepository) SaveRelation(ctx context.Context, r *model.Relation) error {
+	tx, tenantID, err := beginTenantTx(ctx, rr.db)
+	if err != nil {
+		return err
+	}
+	tags := opentracing.Tags{tracer.TagFileID: r.ParentID, tracer.TagTenant: tenantID}
+	cycle := false
+	if err := queryRowContext(ctx, tx, "check_relation_cycle", tags, cycleQuery, []interface{}{r.ChildID, r.ParentID, tenantID}, &cycle); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error reading file relations, %v", err)
+	}
+	if cycle {
+		tx.Rollback()
+		return apperrors.Conflict(apperrors.CodeRelationCycle, "File %s is already reachable from file %s", r.ParentID, r.ChildID)
+	}
+	if err := queryRowContext(
+		ctx,
+		tx,
+		"save_relation",
+		tags,
+		"INSERT INTO FILE_RELATIONS(PARENT_ID, CHILD_ID, TYPE, TENANT_ID, CASCADE_DELETE, CASCADE_HOLD) VALUES($1, $2, $3, $4, $5, $6) ON CONFLICT (PARENT_ID, CHILD_ID, TYPE) DO NOTHING RETURNING CREATED_AT",
+		[]interface{}{r.ParentID, r.ChildID, r.Type, tenantID, r.CascadeDelete, r.CascadeHold},
+		&r.CreatedAt,
+	); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return apperrors.AlreadyExists(apperrors.CodeRelationExists, "Relation %s from file %s to file %s already exists", r.Type, r.ParentID, r.ChildID)
+		}
+		return fmt.Errorf("Error saving file relation, %v", err)
+	}
+	return tx.Commit()
+}
+
+// DeleteRelation ...
+func (rr *SQLRelationRepository) DeleteRelation(ctx context.Context, parentID, childID, relationType string) error {
+	tx, tenantID, err := beginTenantTx(ctx, rr.db)
+	if err != nil {
+		return err
+	}
+	res, err := execContext(
+		ctx,
+		tx,
+		"delete_relation",
+		opentracing.Tags{tracer.TagFileID: parentID, tracer.TagTenant: tenantID},
+		"DELETE FROM FILE_RELATIONS WHERE PARENT_ID=$1 AND CHILD_ID=$2 AND TYPE=$3 AND TENANT_ID=$4",
+		parentID, childID, relationType, tenantID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error deleting file relation, %v", err)
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		tx.Rollback()
+		if err != nil {
+			return fmt.Errorf("Error deleting file relation, %v", err)
+		}
+		return apperrors.NotFound(apperrors.CodeRelationNotFound, "Relation %s from file %s to file %s not found", relationType, parentID, childID)
+	}
+	return tx.Commit()
+}
+
+// FindRelated ...
+func (rr *SQLRelationRepository) FindRelated(ctx context.Context, id, direction string, depth int) ([]*model.RelatedFile, error) {
+	query := relatedOutgoingQuery
+	if direction == model.DirectionIncoming {
+		query = relatedIncomingQuery
+	}
+	return rr.findRelated(ctx, "find_related", id, query+relatedSelect, depth)
+}
+
+// DeleteCascade locks the rows of the cascade before it reads them, so no hold
+// is set on them and no metadata changes until the delete commits. The deleted
+// files are published as file.deleted events which remove their objects.
+func (rr *SQLRelationRepository) DeleteCascade(ctx context.Context, id string, check func(cascade []*model.RelatedFile) error) error {
+	tx, tenantID, err := beginTenantTx(ctx, rr.db)
+	if err != nil {
+		return err
+	}
+	tags := opentracing.Tags{tracer.TagFileID: id, tracer.TagTenant: tenantID}
+	locked := []string{}
+	if err := selectContext(ctx, tx, "lock_delete_cascade", tags, &locked, lockCascadeQuery, id, tenantID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error locking file relations, %v", err)
+	}
+	if len(locked) == 0 {
+		tx.Rollback()
+		return apperrors.NotFound(apperrors.CodeFileNotFound, "File %s not found", id)
+	}
+	rows := []relatedRow{}
+	if err := selectContext(ctx, tx, "find_delete_cascade", tags, &rows, deleteCascadeQuery, id, tenantID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error reading file relations, %v", err)
+	}
+	cascade := make([]*model.RelatedFile, 0, len(rows))
+	for i := range rows {
+		cascade = append(cascade, rows[i].toModel())
+	}
+	if err := check(cascade); err != nil {
+		tx.Rollback()
+		return err
+	}
+	deleted := []deletedRow{}
+	if err := selectContext(
+		ctx,
+		tx,
+		"delete_cascade_files",
+		tags,
+		&deleted,
+		"DELETE FROM FILES WHERE TENANT_ID=$1 AND ID=ANY($2) RETURNING ID, DOC_CLASS",
+		tenantID, pq.Array(locked),
+	); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error during delete the file metadata, %v", err)
+	}
+	for _, d := range deleted {
+		event, err := events.FileDeleted(tenantID, d.ID, d.DocClass)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := insertEvent(ctx, tx, event); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// deletedRow is a file removed by the cascade
+type deletedRow struct {
+	ID       string `db:"id"`
+	DocClass string `db:"doc_class"`
+}
+
+func (rr *SQLRelationRepository) findRelated(ctx context.Context, name, id, query string, args ...interface{}) ([]*model.RelatedFile, error) {
+	rows := []relatedRow{}
+	tx, tenantID, err := beginTenantTx(ctx, rr.db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := selectContext(
+		ctx,
+		tx,
+		name,
+		opentracing.Tags{tracer.TagFileID: id, tracer.TagTenant: tenantID},
+		&rows,
+		query,
+		append([]interface{}{id, tenantID}, args...)...,
+	); err != nil {
+		return nil, fmt.Errorf("Error reading file relations, %v", err)
+	}
+	related := make([]*model.RelatedFile, 0, len(rows))
+	for i := range rows {
+		related = append(related, rows[i].toModel())
+	}
+	return related, nil
+}
+
+// SetLegalHold ...
+func (rr *SQLRelationRepository) SetLegalHold(ctx context.Context, id string, held bool) ([]string, error) {
+	tx, tenantID, err := beginTenantTx(ctx, rr.db)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	if err := selectContext(ctx, tx, "set_legal_hold", opentracing.Tags{tracer.TagFileID: id, tracer.TagTenant: tenantID}, &ids, holdQuery, id, tenantID, held); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("Error updating legal hold, %v", err)
+	}
+	if len(ids) == 0 {
+		tx.Rollback()
+		return nil, apperrors.NotFound(apperrors.CodeFileNotFound, "File %s not found", id)
+	}
+	return ids, tx.Commit()
+}
@@ -0,0 +1,166 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/events"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+func TestRelationRepository_SaveRelation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLRelationRepository(sqlx.NewDb(db, "sqlmock"))
+	created := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("WITH RECURSIVE REACHED").WithArgs("annex", "contract", tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("INSERT INTO FILE_RELATIONS").WithArgs("contract", "annex", model.RelationChild, tenant.DefaultID, true, false).WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(created))
+	mock.ExpectCommit()
+
+	r := &model.Relation{ParentID: "contract", ChildID: "annex", Type: model.RelationChild, CascadeDelete: true}
+	err = repo.SaveRelation(context.Background(), r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, created, r.CreatedAt)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestRelationRepository_SaveRelation_Cycle(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLRelationRepository(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("WITH RECURSIVE REACHED").WithArgs("contract", "annex", tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	err = repo.SaveRelation(context.Background(), &model.Relation{ParentID: "annex", ChildID: "contract", Type: model.RelationReferences})
+
+	assert.True(t, apperrors.Is(err, apperrors.KindConflict))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestRelationRepository_FindRelated(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLRelationRepository(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT PARENT_ID, CHILD_ID, TYPE, 1 FROM FILE_RELATIONS WHERE CHILD_ID").WithArgs("annex", tenant.DefaultID, 2).WillReturnRows(
+		sqlmock.NewRows([]string{"file_id", "via", "type", "depth", "file_name", "doc_class", "doc_type", "legal_hold"}).
+			AddRow("contract", "annex", model.RelationChild, 1, "contract.pdf", "contract", "supply", true),
+	)
+	mock.ExpectRollback()
+
+	related, err := repo.FindRelated(context.Background(), "annex", model.DirectionIncoming, 2)
+
+	assert.Nil(t, err)
+	assert.Len(t, related, 1)
+	assert.Equal(t, "contract", related[0].FileID)
+	assert.True(t, related[0].Held)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestRelationRepository_SetLegalHold(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLRelationRepository(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE FILES SET LEGAL_HOLD").WithArgs("contract", tenant.DefaultID, true).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("contract").AddRow("annex"))
+	mock.ExpectCommit()
+
+	ids, err := repo.SetLegalHold(context.Background(), "contract", true)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"contract", "annex"}, ids)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestRelationRepository_DeleteCascade(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLRelationRepository(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FOR UPDATE").WithArgs("contract", tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("annex").AddRow("contract"))
+	mock.ExpectQuery("MAX\\(RELATED.DEPTH\\)").WithArgs("contract", tenant.DefaultID).WillReturnRows(
+		sqlmock.NewRows([]string{"file_id", "via", "type", "depth", "file_name", "doc_class", "doc_type", "legal_hold", "metadata"}).
+			AddRow("contract", "", "", 0, "contract.pdf", "contract", "supply", false, `{"status":"signed"}`).
+			AddRow("annex", "contract", model.RelationChild, 1, "annex.pdf", "contract", "annex", false, `{}`),
+	)
+	mock.ExpectQuery("DELETE FROM FILES").WithArgs(tenant.DefaultID, pq.Array([]string{"annex", "contract"})).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "doc_class"}).AddRow("annex", "contract").AddRow("contract", "contract"),
+	)
+	mock.ExpectExec("INSERT INTO OUTBOX").WithArgs(sqlmock.AnyArg(), events.TopicFileDeleted, tenant.DefaultID, "annex", "contract", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO OUTBOX").WithArgs(sqlmock.AnyArg(), events.TopicFileDeleted, tenant.DefaultID, "contract", "contract", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	checked := []*model.RelatedFile{}
+	err = repo.DeleteCascade(context.Background(), "contract", func(cascade []*model.RelatedFile) error {
+		checked = cascade
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, checked, 2)
+	assert.Equal(t, "contract", checked[0].FileID)
+	assert.JSONEq(t, `{"status":"signed"}`, string(checked[0].Metadata))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestRelationRepository_DeleteCascade_CheckFails(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLRelationRepository(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FOR UPDATE").WithArgs("contract", tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("contract"))
+	mock.ExpectQuery("MAX\\(RELATED.DEPTH\\)").WithArgs("contract", tenant.DefaultID).WillReturnRows(
+		sqlmock.NewRows([]string{"file_id", "via", "type", "depth", "file_name", "doc_class", "doc_type", "legal_hold", "metadata"}).
+			AddRow("contract", "", "", 0, "contract.pdf", "contract", "supply", true, `{}`),
+	)
+	mock.ExpectRollback()
+
+	err = repo.DeleteCascade(context.Background(), "contract", func(cascade []*model.RelatedFile) error {
+		return apperrors.Conflict(apperrors.CodeFileHeld, "File %s is under legal hold", cascade[0].FileID)
+	})
+
+	assert.True(t, apperrors.Is(err, apperrors.KindConflict))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestRelationRepository_DeleteCascade_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLRelationRepository(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FOR UPDATE").WithArgs("contract", tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	err = repo.DeleteCascade(context.Background(), "contract", func(cascade []*model.RelatedFile) error {
+		t.Fatal("check called without the file")
+		return nil
+	})
+
+	assert.True(t, apperrors.Is(err, apperrors.KindNotFound))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
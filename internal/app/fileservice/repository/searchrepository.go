@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/tracer"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+)
+
+// headlineOptions select the snippets of the search results, the matched words
+// are marked with <mark>
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=\" ... \""
+
+// SearchRepository keeps the extracted text of the files in a tsvector column
+// for the postgres full-text search
+type SearchRepository interface {
+	// SaveFileText replaces the text of the file, the name and the number of the
+	// document are indexed with it and rank higher
+	SaveFileText(ctx context.Context, id, text string) error
+	// SearchFiles returns the files of the tenant matching query in the order of their rank
+	SearchFiles(ctx context.Context, query string, offset, limit int) ([]*model.FileListItem, error)
+}
+
+// SQLSearchRepository ...
+type SQLSearchRepository struct {
+	db       *sqlx.DB
+	language string
+}
+
+// NewSQLSearchRepository returns the repository searching with the text search
+// configuration language of postgres, like simple or english
+func NewSQLSearchRepository(db *sqlx.DB, language string) *SQLSearchRepository {
+	return &SQLSearchRepository{
+		db:       db,
+		language: language,
+	}
+}
+
+// searchRow is a row of the files table with the rank and the snippet of a search
+type searchRow struct {
+	fileRow
+	Rank    float64 `db:"rank"`
+	Snippet string  `db:"snippet"`
+}
+
+// SaveFileText ...
+func (sr *SQLSearchRepository) SaveFileText(ctx context.Context, id, text string) error {
+	tx, tenantID, err := beginTenantTx(ctx, sr.db)
+	if err != nil {
+		return err
+	}
+	res, err := execContext(
+		ctx,
+		tx,
+		"save_file_text",
+		opentracing.Tags{tracer.TagFileID: id, tracer.TagTenant: tenantID},
+		`UPDATE FILES SET CONTENT_TEXT=$1, SEARCH_VECTOR=setweight(to_tsvector($2::regconfig, FILE_NAME || ' ' || DOC_NUM), 'A') || setweight(to_tsvector($2::regconfig, $1), 'B') WHERE ID=$3 AND TENANT_ID=$4`,
+		text, sr.language, id, tenantID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error saving text of file, %v", err)
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		tx.Rollback()
+		if err != nil {
+			return fmt.Errorf("Error saving text of file, %v", err)
+		}
+		return apperrors.NotFound(apperrors.CodeFileNotFound, "File %s not found", id)
+	}
+	return tx.Commit()
+}
+
+// SearchFiles ...
+func (sr *SQLSearchRepository) SearchFiles(ctx context.Context, query string, offset, limit int) ([]*model.FileListItem, error) {
+	rows := []searchRow{}
+	tx, tenantID, err := beginTenantTx(ctx, sr.db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := selectContext(
+		ctx,
+		tx,
+		"search_files",
+		opentracing.Tags{tracer.TagTenant: tenantID},
+		&rows,
+		`SELECT ID, TENANT_ID, FILE_NAME, DOC_CLASS, DOC_TYPE, DOC_NUM, SIZE, CONTENT_TYPE, METADATA,
+			ts_rank_cd(SEARCH_VECTOR, Q) AS RANK,
+			ts_headline($1::regconfig, CONTENT_TEXT, Q, $2) AS SNIPPET
+		FROM FILES, websearch_to_tsquery($1::regconfig, $3) Q
+		WHERE TENANT_ID=$4 AND SEARCH_VECTOR @@ Q
+		ORDER BY RANK DESC, ID LIMIT $5 OFFSET $6`,
+		sr.language, headlineOptions, query, tenantID, limit, offset,
+	); err != nil {
+		return nil, fmt.Errorf("Error searching files, %v", err)
+	}
+	items := make([]*model.FileListItem, 0, len(rows))
+	for i := range rows {
+		items = append(items, &model.FileListItem{
+			FileRecord: *rows[i].record(),
+			Rank:       rows[i].Rank,
+			Snippet:    rows[i].Snippet,
+		})
+	}
+	return items, nil
+}
@@ -0,0 +1,67 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+func TestSearchRepository_SaveFileText(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLSearchRepository(sqlx.NewDb(db, "sqlmock"), "english")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE FILES SET CONTENT_TEXT").WithArgs("supply agreement", "english", "f1", tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.Nil(t, repo.SaveFileText(context.Background(), "f1", "supply agreement"))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchRepository_SaveFileText_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLSearchRepository(sqlx.NewDb(db, "sqlmock"), "english")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE FILES SET CONTENT_TEXT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = repo.SaveFileText(context.Background(), "f1", "supply agreement")
+
+	assert.True(t, apperrors.Is(err, apperrors.KindNotFound))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchRepository_SearchFiles(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLSearchRepository(sqlx.NewDb(db, "sqlmock"), "english")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("websearch_to_tsquery").WithArgs("english", sqlmock.AnyArg(), "supply", tenant.DefaultID, 20, 40).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "tenant_id", "file_name", "doc_class", "doc_type", "doc_num", "size", "content_type", "metadata", "rank", "snippet"}).
+			AddRow("f1", tenant.DefaultID, "contract.docx", "contract", "supply", "C-1", 2048, "application/zip", `{}`, 0.5, "<mark>Supply</mark> agreement"),
+	)
+	mock.ExpectRollback()
+
+	items, err := repo.SearchFiles(context.Background(), "supply", 40, 20)
+
+	assert.Nil(t, err)
+	assert.Len(t, items, 1)
+	assert.Equal(t, "f1", items[0].ID)
+	assert.Equal(t, "C-1", items[0].DocNum)
+	assert.Equal(t, 0.5, items[0].Rank)
+	assert.Equal(t, "<mark>Supply</mark> agreement", items[0].Snippet)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
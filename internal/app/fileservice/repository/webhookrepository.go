@@ -0,0 +1,364 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/tracer"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+)
+
+// WebhookRepository keeps the webhook subscriptions of the tenants and the
+// deliveries of the events to them
+type WebhookRepository interface {
+	SaveSubscription(ctx context.Context, s *model.WebhookSubscription) error
+	FindSubscriptions(ctx context.Context) ([]*model.WebhookSubscription, error)
+	FindSubscriptionByID(ctx context.Context, id string) (*model.WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, s *model.WebhookSubscription) error
+	DeleteSubscription(ctx context.Context, id string) error
+	// FindTenantSubscriptions returns the enabled subscriptions of tenantID for the fanout of its events
+	FindTenantSubscriptions(ctx context.Context, tenantID string) ([]*model.WebhookSubscription, error)
+	// SaveDeliveries skips the deliveries of an event already saved for the subscription
+	SaveDeliveries(ctx context.Context, deliveries []*model.WebhookDelivery) error
+	// ClaimDeliveries leases up to limit due deliveries of the enabled subscriptions
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*model.WebhookDelivery, error)
+	DeleteDelivery(ctx context.Context, id string) error
+	RetryDelivery(ctx context.Context, id string, delay time.Duration, status int, cause string) error
+	DeadLetterDelivery(ctx context.Context, id string, status int, cause string) error
+	FindDeadLetters(ctx context.Context, subscriptionID string) ([]*model.WebhookDelivery, error)
+	// Redeliver schedules the dead delivery id of the subscription for a new round of attempts
+	Redeliver(ctx context.Context, subscriptionID, id string) error
+}
+
+// SQLWebhookRepository ...
+type SQLWebhookRepository struct {
+	db *sqlx.DB
+}
+
+// NewSQLWebhookRepository ...
+func NewSQLWebhookRepository(db *sqlx.DB) *SQLWebhookRepository {
+	return &SQLWebhookRepository{
+		db: db,
+	}
+}
+
+// subscriptionRow is the row of the webhook_subscriptions table, the doc classes
+// and the event types are separated by spaces like the roles of the api keys
+type subscriptionRow struct {
+	ID         string    `db:"id"`
+	TenantID   string    `db:"tenant_id"`
+	URL        string    `db:"url"`
+	Secret     string    `db:"secret"`
+	DocClasses string    `db:"doc_classes"`
+	EventTypes string    `db:"event_types"`
+	Enabled    bool      `db:"enabled"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+func (r *subscriptionRow) toModel() *model.WebhookSubscription {
+	return &model.WebhookSubscription{
+		ID:         r.ID,
+		TenantID:   r.TenantID,
+		URL:        r.URL,
+		Secret:     r.Secret,
+		DocClasses: strings.Fields(r.DocClasses),
+		EventTypes: strings.Fields(r.EventTypes),
+		Enabled:    r.Enabled,
+		CreatedAt:  r.CreatedAt,
+	}
+}
+
+// deliveryRow is the row of the webhook_deliveries table joined with the endpoint of its subscription
+type deliveryRow struct {
+	ID             string    `db:"id"`
+	SubscriptionID string    `db:"subscription_id"`
+	TenantID       string    `db:"tenant_id"`
+	EventID        string    `db:"event_id"`
+	EventType      string    `db:"event_type"`
+	Payload        []byte    `db:"payload"`
+	Attempts       int       `db:"attempts"`
+	LastStatus     int       `db:"last_status"`
+	LastError      string    `db:"last_error"`
+	CreatedAt      time.Time `db:"created_at"`
+	URL            string    `db:"url"`
+	Secret         string    `db:"secret"`
+}
+
+func (r *deliveryRow) toModel() *model.WebhookDelivery {
+	return &model.WebhookDelivery{
+		ID:             r.ID,
+		SubscriptionID: r.SubscriptionID,
+		TenantID:       r.TenantID,
+		EventID:        r.EventID,
+		EventType:      r.EventType,
+		Payload:        r.Payload,
+		Attempts:       r.Attempts,
+		LastStatus:     r.LastStatus,
+		LastError:      r.LastError,
+		CreatedAt:      r.CreatedAt,
+		URL:            r.URL,
+		Secret:         r.Secret,
+	}
+}
+
+const subscriptionColumns = "ID, TENANT_ID, URL, SECRET, DOC_CLASSES, EVENT_TYPES, ENABLED, CREATED_AT"
+
+// SaveSubscription ...
+func (wr *SQLWebhookRepository) SaveSubscription(ctx context.Context, s *model.WebhookSubscription) error {
+	tx, tenantID, err := beginTenantTx(ctx, wr.db)
+	if err != nil {
+		return err
+	}
+	if err := queryRowContext(
+		ctx,
+		tx,
+		"save_subscription",
+		opentracing.Tags{tracer.TagTenant: tenantID},
+		"INSERT INTO WEBHOOK_SUBSCRIPTIONS(ID, TENANT_ID, URL, SECRET, DOC_CLASSES, EVENT_TYPES, ENABLED) VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING CREATED_AT",
+		[]interface{}{s.ID, tenantID, s.URL, s.Secret, strings.Join(s.DocClasses, " "), strings.Join(s.EventTypes, " "), s.Enabled},
+		&s.CreatedAt,
+	); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error saving webhook subscription, %v", err)
+	}
+	s.TenantID = tenantID
+	return tx.Commit()
+}
+
+// FindSubscriptions ...
+func (wr *SQLWebhookRepository) FindSubscriptions(ctx context.Context) ([]*model.WebhookSubscription, error) {
+	tx, tenantID, err := beginTenantTx(ctx, wr.db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return findSubscriptions(ctx, tx, "find_subscriptions", tenantID, "SELECT "+subscriptionColumns+" FROM WEBHOOK_SUBSCRIPTIONS WHERE TENANT_ID=$1 ORDER BY CREATED_AT, ID")
+}
+
+// FindTenantSubscriptions ...
+func (wr *SQLWebhookRepository) FindTenantSubscriptions(ctx context.Context, tenantID string) ([]*model.WebhookSubscription, error) {
+	tx, err := wr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error starting transaction, %v", err)
+	}
+	defer tx.Rollback()
+	return findSubscriptions(ctx, tx, "find_enabled_subscriptions", tenantID, "SELECT "+subscriptionColumns+" FROM WEBHOOK_SUBSCRIPTIONS WHERE TENANT_ID=$1 AND ENABLED ORDER BY CREATED_AT, ID")
+}
+
+func findSubscriptions(ctx context.Context, tx *sqlx.Tx, name, tenantID, query string) ([]*model.WebhookSubscription, error) {
+	rows := []subscriptionRow{}
+	if err := selectContext(ctx, tx, name, opentracing.Tags{tracer.TagTenant: tenantID}, &rows, query, tenantID); err != nil {
+		return nil, fmt.Errorf("Error reading webhook subscriptions, %v", err)
+	}
+	subscriptions := make([]*model.WebhookSubscription, 0, len(rows))
+	for i := range rows {
+		subscriptions = append(subscriptions, rows[i].toModel())
+	}
+	return subscriptions, nil
+}
+
+// FindSubscriptionByID ...
+func (wr *SQLWebhookRepository) FindSubscriptionByID(ctx context.Context, id string) (*model.WebhookSubscription, error) {
+	tx, tenantID, err := beginTenantTx(ctx, wr.db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rows := []subscriptionRow{}
+	if err := selectContext(
+		ctx,
+		tx,
+		"find_subscription",
+		opentracing.Tags{tracer.TagTenant: tenantID},
+		&rows,
+		"SELECT "+subscriptionColumns+" FROM WEBHOOK_SUBSCRIPTIONS WHERE ID=$1 AND TENANT_ID=$2",
+		id, tenantID,
+	); err != nil {
+		return nil, fmt.Errorf("Error reading webhook subscription, %v", err)
+	}
+	if len(rows) == 0 {
+		return nil, apperrors.NotFound(apperrors.CodeWebhookNotFound, "Webhook %s not found", id)
+	}
+	return rows[0].toModel(), nil
+}
+
+// UpdateSubscription ...
+func (wr *SQLWebhookRepository) UpdateSubscription(ctx context.Context, s *model.WebhookSubscription) error {
+	return wr.execTenant(
+		ctx,
+		"update_subscription",
+		apperrors.NotFound(apperrors.CodeWebhookNotFound, "Webhook %s not found", s.ID),
+		"UPDATE WEBHOOK_SUBSCRIPTIONS SET URL=$1, DOC_CLASSES=$2, EVENT_TYPES=$3, ENABLED=$4 WHERE ID=$5 AND TENANT_ID=$6",
+		s.URL, strings.Join(s.DocClasses, " "), strings.Join(s.EventTypes, " "), s.Enabled, s.ID,
+	)
+}
+
+// DeleteSubscription removes the subscription with its pending deliveries
+func (wr *SQLWebhookRepository) DeleteSubscription(ctx context.Context, id string) error {
+	return wr.execTenant(
+		ctx,
+		"delete_subscription",
+		apperrors.NotFound(apperrors.CodeWebhookNotFound, "Webhook %s not found", id),
+		"DELETE FROM WEBHOOK_SUBSCRIPTIONS WHERE ID=$1 AND TENANT_ID=$2",
+		id,
+	)
+}
+
+// SaveDeliveries ...
+func (wr *SQLWebhookRepository) SaveDeliveries(ctx context.Context, deliveries []*model.WebhookDelivery) error {
+	tx, err := wr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Error starting transaction, %v", err)
+	}
+	for _, d := range deliveries {
+		if _, err := execContext(
+			ctx,
+			tx,
+			"save_delivery",
+			opentracing.Tags{tracer.TagTenant: d.TenantID},
+			`INSERT INTO WEBHOOK_DELIVERIES(ID, SUBSCRIPTION_ID, TENANT_ID, EVENT_ID, EVENT_TYPE, PAYLOAD) VALUES($1, $2, $3, $4, $5, $6)
+ON CONFLICT (SUBSCRIPTION_ID, EVENT_ID) DO NOTHING`,
+			d.ID, d.SubscriptionID, d.TenantID, d.EventID, d.EventType, []byte(d.Payload),
+		); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("Error saving webhook delivery, %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ClaimDeliveries leases the due deliveries in the order they were created, the
+// claimed deliveries are skipped by the other replicas until the lease ends
+func (wr *SQLWebhookRepository) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*model.WebhookDelivery, error) {
+	rows := []deliveryRow{}
+	tx, err := wr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error starting transaction, %v", err)
+	}
+	defer tx.Rollback()
+	if err := selectContext(
+		ctx,
+		tx,
+		"claim_deliveries",
+		opentracing.Tags{},
+		&rows,
+		`WITH DUE AS (SELECT D.ID FROM WEBHOOK_DELIVERIES D JOIN WEBHOOK_SUBSCRIPTIONS S ON S.ID=D.SUBSCRIPTION_ID
+WHERE NOT D.DEAD AND S.ENABLED AND D.NEXT_ATTEMPT_AT<=NOW() ORDER BY D.CREATED_AT LIMIT $1 FOR UPDATE OF D SKIP LOCKED)
+UPDATE WEBHOOK_DELIVERIES D SET NEXT_ATTEMPT_AT=NOW()+$2*INTERVAL '1 second' FROM DUE, WEBHOOK_SUBSCRIPTIONS S
+WHERE D.ID=DUE.ID AND S.ID=D.SUBSCRIPTION_ID
+RETURNING D.ID, D.SUBSCRIPTION_ID, D.TENANT_ID, D.EVENT_ID, D.EVENT_TYPE, D.PAYLOAD, D.ATTEMPTS, D.LAST_STATUS, D.LAST_ERROR, D.CREATED_AT, S.URL, S.SECRET`,
+		limit, lease.Seconds(),
+	); err != nil {
+		return nil, fmt.Errorf("Error claiming webhook deliveries, %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("Error claiming webhook deliveries, %v", err)
+	}
+	deliveries := make([]*model.WebhookDelivery, 0, len(rows))
+	for i := range rows {
+		deliveries = append(deliveries, rows[i].toModel())
+	}
+	return deliveries, nil
+}
+
+// DeleteDelivery removes the delivery accepted by the receiver
+func (wr *SQLWebhookRepository) DeleteDelivery(ctx context.Context, id string) error {
+	return wr.exec(ctx, "delete_delivery", "DELETE FROM WEBHOOK_DELIVERIES WHERE ID=$1", id)
+}
+
+// RetryDelivery records the failed attempt of the delivery and schedules the next one after delay
+func (wr *SQLWebhookRepository) RetryDelivery(ctx context.Context, id string, delay time.Duration, status int, cause string) error {
+	return wr.exec(
+		ctx,
+		"retry_delivery",
+		"UPDATE WEBHOOK_DELIVERIES SET ATTEMPTS=ATTEMPTS+1, LAST_STATUS=$1, LAST_ERROR=$2, NEXT_ATTEMPT_AT=NOW()+$3*INTERVAL '1 second' WHERE ID=$4",
+		status, cause, delay.Seconds(), id,
+	)
+}
+
+// DeadLetterDelivery records the last failed attempt of the delivery and stops retrying it
+func (wr *SQLWebhookRepository) DeadLetterDelivery(ctx context.Context, id string, status int, cause string) error {
+	return wr.exec(
+		ctx,
+		"dead_letter_delivery",
+		"UPDATE WEBHOOK_DELIVERIES SET ATTEMPTS=ATTEMPTS+1, LAST_STATUS=$1, LAST_ERROR=$2, DEAD=TRUE WHERE ID=$3",
+		status, cause, id,
+	)
+}
+
+// FindDeadLetters ...
+func (wr *SQLWebhookRepository) FindDeadLetters(ctx context.Context, subscriptionID string) ([]*model.WebhookDelivery, error) {
+	tx, tenantID, err := beginTenantTx(ctx, wr.db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rows := []deliveryRow{}
+	if err := selectContext(
+		ctx,
+		tx,
+		"find_dead_letters",
+		opentracing.Tags{tracer.TagTenant: tenantID},
+		&rows,
+		`SELECT ID, SUBSCRIPTION_ID, TENANT_ID, EVENT_ID, EVENT_TYPE, PAYLOAD, ATTEMPTS, LAST_STATUS, LAST_ERROR, CREATED_AT
+FROM WEBHOOK_DELIVERIES WHERE SUBSCRIPTION_ID=$1 AND TENANT_ID=$2 AND DEAD ORDER BY CREATED_AT`,
+		subscriptionID, tenantID,
+	); err != nil {
+		return nil, fmt.Errorf("Error reading dead webhook deliveries, %v", err)
+	}
+	deliveries := make([]*model.WebhookDelivery, 0, len(rows))
+	for i := range rows {
+		deliveries = append(deliveries, rows[i].toModel())
+	}
+	return deliveries, nil
+}
+
+// Redeliver ...
+func (wr *SQLWebhookRepository) Redeliver(ctx context.Context, subscriptionID, id string) error {
+	return wr.execTenant(
+		ctx,
+		"redeliver",
+		apperrors.NotFound(apperrors.CodeDeliveryNotFound, "Dead delivery %s of webhook %s not found", id, subscriptionID),
+		"UPDATE WEBHOOK_DELIVERIES SET DEAD=FALSE, ATTEMPTS=0, NEXT_ATTEMPT_AT=NOW() WHERE ID=$1 AND SUBSCRIPTION_ID=$2 AND DEAD AND TENANT_ID=$3",
+		id, subscriptionID,
+	)
+}
+
+// execTenant runs the statement name for the tenant of ctx, the tenant is appended to
+// args, notFound is returned if no row was changed
+func (wr *SQLWebhookRepository) execTenant(ctx context.Context, name string, notFound error, query string, args ...interface{}) error {
+	tx, tenantID, err := beginTenantTx(ctx, wr.db)
+	if err != nil {
+		return err
+	}
+	res, err := execContext(ctx, tx, name, opentracing.Tags{tracer.TagTenant: tenantID}, query, append(args, tenantID)...)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error updating webhooks, %v", err)
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		tx.Rollback()
+		if err != nil {
+			return fmt.Errorf("Error updating webhooks, %v", err)
+		}
+		return notFound
+	}
+	return tx.Commit()
+}
+
+func (wr *SQLWebhookRepository) exec(ctx context.Context, name, query string, args ...interface{}) error {
+	tx, err := wr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Error starting transaction, %v", err)
+	}
+	if _, err := execContext(ctx, tx, name, opentracing.Tags{}, query, args...); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error updating webhook deliveries, %v", err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,75 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+func TestWebhookRepository_SaveSubscription(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLWebhookRepository(sqlx.NewDb(db, "sqlmock"))
+	created := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO WEBHOOK_SUBSCRIPTIONS").
+		WithArgs("w1", tenant.DefaultID, "https://erp.example.com", "s3cr3t", "invoice contract", "file.deleted", true).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(created))
+	mock.ExpectCommit()
+
+	s := &model.WebhookSubscription{ID: "w1", URL: "https://erp.example.com", Secret: "s3cr3t", DocClasses: []string{"invoice", "contract"}, EventTypes: []string{"file.deleted"}, Enabled: true}
+	err = repo.SaveSubscription(context.Background(), s)
+
+	assert.Nil(t, err)
+	assert.Equal(t, created, s.CreatedAt)
+	assert.Equal(t, tenant.DefaultID, s.TenantID)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepository_ClaimDeliveries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLWebhookRepository(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE OF D SKIP LOCKED").WithArgs(20, float64(60)).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "subscription_id", "tenant_id", "event_id", "event_type", "payload", "attempts", "last_status", "last_error", "created_at", "url", "secret"}).
+			AddRow("d1", "w1", "sales", "e1", "file.deleted.v1", []byte(`{}`), 2, 503, "Webhook answered 503", time.Now(), "https://erp.example.com", "s3cr3t"),
+	)
+	mock.ExpectCommit()
+
+	deliveries, err := repo.ClaimDeliveries(context.Background(), 20, time.Minute)
+
+	assert.Nil(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, "https://erp.example.com", deliveries[0].URL)
+	assert.Equal(t, "s3cr3t", deliveries[0].Secret)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepository_Redeliver_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	repo := repository.NewSQLWebhookRepository(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE WEBHOOK_DELIVERIES SET DEAD=FALSE").WithArgs("d1", "w1", tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = repo.Redeliver(context.Background(), "w1", "d1")
+
+	assert.True(t, apperrors.Is(err, apperrors.KindNotFound))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
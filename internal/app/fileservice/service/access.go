@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/vielendanke/file-service/internal/app/fileservice/access"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+// AccessRecordingService records the successful metadata reads and downloads
+// in the access log, it wraps the authorization so only the granted accesses
+// are recorded
+type AccessRecordingService struct {
+	FileProcessingService
+	recorder access.Recorder
+}
+
+// NewAccessRecordingService ...
+func NewAccessRecordingService(next FileProcessingService, recorder access.Recorder) FileProcessingService {
+	return &AccessRecordingService{
+		FileProcessingService: next,
+		recorder:              recorder,
+	}
+}
+
+// GetFileMetadata ...
+func (ars *AccessRecordingService) GetFileMetadata(ctx context.Context, id string) (map[string]interface{}, error) {
+	metadata, err := ars.FileProcessingService.GetFileMetadata(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	ars.record(ctx, id, model.AccessRead)
+	return metadata, nil
+}
+
+// DownloadFile records the download once the object is opened, the transfer
+// may still be cut by the client
+func (ars *AccessRecordingService) DownloadFile(ctx context.Context, id string) (io.ReadCloser, *model.ObjectInfo, error) {
+	body, info, err := ars.FileProcessingService.DownloadFile(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	ars.record(ctx, id, model.AccessDownload)
+	return body, info, nil
+}
+
+func (ars *AccessRecordingService) record(ctx context.Context, id, action string) {
+	c := access.FromContext(ctx)
+	e := &model.AccessEvent{
+		TenantID:   tenant.FromContext(ctx).ID,
+		FileID:     id,
+		Action:     action,
+		IP:         c.IP,
+		UserAgent:  c.UserAgent,
+		RequestID:  c.RequestID,
+		AccessedAt: time.Now().UTC(),
+	}
+	if p, ok := middleware.PrincipalFromContext(ctx); ok {
+		e.Principal = p.Subject
+		e.PrincipalKind = p.Kind
+	}
+	ars.recorder.Record(e)
+}
+
+// AccessLogService reads the access log of the tenant of the context, every
+// call is authorized for policy.ActionAudit
+type AccessLogService struct {
+	repo       repository.AccessRepository
+	authorizer policy.Authorizer
+}
+
+// NewAccessLogService ...
+func NewAccessLogService(repo repository.AccessRepository, authorizer policy.Authorizer) *AccessLogService {
+	return &AccessLogService{
+		repo:       repo,
+		authorizer: authorizer,
+	}
+}
+
+// Events returns a page of the events selected by q newest first, the Limit
+// and the Before of q are set from limit and cursor. The cursor is the Next of
+// the previous page.
+func (als *AccessLogService) Events(ctx context.Context, q *model.AccessQuery, cursor string, limit int) (*model.AccessList, error) {
+	if limit == 0 {
+		limit = defaultListLimit
+	}
+	if limit < 0 || limit > maxListLimit {
+		return nil, apperrors.Invalid(apperrors.CodeInvalidRequest, "Limit must be between 1 and %d, got %d", maxListLimit, limit)
+	}
+	before, err := parseAccessCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	if err := als.authorize(ctx, q); err != nil {
+		return nil, err
+	}
+	page := *q
+	page.Before, page.Limit = before, limit
+	events, err := als.repo.FindAccessEvents(ctx, &page)
+	if err != nil {
+		return nil, err
+	}
+	list := &model.AccessList{Events: events}
+	if len(events) == limit {
+		list.Next = strconv.FormatInt(events[len(events)-1].ID, 10)
+	}
+	return list, nil
+}
+
+// Export passes all of the events selected by q newest first to fn, the events
+// are read in pages of the largest list limit
+func (als *AccessLogService) Export(ctx context.Context, q *model.AccessQuery, fn func(*model.AccessEvent) error) error {
+	if err := als.authorize(ctx, q); err != nil {
+		return err
+	}
+	page := *q
+	page.Before, page.Limit = 0, maxListLimit
+	for {
+		events, err := als.repo.FindAccessEvents(ctx, &page)
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+		if len(events) < page.Limit {
+			return nil
+		}
+		page.Before = events[len(events)-1].ID
+	}
+}
+
+// authorize validates the period of q and checks the principal may read the
+// access log, of the file of q when it is set
+func (als *AccessLogService) authorize(ctx context.Context, q *model.AccessQuery) error {
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return apperrors.Invalid(apperrors.CodeInvalidRequest, "From must be before to, got %s and %s", q.From.Format(time.RFC3339), q.To.Format(time.RFC3339))
+	}
+	return als.authorizer.Authorize(ctx, policy.ActionAudit, &policy.Resource{ID: q.FileID})
+}
+
+func parseAccessCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	before, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil || before <= 0 {
+		return 0, apperrors.Invalid(apperrors.CodeInvalidRequest, "Invalid cursor %s", cursor)
+	}
+	return before, nil
+}
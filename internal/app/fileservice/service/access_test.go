@@ -0,0 +1,150 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/vielendanke/file-service/internal/app/fileservice/access"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+const accessPolicy = `{
+	"default_effect": "allow",
+	"rules": [
+		{"name": "auditors", "effect": "deny", "actions": ["audit"], "not_roles": ["auditor"]}
+	]
+}`
+
+type testRecorder struct {
+	events []*model.AccessEvent
+}
+
+func (tr *testRecorder) Record(e *model.AccessEvent) {
+	tr.events = append(tr.events, e)
+}
+
+func prepareAccessLogService(t *testing.T, repo *mocks.AccessRepository) *service.AccessLogService {
+	p, err := policy.Parse([]byte(accessPolicy))
+	if err != nil {
+		t.Fatalf("Error parsing policy, %v", err)
+	}
+	return service.NewAccessLogService(repo, &testAuthorizer{policy: p})
+}
+
+func TestAccessRecordingService_GetFileMetadata(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	recorder := &testRecorder{}
+	ctx := middleware.NewPrincipalContext(context.Background(), &middleware.Principal{Subject: "bob", Kind: "user"})
+	ctx = tenant.NewContext(ctx, &tenant.Tenant{ID: "hr"})
+	ctx = access.NewContext(ctx, &access.Client{IP: "10.0.0.1", UserAgent: "curl/7.68.0", RequestID: "req-1"})
+	mockService.On("GetFileMetadata", ctx, "f1").Return(map[string]interface{}{"holder": "bob"}, nil)
+
+	_, err := service.NewAccessRecordingService(mockService, recorder).GetFileMetadata(ctx, "f1")
+
+	assert.Nil(t, err)
+	assert.Len(t, recorder.events, 1)
+	e := recorder.events[0]
+	assert.Equal(t, "hr", e.TenantID)
+	assert.Equal(t, "f1", e.FileID)
+	assert.Equal(t, model.AccessRead, e.Action)
+	assert.Equal(t, "bob", e.Principal)
+	assert.Equal(t, "user", e.PrincipalKind)
+	assert.Equal(t, "10.0.0.1", e.IP)
+	assert.Equal(t, "curl/7.68.0", e.UserAgent)
+	assert.Equal(t, "req-1", e.RequestID)
+	assert.False(t, e.AccessedAt.IsZero())
+}
+
+func TestAccessRecordingService_DownloadFile_Denied(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	recorder := &testRecorder{}
+	mockService.On("DownloadFile", context.Background(), "f1").Return(nil, nil, apperrors.Forbidden(apperrors.CodeForbidden, "Forbidden"))
+
+	_, _, err := service.NewAccessRecordingService(mockService, recorder).DownloadFile(context.Background(), "f1")
+
+	assert.True(t, apperrors.Is(err, apperrors.KindForbidden))
+	assert.Empty(t, recorder.events)
+}
+
+func TestAccessLogService_Events(t *testing.T) {
+	repo := new(mocks.AccessRepository)
+	ctx := principalContext("auditor")
+	from := time.Date(2030, 5, 1, 0, 0, 0, 0, time.UTC)
+	repo.On("FindAccessEvents", ctx, &model.AccessQuery{FileID: "f1", From: from, Before: 40, Limit: 2}).Return([]*model.AccessEvent{{ID: 39}, {ID: 35}}, nil)
+
+	list, err := prepareAccessLogService(t, repo).Events(ctx, &model.AccessQuery{FileID: "f1", From: from}, "40", 2)
+
+	assert.Nil(t, err)
+	assert.Len(t, list.Events, 2)
+	assert.Equal(t, "35", list.Next)
+	repo.AssertExpectations(t)
+}
+
+func TestAccessLogService_Events_Invalid(t *testing.T) {
+	repo := new(mocks.AccessRepository)
+	als := prepareAccessLogService(t, repo)
+	ctx := principalContext("auditor")
+	from := time.Date(2030, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := als.Events(ctx, &model.AccessQuery{}, "abc", 0)
+	assert.True(t, apperrors.Is(err, apperrors.KindInvalid))
+	_, err = als.Events(ctx, &model.AccessQuery{From: from, To: from}, "", 0)
+	assert.True(t, apperrors.Is(err, apperrors.KindInvalid))
+	_, err = als.Events(ctx, &model.AccessQuery{}, "", 1000)
+	assert.True(t, apperrors.Is(err, apperrors.KindInvalid))
+	repo.AssertNotCalled(t, "FindAccessEvents", mock.Anything, mock.Anything)
+}
+
+func TestAccessLogService_Events_Denied(t *testing.T) {
+	repo := new(mocks.AccessRepository)
+
+	_, err := prepareAccessLogService(t, repo).Events(principalContext("sales"), &model.AccessQuery{Principal: "bob"}, "", 0)
+
+	assert.True(t, apperrors.Is(err, apperrors.KindForbidden))
+	repo.AssertNotCalled(t, "FindAccessEvents", mock.Anything, mock.Anything)
+}
+
+func TestAccessLogService_Export(t *testing.T) {
+	repo := new(mocks.AccessRepository)
+	ctx := principalContext("auditor")
+	page := make([]*model.AccessEvent, 0, 100)
+	for id := int64(200); id > 100; id-- {
+		page = append(page, &model.AccessEvent{ID: id})
+	}
+	repo.On("FindAccessEvents", ctx, &model.AccessQuery{Principal: "bob", Limit: 100}).Return(page, nil).Once()
+	repo.On("FindAccessEvents", ctx, &model.AccessQuery{Principal: "bob", Before: 101, Limit: 100}).Return([]*model.AccessEvent{{ID: 7}}, nil).Once()
+
+	exported := 0
+	err := prepareAccessLogService(t, repo).Export(ctx, &model.AccessQuery{Principal: "bob"}, func(e *model.AccessEvent) error {
+		exported++
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 101, exported)
+	repo.AssertExpectations(t)
+}
+
+func TestAccessLogService_Export_StopsOnWriteError(t *testing.T) {
+	repo := new(mocks.AccessRepository)
+	ctx := principalContext("auditor")
+	repo.On("FindAccessEvents", ctx, &model.AccessQuery{Limit: 100}).Return([]*model.AccessEvent{{ID: 2}, {ID: 1}}, nil).Once()
+	failure := errors.New("broken pipe")
+
+	err := prepareAccessLogService(t, repo).Export(ctx, &model.AccessQuery{}, func(e *model.AccessEvent) error {
+		return failure
+	})
+
+	assert.Equal(t, failure, err)
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	s3store "github.com/unistack-org/micro-store-s3/v3"
+	"github.com/unistack-org/micro/v3/store"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/contenttype"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+// exportPageSize is the number of rows read at once by Export
+const exportPageSize = 500
+
+// ObjectStat is the object of a file in a store
+type ObjectStat struct {
+	Store  string `json:"store"`
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	Exists bool   `json:"exists"`
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// FileReport is the row of a file with its objects in the dirty and the clean store
+type FileReport struct {
+	File    *model.FileRecord `json:"file"`
+	Objects []*ObjectStat     `json:"objects"`
+}
+
+// Verification compares the recorded size of a file with its objects
+type Verification struct {
+	Objects  []*ObjectStat `json:"objects"`
+	Problems []string      `json:"problems,omitempty"`
+}
+
+// OK reports if the objects match the row of the file
+func (v *Verification) OK() bool {
+	return len(v.Problems) == 0
+}
+
+// AdminService holds the operations of the operators, it works on the tenant
+// of the context without the authorization of the file service
+type AdminService struct {
+	fileRepository repository.FileRepository
+	processing     FileProcessingService
+	cleanStore     store.Store
+	dirtyStore     store.Store
+	cleanOpener    ObjectOpener
+	dirtyOpener    ObjectOpener
+}
+
+// NewAdminService creates the service, Verify streams the objects through
+// cleanOpener and dirtyOpener
+func NewAdminService(fileRepository repository.FileRepository, processing FileProcessingService, cleanStore store.Store, dirtyStore store.Store, cleanOpener ObjectOpener, dirtyOpener ObjectOpener) *AdminService {
+	return &AdminService{
+		fileRepository: fileRepository,
+		processing:     processing,
+		cleanStore:     cleanStore,
+		dirtyStore:     dirtyStore,
+		cleanOpener:    cleanOpener,
+		dirtyOpener:    dirtyOpener,
+	}
+}
+
+// Inspect returns the row of the file and the presence of its objects
+func (as *AdminService) Inspect(ctx context.Context, id string) (*FileReport, error) {
+	f, err := as.fileRepository.FindFileByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	report := &FileReport{File: f}
+	for _, s := range as.stores() {
+		stat, err := as.stat(ctx, s.name, s.store, id)
+		if err != nil {
+			return nil, err
+		}
+		report.Objects = append(report.Objects, stat)
+	}
+	return report, nil
+}
+
+// Promote copies the object of the file from the dirty to the clean store
+// without waiting for the scan and records the file as clean
+func (as *AdminService) Promote(ctx context.Context, id string) error {
+	record, err := as.fileRepository.FindFileByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	contentType := record.ContentType
+	if contentType == "" {
+		contentType = contenttype.Default
+	}
+	t := tenant.FromContext(ctx)
+	data, err := as.read(ctx, dirtyStoreName, as.dirtyStore, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return apperrors.NotFound(apperrors.CodeFileNotFound, "File %s not present in dirty store", id)
+	}
+	if err != nil {
+		return err
+	}
+	call, spanCtx := startS3Call(ctx, cleanStoreName, "Write", t, id)
+	err = as.cleanStore.Write(
+		spanCtx,
+		t.Key(id),
+		data,
+		s3store.WriteBucket(t.Bucket),
+		s3store.ContentType(contentType),
+	)
+	call.finish(err)
+	if err != nil {
+		return fmt.Errorf("Error writing file to clean store, %v", err)
+	}
+	return as.fileRepository.MarkFileClean(ctx, id)
+}
+
+// Quarantine removes the object of the file from the clean store so it can not
+// be downloaded, the object in the dirty store is kept for the investigation
+func (as *AdminService) Quarantine(ctx context.Context, id string) error {
+	if _, err := as.fileRepository.FindFileByID(ctx, id); err != nil {
+		return err
+	}
+	return as.delete(ctx, cleanStoreName, as.cleanStore, id)
+}
+
+// Reupload replaces the object of the file in the dirty store with r, the file
+// reaches the clean store after the scan or Promote
+func (as *AdminService) Reupload(ctx context.Context, id string, r io.Reader) error {
+	f, err := as.fileRepository.FindFileByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return as.processing.StoreFile(ctx, &model.AWSModel{
+		File:     r,
+		FileID:   f.ID,
+		FileName: f.FileName,
+		DocClass: f.DocClass,
+		DocType:  f.DocType,
+		DocNum:   f.DocNum,
+	})
+}
+
+// Export writes the rows of the files of the tenant as JSON lines to w and
+// returns the number of written rows
+func (as *AdminService) Export(ctx context.Context, w io.Writer) (int, error) {
+	enc := json.NewEncoder(w)
+	written := 0
+	after := ""
+	for {
+		files, err := as.fileRepository.ListFiles(ctx, after, exportPageSize)
+		if err != nil {
+			return written, err
+		}
+		for _, f := range files {
+			if err := enc.Encode(f); err != nil {
+				return written, fmt.Errorf("Error writing file %s, %v", f.ID, err)
+			}
+			written++
+		}
+		if len(files) < exportPageSize {
+			return written, nil
+		}
+		after = files[len(files)-1].ID
+	}
+}
+
+// Verify checksums the objects of the file, the objects must have the recorded
+// size and the clean copy must match the dirty one
+func (as *AdminService) Verify(ctx context.Context, id string) (*Verification, error) {
+	f, err := as.fileRepository.FindFileByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	t := tenant.FromContext(ctx)
+	v := &Verification{}
+	for _, s := range as.stores() {
+		stat := &ObjectStat{Store: s.name, Bucket: t.Bucket, Key: t.Key(id)}
+		size, sum, err := as.checksum(ctx, s, id)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			v.Problems = append(v.Problems, fmt.Sprintf("object is missing in %s", s.name))
+		case err != nil:
+			return nil, err
+		default:
+			stat.Exists = true
+			stat.Size = size
+			stat.SHA256 = sum
+			if stat.Size != f.Size {
+				v.Problems = append(v.Problems, fmt.Sprintf("object in %s has %d bytes, %d recorded", s.name, stat.Size, f.Size))
+			}
+		}
+		v.Objects = append(v.Objects, stat)
+	}
+	if dirty, clean := v.Objects[0], v.Objects[1]; dirty.Exists && clean.Exists && dirty.SHA256 != clean.SHA256 {
+		v.Problems = append(v.Problems, "objects in dirty and clean store differ")
+	}
+	return v, nil
+}
+
+// Purge deletes the objects and the row of the file
+func (as *AdminService) Purge(ctx context.Context, id string) error {
+	if _, err := as.fileRepository.FindFileByID(ctx, id); err != nil {
+		return err
+	}
+	if err := as.delete(ctx, cleanStoreName, as.cleanStore, id); err != nil {
+		return err
+	}
+	if err := as.delete(ctx, dirtyStoreName, as.dirtyStore, id); err != nil {
+		return err
+	}
+	return as.fileRepository.DeleteMetadataByID(ctx, id)
+}
+
+type namedStore struct {
+	name   string
+	store  store.Store
+	opener ObjectOpener
+}
+
+// stores returns the dirty and the clean store in the order of the upload
+func (as *AdminService) stores() []namedStore {
+	return []namedStore{{dirtyStoreName, as.dirtyStore, as.dirtyOpener}, {cleanStoreName, as.cleanStore, as.cleanOpener}}
+}
+
+func (as *AdminService) stat(ctx context.Context, storeName string, s store.Store, id string) (*ObjectStat, error) {
+	t := tenant.FromContext(ctx)
+	call, spanCtx := startS3Call(ctx, storeName, "Exists", t, id)
+	err := s.Exists(spanCtx, storedKey(t.Key(id)), s3store.ExistsBucket(t.Bucket))
+	call.finish(err)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("Error accessing %s, %v", storeName, err)
+	}
+	return &ObjectStat{Store: storeName, Bucket: t.Bucket, Key: t.Key(id), Exists: err == nil}, nil
+}
+
+func (as *AdminService) read(ctx context.Context, storeName string, s store.Store, id string) ([]byte, error) {
+	t := tenant.FromContext(ctx)
+	data := []byte{}
+	call, spanCtx := startS3Call(ctx, storeName, "Read", t, id)
+	err := s.Read(spanCtx, t.Key(id), &data, s3store.ReadBucket(t.Bucket))
+	call.finish(err)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading file from %s, %v", storeName, err)
+	}
+	return data, nil
+}
+
+// checksum streams the object of the file through sha256 and returns its size
+// and its hex encoded hash
+func (as *AdminService) checksum(ctx context.Context, s namedStore, id string) (int64, string, error) {
+	body, err := openObject(ctx, s.opener, s.name, tenant.FromContext(ctx), id)
+	if errors.Is(err, store.ErrNotFound) {
+		return 0, "", err
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("Error reading file from %s, %v", s.name, err)
+	}
+	defer body.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, body)
+	if err != nil {
+		return 0, "", fmt.Errorf("Error reading file from %s, %v", s.name, err)
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (as *AdminService) delete(ctx context.Context, storeName string, s store.Store, id string) error {
+	t := tenant.FromContext(ctx)
+	call, spanCtx := startS3Call(ctx, storeName, "Delete", t, id)
+	err := s.Delete(spanCtx, t.Key(id), s3store.DeleteBucket(t.Bucket))
+	call.finish(err)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("Error deleting file from %s, %v", storeName, err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/unistack-org/micro/v3/store"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+func readReturns(data []byte) func(mock.Arguments) {
+	return func(args mock.Arguments) {
+		*args.Get(2).(*[]byte) = data
+	}
+}
+
+func TestAdminService_Inspect(t *testing.T) {
+	mockRepo := new(mocks.FileRepository)
+	dirtyStore := new(mocks.MockStore)
+	cleanStore := new(mocks.MockStore)
+	record := &model.FileRecord{ID: "id", Size: 3}
+
+	mockRepo.On("FindFileByID", context.Background(), "id").Return(record, nil)
+	dirtyStore.On("Exists", withSpan, "id", mock.AnythingOfType("store.ExistsOption")).Return(nil)
+	cleanStore.On("Exists", withSpan, "id", mock.AnythingOfType("store.ExistsOption")).Return(store.ErrNotFound)
+
+	report, err := service.NewAdminService(mockRepo, nil, cleanStore, dirtyStore, nil, nil).Inspect(context.Background(), "id")
+
+	assert.Nil(t, err)
+	assert.Equal(t, record, report.File)
+	assert.True(t, report.Objects[0].Exists)
+	assert.False(t, report.Objects[1].Exists)
+}
+
+func TestAdminService_Verify(t *testing.T) {
+	mockRepo := new(mocks.FileRepository)
+	dirtyOpener := new(mocks.ObjectOpener)
+	cleanOpener := new(mocks.ObjectOpener)
+
+	mockRepo.On("FindFileByID", context.Background(), "id").Return(&model.FileRecord{ID: "id", Size: 3}, nil)
+	dirtyOpener.On("Open", withSpan, tenant.DefaultBucket, "id").Return(ioutil.NopCloser(strings.NewReader("abc")), nil)
+	cleanOpener.On("Open", withSpan, tenant.DefaultBucket, "id").Return(ioutil.NopCloser(strings.NewReader("abd")), nil)
+
+	v, err := service.NewAdminService(mockRepo, nil, nil, nil, cleanOpener, dirtyOpener).Verify(context.Background(), "id")
+
+	assert.Nil(t, err)
+	assert.False(t, v.OK())
+	assert.Equal(t, []string{"objects in dirty and clean store differ"}, v.Problems)
+	assert.Equal(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", v.Objects[0].SHA256)
+	assert.Equal(t, int64(3), v.Objects[1].Size)
+}
+
+func TestAdminService_Verify_Missing(t *testing.T) {
+	mockRepo := new(mocks.FileRepository)
+	dirtyOpener := new(mocks.ObjectOpener)
+	cleanOpener := new(mocks.ObjectOpener)
+
+	mockRepo.On("FindFileByID", context.Background(), "id").Return(&model.FileRecord{ID: "id", Size: 4}, nil)
+	dirtyOpener.On("Open", withSpan, tenant.DefaultBucket, "id").Return(ioutil.NopCloser(strings.NewReader("abc")), nil)
+	cleanOpener.On("Open", withSpan, tenant.DefaultBucket, "id").Return(nil, store.ErrNotFound)
+
+	v, err := service.NewAdminService(mockRepo, nil, nil, nil, cleanOpener, dirtyOpener).Verify(context.Background(), "id")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"object in dirty_region has 3 bytes, 4 recorded", "object is missing in clean_region"}, v.Problems)
+	assert.False(t, v.Objects[1].Exists)
+}
+
+func TestAdminService_Export(t *testing.T) {
+	mockRepo := new(mocks.FileRepository)
+	out := &bytes.Buffer{}
+
+	mockRepo.On("ListFiles", context.Background(), "", 500).Return([]*model.FileRecord{
+		{ID: "a", FileName: "a.pdf", Metadata: []byte(`{"k":"v"}`)},
+		{ID: "b", FileName: "b.pdf", Metadata: []byte(`{}`)},
+	}, nil)
+
+	n, err := service.NewAdminService(mockRepo, nil, nil, nil, nil, nil).Export(context.Background(), out)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, `{"id":"a","tenant_id":"","file_name":"a.pdf","doc_class":"","doc_type":"","doc_num":"","size":0,"content_type":"","metadata":{"k":"v"}}
+{"id":"b","tenant_id":"","file_name":"b.pdf","doc_class":"","doc_type":"","doc_num":"","size":0,"content_type":"","metadata":{}}
+`, out.String())
+}
+
+func TestAdminService_Purge(t *testing.T) {
+	mockRepo := new(mocks.FileRepository)
+	dirtyStore := new(mocks.MockStore)
+	cleanStore := new(mocks.MockStore)
+
+	mockRepo.On("FindFileByID", context.Background(), "id").Return(&model.FileRecord{ID: "id"}, nil)
+	cleanStore.On("Delete", withSpan, "id", mock.AnythingOfType("store.DeleteOption")).Return(store.ErrNotFound)
+	dirtyStore.On("Delete", withSpan, "id", mock.AnythingOfType("store.DeleteOption")).Return(nil)
+	mockRepo.On("DeleteMetadataByID", context.Background(), "id").Return(nil)
+
+	err := service.NewAdminService(mockRepo, nil, cleanStore, dirtyStore, nil, nil).Purge(context.Background(), "id")
+
+	assert.Nil(t, err)
+	mockRepo.AssertExpectations(t)
+	dirtyStore.AssertExpectations(t)
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/archives"
+	metrics "github.com/vielendanke/file-service/internal/app/fileservice/commons/metrics"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+)
+
+var rejectedArchives = metrics.GetOrMakeCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.NS,
+		Name:      "archives_rejected_total",
+		Help:      "Total number of uploaded archives rejected by the inspection.",
+	},
+	[]string{"doc_class"},
+)
+
+// ArchiveService inspects the uploaded archives before passing them to the wrapped
+// service and records their entries once they are stored. It must be wrapped by
+// ContentTypeService which detects the archives. The archive is spooled to a
+// temporary file of at most maxBytes as the zip listing needs random access.
+type ArchiveService struct {
+	FileProcessingService
+	entryRepository repository.EntryRepository
+	limits          archives.Limits
+	maxBytes        int64
+}
+
+// NewArchiveService ...
+func NewArchiveService(next FileProcessingService, entryRepository repository.EntryRepository, limits archives.Limits, maxBytes int64) FileProcessingService {
+	return &ArchiveService{
+		FileProcessingService: next,
+		entryRepository:       entryRepository,
+		limits:                limits,
+		maxBytes:              maxBytes,
+	}
+}
+
+// StoreFile ...
+func (as *ArchiveService) StoreFile(ctx context.Context, f model.FileModel) error {
+	awsFile := f.(*model.AWSModel)
+	if !archives.IsArchive(awsFile.ContentType) {
+		return as.FileProcessingService.StoreFile(ctx, awsFile)
+	}
+	spool, err := ioutil.TempFile("", "archive-*")
+	if err != nil {
+		return fmt.Errorf("Error creating archive spool, %v", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	size, err := io.Copy(spool, io.LimitReader(awsFile.File, as.maxBytes+1))
+	if err != nil {
+		return fmt.Errorf("Error spooling archive, %v", err)
+	}
+	if size > as.maxBytes {
+		rejectedArchives.WithLabelValues(awsFile.GetDocClass()).Inc()
+		return apperrors.Invalid(apperrors.CodeArchiveRejected, "Archive is larger than %d bytes", as.maxBytes)
+	}
+	entries, err := archives.Inspect(spool, size, awsFile.ContentType, as.limits)
+	if err != nil {
+		rejectedArchives.WithLabelValues(awsFile.GetDocClass()).Inc()
+		return err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("Error rewinding archive spool, %v", err)
+	}
+	awsFile.File = spool
+	if err := as.FileProcessingService.StoreFile(ctx, awsFile); err != nil {
+		return err
+	}
+	return as.entryRepository.SaveFileEntries(ctx, awsFile.FileID, entries)
+}
+
+// EntryService serves the entries of the archives, they are authorized like the
+// metadata of their file
+type EntryService struct {
+	fileRepository  repository.FileRepository
+	entryRepository repository.EntryRepository
+	authorizer      policy.Authorizer
+}
+
+// NewEntryService ...
+func NewEntryService(fileRepository repository.FileRepository, entryRepository repository.EntryRepository, authorizer policy.Authorizer) *EntryService {
+	return &EntryService{
+		fileRepository:  fileRepository,
+		entryRepository: entryRepository,
+		authorizer:      authorizer,
+	}
+}
+
+// Entries returns the entries of the file, the files which are not archives have none
+func (es *EntryService) Entries(ctx context.Context, id string) ([]*model.ArchiveEntry, error) {
+	f, err := es.fileRepository.FindFileByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := es.authorizer.Authorize(ctx, policy.ActionRead, &policy.Resource{
+		ID:       id,
+		DocClass: f.DocClass,
+		DocType:  f.DocType,
+	}); err != nil {
+		return nil, err
+	}
+	return es.entryRepository.FindFileEntries(ctx, id)
+}
@@ -0,0 +1,105 @@
+package service_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/archives"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+var testArchiveLimits = archives.Limits{MaxDepth: 2, MaxEntries: 10, MaxRatio: 100, MaxExpandedBytes: 1 << 20}
+
+func testZip(t *testing.T, name, content string) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	w, err := zw.Create(name)
+	if err != nil {
+		t.Fatalf("Error creating zip entry, %v", err)
+	}
+	w.Write([]byte(content))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Error closing zip, %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchiveService_StoreFile(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	mockEntries := new(mocks.EntryRepository)
+	data := testZip(t, "notes.txt", "notes")
+	awsModel := &model.AWSModel{
+		File:        bytes.NewReader(data),
+		FileID:      "f1",
+		ContentType: archives.TypeZip,
+	}
+
+	mockService.On("StoreFile", context.Background(), awsModel).Return(func(ctx context.Context, f model.FileModel) error {
+		stored, err := ioutil.ReadAll(f.(*model.AWSModel).File)
+		assert.Nil(t, err)
+		assert.Equal(t, data, stored)
+		return nil
+	})
+	mockEntries.On("SaveFileEntries", context.Background(), "f1", []*model.ArchiveEntry{
+		{Path: "notes.txt", Size: 5, ContentType: "text/plain", Depth: 1},
+	}).Return(nil)
+
+	err := service.NewArchiveService(mockService, mockEntries, testArchiveLimits, 1<<20).StoreFile(context.Background(), awsModel)
+
+	assert.Nil(t, err)
+	mockService.AssertExpectations(t)
+	mockEntries.AssertExpectations(t)
+}
+
+func TestArchiveService_StoreFile_Bomb(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	mockEntries := new(mocks.EntryRepository)
+	awsModel := &model.AWSModel{
+		File:        bytes.NewReader(testZip(t, "zeros.txt", strings.Repeat("0", 1<<20))),
+		ContentType: archives.TypeZip,
+	}
+
+	err := service.NewArchiveService(mockService, mockEntries, testArchiveLimits, 1<<20).StoreFile(context.Background(), awsModel)
+
+	assert.True(t, apperrors.Is(err, apperrors.KindInvalid))
+	mockService.AssertNotCalled(t, "StoreFile", mock.Anything, mock.Anything)
+}
+
+func TestArchiveService_StoreFile_TooLarge(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	awsModel := &model.AWSModel{
+		File:        bytes.NewReader(testZip(t, "notes.txt", "notes")),
+		ContentType: archives.TypeZip,
+	}
+
+	err := service.NewArchiveService(mockService, new(mocks.EntryRepository), testArchiveLimits, 16).StoreFile(context.Background(), awsModel)
+
+	assert.True(t, apperrors.Is(err, apperrors.KindInvalid))
+	mockService.AssertNotCalled(t, "StoreFile", mock.Anything, mock.Anything)
+}
+
+func TestEntryService_Entries_Denied(t *testing.T) {
+	fileRepo := new(mocks.FileRepository)
+	entryRepo := new(mocks.EntryRepository)
+	ctx := principalContext("sales")
+	fileRepo.On("FindFileByID", ctx, "f1").Return(&model.FileRecord{ID: "f1", DocClass: "HR"}, nil)
+	p, err := policy.Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("Error parsing policy, %v", err)
+	}
+
+	_, err = service.NewEntryService(fileRepo, entryRepo, &testAuthorizer{policy: p}).Entries(ctx, "f1")
+
+	assert.True(t, apperrors.Is(err, apperrors.KindForbidden))
+	entryRepo.AssertNotCalled(t, "FindFileEntries", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/unistack-org/micro/v3/codec"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+)
+
+// AuthorizingService checks the access policy before passing the calls to the wrapped service
+type AuthorizingService struct {
+	next           FileProcessingService
+	fileRepository repository.FileRepository
+	codec          codec.Codec
+	authorizer     policy.Authorizer
+}
+
+// NewAuthorizingService ...
+func NewAuthorizingService(next FileProcessingService, codec codec.Codec, fileRepository repository.FileRepository, authorizer policy.Authorizer) FileProcessingService {
+	return &AuthorizingService{
+		next:           next,
+		fileRepository: fileRepository,
+		codec:          codec,
+		authorizer:     authorizer,
+	}
+}
+
+// StoreFile is authorized by SaveFileData which is called before it for every upload
+func (as *AuthorizingService) StoreFile(ctx context.Context, f model.FileModel) error {
+	return as.next.StoreFile(ctx, f)
+}
+
+// SaveFileData ...
+func (as *AuthorizingService) SaveFileData(ctx context.Context, f model.FileModel) error {
+	if err := as.authorizer.Authorize(ctx, policy.ActionUpload, &policy.Resource{
+		DocClass: f.GetDocClass(),
+		DocType:  f.GetDocType(),
+		Metadata: f.GetMetadata(),
+	}); err != nil {
+		return err
+	}
+	return as.next.SaveFileData(ctx, f)
+}
+
+// GetFileMetadata ...
+func (as *AuthorizingService) GetFileMetadata(ctx context.Context, id string) (map[string]interface{}, error) {
+	metadata, err := as.next.GetFileMetadata(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	class, _ := metadata["class"].(string)
+	docType, _ := metadata["type"].(string)
+	if err := as.authorizer.Authorize(ctx, policy.ActionRead, &policy.Resource{
+		ID:       id,
+		DocClass: class,
+		DocType:  docType,
+		Metadata: metadata,
+	}); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// DownloadFile ...
+func (as *AuthorizingService) DownloadFile(ctx context.Context, id string) (io.ReadCloser, *model.ObjectInfo, error) {
+	res, err := as.resource(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := as.authorizer.Authorize(ctx, policy.ActionDownload, res); err != nil {
+		return nil, nil, err
+	}
+	return as.next.DownloadFile(ctx, id)
+}
+
+// UpdateFileMetadata authorizes the update by the fields which are added, changed or removed
+func (as *AuthorizingService) UpdateFileMetadata(ctx context.Context, metadata map[string]interface{}, id string) error {
+	res, err := as.resource(ctx, id)
+	if err != nil {
+		return err
+	}
+	res.Fields = changedFields(res.Metadata, metadata)
+	if err := as.authorizer.Authorize(ctx, policy.ActionUpdate, res); err != nil {
+		return err
+	}
+	return as.next.UpdateFileMetadata(ctx, metadata, id)
+}
+
+// DeleteMetadataByID ...
+func (as *AuthorizingService) DeleteMetadataByID(ctx context.Context, id string) error {
+	res, err := as.resource(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := as.authorizer.Authorize(ctx, policy.ActionDelete, res); err != nil {
+		return err
+	}
+	return as.next.DeleteMetadataByID(ctx, id)
+}
+
+func (as *AuthorizingService) resource(ctx context.Context, id string) (*policy.Resource, error) {
+	properties, err := as.fileRepository.FindFileMetadataByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	metadata := make(map[string]interface{})
+	if err := as.codec.Unmarshal([]byte(properties["metadata"]), &metadata); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling JSONB, %v", err)
+	}
+	return &policy.Resource{
+		ID:       id,
+		DocClass: properties["class"],
+		DocType:  properties["type"],
+		Metadata: metadata,
+	}, nil
+}
+
+func changedFields(current, updated map[string]interface{}) []string {
+	fields := []string{}
+	for k, v := range updated {
+		if c, ok := current[k]; !ok || !reflect.DeepEqual(c, v) {
+			fields = append(fields, k)
+		}
+	}
+	for k := range current {
+		if _, ok := updated[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
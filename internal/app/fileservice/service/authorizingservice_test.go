@@ -0,0 +1,131 @@
+package service_test
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	jsoncodec "github.com/unistack-org/micro-codec-json/v3"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+const testPolicy = `{
+	"default_effect": "allow",
+	"rules": [
+		{"name": "hr", "effect": "deny", "actions": ["*"], "doc_classes": ["HR"], "not_roles": ["hr"]},
+		{"name": "status", "effect": "deny", "actions": ["update"], "fields": ["status"], "not_roles": ["back_office"]}
+	]
+}`
+
+type testAuthorizer struct {
+	policy *policy.Policy
+}
+
+func (ta *testAuthorizer) Authorize(ctx context.Context, action policy.Action, res *policy.Resource) error {
+	principal, ok := middleware.PrincipalFromContext(ctx)
+	if !ok {
+		principal = &middleware.Principal{}
+	}
+	return ta.policy.Evaluate(principal, action, res)
+}
+
+func prepareAuthorizingService(t *testing.T, next service.FileProcessingService, repo *mocks.FileRepository) service.FileProcessingService {
+	p, err := policy.Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("Error parsing policy, %v", err)
+	}
+	return service.NewAuthorizingService(next, jsoncodec.NewCodec(), repo, &testAuthorizer{policy: p})
+}
+
+func principalContext(roles ...string) context.Context {
+	return middleware.NewPrincipalContext(context.Background(), &middleware.Principal{Subject: "user", Roles: roles})
+}
+
+func TestAuthorizingService_SaveFileData_Denied(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	mockRepo := new(mocks.FileRepository)
+	awsService := prepareAuthorizingService(t, mockService, mockRepo)
+
+	err := awsService.SaveFileData(principalContext("sales"), &model.AWSModel{DocClass: "HR"})
+
+	assert.True(t, apperrors.Is(err, apperrors.KindForbidden))
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthorizingService_DownloadFile(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	mockRepo := new(mocks.FileRepository)
+	awsService := prepareAuthorizingService(t, mockService, mockRepo)
+	ctx := principalContext("hr")
+
+	mockRepo.On("FindFileMetadataByID", ctx, "testID").Return(map[string]string{"class": "HR", "metadata": "{}"}, nil)
+	mockService.On("DownloadFile", ctx, "testID").Return(ioutil.NopCloser(strings.NewReader("testData")), &model.ObjectInfo{FileName: "file.txt"}, nil)
+
+	body, info, err := awsService.DownloadFile(ctx, "testID")
+
+	assert.Nil(t, err)
+	data, _ := ioutil.ReadAll(body)
+	assert.Equal(t, "testData", string(data))
+	assert.Equal(t, "file.txt", info.FileName)
+	mockRepo.AssertExpectations(t)
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthorizingService_GetFileMetadata_Denied(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	mockRepo := new(mocks.FileRepository)
+	awsService := prepareAuthorizingService(t, mockService, mockRepo)
+	ctx := principalContext()
+
+	mockService.On("GetFileMetadata", ctx, "testID").Return(map[string]interface{}{"class": "HR"}, nil)
+
+	res, err := awsService.GetFileMetadata(ctx, "testID")
+
+	assert.Nil(t, res)
+	assert.True(t, apperrors.Is(err, apperrors.KindForbidden))
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthorizingService_UpdateFileMetadata_ChangedFields(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	mockRepo := new(mocks.FileRepository)
+	awsService := prepareAuthorizingService(t, mockService, mockRepo)
+	ctx := principalContext()
+	unchanged := map[string]interface{}{"status": "approved", "iin": "new"}
+	changed := map[string]interface{}{"status": "rejected"}
+
+	mockRepo.On("FindFileMetadataByID", ctx, "testID").Return(map[string]string{
+		"class":    "SALES",
+		"metadata": `{"status": "approved", "iin": "old"}`,
+	}, nil)
+	mockService.On("UpdateFileMetadata", ctx, unchanged, "testID").Return(nil)
+
+	assert.Nil(t, awsService.UpdateFileMetadata(ctx, unchanged, "testID"))
+	assert.True(t, apperrors.Is(awsService.UpdateFileMetadata(ctx, changed, "testID"), apperrors.KindForbidden))
+	mockRepo.AssertExpectations(t)
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthorizingService_DeleteMetadataByID_SystemContext(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	mockRepo := new(mocks.FileRepository)
+	engine, err := policy.NewEngine("")
+	if err != nil {
+		t.Fatalf("Error creating engine, %v", err)
+	}
+	awsService := service.NewAuthorizingService(mockService, jsoncodec.NewCodec(), mockRepo, engine)
+	ctx := policy.NewSystemContext(context.Background())
+
+	mockRepo.On("FindFileMetadataByID", ctx, "testID").Return(map[string]string{"class": "HR", "metadata": "{}"}, nil)
+	mockService.On("DeleteMetadataByID", ctx, "testID").Return(nil)
+
+	assert.Nil(t, awsService.DeleteMetadataByID(ctx, "testID"))
+	mockService.AssertExpectations(t)
+}
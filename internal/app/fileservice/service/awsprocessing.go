@@ -2,36 +2,51 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"regexp"
 	"strings"
-	"sync"
 
 	"github.com/google/uuid"
 	s3store "github.com/unistack-org/micro-store-s3/v3"
 	"github.com/unistack-org/micro/v3/codec"
 	"github.com/unistack-org/micro/v3/store"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/tracer"
+	"github.com/vielendanke/file-service/internal/app/fileservice/contenttype"
 	"github.com/vielendanke/file-service/internal/app/fileservice/model"
 	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
 )
 
 var keyRegex = regexp.MustCompile("[^a-zA-Z0-9]+")
 
+// storedKey returns the name of the object of key in the bucket, the s3 store
+// replaces the characters other than letters and digits when it writes, reads
+// and deletes the objects but not when it checks that they exist
+func storedKey(key string) string {
+	return keyRegex.ReplaceAllString(key, "-")
+}
+
 // AWSProcessingService ...
 type AWSProcessingService struct {
 	fileRepository repository.FileRepository
 	codec          codec.Codec
 	cleanStore     store.Store
 	dirtyStore     store.Store
+	cleanOpener    ObjectOpener
 }
 
-// NewAWSProcessingService ...
-func NewAWSProcessingService(codec codec.Codec, fileRepository repository.FileRepository, cleanStore store.Store, dirtyStore store.Store) FileProcessingService {
+// NewAWSProcessingService creates the service, the downloads stream the clean
+// files through cleanOpener
+func NewAWSProcessingService(codec codec.Codec, fileRepository repository.FileRepository, cleanStore store.Store, dirtyStore store.Store, cleanOpener ObjectOpener) FileProcessingService {
 	return &AWSProcessingService{
 		fileRepository: fileRepository,
 		codec:          codec,
 		cleanStore:     cleanStore,
 		dirtyStore:     dirtyStore,
+		cleanOpener:    cleanOpener,
 	}
 }
 
@@ -40,21 +55,47 @@ func (aps *AWSProcessingService) DeleteMetadataByID(ctx context.Context, id stri
 	return aps.fileRepository.DeleteMetadataByID(ctx, id)
 }
 
-// StoreFile ...
+// StoreFile writes the file to the bucket of the tenant and records its size and
+// content type, the upload fails once the bytes quota of the tenant is exceeded
 func (aps *AWSProcessingService) StoreFile(ctx context.Context, f model.FileModel) error {
 	awsFile := f.(*model.AWSModel)
 	if awsFile.GetFileID() == "" {
-		return fmt.Errorf("ID of file not found")
-	}
-	if err := aps.dirtyStore.Write(
-		ctx,
-		awsFile.FileID,
-		awsFile.File,
-		s3store.WriteBucket("micro-store-s3"),
-		s3store.ContentType("application/octet-stream"),
-	); err != nil {
+		return apperrors.Invalid(apperrors.CodeFileIDMissing, "ID of file not found")
+	}
+	contentType := awsFile.ContentType
+	if contentType == "" {
+		contentType = contenttype.Default
+	}
+	t := tenant.FromContext(ctx)
+	reader, err := aps.newQuotaReader(ctx, t, awsFile.FileID, awsFile.File)
+	if err != nil {
+		return err
+	}
+	call, spanCtx := startS3Call(ctx, dirtyStoreName, "Write", t, awsFile.FileID)
+	call.span.SetTag(tracer.TagDocClass, awsFile.GetDocClass())
+	err = aps.dirtyStore.Write(
+		spanCtx,
+		t.Key(awsFile.FileID),
+		reader,
+		s3store.WriteBucket(t.Bucket),
+		s3store.ContentType(contentType),
+	)
+	call.span.SetTag(tracer.TagBytes, reader.n)
+	call.finish(err)
+	if err != nil {
+		aps.releaseQuota(ctx, reader, awsFile.FileID)
+		if reader.reserveErr != nil {
+			return reader.reserveErr
+		}
 		return fmt.Errorf("Error writing file to s3, %v", err)
 	}
+	uploads.WithLabelValues(awsFile.GetDocClass()).Inc()
+	storedBytes.WithLabelValues(awsFile.GetDocClass()).Add(float64(reader.n))
+	// the stored size replaces the reservation of the upload
+	if err := aps.fileRepository.UpdateFileContentByID(ctx, reader.n, contentType, awsFile.FileID); err != nil {
+		aps.releaseQuota(ctx, reader, awsFile.FileID)
+		return err
+	}
 	return nil
 }
 
@@ -80,8 +121,8 @@ func (aps *AWSProcessingService) SaveFileData(ctx context.Context, f model.FileM
 
 // GetFileMetadata ...
 func (aps *AWSProcessingService) GetFileMetadata(ctx context.Context, id string) (map[string]interface{}, error) {
-	if err := aps.cleanStore.Exists(ctx, id, s3store.ExistsBucket("micro-store-s3")); err != nil {
-		return nil, fmt.Errorf("File not present in clean store, %v", err)
+	if err := aps.exists(ctx, id); err != nil {
+		return nil, err
 	}
 	properties, err := aps.fileRepository.FindFileMetadataByID(ctx, id)
 	if err != nil {
@@ -98,41 +139,28 @@ func (aps *AWSProcessingService) GetFileMetadata(ctx context.Context, id string)
 	return jsonMap, nil
 }
 
-// DownloadFile ...
-func (aps *AWSProcessingService) DownloadFile(ctx context.Context, id string) ([]byte, string, error) {
-	wg := sync.WaitGroup{}
-	errCh := make(chan error, 2)
-	defer close(errCh)
-	file := []byte{}
-	filename := ""
-	wg.Add(2)
-	go func(file *[]byte) {
-		defer wg.Done()
-		if err := aps.cleanStore.Read(ctx, id, file, s3store.ReadBucket("micro-store-s3")); err != nil {
-			errCh <- fmt.Errorf("Error download file from s3, %v", err)
-		}
-	}(&file)
-	go func(filename *string) {
-		defer wg.Done()
-		fn, err := aps.fileRepository.FindFileNameByID(ctx, id)
-		if err != nil {
-			errCh <- err
-		}
-		*filename = fn
-	}(&filename)
-	wg.Wait()
-	select {
-	case err := <-errCh:
-		return nil, "", err
-	default:
-		return file, filename, nil
+// DownloadFile opens the clean file for reading together with its stored name
+// and content type, the caller closes the reader
+func (aps *AWSProcessingService) DownloadFile(ctx context.Context, id string) (io.ReadCloser, *model.ObjectInfo, error) {
+	record, err := aps.fileRepository.FindFileByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := openObject(ctx, aps.cleanOpener, cleanStoreName, tenant.FromContext(ctx), id)
+	if err != nil {
+		return nil, nil, cleanStoreError(err)
+	}
+	contentType := record.ContentType
+	if contentType == "" {
+		contentType = contenttype.Default
 	}
+	return body, &model.ObjectInfo{FileName: record.FileName, ContentType: contentType}, nil
 }
 
 // UpdateFileMetadata ...
 func (aps *AWSProcessingService) UpdateFileMetadata(ctx context.Context, metadata map[string]interface{}, id string) error {
-	if err := aps.cleanStore.Exists(ctx, id, s3store.ExistsBucket("micro-store-s3")); err != nil {
-		return fmt.Errorf("File not present in clean store, %v", err)
+	if err := aps.exists(ctx, id); err != nil {
+		return err
 	}
 	jsonMetadata, err := aps.codec.Marshal(metadata)
 	if err != nil {
@@ -143,3 +171,24 @@ func (aps *AWSProcessingService) UpdateFileMetadata(ctx context.Context, metadat
 	}
 	return nil
 }
+
+// exists checks that the file is present in the clean store
+func (aps *AWSProcessingService) exists(ctx context.Context, id string) error {
+	t := tenant.FromContext(ctx)
+	call, spanCtx := startS3Call(ctx, cleanStoreName, "Exists", t, id)
+	err := aps.cleanStore.Exists(spanCtx, storedKey(t.Key(id)), s3store.ExistsBucket(t.Bucket))
+	call.finish(err)
+	if err != nil {
+		return cleanStoreError(err)
+	}
+	return nil
+}
+
+// cleanStoreError reports a missing object as not ready, the file is either
+// not uploaded or not yet moved to the clean store
+func cleanStoreError(err error) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return apperrors.Wrap(err, apperrors.KindNotReady, apperrors.CodeFileNotReady, "File not present in clean store")
+	}
+	return fmt.Errorf("Error accessing clean store, %v", err)
+}
@@ -4,15 +4,29 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
 	"testing"
+	"testing/iotest"
 
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/unistack-org/micro/v3/store"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
 	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
 	"github.com/vielendanke/file-service/internal/app/fileservice/model"
 	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
 )
 
+// withSpan matches the contexts of the calls to the object storage, which carry their span
+var withSpan = mock.MatchedBy(func(ctx context.Context) bool {
+	return opentracing.SpanFromContext(ctx) != nil
+})
+
 func TestAWSProcessingService_SaveFileMetadata(t *testing.T) {
 	mockRepo := new(mocks.FileRepository)
 	mockCodec := new(mocks.MockCodec)
@@ -27,7 +41,7 @@ func TestAWSProcessingService_SaveFileMetadata(t *testing.T) {
 	mockCodec.On("Marshal", metadata).Return([]byte(testData), nil)
 	mockRepo.On("SaveFileMetadata", context.Background(), awsModel, testData).Return(nil)
 
-	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, nil, nil)
+	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, nil, nil, nil)
 
 	err := awsService.SaveFileData(context.Background(), awsModel)
 
@@ -50,7 +64,7 @@ func TestAWSProcessingService_SaveFileMetadata_FileMetadataAlreadyExists(t *test
 
 	mockRepo.On("CheckIfExists", mock.Anything, awsModel).Return(fmt.Errorf(errMsg))
 
-	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, nil, nil)
+	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, nil, nil, nil)
 
 	err := awsService.SaveFileData(context.Background(), awsModel)
 
@@ -72,7 +86,7 @@ func TestAWSProcessingService_SaveFileMetadata_CodecMarshalReturnError(t *testin
 	mockRepo.On("CheckIfExists", mock.Anything, awsModel).Return(nil)
 	mockCodec.On("Marshal", metadata).Return(nil, fmt.Errorf(errMsg))
 
-	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, nil, nil)
+	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, nil, nil, nil)
 
 	err := awsService.SaveFileData(context.Background(), awsModel)
 
@@ -98,7 +112,7 @@ func TestAWSProcessingService_SaveFileMetadata_RepositorySaveFileReturnError(t *
 	mockCodec.On("Marshal", metadata).Return([]byte(testData), nil)
 	mockRepo.On("SaveFileMetadata", context.Background(), awsModel, testData).Return(fmt.Errorf(errMsg))
 
-	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, nil, nil)
+	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, nil, nil, nil)
 
 	err := awsService.SaveFileData(context.Background(), awsModel)
 
@@ -111,6 +125,7 @@ func TestAWSProcessingService_SaveFileMetadata_RepositorySaveFileReturnError(t *
 
 func TestAWSProcessingService_StoreFile(t *testing.T) {
 	mockStore := new(mocks.MockStore)
+	mockRepo := new(mocks.FileRepository)
 	testData := "metadata"
 	metadata := make(map[string]interface{})
 	awsModel := &model.AWSModel{
@@ -121,20 +136,66 @@ func TestAWSProcessingService_StoreFile(t *testing.T) {
 
 	mockStore.On(
 		"Write",
-		context.Background(),
+		withSpan,
+		testData,
 		mock.Anything,
-		awsModel.File,
 		mock.AnythingOfType("store.WriteOption"),
 		mock.AnythingOfType("store.WriteOption"),
 	).Return(nil)
+	mockRepo.On("UpdateFileContentByID", context.Background(), int64(0), "application/octet-stream", testData).Return(nil)
 
-	awsService := service.NewAWSProcessingService(nil, nil, mockStore, mockStore)
+	awsService := service.NewAWSProcessingService(nil, mockRepo, mockStore, mockStore, nil)
 
 	err := awsService.StoreFile(context.Background(), awsModel)
 
 	assert.Nil(t, err)
 
 	mockStore.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAWSProcessingService_StoreFile_Span(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+	mockStore := new(mocks.MockStore)
+	mockRepo := new(mocks.FileRepository)
+	testData := "metadata"
+	awsModel := &model.AWSModel{
+		DocClass:    "HR",
+		File:        bytes.NewBuffer([]byte(testData)),
+		FileID:      testData,
+		ContentType: "text/plain",
+	}
+	parent := tracer.StartSpan("upload")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+
+	mockStore.On(
+		"Write",
+		withSpan,
+		testData,
+		mock.Anything,
+		mock.AnythingOfType("store.WriteOption"),
+		mock.AnythingOfType("store.WriteOption"),
+	).Return(func(ctx context.Context, key string, val interface{}, opts ...store.WriteOption) error {
+		_, err := ioutil.ReadAll(val.(io.Reader))
+		return err
+	})
+	mockRepo.On("UpdateFileContentByID", ctx, int64(len(testData)), "text/plain", testData).Return(nil)
+
+	awsService := service.NewAWSProcessingService(nil, mockRepo, mockStore, mockStore, nil)
+
+	assert.Nil(t, awsService.StoreFile(ctx, awsModel))
+
+	spans := tracer.FinishedSpans()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "s3 Write", spans[0].OperationName)
+	assert.Equal(t, parent.Context().(mocktracer.MockSpanContext).SpanID, spans[0].ParentID)
+	assert.Equal(t, testData, spans[0].Tag("file.id"))
+	assert.Equal(t, "HR", spans[0].Tag("file.doc_class"))
+	assert.Equal(t, int64(len(testData)), spans[0].Tag("file.bytes"))
+	mockStore.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
 }
 
 func TestAWSProcessingService_StoreFile_EmptyFileID(t *testing.T) {
@@ -146,7 +207,7 @@ func TestAWSProcessingService_StoreFile_EmptyFileID(t *testing.T) {
 		File:     bytes.NewBuffer([]byte(testData)),
 	}
 
-	awsService := service.NewAWSProcessingService(nil, nil, mockStore, mockStore)
+	awsService := service.NewAWSProcessingService(nil, nil, mockStore, mockStore, nil)
 
 	err := awsService.StoreFile(context.Background(), awsModel)
 
@@ -168,14 +229,14 @@ func TestAWSProcessingService_StoreFile_StoreWriterReturnError(t *testing.T) {
 
 	mockStore.On(
 		"Write",
-		context.Background(),
+		withSpan,
+		mock.Anything,
 		mock.Anything,
-		awsModel.File,
 		mock.AnythingOfType("store.WriteOption"),
 		mock.AnythingOfType("store.WriteOption"),
 	).Return(fmt.Errorf(errMsg))
 
-	awsService := service.NewAWSProcessingService(nil, nil, mockStore, mockStore)
+	awsService := service.NewAWSProcessingService(nil, nil, mockStore, mockStore, nil)
 
 	err := awsService.StoreFile(context.Background(), awsModel)
 
@@ -185,6 +246,125 @@ func TestAWSProcessingService_StoreFile_StoreWriterReturnError(t *testing.T) {
 	mockStore.AssertExpectations(t)
 }
 
+// readAll is a Write of the store which reads the whole file
+func readAll(ctx context.Context, key string, val interface{}, opts ...store.WriteOption) error {
+	_, err := ioutil.ReadAll(val.(io.Reader))
+	return err
+}
+
+func TestAWSProcessingService_StoreFile_ReservesQuota(t *testing.T) {
+	mockStore := new(mocks.MockStore)
+	mockRepo := new(mocks.FileRepository)
+	testData := "metadata"
+	ctx := tenant.NewContext(context.Background(), &tenant.Tenant{ID: "hr", Bucket: "hr-bucket", MaxBytes: 10 << 20})
+	awsModel := &model.AWSModel{
+		File:   bytes.NewBuffer([]byte(testData)),
+		FileID: testData,
+	}
+
+	mockRepo.On("ReserveBytes", ctx, testData, int64(1<<20)).Return(nil).Once()
+	mockStore.On(
+		"Write",
+		withSpan,
+		testData,
+		mock.Anything,
+		mock.AnythingOfType("store.WriteOption"),
+		mock.AnythingOfType("store.WriteOption"),
+	).Return(readAll)
+	mockRepo.On("UpdateFileContentByID", ctx, int64(len(testData)), "application/octet-stream", testData).Return(nil)
+
+	awsService := service.NewAWSProcessingService(nil, mockRepo, mockStore, mockStore, nil)
+
+	assert.Nil(t, awsService.StoreFile(ctx, awsModel))
+
+	mockStore.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "ReleaseBytes", mock.Anything, mock.Anything)
+}
+
+func TestAWSProcessingService_StoreFile_BytesQuotaExceeded(t *testing.T) {
+	mockStore := new(mocks.MockStore)
+	mockRepo := new(mocks.FileRepository)
+	testData := "metadata"
+	ctx := tenant.NewContext(context.Background(), &tenant.Tenant{ID: "hr", Bucket: "hr-bucket", MaxBytes: 10})
+	awsModel := &model.AWSModel{
+		File:   bytes.NewBuffer([]byte(testData)),
+		FileID: testData,
+	}
+	quotaErr := apperrors.QuotaExceeded(apperrors.CodeQuotaExceeded, "Storage quota of the tenant exceeded")
+
+	// the chunks do not fit, one byte is left before the upload and none after it
+	mockRepo.On("ReserveBytes", ctx, testData, int64(1<<20)).Return(quotaErr)
+	mockRepo.On("ReserveBytes", ctx, testData, int64(1)).Return(nil)
+	mockRepo.On("ReserveBytes", ctx, testData, int64(len(testData)-1)).Return(quotaErr)
+	mockRepo.On("ReleaseBytes", ctx, testData).Return(nil)
+	mockStore.On(
+		"Write",
+		withSpan,
+		testData,
+		mock.Anything,
+		mock.AnythingOfType("store.WriteOption"),
+		mock.AnythingOfType("store.WriteOption"),
+	).Return(readAll)
+
+	awsService := service.NewAWSProcessingService(nil, mockRepo, mockStore, mockStore, nil)
+
+	err := awsService.StoreFile(ctx, awsModel)
+
+	assert.True(t, apperrors.Is(err, apperrors.KindQuotaExceeded))
+
+	mockStore.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAWSProcessingService_StoreFile_QuotaFull(t *testing.T) {
+	mockStore := new(mocks.MockStore)
+	mockRepo := new(mocks.FileRepository)
+	testData := "metadata"
+	ctx := tenant.NewContext(context.Background(), &tenant.Tenant{ID: "hr", Bucket: "hr-bucket", MaxBytes: 10})
+	quotaErr := apperrors.QuotaExceeded(apperrors.CodeQuotaExceeded, "Storage quota of the tenant exceeded")
+
+	mockRepo.On("ReserveBytes", ctx, testData, mock.AnythingOfType("int64")).Return(quotaErr)
+
+	awsService := service.NewAWSProcessingService(nil, mockRepo, mockStore, mockStore, nil)
+
+	err := awsService.StoreFile(ctx, &model.AWSModel{File: bytes.NewBuffer([]byte(testData)), FileID: testData})
+
+	assert.True(t, apperrors.Is(err, apperrors.KindQuotaExceeded))
+
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "ReleaseBytes", mock.Anything, mock.Anything)
+	mockStore.AssertNotCalled(t, "Write", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAWSProcessingService_StoreFile_WriteFailsReleasesQuota(t *testing.T) {
+	mockStore := new(mocks.MockStore)
+	mockRepo := new(mocks.FileRepository)
+	testData := "metadata"
+	ctx := tenant.NewContext(context.Background(), &tenant.Tenant{ID: "hr", Bucket: "hr-bucket", MaxBytes: 10 << 20})
+	errMsg := "connection reset"
+
+	mockRepo.On("ReserveBytes", ctx, testData, int64(1<<20)).Return(nil)
+	mockRepo.On("ReleaseBytes", ctx, testData).Return(nil)
+	mockStore.On(
+		"Write",
+		withSpan,
+		testData,
+		mock.Anything,
+		mock.AnythingOfType("store.WriteOption"),
+		mock.AnythingOfType("store.WriteOption"),
+	).Return(fmt.Errorf(errMsg))
+
+	awsService := service.NewAWSProcessingService(nil, mockRepo, mockStore, mockStore, nil)
+
+	err := awsService.StoreFile(ctx, &model.AWSModel{File: bytes.NewBuffer([]byte(testData)), FileID: testData})
+
+	assert.Contains(t, err.Error(), errMsg)
+
+	mockStore.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestAWSProcessingService_GetFileMetadata(t *testing.T) {
 	mockRepo := new(mocks.FileRepository)
 	mockCodec := new(mocks.MockCodec)
@@ -197,7 +377,7 @@ func TestAWSProcessingService_GetFileMetadata(t *testing.T) {
 	mockRepo.On("FindFileMetadataByID", context.Background(), mock.Anything).Return(testMetadata, nil)
 	mockCodec.On("Unmarshal", []byte(testData), mock.Anything).Return(nil)
 
-	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, mockStore, nil)
+	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, mockStore, nil, nil)
 
 	res, err := awsService.GetFileMetadata(context.Background(), testData)
 
@@ -219,7 +399,7 @@ func TestAWSProcessingService_GetFileMetadata_StoreExistsReturnError(t *testing.
 
 	mockStore.On("Exists", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("store.ExistsOption")).Return(fmt.Errorf(errMsg))
 
-	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, mockStore, nil)
+	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, mockStore, nil, nil)
 
 	res, err := awsService.GetFileMetadata(context.Background(), testData)
 
@@ -239,7 +419,7 @@ func TestAWSProcessingService_GetFileMetadata_RepositoryReturnFindFileMetadataBy
 	mockStore.On("Exists", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("store.ExistsOption")).Return(nil)
 	mockRepo.On("FindFileMetadataByID", context.Background(), mock.Anything).Return(nil, fmt.Errorf(errMsg))
 
-	awsService := service.NewAWSProcessingService(nil, mockRepo, mockStore, nil)
+	awsService := service.NewAWSProcessingService(nil, mockRepo, mockStore, nil, nil)
 
 	res, err := awsService.GetFileMetadata(context.Background(), testData)
 
@@ -261,7 +441,7 @@ func TestAWSProcessingService_GetFileMetadata_CodecUnmarshalReturnError(t *testi
 	mockRepo.On("FindFileMetadataByID", context.Background(), mock.Anything).Return(make(map[string]string), nil)
 	mockCodec.On("Unmarshal", mock.Anything, mock.Anything).Return(fmt.Errorf(errMsg))
 
-	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, mockStore, nil)
+	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, mockStore, nil, nil)
 
 	res, err := awsService.GetFileMetadata(context.Background(), testData)
 
@@ -273,87 +453,135 @@ func TestAWSProcessingService_GetFileMetadata_CodecUnmarshalReturnError(t *testi
 	mockStore.AssertExpectations(t)
 }
 
+// closeRecorder reports whether the body of the object was closed
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
 func TestAWSProcessingService_DownloadFile(t *testing.T) {
 	mockRepo := new(mocks.FileRepository)
-	mockStore := new(mocks.MockStore)
+	mockOpener := new(mocks.ObjectOpener)
 	testData := "testData"
-	file := []byte{}
+	body := &closeRecorder{Reader: strings.NewReader(testData)}
 
-	mockStore.On("Read", context.Background(), testData, &file, mock.AnythingOfType("store.ReadOption")).Return(nil)
-	mockRepo.On("FindFileNameByID", context.Background(), testData).Return(testData, nil)
+	mockOpener.On("Open", withSpan, tenant.DefaultBucket, testData).Return(body, nil)
+	mockRepo.On("FindFileByID", context.Background(), testData).Return(&model.FileRecord{FileName: testData, ContentType: "application/pdf"}, nil)
 
-	awsService := service.NewAWSProcessingService(nil, mockRepo, mockStore, mockStore)
+	awsService := service.NewAWSProcessingService(nil, mockRepo, nil, nil, mockOpener)
 
-	fileBytes, filename, err := awsService.DownloadFile(context.Background(), testData)
+	rc, info, err := awsService.DownloadFile(context.Background(), testData)
 
 	assert.Nil(t, err)
-	assert.NotNil(t, fileBytes)
-	assert.Equal(t, testData, filename)
+	data, err := ioutil.ReadAll(rc)
+	assert.Nil(t, err)
+	assert.Nil(t, rc.Close())
+	assert.True(t, body.closed)
+	assert.Equal(t, testData, string(data))
+	assert.Equal(t, testData, info.FileName)
+	assert.Equal(t, "application/pdf", info.ContentType)
 	mockRepo.AssertExpectations(t)
-	mockStore.AssertExpectations(t)
+	mockOpener.AssertExpectations(t)
 }
 
-func TestAWSProcessingService_DownloadFile_StoreReadReturnError(t *testing.T) {
+func TestAWSProcessingService_DownloadFile_Empty(t *testing.T) {
 	mockRepo := new(mocks.FileRepository)
-	mockStore := new(mocks.MockStore)
+	mockOpener := new(mocks.ObjectOpener)
 	testData := "testData"
-	errMsg := "my custom error message"
-	file := []byte{}
 
-	mockStore.On("Read", context.Background(), testData, &file, mock.AnythingOfType("store.ReadOption")).Return(fmt.Errorf(errMsg))
-	mockRepo.On("FindFileNameByID", context.Background(), testData).Return(testData, nil)
+	mockOpener.On("Open", withSpan, tenant.DefaultBucket, testData).Return(ioutil.NopCloser(strings.NewReader("")), nil)
+	mockRepo.On("FindFileByID", context.Background(), testData).Return(&model.FileRecord{FileName: testData}, nil)
 
-	awsService := service.NewAWSProcessingService(nil, mockRepo, mockStore, mockStore)
+	awsService := service.NewAWSProcessingService(nil, mockRepo, nil, nil, mockOpener)
 
-	fileBytes, filename, err := awsService.DownloadFile(context.Background(), testData)
+	body, info, err := awsService.DownloadFile(context.Background(), testData)
 
-	assert.NotNil(t, err)
-	assert.Nil(t, fileBytes)
-	assert.NotEqual(t, testData, filename)
-	mockRepo.AssertExpectations(t)
-	mockStore.AssertExpectations(t)
+	assert.Nil(t, err)
+	data, err := ioutil.ReadAll(body)
+	assert.Nil(t, err)
+	assert.Empty(t, data)
+	assert.Equal(t, "application/octet-stream", info.ContentType)
 }
 
-func TestAWSProcessingService_DownloadFile_RepositoryFindFileNameByIDReturnError(t *testing.T) {
+func TestAWSProcessingService_DownloadFile_OpenReturnError(t *testing.T) {
 	mockRepo := new(mocks.FileRepository)
-	mockStore := new(mocks.MockStore)
+	mockOpener := new(mocks.ObjectOpener)
 	testData := "testData"
 	errMsg := "my custom error message"
-	file := []byte{}
 
-	mockStore.On("Read", context.Background(), testData, &file, mock.AnythingOfType("store.ReadOption")).Return(nil)
-	mockRepo.On("FindFileNameByID", context.Background(), testData).Return("", fmt.Errorf(errMsg))
+	mockOpener.On("Open", withSpan, tenant.DefaultBucket, testData).Return(nil, fmt.Errorf(errMsg))
+	mockRepo.On("FindFileByID", context.Background(), testData).Return(&model.FileRecord{FileName: testData}, nil)
 
-	awsService := service.NewAWSProcessingService(nil, mockRepo, mockStore, mockStore)
+	awsService := service.NewAWSProcessingService(nil, mockRepo, nil, nil, mockOpener)
 
-	fileBytes, filename, err := awsService.DownloadFile(context.Background(), testData)
+	body, info, err := awsService.DownloadFile(context.Background(), testData)
 
 	assert.NotNil(t, err)
-	assert.Nil(t, fileBytes)
-	assert.NotEqual(t, testData, filename)
+	assert.Contains(t, err.Error(), errMsg)
+	assert.Nil(t, body)
+	assert.Nil(t, info)
 	mockRepo.AssertExpectations(t)
-	mockStore.AssertExpectations(t)
+	mockOpener.AssertExpectations(t)
 }
 
-func TestAWSProcessingService_DownloadFile_RepositoryFindFileNameByIDReturnError_StoreReadReturnError(t *testing.T) {
+func TestAWSProcessingService_DownloadFile_NotInCleanStore(t *testing.T) {
 	mockRepo := new(mocks.FileRepository)
-	mockStore := new(mocks.MockStore)
+	mockOpener := new(mocks.ObjectOpener)
+	testData := "testData"
+
+	mockOpener.On("Open", withSpan, tenant.DefaultBucket, testData).Return(nil, store.ErrNotFound)
+	mockRepo.On("FindFileByID", context.Background(), testData).Return(&model.FileRecord{FileName: testData}, nil)
+
+	awsService := service.NewAWSProcessingService(nil, mockRepo, nil, nil, mockOpener)
+
+	_, _, err := awsService.DownloadFile(context.Background(), testData)
+
+	assert.True(t, apperrors.Is(err, apperrors.KindNotReady))
+}
+
+func TestAWSProcessingService_DownloadFile_ReadFailsWhileStreaming(t *testing.T) {
+	mockRepo := new(mocks.FileRepository)
+	mockOpener := new(mocks.ObjectOpener)
+	testData := "testData"
+	errMsg := "connection reset"
+	body := io.MultiReader(strings.NewReader(testData), iotest.ErrReader(fmt.Errorf(errMsg)))
+
+	mockOpener.On("Open", withSpan, tenant.DefaultBucket, testData).Return(ioutil.NopCloser(body), nil)
+	mockRepo.On("FindFileByID", context.Background(), testData).Return(&model.FileRecord{FileName: testData}, nil)
+
+	awsService := service.NewAWSProcessingService(nil, mockRepo, nil, nil, mockOpener)
+
+	rc, _, err := awsService.DownloadFile(context.Background(), testData)
+
+	assert.Nil(t, err)
+	data, err := ioutil.ReadAll(rc)
+	assert.Equal(t, testData, string(data))
+	assert.EqualError(t, err, errMsg)
+	assert.Nil(t, rc.Close())
+}
+
+func TestAWSProcessingService_DownloadFile_RepositoryFindFileByIDReturnError(t *testing.T) {
+	mockRepo := new(mocks.FileRepository)
+	mockOpener := new(mocks.ObjectOpener)
 	testData := "testData"
 	errMsg := "my custom error message"
-	file := []byte{}
 
-	mockStore.On("Read", context.Background(), testData, &file, mock.AnythingOfType("store.ReadOption")).Return(fmt.Errorf(errMsg))
-	mockRepo.On("FindFileNameByID", context.Background(), testData).Return("", fmt.Errorf(errMsg))
+	mockRepo.On("FindFileByID", context.Background(), testData).Return(nil, fmt.Errorf(errMsg))
 
-	awsService := service.NewAWSProcessingService(nil, mockRepo, mockStore, mockStore)
+	awsService := service.NewAWSProcessingService(nil, mockRepo, nil, nil, mockOpener)
 
-	fileBytes, filename, err := awsService.DownloadFile(context.Background(), testData)
+	body, info, err := awsService.DownloadFile(context.Background(), testData)
 
 	assert.NotNil(t, err)
-	assert.Nil(t, fileBytes)
-	assert.NotEqual(t, testData, filename)
+	assert.Nil(t, body)
+	assert.Nil(t, info)
 	mockRepo.AssertExpectations(t)
-	mockStore.AssertExpectations(t)
+	mockOpener.AssertNotCalled(t, "Open", mock.Anything, mock.Anything, mock.Anything)
 }
 
 func TestAWSProcessingService_UpdateFileMetadata(t *testing.T) {
@@ -367,7 +595,7 @@ func TestAWSProcessingService_UpdateFileMetadata(t *testing.T) {
 	mockCodec.On("Marshal", testMetadata).Return([]byte(testData), nil)
 	mockRepo.On("UpdateFileMetadataByID", context.Background(), testData, testData).Return(nil)
 
-	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, mockStore, nil)
+	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, mockStore, nil, nil)
 
 	err := awsService.UpdateFileMetadata(context.Background(), testMetadata, testData)
 
@@ -387,7 +615,7 @@ func TestAWSProcessingService_UpdateFileMetadata_StoreExistsReturnError(t *testi
 
 	mockStore.On("Exists", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("store.ExistsOption")).Return(fmt.Errorf(errMsg))
 
-	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, mockStore, nil)
+	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, mockStore, nil, nil)
 
 	err := awsService.UpdateFileMetadata(context.Background(), testMetadata, testData)
 
@@ -397,6 +625,31 @@ func TestAWSProcessingService_UpdateFileMetadata_StoreExistsReturnError(t *testi
 	mockRepo.AssertExpectations(t)
 }
 
+func TestAWSProcessingService_UpdateFileMetadata_PrefixedTenant(t *testing.T) {
+	mockRepo := new(mocks.FileRepository)
+	mockCodec := new(mocks.MockCodec)
+	mockStore := new(mocks.MockStore)
+	testMetadata := make(map[string]interface{})
+	registry := tenant.NewRegistry("hr", []*tenant.Tenant{{ID: "hr"}})
+	hr, err := registry.Lookup("hr")
+	assert.Nil(t, err)
+	ctx := tenant.NewContext(context.Background(), hr)
+
+	// the s3 store writes hr/file-1 as hr-file-1, the check must look for the same object
+	mockStore.On("Exists", withSpan, "hr-file-1", mock.AnythingOfType("store.ExistsOption")).Return(nil)
+	mockCodec.On("Marshal", testMetadata).Return([]byte("{}"), nil)
+	mockRepo.On("UpdateFileMetadataByID", ctx, "{}", "file-1").Return(nil)
+
+	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, mockStore, nil, nil)
+
+	err = awsService.UpdateFileMetadata(ctx, testMetadata, "file-1")
+
+	assert.Nil(t, err)
+	mockStore.AssertExpectations(t)
+	mockCodec.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestAWSProcessingService_UpdateFileMetadata_MarshalReturnError(t *testing.T) {
 	mockRepo := new(mocks.FileRepository)
 	mockCodec := new(mocks.MockCodec)
@@ -408,7 +661,7 @@ func TestAWSProcessingService_UpdateFileMetadata_MarshalReturnError(t *testing.T
 	mockStore.On("Exists", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("store.ExistsOption")).Return(nil)
 	mockCodec.On("Marshal", testMetadata).Return(nil, fmt.Errorf(errMsg))
 
-	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, mockStore, nil)
+	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, mockStore, nil, nil)
 
 	err := awsService.UpdateFileMetadata(context.Background(), testMetadata, testData)
 
@@ -431,7 +684,7 @@ func TestAWSProcessingService_UpdateFileMetadata_RepositoryUpdateFileMetadataRet
 	mockCodec.On("Marshal", testMetadata).Return([]byte(testData), nil)
 	mockRepo.On("UpdateFileMetadataByID", context.Background(), testData, testData).Return(fmt.Errorf(errMsg))
 
-	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, mockStore, nil)
+	awsService := service.NewAWSProcessingService(mockCodec, mockRepo, mockStore, nil, nil)
 
 	err := awsService.UpdateFileMetadata(context.Background(), testMetadata, testData)
 
@@ -448,7 +701,7 @@ func TestAWSProcessingService_DeleteMetadataByID(t *testing.T) {
 
 	mockRepo.On("DeleteMetadataByID", mock.Anything, mock.AnythingOfType("string")).Return(nil)
 
-	awsService := service.NewAWSProcessingService(nil, mockRepo, nil, nil)
+	awsService := service.NewAWSProcessingService(nil, mockRepo, nil, nil, nil)
 
 	err := awsService.DeleteMetadataByID(context.Background(), testData)
 
@@ -462,7 +715,7 @@ func TestAWSProcessingService_DeleteMetadataByID_RepositoryDeleteByIDReturnError
 
 	mockRepo.On("DeleteMetadataByID", mock.Anything, mock.AnythingOfType("string")).Return(fmt.Errorf(testData))
 
-	awsService := service.NewAWSProcessingService(nil, mockRepo, nil, nil)
+	awsService := service.NewAWSProcessingService(nil, mockRepo, nil, nil, nil)
 
 	err := awsService.DeleteMetadataByID(context.Background(), testData)
 
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vielendanke/file-service/internal/app/fileservice/cache"
+	metrics "github.com/vielendanke/file-service/internal/app/fileservice/commons/metrics"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+var metadataCache = metrics.GetOrMakeCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.NS,
+		Name:      "metadata_cache_requests_total",
+		Help:      "Total number of the metadata reads by cache result, hit or miss.",
+	},
+	[]string{"result"},
+)
+
+// CachingService serves the metadata reads from the in process cache, the
+// concurrent misses of a file are loaded once. The updates and deletes through
+// the service drop the entry of the file, the changes made by the other
+// replicas are dropped by the database notifications.
+type CachingService struct {
+	FileProcessingService
+	cache       *cache.LRU
+	group       cache.Group
+	loadTimeout time.Duration
+}
+
+// NewCachingService returns the cache of the metadata read from next, a miss is
+// loaded for up to loadTimeout
+func NewCachingService(next FileProcessingService, lru *cache.LRU, loadTimeout time.Duration) FileProcessingService {
+	return &CachingService{
+		FileProcessingService: next,
+		cache:                 lru,
+		loadTimeout:           loadTimeout,
+	}
+}
+
+// GetFileMetadata returns a copy of the cached metadata, the callers may change it
+func (cs *CachingService) GetFileMetadata(ctx context.Context, id string) (map[string]interface{}, error) {
+	key := cache.Key{TenantID: tenant.FromContext(ctx).ID, FileID: id}
+	metadata, version, ok := cs.cache.Get(key)
+	if ok {
+		metadataCache.WithLabelValues("hit").Inc()
+		return copyMetadata(metadata), nil
+	}
+	metadataCache.WithLabelValues("miss").Inc()
+	metadata, _, err := cs.group.Do(ctx, key, version, func() (map[string]interface{}, error) {
+		// the load is shared by the callers, the first one going away must
+		// not cancel it for the others
+		loadCtx, cancel := context.WithTimeout(tenant.NewContext(context.Background(), tenant.FromContext(ctx)), cs.loadTimeout)
+		defer cancel()
+		metadata, err := cs.FileProcessingService.GetFileMetadata(loadCtx, id)
+		if err != nil {
+			return nil, err
+		}
+		cs.cache.Add(key, metadata, version)
+		return metadata, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return copyMetadata(metadata), nil
+}
+
+// UpdateFileMetadata ...
+func (cs *CachingService) UpdateFileMetadata(ctx context.Context, metadata map[string]interface{}, id string) error {
+	defer cs.invalidate(ctx, id)
+	return cs.FileProcessingService.UpdateFileMetadata(ctx, metadata, id)
+}
+
+// DeleteMetadataByID ...
+func (cs *CachingService) DeleteMetadataByID(ctx context.Context, id string) error {
+	defer cs.invalidate(ctx, id)
+	return cs.FileProcessingService.DeleteMetadataByID(ctx, id)
+}
+
+// invalidate drops the file even when the change failed, it may be partly done
+func (cs *CachingService) invalidate(ctx context.Context, id string) {
+	cs.cache.Invalidate(cache.Key{TenantID: tenant.FromContext(ctx).ID, FileID: id})
+}
+
+func copyMetadata(metadata map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,116 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/cache"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+// loadContext matches the contexts of the shared loads, they have their own deadline
+var loadContext = mock.MatchedBy(func(ctx context.Context) bool {
+	_, ok := ctx.Deadline()
+	return ok
+})
+
+func TestCachingService_GetFileMetadata(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	mockService.On("GetFileMetadata", loadContext, "f1").Return(map[string]interface{}{"class": "invoice"}, nil).Once()
+	srv := service.NewCachingService(mockService, cache.NewLRU(10, time.Minute), time.Second)
+
+	metadata, err := srv.GetFileMetadata(context.Background(), "f1")
+	assert.Nil(t, err)
+	metadata["class"] = "changed"
+	metadata, err = srv.GetFileMetadata(context.Background(), "f1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "invoice", metadata["class"])
+	mockService.AssertExpectations(t)
+}
+
+func TestCachingService_GetFileMetadata_ErrorNotCached(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	mockService.On("GetFileMetadata", loadContext, "f1").Return(nil, apperrors.NotFound(apperrors.CodeFileNotFound, "File not found")).Twice()
+	srv := service.NewCachingService(mockService, cache.NewLRU(10, time.Minute), time.Second)
+
+	_, err := srv.GetFileMetadata(context.Background(), "f1")
+	assert.NotNil(t, err)
+	_, err = srv.GetFileMetadata(context.Background(), "f1")
+
+	assert.True(t, apperrors.Is(err, apperrors.KindNotFound))
+	mockService.AssertExpectations(t)
+}
+
+func TestCachingService_GetFileMetadata_CanceledCallerDoesNotCancelLoad(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	release := make(chan struct{})
+	ctx, cancel := context.WithCancel(tenant.NewContext(context.Background(), &tenant.Tenant{ID: "hr"}))
+	mockService.On("GetFileMetadata", mock.MatchedBy(func(loadCtx context.Context) bool {
+		return tenant.FromContext(loadCtx).ID == "hr"
+	}), "f1").Run(func(args mock.Arguments) {
+		<-release
+		if err := args.Get(0).(context.Context).Err(); err != nil {
+			t.Errorf("The shared load was canceled, %v", err)
+		}
+	}).Return(map[string]interface{}{"class": "invoice"}, nil).Once()
+	srv := service.NewCachingService(mockService, cache.NewLRU(10, time.Minute), time.Second)
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := srv.GetFileMetadata(ctx, "f1")
+		first <- err
+	}()
+	waiter := make(chan map[string]interface{}, 1)
+	go func() {
+		metadata, _ := srv.GetFileMetadata(tenant.NewContext(context.Background(), &tenant.Tenant{ID: "hr"}), "f1")
+		waiter <- metadata
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	assert.Equal(t, context.Canceled, <-first)
+	close(release)
+	assert.Equal(t, "invoice", (<-waiter)["class"])
+	mockService.AssertExpectations(t)
+}
+
+func TestCachingService_UpdateFileMetadata_Invalidates(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	update := map[string]interface{}{"number": "2"}
+	mockService.On("GetFileMetadata", loadContext, "f1").Return(map[string]interface{}{"number": "1"}, nil).Once()
+	mockService.On("UpdateFileMetadata", context.Background(), update, "f1").Return(nil)
+	mockService.On("GetFileMetadata", loadContext, "f1").Return(map[string]interface{}{"number": "2"}, nil).Once()
+	srv := service.NewCachingService(mockService, cache.NewLRU(10, time.Minute), time.Second)
+
+	_, err := srv.GetFileMetadata(context.Background(), "f1")
+	assert.Nil(t, err)
+	assert.Nil(t, srv.UpdateFileMetadata(context.Background(), update, "f1"))
+	metadata, err := srv.GetFileMetadata(context.Background(), "f1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "2", metadata["number"])
+	mockService.AssertExpectations(t)
+}
+
+func TestCachingService_DeleteMetadataByID_Invalidates(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	mockService.On("GetFileMetadata", loadContext, "f1").Return(map[string]interface{}{"number": "1"}, nil).Once()
+	mockService.On("DeleteMetadataByID", context.Background(), "f1").Return(nil)
+	mockService.On("GetFileMetadata", loadContext, "f1").Return(nil, apperrors.NotFound(apperrors.CodeFileNotFound, "File not found")).Once()
+	srv := service.NewCachingService(mockService, cache.NewLRU(10, time.Minute), time.Second)
+
+	_, err := srv.GetFileMetadata(context.Background(), "f1")
+	assert.Nil(t, err)
+	assert.Nil(t, srv.DeleteMetadataByID(context.Background(), "f1"))
+	_, err = srv.GetFileMetadata(context.Background(), "f1")
+
+	assert.True(t, apperrors.Is(err, apperrors.KindNotFound))
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+)
+
+// Limits of the collections
+const (
+	MaxCollectionNameLength = 256
+	MaxCollectionFiles      = 1000
+)
+
+// CollectionService manages the collections of the tenant of the context, every
+// call is authorized for policy.ActionManageCollections. The files are added
+// only if the principal may read them and the files it may not read are left
+// out of the collections it gets.
+type CollectionService struct {
+	fileRepository       repository.FileRepository
+	collectionRepository repository.CollectionRepository
+	authorizer           policy.Authorizer
+}
+
+// NewCollectionService ...
+func NewCollectionService(fileRepository repository.FileRepository, collectionRepository repository.CollectionRepository, authorizer policy.Authorizer) *CollectionService {
+	return &CollectionService{
+		fileRepository:       fileRepository,
+		collectionRepository: collectionRepository,
+		authorizer:           authorizer,
+	}
+}
+
+// Create saves a new collection with the files of the request
+func (cs *CollectionService) Create(ctx context.Context, req *model.CollectionRequest) (*model.Collection, error) {
+	if err := cs.authorize(ctx); err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > MaxCollectionNameLength {
+		return nil, apperrors.Invalid(apperrors.CodeInvalidRequest, "Collection name must have between 1 and %d bytes", MaxCollectionNameLength)
+	}
+	if err := cs.checkFiles(ctx, req.FileIDs); err != nil {
+		return nil, err
+	}
+	c := &model.Collection{
+		ID:          strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Name:        name,
+		Description: req.Description,
+	}
+	if err := cs.collectionRepository.SaveCollection(ctx, c); err != nil {
+		return nil, err
+	}
+	if len(req.FileIDs) > 0 {
+		if err := cs.collectionRepository.SaveCollectionFiles(ctx, c.ID, req.FileIDs); err != nil {
+			return nil, err
+		}
+	}
+	return cs.Get(ctx, c.ID)
+}
+
+// List ...
+func (cs *CollectionService) List(ctx context.Context) ([]*model.Collection, error) {
+	if err := cs.authorize(ctx); err != nil {
+		return nil, err
+	}
+	return cs.collectionRepository.FindCollections(ctx)
+}
+
+// Get returns the collection with the files the principal may read in order
+func (cs *CollectionService) Get(ctx context.Context, id string) (*model.Collection, error) {
+	if err := cs.authorize(ctx); err != nil {
+		return nil, err
+	}
+	c, err := cs.collectionRepository.FindCollectionByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	readable := make([]*model.CollectionFile, 0, len(c.Files))
+	for _, f := range c.Files {
+		err := cs.authorizer.Authorize(ctx, policy.ActionRead, &policy.Resource{ID: f.FileID, DocClass: f.DocClass, DocType: f.DocType})
+		if apperrors.Is(err, apperrors.KindForbidden) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		readable = append(readable, f)
+	}
+	c.Files = readable
+	return c, nil
+}
+
+// SetFiles replaces the files of the collection in the order of the request, the
+// files the principal may not read are dropped from it too
+func (cs *CollectionService) SetFiles(ctx context.Context, id string, req *model.CollectionRequest) (*model.Collection, error) {
+	if err := cs.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if err := cs.checkFiles(ctx, req.FileIDs); err != nil {
+		return nil, err
+	}
+	if err := cs.collectionRepository.SaveCollectionFiles(ctx, id, req.FileIDs); err != nil {
+		return nil, err
+	}
+	return cs.Get(ctx, id)
+}
+
+// Delete removes the collection, its files are kept
+func (cs *CollectionService) Delete(ctx context.Context, id string) error {
+	if err := cs.authorize(ctx); err != nil {
+		return err
+	}
+	return cs.collectionRepository.DeleteCollection(ctx, id)
+}
+
+func (cs *CollectionService) authorize(ctx context.Context) error {
+	return cs.authorizer.Authorize(ctx, policy.ActionManageCollections, &policy.Resource{})
+}
+
+// checkFiles accepts the distinct files the principal may read
+func (cs *CollectionService) checkFiles(ctx context.Context, ids []string) error {
+	if len(ids) > MaxCollectionFiles {
+		return apperrors.Invalid(apperrors.CodeInvalidRequest, "Collection may hold at most %d files", MaxCollectionFiles)
+	}
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return apperrors.Invalid(apperrors.CodeInvalidRequest, "File %s is listed more than once", id)
+		}
+		seen[id] = true
+		f, err := cs.fileRepository.FindFileByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := cs.authorizer.Authorize(ctx, policy.ActionRead, &policy.Resource{ID: id, DocClass: f.DocClass, DocType: f.DocType}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metrics "github.com/vielendanke/file-service/internal/app/fileservice/commons/metrics"
+	"github.com/vielendanke/file-service/internal/app/fileservice/contenttype"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+)
+
+var rejectedUploads = metrics.GetOrMakeCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.NS,
+		Name:      "file_uploads_rejected_total",
+		Help:      "Total number of uploads rejected by their content type.",
+	},
+	[]string{"doc_class"},
+)
+
+// ContentTypeService detects the type of the uploaded files before passing them
+// to the wrapped service, the files which do not match their declared type or
+// the allowlist of their class are rejected before anything is written
+type ContentTypeService struct {
+	FileProcessingService
+	allowlist contenttype.Allowlist
+}
+
+// NewContentTypeService ...
+func NewContentTypeService(next FileProcessingService, allowlist contenttype.Allowlist) FileProcessingService {
+	return &ContentTypeService{
+		FileProcessingService: next,
+		allowlist:             allowlist,
+	}
+}
+
+// StoreFile sniffs the head of the file and stores it with the detected type
+func (cs *ContentTypeService) StoreFile(ctx context.Context, f model.FileModel) error {
+	awsFile := f.(*model.AWSModel)
+	detected, file, err := contenttype.Sniff(awsFile.File)
+	if err != nil {
+		return fmt.Errorf("Error reading file, %v", err)
+	}
+	if err := contenttype.Check(detected, awsFile.DeclaredType, awsFile.FileName); err != nil {
+		rejectedUploads.WithLabelValues(awsFile.GetDocClass()).Inc()
+		return err
+	}
+	if err := cs.allowlist.Check(awsFile.GetDocClass(), detected); err != nil {
+		rejectedUploads.WithLabelValues(awsFile.GetDocClass()).Inc()
+		return err
+	}
+	awsFile.ContentType = detected
+	awsFile.File = file
+	return cs.FileProcessingService.StoreFile(ctx, awsFile)
+}
@@ -0,0 +1,71 @@
+package service_test
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/contenttype"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+var testAllowlist = contenttype.Allowlist{"invoice": {"application/pdf"}}
+
+func TestContentTypeService_StoreFile(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	content := "%PDF-1.7\n" + strings.Repeat("x", 1024)
+	awsModel := &model.AWSModel{
+		File:         strings.NewReader(content),
+		FileName:     "invoice.pdf",
+		DeclaredType: "application/pdf",
+		DocClass:     "invoice",
+	}
+
+	mockService.On("StoreFile", context.Background(), awsModel).Return(func(ctx context.Context, f model.FileModel) error {
+		data, err := ioutil.ReadAll(f.(*model.AWSModel).File)
+		assert.Nil(t, err)
+		assert.Equal(t, content, string(data))
+		return nil
+	})
+
+	err := service.NewContentTypeService(mockService, testAllowlist).StoreFile(context.Background(), awsModel)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "application/pdf", awsModel.ContentType)
+	mockService.AssertExpectations(t)
+}
+
+func TestContentTypeService_StoreFile_Mismatch(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	awsModel := &model.AWSModel{
+		File:         strings.NewReader("<html><script></script></html>"),
+		FileName:     "invoice.pdf",
+		DeclaredType: "application/pdf",
+		DocClass:     "invoice",
+	}
+
+	err := service.NewContentTypeService(mockService, testAllowlist).StoreFile(context.Background(), awsModel)
+
+	assert.True(t, apperrors.Is(err, apperrors.KindUnsupportedMedia))
+	mockService.AssertNotCalled(t, "StoreFile", mock.Anything, mock.Anything)
+}
+
+func TestContentTypeService_StoreFile_NotAllowed(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	awsModel := &model.AWSModel{
+		File:     strings.NewReader("\x89PNG\r\n\x1a\n"),
+		FileName: "invoice.png",
+		DocClass: "invoice",
+	}
+
+	err := service.NewContentTypeService(mockService, testAllowlist).StoreFile(context.Background(), awsModel)
+
+	assert.True(t, apperrors.Is(err, apperrors.KindUnsupportedMedia))
+	mockService.AssertNotCalled(t, "StoreFile", mock.Anything, mock.Anything)
+}
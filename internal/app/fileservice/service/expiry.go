@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/expiry"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+)
+
+// Periods of the expiring files queries in days
+const (
+	DefaultExpiringDays = 30
+	MaxExpiringDays     = 3650
+)
+
+// ExpiryService records the validity end dates of the files of the classes with
+// an end date field whenever their metadata is saved, an invalid date rejects
+// the metadata before anything is written
+type ExpiryService struct {
+	FileProcessingService
+	fileRepository   repository.FileRepository
+	expiryRepository repository.ExpiryRepository
+	fields           expiry.Fields
+}
+
+// NewExpiryService ...
+func NewExpiryService(next FileProcessingService, fileRepository repository.FileRepository, expiryRepository repository.ExpiryRepository, fields expiry.Fields) FileProcessingService {
+	return &ExpiryService{
+		FileProcessingService: next,
+		fileRepository:        fileRepository,
+		expiryRepository:      expiryRepository,
+		fields:                fields,
+	}
+}
+
+// SaveFileData ...
+func (es *ExpiryService) SaveFileData(ctx context.Context, f model.FileModel) error {
+	field, expiresAt, err := es.fields.Read(f.GetDocClass(), f.GetMetadata())
+	if err != nil {
+		return err
+	}
+	if err := es.FileProcessingService.SaveFileData(ctx, f); err != nil {
+		return err
+	}
+	if field == "" {
+		return nil
+	}
+	return es.expiryRepository.SaveFileExpiry(ctx, f.GetFileID(), field, expiresAt)
+}
+
+// UpdateFileMetadata replaces the end date with the one of the new metadata
+func (es *ExpiryService) UpdateFileMetadata(ctx context.Context, metadata map[string]interface{}, id string) error {
+	f, err := es.fileRepository.FindFileByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	field, expiresAt, err := es.fields.Read(f.DocClass, metadata)
+	if err != nil {
+		return err
+	}
+	if err := es.FileProcessingService.UpdateFileMetadata(ctx, metadata, id); err != nil {
+		return err
+	}
+	if field == "" {
+		return nil
+	}
+	return es.expiryRepository.SaveFileExpiry(ctx, id, field, expiresAt)
+}
+
+// ExpiringService lists the files whose end date comes close, the files the
+// principal may not read are left out
+type ExpiringService struct {
+	expiryRepository repository.ExpiryRepository
+	authorizer       policy.Authorizer
+}
+
+// NewExpiringService ...
+func NewExpiringService(expiryRepository repository.ExpiryRepository, authorizer policy.Authorizer) *ExpiringService {
+	return &ExpiringService{
+		expiryRepository: expiryRepository,
+		authorizer:       authorizer,
+	}
+}
+
+// Expiring returns a page of the files expiring within days ordered by their end
+// date, the expired files come first when withExpired is set. The cursor is the
+// Next of the previous page, the pages may hold less than limit files when some
+// of them are not authorized.
+func (es *ExpiringService) Expiring(ctx context.Context, days int, withExpired bool, cursor string, limit int) (*model.ExpiringList, error) {
+	if days == 0 {
+		days = DefaultExpiringDays
+	}
+	if days < 0 || days > MaxExpiringDays {
+		return nil, apperrors.Invalid(apperrors.CodeInvalidRequest, "Days must be between 1 and %d, got %d", MaxExpiringDays, days)
+	}
+	if limit == 0 {
+		limit = defaultListLimit
+	}
+	if limit < 0 || limit > maxListLimit {
+		return nil, apperrors.Invalid(apperrors.CodeInvalidRequest, "Limit must be between 1 and %d, got %d", maxListLimit, limit)
+	}
+	offset := 0
+	if cursor != "" {
+		var err error
+		if offset, err = strconv.Atoi(cursor); err != nil || offset < 0 {
+			return nil, apperrors.Invalid(apperrors.CodeInvalidRequest, "Invalid cursor %s", cursor)
+		}
+	}
+	now := time.Now()
+	from := now
+	if withExpired {
+		from = time.Time{}
+	}
+	files, err := es.expiryRepository.FindExpiring(ctx, from, now.AddDate(0, 0, days), offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	list := &model.ExpiringList{Files: []*model.ExpiringFile{}}
+	if len(files) == limit {
+		list.Next = strconv.Itoa(offset + limit)
+	}
+	for _, f := range files {
+		err := es.authorizer.Authorize(ctx, policy.ActionRead, &policy.Resource{ID: f.ID, DocClass: f.DocClass, DocType: f.DocType})
+		if apperrors.Is(err, apperrors.KindForbidden) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		f.Expired = !f.ExpiresAt.After(now)
+		list.Files = append(list.Files, f)
+	}
+	return list, nil
+}
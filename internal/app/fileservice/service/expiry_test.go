@@ -0,0 +1,93 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/expiry"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+var testExpiryFields = expiry.Fields{"passport": "valid_until"}
+
+func TestExpiryService_SaveFileData(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	mockExpiry := new(mocks.ExpiryRepository)
+	awsModel := &model.AWSModel{DocClass: "passport", Metadata: map[string]interface{}{"valid_until": "2030-05-01"}}
+	mockService.On("SaveFileData", context.Background(), awsModel).Return(func(ctx context.Context, f model.FileModel) error {
+		f.(*model.AWSModel).FileID = "f1"
+		return nil
+	})
+	expiresAt := time.Date(2030, 5, 2, 0, 0, 0, 0, time.UTC)
+	mockExpiry.On("SaveFileExpiry", context.Background(), "f1", "valid_until", &expiresAt).Return(nil)
+
+	err := service.NewExpiryService(mockService, new(mocks.FileRepository), mockExpiry, testExpiryFields).SaveFileData(context.Background(), awsModel)
+
+	assert.Nil(t, err)
+	mockService.AssertExpectations(t)
+	mockExpiry.AssertExpectations(t)
+}
+
+func TestExpiryService_SaveFileData_InvalidDate(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	awsModel := &model.AWSModel{DocClass: "passport", Metadata: map[string]interface{}{"valid_until": "next year"}}
+
+	err := service.NewExpiryService(mockService, new(mocks.FileRepository), new(mocks.ExpiryRepository), testExpiryFields).SaveFileData(context.Background(), awsModel)
+
+	assert.True(t, apperrors.Is(err, apperrors.KindInvalid))
+	mockService.AssertNotCalled(t, "SaveFileData", mock.Anything, mock.Anything)
+}
+
+func TestExpiryService_UpdateFileMetadata_ClearsDate(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	mockRepo := new(mocks.FileRepository)
+	mockExpiry := new(mocks.ExpiryRepository)
+	metadata := map[string]interface{}{"holder": "bob"}
+	mockRepo.On("FindFileByID", context.Background(), "f1").Return(&model.FileRecord{ID: "f1", DocClass: "passport"}, nil)
+	mockService.On("UpdateFileMetadata", context.Background(), metadata, "f1").Return(nil)
+	mockExpiry.On("SaveFileExpiry", context.Background(), "f1", "valid_until", (*time.Time)(nil)).Return(nil)
+
+	err := service.NewExpiryService(mockService, mockRepo, mockExpiry, testExpiryFields).UpdateFileMetadata(context.Background(), metadata, "f1")
+
+	assert.Nil(t, err)
+	mockExpiry.AssertExpectations(t)
+}
+
+func prepareExpiringService(t *testing.T, repo *mocks.ExpiryRepository) *service.ExpiringService {
+	p, err := policy.Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("Error parsing policy, %v", err)
+	}
+	return service.NewExpiringService(repo, &testAuthorizer{policy: p})
+}
+
+func TestExpiringService_Expiring(t *testing.T) {
+	mockExpiry := new(mocks.ExpiryRepository)
+	ctx := principalContext("sales")
+	mockExpiry.On("FindExpiring", ctx, time.Time{}, mock.AnythingOfType("time.Time"), 2, 2).Return([]*model.ExpiringFile{
+		{ID: "f1", DocClass: "passport", ExpiresAt: time.Now().Add(-time.Hour)},
+		{ID: "f2", DocClass: "HR", ExpiresAt: time.Now().Add(time.Hour)},
+	}, nil)
+
+	list, err := prepareExpiringService(t, mockExpiry).Expiring(ctx, 7, true, "2", 2)
+
+	assert.Nil(t, err)
+	assert.Len(t, list.Files, 1)
+	assert.Equal(t, "f1", list.Files[0].ID)
+	assert.True(t, list.Files[0].Expired)
+	assert.Equal(t, "4", list.Next)
+	mockExpiry.AssertExpectations(t)
+}
+
+func TestExpiringService_Expiring_InvalidDays(t *testing.T) {
+	_, err := prepareExpiringService(t, new(mocks.ExpiryRepository)).Expiring(principalContext("sales"), service.MaxExpiringDays+1, false, "", 0)
+
+	assert.True(t, apperrors.Is(err, apperrors.KindInvalid))
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"io"
 
 	"github.com/vielendanke/file-service/internal/app/fileservice/model"
 )
@@ -11,7 +12,7 @@ type FileProcessingService interface {
 	StoreFile(ctx context.Context, f model.FileModel) error
 	SaveFileData(ctx context.Context, f model.FileModel) error
 	GetFileMetadata(ctx context.Context, id string) (map[string]interface{}, error)
-	DownloadFile(ctx context.Context, id string) ([]byte, string, error)
+	DownloadFile(ctx context.Context, id string) (io.ReadCloser, *model.ObjectInfo, error)
 	UpdateFileMetadata(ctx context.Context, metadata map[string]interface{}, id string) error
 	DeleteMetadataByID(ctx context.Context, id string) error
 }
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/heptiolabs/healthcheck"
+	s3store "github.com/unistack-org/micro-store-s3/v3"
+	"github.com/unistack-org/micro/v3/store"
+)
+
+// healthKey is the object looked up by the store checks, it is not expected to exist
+const healthKey = ".health"
+
+// NewStoreCheck checks that the bucket of the store is reachable within timeout,
+// a missing object is an answer of the bucket and is healthy
+func NewStoreCheck(s store.Store, bucket string, timeout time.Duration) healthcheck.Check {
+	return func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		err := s.Exists(ctx, healthKey, s3store.ExistsBucket(bucket))
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("Error reaching bucket %s, %v", bucket, err)
+		}
+		return nil
+	}
+}
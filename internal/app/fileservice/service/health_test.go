@@ -0,0 +1,26 @@
+package service_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/unistack-org/micro/v3/store"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+func TestNewStoreCheck(t *testing.T) {
+	mockStore := new(mocks.MockStore)
+
+	mockStore.On("Exists", mock.Anything, ".health", mock.AnythingOfType("store.ExistsOption")).Return(store.ErrNotFound).Once()
+	mockStore.On("Exists", mock.Anything, ".health", mock.AnythingOfType("store.ExistsOption")).Return(fmt.Errorf("connection refused")).Once()
+
+	check := service.NewStoreCheck(mockStore, "micro-store-s3", time.Second)
+
+	assert.Nil(t, check())
+	assert.NotNil(t, check())
+	mockStore.AssertExpectations(t)
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/unistack-org/micro/v3/store"
+	metrics "github.com/vielendanke/file-service/internal/app/fileservice/commons/metrics"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/tracer"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+// Names of the stores in the metrics
+const (
+	dirtyStoreName = "dirty_region"
+	cleanStoreName = "clean_region"
+)
+
+var (
+	s3Duration = metrics.GetOrMakeHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.NS,
+			Name:      "s3_operation_duration_seconds",
+			Help:      "Duration of the object storage operations.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"store", "operation"},
+	)
+	s3Errors = metrics.GetOrMakeCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NS,
+			Name:      "s3_operation_errors_total",
+			Help:      "Total number of failed object storage operations.",
+		},
+		[]string{"store", "operation"},
+	)
+	uploads = metrics.GetOrMakeCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NS,
+			Name:      "file_uploads_total",
+			Help:      "Total number of stored uploads.",
+		},
+		[]string{"doc_class"},
+	)
+	storedBytes = metrics.GetOrMakeCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NS,
+			Name:      "file_stored_bytes_total",
+			Help:      "Total number of bytes of the stored uploads.",
+		},
+		[]string{"doc_class"},
+	)
+)
+
+// s3Call is a traced and measured call to the object storage
+type s3Call struct {
+	span      opentracing.Span
+	store     string
+	operation string
+	start     time.Time
+}
+
+// startS3Call starts the call to the store for the file id of the tenant t
+func startS3Call(ctx context.Context, storeName, operation string, t *tenant.Tenant, id string) (*s3Call, context.Context) {
+	span, ctx := tracer.StartSpan(ctx, "s3 "+operation, opentracing.Tags{
+		tracer.TagFileID: id,
+		tracer.TagBucket: t.Bucket,
+		tracer.TagKey:    t.Key(id),
+		tracer.TagTenant: t.ID,
+	})
+	ext.SpanKindRPCClient.Set(span)
+	ext.Component.Set(span, "s3")
+	return &s3Call{span: span, store: storeName, operation: operation, start: time.Now()}, ctx
+}
+
+// finish records the duration and the error of the call, a missing object is
+// an answer of the store and is not counted as an error
+func (c *s3Call) finish(err error) {
+	s3Duration.WithLabelValues(c.store, c.operation).Observe(time.Since(c.start).Seconds())
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		s3Errors.WithLabelValues(c.store, c.operation).Inc()
+		tracer.FinishSpan(c.span, err)
+		return
+	}
+	tracer.FinishSpan(c.span, nil)
+}
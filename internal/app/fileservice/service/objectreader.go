@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/unistack-org/micro/v3/store"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/tracer"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+// ObjectOpener opens the objects of the object storage for streaming reads,
+// the store interface only reads whole values into memory
+type ObjectOpener interface {
+	// Open returns the body of the object key in bucket, a missing object is
+	// reported as store.ErrNotFound
+	Open(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+}
+
+// S3Opener reads the objects from the body of the S3 GetObject responses
+type S3Opener struct {
+	client *minio.Client
+}
+
+// NewS3Opener creates an opener of the objects written by the s3 store at
+// endpoint, it connects and names the keys the same way as the store
+func NewS3Opener(endpoint, region, accessKey, secretKey string) (*S3Opener, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("Error creating s3 client, missing endpoint")
+	}
+	opts := &minio.Options{
+		Secure: strings.HasPrefix(endpoint, "https://"),
+		Region: region,
+	}
+	if accessKey != "" && secretKey != "" {
+		opts.Creds = credentials.NewStaticV2(accessKey, secretKey, "")
+	}
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		endpoint = u.Host
+	}
+	client, err := minio.New(endpoint, opts)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating s3 client, %v", err)
+	}
+	return &S3Opener{client: client}, nil
+}
+
+// Open stats the object before returning it so that a missing object fails
+// here and not on the first read, the reads fetch the body as they go
+func (o *S3Opener) Open(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	obj, err := o.client.GetObject(ctx, bucket, storedKey(key), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		return nil, s3Error(err)
+	}
+	return obj, nil
+}
+
+// s3Error reports the missing objects as the store does
+func s3Error(err error) error {
+	if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+		return store.ErrNotFound
+	}
+	return err
+}
+
+// objectReader counts the bytes read from the object and ends the span of the
+// read when it is closed
+type objectReader struct {
+	io.ReadCloser
+	ctx  context.Context
+	call *s3Call
+	n    int64
+	err  error
+}
+
+func (or *objectReader) Read(p []byte) (int, error) {
+	n, err := or.ReadCloser.Read(p)
+	or.n += int64(n)
+	if err != nil && err != io.EOF && or.err == nil {
+		or.err = err
+	}
+	return n, err
+}
+
+// Close stops the read of the object, the reads stopped by the caller are not
+// failures of the store
+func (or *objectReader) Close() error {
+	err := or.ReadCloser.Close()
+	or.call.span.SetTag(tracer.TagBytes, or.n)
+	if or.ctx.Err() != nil {
+		or.call.finish(nil)
+	} else {
+		or.call.finish(or.err)
+	}
+	return err
+}
+
+// openObject opens the file id of the tenant t with o, the missing objects and
+// the other failures of the store are reported before anything is sent to the
+// client, the failures after that end the reads of the returned reader
+func openObject(ctx context.Context, o ObjectOpener, storeName string, t *tenant.Tenant, id string) (io.ReadCloser, error) {
+	call, spanCtx := startS3Call(ctx, storeName, "Read", t, id)
+	body, err := o.Open(spanCtx, t.Bucket, t.Key(id))
+	if err != nil {
+		call.finish(err)
+		return nil, err
+	}
+	return &objectReader{ReadCloser: body, ctx: ctx, call: call}, nil
+}
@@ -0,0 +1,100 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unistack-org/micro/v3/store"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+// fakeS3 serves one object, the GET sends the first chunk and holds the rest
+// back until release
+func fakeS3(path string, first, rest []byte, release chan struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			rw.Header().Set("Content-Type", "application/xml")
+			rw.WriteHeader(http.StatusNotFound)
+			io.WriteString(rw, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			return
+		}
+		rw.Header().Set("Content-Length", strconv.Itoa(len(first)+len(rest)))
+		rw.Header().Set("Content-Type", "application/octet-stream")
+		rw.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		rw.Header().Set("ETag", `"etag"`)
+		if r.Method == http.MethodHead {
+			return
+		}
+		rw.Write(first)
+		rw.(http.Flusher).Flush()
+		<-release
+		rw.Write(rest)
+	}))
+}
+
+func TestS3Opener_Open_Streams(t *testing.T) {
+	first := bytes.Repeat([]byte("a"), 64<<10)
+	rest := bytes.Repeat([]byte("b"), 8<<20)
+	release := make(chan struct{})
+	srv := fakeS3("/bucket/tenant-a-file-1", first, rest, release)
+	defer srv.Close()
+	defer close(release)
+
+	opener, err := service.NewS3Opener(srv.URL, "us-east-1", "key", "secret")
+	assert.Nil(t, err)
+
+	body, err := opener.Open(context.Background(), "bucket", "tenant-a/file-1")
+	assert.Nil(t, err)
+	defer body.Close()
+
+	// the first chunk is readable while the server still holds back the rest
+	read := make(chan error, 1)
+	go func() {
+		buf := make([]byte, len(first))
+		_, err := io.ReadFull(body, buf)
+		if err == nil && !bytes.Equal(buf, first) {
+			err = io.ErrUnexpectedEOF
+		}
+		read <- err
+	}()
+	select {
+	case err := <-read:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the object is not streamed, the first chunk was not readable before the end of the body")
+	}
+
+	release <- struct{}{}
+	data, err := ioutil.ReadAll(body)
+	assert.Nil(t, err)
+	assert.Equal(t, len(rest), len(data))
+}
+
+func TestS3Opener_Open_NotFound(t *testing.T) {
+	release := make(chan struct{})
+	srv := fakeS3("/bucket/other", nil, nil, release)
+	defer srv.Close()
+	defer close(release)
+
+	opener, err := service.NewS3Opener(srv.URL, "us-east-1", "key", "secret")
+	assert.Nil(t, err)
+
+	body, err := opener.Open(context.Background(), "bucket", "missing")
+
+	assert.Equal(t, store.ErrNotFound, err)
+	assert.Nil(t, body)
+}
+
+func TestNewS3Opener_MissingEndpoint(t *testing.T) {
+	_, err := service.NewS3Opener("", "us-east-1", "key", "secret")
+
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"io"
+
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+// reservationChunk is the number of bytes of the quota reserved at once while
+// an upload is read
+const reservationChunk = 1 << 20
+
+// quotaReader counts the bytes read from r and reserves them in the bytes quota
+// of the tenant before they are passed on, reserve is nil when the quota is not
+// enforced
+type quotaReader struct {
+	r          io.Reader
+	reserve    func(n int64) error
+	n          int64
+	reserved   int64
+	reserveErr error
+}
+
+func (qr *quotaReader) Read(p []byte) (int, error) {
+	if qr.reserveErr != nil {
+		return 0, qr.reserveErr
+	}
+	n, err := qr.r.Read(p)
+	qr.n += int64(n)
+	if qr.reserve != nil && qr.n > qr.reserved {
+		if qr.reserveErr = qr.grow(qr.n - qr.reserved); qr.reserveErr != nil {
+			return n, qr.reserveErr
+		}
+	}
+	return n, err
+}
+
+// grow reserves need more bytes, a whole chunk to spare the round trips to the
+// database or exactly need once the chunk does not fit in the quota
+func (qr *quotaReader) grow(need int64) error {
+	n := need
+	if n < reservationChunk {
+		n = reservationChunk
+	}
+	err := qr.reserve(n)
+	if n > need && apperrors.Is(err, apperrors.KindQuotaExceeded) {
+		n = need
+		err = qr.reserve(n)
+	}
+	if err != nil {
+		return err
+	}
+	qr.reserved += n
+	return nil
+}
+
+// newQuotaReader reserves the bytes of the upload of the file id read from r in
+// the quota of the tenant, a tenant without any bytes left is rejected before
+// the upload starts
+func (aps *AWSProcessingService) newQuotaReader(ctx context.Context, t *tenant.Tenant, id string, r io.Reader) (*quotaReader, error) {
+	qr := &quotaReader{r: r}
+	if t.MaxBytes <= 0 {
+		return qr, nil
+	}
+	qr.reserve = func(n int64) error {
+		return aps.fileRepository.ReserveBytes(ctx, id, n)
+	}
+	if err := qr.grow(1); err != nil {
+		return nil, err
+	}
+	return qr, nil
+}
+
+// releaseQuota ends the reservation of the upload of the file id which did not
+// complete, a reservation which fails to be released expires
+func (aps *AWSProcessingService) releaseQuota(ctx context.Context, qr *quotaReader, id string) {
+	if qr.reserved > 0 {
+		aps.fileRepository.ReleaseBytes(ctx, id)
+	}
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+)
+
+// Depths of the traversal of the relations
+const (
+	DefaultRelationDepth = 1
+	MaxRelationDepth     = 10
+)
+
+var relationTypes = map[string]bool{
+	model.RelationChild:      true,
+	model.RelationReferences: true,
+	model.RelationSupersedes: true,
+}
+
+// RelationService links the files and traverses their links, linking updates
+// both files and the legal hold is authorized for policy.ActionHold
+type RelationService struct {
+	fileRepository     repository.FileRepository
+	relationRepository repository.RelationRepository
+	authorizer         policy.Authorizer
+}
+
+// NewRelationService ...
+func NewRelationService(fileRepository repository.FileRepository, relationRepository repository.RelationRepository, authorizer policy.Authorizer) *RelationService {
+	return &RelationService{
+		fileRepository:     fileRepository,
+		relationRepository: relationRepository,
+		authorizer:         authorizer,
+	}
+}
+
+// Link saves the relation from its parent to its child
+func (rs *RelationService) Link(ctx context.Context, r *model.Relation) (*model.Relation, error) {
+	if !relationTypes[r.Type] {
+		return nil, apperrors.Invalid(apperrors.CodeInvalidRelation, "Relation type %q is not one of child, references, supersedes", r.Type)
+	}
+	if r.ChildID == "" || r.ChildID == r.ParentID {
+		return nil, apperrors.Invalid(apperrors.CodeInvalidRelation, "Relation needs a child other than its parent")
+	}
+	for _, id := range []string{r.ParentID, r.ChildID} {
+		if err := rs.authorize(ctx, policy.ActionUpdate, id); err != nil {
+			return nil, err
+		}
+	}
+	if err := rs.relationRepository.SaveRelation(ctx, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Unlink deletes the relation, it updates the parent only
+func (rs *RelationService) Unlink(ctx context.Context, parentID, childID, relationType string) error {
+	if err := rs.authorize(ctx, policy.ActionUpdate, parentID); err != nil {
+		return err
+	}
+	return rs.relationRepository.DeleteRelation(ctx, parentID, childID, relationType)
+}
+
+// Related returns the files linked to the file in direction up to depth links
+// away, the files the principal may not read are left out
+func (rs *RelationService) Related(ctx context.Context, id, direction string, depth int) ([]*model.RelatedFile, error) {
+	if direction == "" {
+		direction = model.DirectionOutgoing
+	}
+	if direction != model.DirectionOutgoing && direction != model.DirectionIncoming {
+		return nil, apperrors.Invalid(apperrors.CodeInvalidRequest, "Direction %q is not one of outgoing, incoming", direction)
+	}
+	if depth == 0 {
+		depth = DefaultRelationDepth
+	}
+	if depth < 0 || depth > MaxRelationDepth {
+		return nil, apperrors.Invalid(apperrors.CodeInvalidRequest, "Depth must be between 1 and %d", MaxRelationDepth)
+	}
+	if err := rs.authorize(ctx, policy.ActionRead, id); err != nil {
+		return nil, err
+	}
+	related, err := rs.relationRepository.FindRelated(ctx, id, direction, depth)
+	if err != nil {
+		return nil, err
+	}
+	readable := make([]*model.RelatedFile, 0, len(related))
+	for _, f := range related {
+		err := rs.authorizer.Authorize(ctx, policy.ActionRead, &policy.Resource{ID: f.FileID, DocClass: f.DocClass, DocType: f.DocType})
+		if apperrors.Is(err, apperrors.KindForbidden) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		readable = append(readable, f)
+	}
+	return readable, nil
+}
+
+// Hold sets or releases the legal hold of the file and of the files its links
+// cascade the hold to, it returns the ids of all of them
+func (rs *RelationService) Hold(ctx context.Context, id string, held bool) ([]string, error) {
+	if err := rs.authorize(ctx, policy.ActionHold, id); err != nil {
+		return nil, err
+	}
+	return rs.relationRepository.SetLegalHold(ctx, id, held)
+}
+
+func (rs *RelationService) authorize(ctx context.Context, action policy.Action, id string) error {
+	f, err := rs.fileRepository.FindFileByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return rs.authorizer.Authorize(ctx, action, &policy.Resource{
+		ID:       id,
+		DocClass: f.DocClass,
+		DocType:  f.DocType,
+	})
+}
+
+// CascadingService deletes the files with the files their links cascade the
+// delete to. The whole cascade is locked, checked for legal holds and
+// authorized with the metadata of every file in the transaction which deletes
+// it, so nothing is deleted when one file is held or forbidden. The objects of
+// the files are removed afterwards by the consumers of their file.deleted
+// events and the caches by the notifications of the deleted rows.
+type CascadingService struct {
+	FileProcessingService
+	relationRepository repository.RelationRepository
+	authorizer         policy.Authorizer
+}
+
+// NewCascadingService ...
+func NewCascadingService(next FileProcessingService, relationRepository repository.RelationRepository, authorizer policy.Authorizer) FileProcessingService {
+	return &CascadingService{
+		FileProcessingService: next,
+		relationRepository:    relationRepository,
+		authorizer:            authorizer,
+	}
+}
+
+// DeleteMetadataByID ...
+func (cs *CascadingService) DeleteMetadataByID(ctx context.Context, id string) error {
+	return cs.relationRepository.DeleteCascade(ctx, id, func(cascade []*model.RelatedFile) error {
+		for _, f := range cascade {
+			if f.Held {
+				return apperrors.Conflict(apperrors.CodeFileHeld, "File %s is under legal hold", f.FileID)
+			}
+			metadata := make(map[string]interface{})
+			if len(f.Metadata) > 0 {
+				if err := json.Unmarshal(f.Metadata, &metadata); err != nil {
+					return fmt.Errorf("Error unmarshalling JSONB, %v", err)
+				}
+			}
+			if err := cs.authorizer.Authorize(ctx, policy.ActionDelete, &policy.Resource{
+				ID:       f.FileID,
+				DocClass: f.DocClass,
+				DocType:  f.DocType,
+				Metadata: metadata,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
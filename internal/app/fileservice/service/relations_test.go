@@ -0,0 +1,169 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+func prepareTestAuthorizer(t *testing.T) policy.Authorizer {
+	p, err := policy.Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("Error parsing policy, %v", err)
+	}
+	return &testAuthorizer{policy: p}
+}
+
+func TestRelationService_Link(t *testing.T) {
+	fileRepo := new(mocks.FileRepository)
+	relationRepo := new(mocks.RelationRepository)
+	ctx := principalContext("sales")
+	relation := &model.Relation{ParentID: "contract", ChildID: "annex", Type: model.RelationChild, CascadeDelete: true}
+	fileRepo.On("FindFileByID", ctx, "contract").Return(&model.FileRecord{ID: "contract", DocClass: "contract"}, nil)
+	fileRepo.On("FindFileByID", ctx, "annex").Return(&model.FileRecord{ID: "annex", DocClass: "contract"}, nil)
+	relationRepo.On("SaveRelation", ctx, relation).Return(nil)
+
+	saved, err := service.NewRelationService(fileRepo, relationRepo, prepareTestAuthorizer(t)).Link(ctx, relation)
+
+	assert.Nil(t, err)
+	assert.Equal(t, relation, saved)
+	relationRepo.AssertExpectations(t)
+}
+
+func TestRelationService_Link_InvalidType(t *testing.T) {
+	relationRepo := new(mocks.RelationRepository)
+
+	_, err := service.NewRelationService(new(mocks.FileRepository), relationRepo, prepareTestAuthorizer(t)).
+		Link(principalContext("sales"), &model.Relation{ParentID: "contract", ChildID: "annex", Type: "sibling"})
+
+	assert.True(t, apperrors.Is(err, apperrors.KindInvalid))
+	relationRepo.AssertNotCalled(t, "SaveRelation", mock.Anything, mock.Anything)
+}
+
+func TestRelationService_Related_FiltersUnreadable(t *testing.T) {
+	fileRepo := new(mocks.FileRepository)
+	relationRepo := new(mocks.RelationRepository)
+	ctx := principalContext("sales")
+	fileRepo.On("FindFileByID", ctx, "case").Return(&model.FileRecord{ID: "case", DocClass: "claim"}, nil)
+	relationRepo.On("FindRelated", ctx, "case", model.DirectionOutgoing, service.DefaultRelationDepth).Return([]*model.RelatedFile{
+		{FileID: "invoice", Via: "case", Type: model.RelationReferences, Depth: 1, DocClass: "invoice"},
+		{FileID: "contract", Via: "case", Type: model.RelationReferences, Depth: 1, DocClass: "HR"},
+	}, nil)
+
+	related, err := service.NewRelationService(fileRepo, relationRepo, prepareTestAuthorizer(t)).Related(ctx, "case", "", 0)
+
+	assert.Nil(t, err)
+	assert.Len(t, related, 1)
+	assert.Equal(t, "invoice", related[0].FileID)
+}
+
+// cascadeRepository runs the check of the delete on cascade as the transaction
+// of the repository does, deleted reports whether the check let it commit
+func cascadeRepository(ctx context.Context, id string, cascade []*model.RelatedFile) (*mocks.RelationRepository, *bool) {
+	relationRepo := new(mocks.RelationRepository)
+	deleted := false
+	relationRepo.On("DeleteCascade", ctx, id, mock.Anything).Return(func(ctx context.Context, id string, check func([]*model.RelatedFile) error) error {
+		if err := check(cascade); err != nil {
+			return err
+		}
+		deleted = true
+		return nil
+	})
+	return relationRepo, &deleted
+}
+
+func TestCascadingService_DeleteMetadataByID(t *testing.T) {
+	mockService := new(mocks.FileProcessingService)
+	ctx := principalContext("sales")
+	relationRepo, deleted := cascadeRepository(ctx, "contract", []*model.RelatedFile{
+		{FileID: "contract", DocClass: "contract"},
+		{FileID: "annex", Via: "contract", Type: model.RelationChild, Depth: 1, DocClass: "contract"},
+		{FileID: "schedule", Via: "annex", Type: model.RelationChild, Depth: 2, DocClass: "contract", Metadata: json.RawMessage(`{"status":"draft"}`)},
+	})
+
+	err := service.NewCascadingService(mockService, relationRepo, prepareTestAuthorizer(t)).DeleteMetadataByID(ctx, "contract")
+
+	assert.Nil(t, err)
+	assert.True(t, *deleted)
+	mockService.AssertNotCalled(t, "DeleteMetadataByID", mock.Anything, mock.Anything)
+}
+
+func TestCascadingService_DeleteMetadataByID_Held(t *testing.T) {
+	ctx := principalContext("sales")
+	relationRepo, deleted := cascadeRepository(ctx, "contract", []*model.RelatedFile{
+		{FileID: "contract", DocClass: "contract"},
+		{FileID: "annex", Via: "contract", Type: model.RelationChild, Depth: 1, DocClass: "contract", Held: true},
+	})
+
+	err := service.NewCascadingService(new(mocks.FileProcessingService), relationRepo, prepareTestAuthorizer(t)).DeleteMetadataByID(ctx, "contract")
+
+	assert.True(t, apperrors.Is(err, apperrors.KindConflict))
+	assert.False(t, *deleted)
+}
+
+func TestCascadingService_DeleteMetadataByID_ForbiddenChild(t *testing.T) {
+	ctx := principalContext("sales")
+	relationRepo, deleted := cascadeRepository(ctx, "case", []*model.RelatedFile{
+		{FileID: "case", DocClass: "claim"},
+		{FileID: "review", Via: "case", Type: model.RelationChild, Depth: 1, DocClass: "HR"},
+	})
+
+	err := service.NewCascadingService(new(mocks.FileProcessingService), relationRepo, prepareTestAuthorizer(t)).DeleteMetadataByID(ctx, "case")
+
+	assert.True(t, apperrors.Is(err, apperrors.KindForbidden))
+	assert.False(t, *deleted)
+}
+
+func TestCascadingService_DeleteMetadataByID_ForbiddenMetadata(t *testing.T) {
+	p, err := policy.Parse([]byte(`{
+		"default_effect": "allow",
+		"rules": [{"name": "signed", "effect": "deny", "actions": ["delete"], "metadata": {"status": "signed"}, "not_roles": ["legal"]}]
+	}`))
+	assert.Nil(t, err)
+	ctx := principalContext("sales")
+	relationRepo, deleted := cascadeRepository(ctx, "contract", []*model.RelatedFile{
+		{FileID: "contract", DocClass: "contract", Metadata: json.RawMessage(`{"status":"draft"}`)},
+		{FileID: "annex", Via: "contract", Type: model.RelationChild, Depth: 1, DocClass: "contract", Metadata: json.RawMessage(`{"status":"signed"}`)},
+	})
+
+	err = service.NewCascadingService(new(mocks.FileProcessingService), relationRepo, &testAuthorizer{policy: p}).DeleteMetadataByID(ctx, "contract")
+
+	assert.True(t, apperrors.Is(err, apperrors.KindForbidden))
+	assert.False(t, *deleted)
+}
+
+func TestCollectionService_Get_FiltersUnreadable(t *testing.T) {
+	collectionRepo := new(mocks.CollectionRepository)
+	ctx := principalContext("sales")
+	collectionRepo.On("FindCollectionByID", ctx, "c1").Return(&model.Collection{ID: "c1", Name: "Case 42", Files: []*model.CollectionFile{
+		{FileID: "f1", Position: 0, DocClass: "HR"},
+		{FileID: "f2", Position: 1, DocClass: "claim"},
+	}}, nil)
+
+	c, err := service.NewCollectionService(new(mocks.FileRepository), collectionRepo, prepareTestAuthorizer(t)).Get(ctx, "c1")
+
+	assert.Nil(t, err)
+	assert.Len(t, c.Files, 1)
+	assert.Equal(t, "f2", c.Files[0].FileID)
+}
+
+func TestCollectionService_SetFiles_Duplicate(t *testing.T) {
+	fileRepo := new(mocks.FileRepository)
+	collectionRepo := new(mocks.CollectionRepository)
+	ctx := principalContext("sales")
+	fileRepo.On("FindFileByID", ctx, "f1").Return(&model.FileRecord{ID: "f1", DocClass: "claim"}, nil)
+
+	_, err := service.NewCollectionService(fileRepo, collectionRepo, prepareTestAuthorizer(t)).
+		SetFiles(ctx, "c1", &model.CollectionRequest{FileIDs: []string{"f1", "f1"}})
+
+	assert.True(t, apperrors.Is(err, apperrors.KindInvalid))
+	collectionRepo.AssertNotCalled(t, "SaveCollectionFiles", mock.Anything, mock.Anything, mock.Anything)
+}
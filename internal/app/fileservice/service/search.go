@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+)
+
+// Limits of the pages of the list of files
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+	maxQueryLength   = 256
+)
+
+// SearchService lists the files of the tenant and searches them by their
+// extracted text, the files the principal may not search are left out
+type SearchService struct {
+	fileRepository   repository.FileRepository
+	searchRepository repository.SearchRepository
+	authorizer       policy.Authorizer
+}
+
+// NewSearchService ...
+func NewSearchService(fileRepository repository.FileRepository, searchRepository repository.SearchRepository, authorizer policy.Authorizer) *SearchService {
+	return &SearchService{
+		fileRepository:   fileRepository,
+		searchRepository: searchRepository,
+		authorizer:       authorizer,
+	}
+}
+
+// List returns a page of the files ordered by id, or by rank if query is not
+// empty. The cursor is the Next of the previous page, the pages may hold less
+// than limit files when some of them are not authorized.
+func (ss *SearchService) List(ctx context.Context, query, cursor string, limit int) (*model.FileList, error) {
+	if limit == 0 {
+		limit = defaultListLimit
+	}
+	if limit < 0 || limit > maxListLimit {
+		return nil, apperrors.Invalid(apperrors.CodeInvalidRequest, "Limit must be between 1 and %d, got %d", maxListLimit, limit)
+	}
+	query = strings.TrimSpace(query)
+	if len(query) > maxQueryLength {
+		return nil, apperrors.Invalid(apperrors.CodeInvalidRequest, "Query must not be longer than %d bytes", maxQueryLength)
+	}
+	var items []*model.FileListItem
+	next := ""
+	if query == "" {
+		records, err := ss.fileRepository.ListFiles(ctx, cursor, limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range records {
+			items = append(items, &model.FileListItem{FileRecord: *r})
+		}
+		if len(records) == limit {
+			next = records[len(records)-1].ID
+		}
+	} else {
+		offset := 0
+		if cursor != "" {
+			var err error
+			if offset, err = strconv.Atoi(cursor); err != nil || offset < 0 {
+				return nil, apperrors.Invalid(apperrors.CodeInvalidRequest, "Invalid cursor %s", cursor)
+			}
+		}
+		var err error
+		if items, err = ss.searchRepository.SearchFiles(ctx, query, offset, limit); err != nil {
+			return nil, err
+		}
+		if len(items) == limit {
+			next = strconv.Itoa(offset + limit)
+		}
+	}
+	list := &model.FileList{Files: []*model.FileListItem{}, Next: next}
+	for _, item := range items {
+		allowed, err := ss.allowed(ctx, &item.FileRecord)
+		if err != nil {
+			return nil, err
+		}
+		if allowed {
+			list.Files = append(list.Files, item)
+		}
+	}
+	return list, nil
+}
+
+func (ss *SearchService) allowed(ctx context.Context, f *model.FileRecord) (bool, error) {
+	metadata := make(map[string]interface{})
+	if len(f.Metadata) > 0 {
+		if err := json.Unmarshal(f.Metadata, &metadata); err != nil {
+			return false, fmt.Errorf("Error unmarshalling JSONB, %v", err)
+		}
+	}
+	err := ss.authorizer.Authorize(ctx, policy.ActionSearch, &policy.Resource{
+		ID:       f.ID,
+		DocClass: f.DocClass,
+		DocType:  f.DocType,
+		Metadata: metadata,
+	})
+	if apperrors.Is(err, apperrors.KindForbidden) {
+		return false, nil
+	}
+	return err == nil, err
+}
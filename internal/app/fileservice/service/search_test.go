@@ -0,0 +1,60 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+func prepareSearchService(t *testing.T, fileRepo *mocks.FileRepository, searchRepo *mocks.SearchRepository) *service.SearchService {
+	p, err := policy.Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("Error parsing policy, %v", err)
+	}
+	return service.NewSearchService(fileRepo, searchRepo, &testAuthorizer{policy: p})
+}
+
+func TestSearchService_List_Search(t *testing.T) {
+	fileRepo := new(mocks.FileRepository)
+	searchRepo := new(mocks.SearchRepository)
+	ctx := principalContext("sales")
+	searchRepo.On("SearchFiles", ctx, "supply agreement", 2, 2).Return([]*model.FileListItem{
+		{FileRecord: model.FileRecord{ID: "f1", DocClass: "contract", Metadata: []byte(`{}`)}, Rank: 0.8, Snippet: "<mark>supply</mark>"},
+		{FileRecord: model.FileRecord{ID: "f2", DocClass: "HR", Metadata: []byte(`{}`)}, Rank: 0.4},
+	}, nil)
+
+	list, err := prepareSearchService(t, fileRepo, searchRepo).List(ctx, " supply agreement ", "2", 2)
+
+	assert.Nil(t, err)
+	assert.Len(t, list.Files, 1)
+	assert.Equal(t, "f1", list.Files[0].ID)
+	assert.Equal(t, "4", list.Next)
+	searchRepo.AssertExpectations(t)
+}
+
+func TestSearchService_List_WithoutQuery(t *testing.T) {
+	fileRepo := new(mocks.FileRepository)
+	searchRepo := new(mocks.SearchRepository)
+	ctx := principalContext("hr")
+	fileRepo.On("ListFiles", ctx, "f0", 20).Return([]*model.FileRecord{
+		{ID: "f1", DocClass: "HR"},
+	}, nil)
+
+	list, err := prepareSearchService(t, fileRepo, searchRepo).List(ctx, "", "f0", 0)
+
+	assert.Nil(t, err)
+	assert.Len(t, list.Files, 1)
+	assert.Empty(t, list.Next)
+	fileRepo.AssertExpectations(t)
+}
+
+func TestSearchService_List_InvalidCursor(t *testing.T) {
+	_, err := prepareSearchService(t, new(mocks.FileRepository), new(mocks.SearchRepository)).List(principalContext("sales"), "supply", "f1", 20)
+
+	assert.True(t, apperrors.Is(err, apperrors.KindInvalid))
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	s3store "github.com/unistack-org/micro-store-s3/v3"
+	"github.com/unistack-org/micro/v3/store"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+	"github.com/vielendanke/file-service/internal/app/fileservice/thumbnails"
+)
+
+// ThumbnailService serves the thumbnails written by the thumbnails generator, a
+// thumbnail is authorized like the download of its file
+type ThumbnailService struct {
+	fileRepository repository.FileRepository
+	cleanStore     store.Store
+	authorizer     policy.Authorizer
+	sizes          []int
+}
+
+// NewThumbnailService returns the service of the thumbnails of sizes, the first size is the default one
+func NewThumbnailService(fileRepository repository.FileRepository, cleanStore store.Store, authorizer policy.Authorizer, sizes []int) *ThumbnailService {
+	return &ThumbnailService{
+		fileRepository: fileRepository,
+		cleanStore:     cleanStore,
+		authorizer:     authorizer,
+		sizes:          sizes,
+	}
+}
+
+// Thumbnail returns the thumbnail of size of the file with its content type, a
+// zero size selects the default one
+func (ts *ThumbnailService) Thumbnail(ctx context.Context, id string, size int) ([]byte, string, error) {
+	if size == 0 && len(ts.sizes) > 0 {
+		size = ts.sizes[0]
+	}
+	if !ts.supported(size) {
+		return nil, "", apperrors.Invalid(apperrors.CodeInvalidRequest, "Thumbnail size %d is not supported, expected one of %v", size, ts.sizes)
+	}
+	f, err := ts.fileRepository.FindFileByID(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := ts.authorizer.Authorize(ctx, policy.ActionDownload, &policy.Resource{
+		ID:       id,
+		DocClass: f.DocClass,
+		DocType:  f.DocType,
+	}); err != nil {
+		return nil, "", err
+	}
+	t := tenant.FromContext(ctx)
+	data := []byte{}
+	call, spanCtx := startS3Call(ctx, cleanStoreName, "Read", t, id)
+	err = ts.cleanStore.Read(spanCtx, t.DerivedKey(id, thumbnails.Name(size)), &data, s3store.ReadBucket(t.Bucket))
+	call.finish(err)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, "", apperrors.NotFound(apperrors.CodeThumbnailNotFound, "Thumbnail of file %s is not available", id)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("Error reading thumbnail from clean store, %v", err)
+	}
+	return data, http.DetectContentType(data), nil
+}
+
+func (ts *ThumbnailService) supported(size int) bool {
+	for _, s := range ts.sizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
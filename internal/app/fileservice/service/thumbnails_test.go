@@ -0,0 +1,68 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/unistack-org/micro/v3/store"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+func prepareThumbnailService(t *testing.T, repo *mocks.FileRepository, cleanStore *mocks.MockStore) *service.ThumbnailService {
+	p, err := policy.Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("Error parsing policy, %v", err)
+	}
+	return service.NewThumbnailService(repo, cleanStore, &testAuthorizer{policy: p}, []int{200, 800})
+}
+
+func TestThumbnailService_Thumbnail(t *testing.T) {
+	repo := new(mocks.FileRepository)
+	cleanStore := new(mocks.MockStore)
+	ctx := principalContext("sales")
+	png := []byte("\x89PNG\r\n\x1a\n")
+	repo.On("FindFileByID", ctx, "id").Return(&model.FileRecord{ID: "id", DocClass: "invoice"}, nil)
+	cleanStore.On("Read", withSpan, "derived/id/thumbnail-200", mock.Anything, mock.AnythingOfType("store.ReadOption")).Run(readReturns(png)).Return(nil)
+
+	data, contentType, err := prepareThumbnailService(t, repo, cleanStore).Thumbnail(ctx, "id", 0)
+
+	assert.Nil(t, err)
+	assert.Equal(t, png, data)
+	assert.Equal(t, "image/png", contentType)
+}
+
+func TestThumbnailService_Thumbnail_NotGenerated(t *testing.T) {
+	repo := new(mocks.FileRepository)
+	cleanStore := new(mocks.MockStore)
+	ctx := principalContext("sales")
+	repo.On("FindFileByID", ctx, "id").Return(&model.FileRecord{ID: "id", DocClass: "invoice"}, nil)
+	cleanStore.On("Read", withSpan, "derived/id/thumbnail-800", mock.Anything, mock.AnythingOfType("store.ReadOption")).Return(store.ErrNotFound)
+
+	_, _, err := prepareThumbnailService(t, repo, cleanStore).Thumbnail(ctx, "id", 800)
+
+	assert.True(t, apperrors.Is(err, apperrors.KindNotFound))
+}
+
+func TestThumbnailService_Thumbnail_Denied(t *testing.T) {
+	repo := new(mocks.FileRepository)
+	cleanStore := new(mocks.MockStore)
+	ctx := principalContext("sales")
+	repo.On("FindFileByID", ctx, "id").Return(&model.FileRecord{ID: "id", DocClass: "HR"}, nil)
+
+	_, _, err := prepareThumbnailService(t, repo, cleanStore).Thumbnail(ctx, "id", 200)
+
+	assert.True(t, apperrors.Is(err, apperrors.KindForbidden))
+	cleanStore.AssertNotCalled(t, "Read", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestThumbnailService_Thumbnail_UnsupportedSize(t *testing.T) {
+	_, _, err := prepareThumbnailService(t, new(mocks.FileRepository), new(mocks.MockStore)).Thumbnail(context.Background(), "id", 123)
+
+	assert.True(t, apperrors.Is(err, apperrors.KindInvalid))
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/events"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+	"github.com/vielendanke/file-service/internal/app/fileservice/webhooks"
+)
+
+// secretSize is the number of random bytes of the signing secret of a subscription
+const secretSize = 32
+
+// WebhookService manages the webhook subscriptions of the tenant of the context,
+// every call is authorized for policy.ActionManageWebhooks
+type WebhookService struct {
+	repo       repository.WebhookRepository
+	authorizer policy.Authorizer
+	resolver   webhooks.Resolver
+}
+
+// NewWebhookService creates the service, the hosts of the endpoints are looked
+// up with resolver
+func NewWebhookService(repo repository.WebhookRepository, authorizer policy.Authorizer, resolver webhooks.Resolver) *WebhookService {
+	return &WebhookService{
+		repo:       repo,
+		authorizer: authorizer,
+		resolver:   resolver,
+	}
+}
+
+// Create saves a new subscription, its secret is only returned here
+func (ws *WebhookService) Create(ctx context.Context, req *model.WebhookRequest) (*model.WebhookSubscription, error) {
+	if err := ws.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if err := ws.validateWebhook(ctx, req); err != nil {
+		return nil, err
+	}
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("Error generating webhook secret, %v", err)
+	}
+	s := &model.WebhookSubscription{
+		ID:         strings.ReplaceAll(uuid.New().String(), "-", ""),
+		URL:        req.URL,
+		Secret:     hex.EncodeToString(secret),
+		DocClasses: req.DocClasses,
+		EventTypes: req.EventTypes,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+	}
+	if err := ws.repo.SaveSubscription(ctx, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// List ...
+func (ws *WebhookService) List(ctx context.Context) ([]*model.WebhookSubscription, error) {
+	if err := ws.authorize(ctx); err != nil {
+		return nil, err
+	}
+	subscriptions, err := ws.repo.FindSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range subscriptions {
+		s.Secret = ""
+	}
+	return subscriptions, nil
+}
+
+// Get ...
+func (ws *WebhookService) Get(ctx context.Context, id string) (*model.WebhookSubscription, error) {
+	if err := ws.authorize(ctx); err != nil {
+		return nil, err
+	}
+	s, err := ws.repo.FindSubscriptionByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.Secret = ""
+	return s, nil
+}
+
+// Update replaces the endpoint and the filters of the subscription, the secret is kept
+func (ws *WebhookService) Update(ctx context.Context, id string, req *model.WebhookRequest) (*model.WebhookSubscription, error) {
+	if err := ws.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if err := ws.validateWebhook(ctx, req); err != nil {
+		return nil, err
+	}
+	s, err := ws.repo.FindSubscriptionByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.URL = req.URL
+	s.DocClasses = req.DocClasses
+	s.EventTypes = req.EventTypes
+	if req.Enabled != nil {
+		s.Enabled = *req.Enabled
+	}
+	if err := ws.repo.UpdateSubscription(ctx, s); err != nil {
+		return nil, err
+	}
+	s.Secret = ""
+	return s, nil
+}
+
+// Delete removes the subscription with its pending and dead deliveries
+func (ws *WebhookService) Delete(ctx context.Context, id string) error {
+	if err := ws.authorize(ctx); err != nil {
+		return err
+	}
+	return ws.repo.DeleteSubscription(ctx, id)
+}
+
+// ListDeadLetters returns the deliveries of the subscription which exhausted their attempts
+func (ws *WebhookService) ListDeadLetters(ctx context.Context, id string) ([]*model.WebhookDelivery, error) {
+	if err := ws.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if _, err := ws.repo.FindSubscriptionByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return ws.repo.FindDeadLetters(ctx, id)
+}
+
+// Redeliver schedules the dead delivery of the subscription for a new round of attempts
+func (ws *WebhookService) Redeliver(ctx context.Context, id, deliveryID string) error {
+	if err := ws.authorize(ctx); err != nil {
+		return err
+	}
+	return ws.repo.Redeliver(ctx, id, deliveryID)
+}
+
+func (ws *WebhookService) authorize(ctx context.Context) error {
+	return ws.authorizer.Authorize(ctx, policy.ActionManageWebhooks, &policy.Resource{})
+}
+
+// validateWebhook checks the endpoint and the event types of the request, the
+// endpoint must not resolve to an internal address and the event types are the
+// topics of the events with or without their version
+func (ws *WebhookService) validateWebhook(ctx context.Context, req *model.WebhookRequest) error {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return apperrors.Invalid(apperrors.CodeInvalidRequest, "Webhook url %q must be an absolute http or https url", req.URL)
+	}
+	if err := webhooks.CheckEndpoint(ctx, ws.resolver, u); err != nil {
+		return apperrors.Invalid(apperrors.CodeInvalidRequest, "Webhook url %q is not allowed, %v", req.URL, err)
+	}
+	for _, t := range req.EventTypes {
+		if !knownEventType(t) {
+			return apperrors.Invalid(apperrors.CodeInvalidRequest, "Unknown event type %s, expected one of %s", t, strings.Join(events.Topics, ", "))
+		}
+	}
+	return nil
+}
+
+func knownEventType(t string) bool {
+	for _, topic := range events.Topics {
+		if t == topic || t == topic[:strings.LastIndex(topic, ".")] {
+			return true
+		}
+	}
+	return false
+}
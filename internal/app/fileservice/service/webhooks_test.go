@@ -0,0 +1,124 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/policy"
+	"github.com/vielendanke/file-service/internal/app/fileservice/service"
+)
+
+const webhooksPolicy = `{
+	"default_effect": "allow",
+	"rules": [
+		{"name": "integrations", "effect": "deny", "actions": ["manage_webhooks"], "not_roles": ["integrations"]}
+	]
+}`
+
+// testResolver answers the lookups of the hosts from its addresses
+type testResolver map[string][]string
+
+func (tr testResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := tr[host]
+	if !ok {
+		return nil, fmt.Errorf("no such host")
+	}
+	addrs := make([]net.IPAddr, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return addrs, nil
+}
+
+var webhookHosts = testResolver{
+	"erp.example.com":      {"203.0.113.10"},
+	"internal.example.com": {"203.0.113.11", "10.0.0.5"},
+}
+
+func prepareWebhookService(t *testing.T, repo *mocks.WebhookRepository) *service.WebhookService {
+	p, err := policy.Parse([]byte(webhooksPolicy))
+	if err != nil {
+		t.Fatalf("Error parsing policy, %v", err)
+	}
+	return service.NewWebhookService(repo, &testAuthorizer{policy: p}, webhookHosts)
+}
+
+func TestWebhookService_Create(t *testing.T) {
+	repo := new(mocks.WebhookRepository)
+	ctx := principalContext("integrations")
+	repo.On("SaveSubscription", ctx, mock.AnythingOfType("*model.WebhookSubscription")).Return(nil)
+
+	s, err := prepareWebhookService(t, repo).Create(ctx, &model.WebhookRequest{
+		URL:        "https://erp.example.com/hooks",
+		DocClasses: []string{"invoice"},
+		EventTypes: []string{"file.clean", "file.deleted.v1"},
+	})
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, s.ID)
+	assert.Len(t, s.Secret, 64)
+	assert.True(t, s.Enabled)
+	repo.AssertExpectations(t)
+}
+
+func TestWebhookService_Create_Invalid(t *testing.T) {
+	repo := new(mocks.WebhookRepository)
+	ws := prepareWebhookService(t, repo)
+
+	_, err := ws.Create(principalContext("integrations"), &model.WebhookRequest{URL: "ftp://erp.example.com"})
+	assert.True(t, apperrors.Is(err, apperrors.KindInvalid))
+	_, err = ws.Create(principalContext("integrations"), &model.WebhookRequest{URL: "https://erp.example.com", EventTypes: []string{"file.moved"}})
+	assert.True(t, apperrors.Is(err, apperrors.KindInvalid))
+	repo.AssertNotCalled(t, "SaveSubscription", mock.Anything, mock.Anything)
+}
+
+func TestWebhookService_Create_InternalAddress(t *testing.T) {
+	repo := new(mocks.WebhookRepository)
+	ws := prepareWebhookService(t, repo)
+
+	for _, u := range []string{
+		"http://127.0.0.1:8080/hooks",
+		"http://[::1]/hooks",
+		"http://169.254.169.254/latest/meta-data",
+		"http://192.168.1.10/hooks",
+		"http://0.0.0.0/hooks",
+		"https://internal.example.com/hooks",
+		"https://unknown.example.com/hooks",
+	} {
+		_, err := ws.Create(principalContext("integrations"), &model.WebhookRequest{URL: u})
+		assert.True(t, apperrors.Is(err, apperrors.KindInvalid), u)
+	}
+	repo.AssertNotCalled(t, "SaveSubscription", mock.Anything, mock.Anything)
+}
+
+func TestWebhookService_Denied(t *testing.T) {
+	repo := new(mocks.WebhookRepository)
+
+	_, err := prepareWebhookService(t, repo).List(principalContext("sales"))
+
+	assert.True(t, apperrors.Is(err, apperrors.KindForbidden))
+	repo.AssertNotCalled(t, "FindSubscriptions", mock.Anything)
+}
+
+func TestWebhookService_Update_KeepsSecret(t *testing.T) {
+	repo := new(mocks.WebhookRepository)
+	ctx := principalContext("integrations")
+	disabled := false
+	repo.On("FindSubscriptionByID", ctx, "w1").Return(&model.WebhookSubscription{ID: "w1", Secret: "s3cr3t", Enabled: true}, nil)
+	repo.On("UpdateSubscription", ctx, mock.MatchedBy(func(s *model.WebhookSubscription) bool {
+		return s.URL == "https://erp.example.com/v2" && !s.Enabled
+	})).Return(nil)
+
+	s, err := prepareWebhookService(t, repo).Update(ctx, "w1", &model.WebhookRequest{URL: "https://erp.example.com/v2", Enabled: &disabled})
+
+	assert.Nil(t, err)
+	assert.Empty(t, s.Secret)
+	repo.AssertExpectations(t)
+}
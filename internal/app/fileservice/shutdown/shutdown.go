@@ -0,0 +1,173 @@
+// Package shutdown coordinates the graceful stop of the service, the requests
+// in flight are drained before the background workers and the connections are stopped.
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrDraining is returned for the requests started after the drain began
+var ErrDraining = errors.New("service is shutting down")
+
+// Request is a request in flight
+type Request struct {
+	Method string
+	Path   string
+	Start  time.Time
+}
+
+// Drainer tracks the requests in flight, it rejects the new requests once Drain is called
+type Drainer struct {
+	mu       sync.Mutex
+	draining bool
+	nextID   uint64
+	inflight map[uint64]Request
+	idle     chan struct{}
+}
+
+// NewDrainer ...
+func NewDrainer() *Drainer {
+	return &Drainer{
+		inflight: make(map[uint64]Request),
+	}
+}
+
+// Begin registers a request, done must be called when it completes, ok is
+// false if the service is draining and the request must be rejected
+func (d *Drainer) Begin(method, path string) (done func(), ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return nil, false
+	}
+	d.nextID++
+	id := d.nextID
+	d.inflight[id] = Request{Method: method, Path: path, Start: time.Now()}
+	var once sync.Once
+	return func() {
+		once.Do(func() { d.end(id) })
+	}, true
+}
+
+func (d *Drainer) end(id uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.inflight, id)
+	if d.draining && len(d.inflight) == 0 && d.idle != nil {
+		close(d.idle)
+		d.idle = nil
+	}
+}
+
+// Check is the readiness check of the drainer, it fails once the drain began
+func (d *Drainer) Check() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return ErrDraining
+	}
+	return nil
+}
+
+// Drain rejects the new requests and waits for the requests in flight until
+// ctx is done, it returns the requests which did not complete in time
+func (d *Drainer) Drain(ctx context.Context) []Request {
+	d.mu.Lock()
+	d.draining = true
+	if len(d.inflight) == 0 {
+		d.mu.Unlock()
+		return nil
+	}
+	if d.idle == nil {
+		d.idle = make(chan struct{})
+	}
+	idle := d.idle
+	d.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	aborted := make([]Request, 0, len(d.inflight))
+	for _, r := range d.inflight {
+		aborted = append(aborted, r)
+	}
+	sort.Slice(aborted, func(i, j int) bool { return aborted[i].Start.Before(aborted[j].Start) })
+	return aborted
+}
+
+// Workers runs the background workers of the service, the workers get a
+// context which is cancelled by Stop and return after their current item
+type Workers struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	running map[string]int
+}
+
+// NewWorkers ...
+func NewWorkers() *Workers {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Workers{
+		ctx:     ctx,
+		cancel:  cancel,
+		running: make(map[string]int),
+	}
+}
+
+// Go runs the worker fn named name in a new goroutine
+func (w *Workers) Go(name string, fn func(ctx context.Context)) {
+	w.mu.Lock()
+	w.running[name]++
+	w.mu.Unlock()
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			if w.running[name]--; w.running[name] == 0 {
+				delete(w.running, name)
+			}
+		}()
+		fn(w.ctx)
+	}()
+}
+
+// Context is cancelled by Stop, it is for the workers started by their own packages
+func (w *Workers) Context() context.Context {
+	return w.ctx
+}
+
+// Stop cancels the workers and waits for them until ctx is done, it returns
+// the names of the workers which are still running
+func (w *Workers) Stop(ctx context.Context) []string {
+	w.cancel()
+	stopped := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	names := make([]string, 0, len(w.running))
+	for name := range w.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
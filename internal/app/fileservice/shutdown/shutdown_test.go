@@ -0,0 +1,62 @@
+package shutdown_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/shutdown"
+)
+
+func TestDrainer_Drain(t *testing.T) {
+	d := shutdown.NewDrainer()
+	done, ok := d.Begin("POST", "/files")
+	assert.True(t, ok)
+	assert.Nil(t, d.Check())
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		done()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.Empty(t, d.Drain(ctx))
+	assert.Equal(t, shutdown.ErrDraining, d.Check())
+	_, ok = d.Begin("GET", "/files/{id}")
+	assert.False(t, ok)
+}
+
+func TestDrainer_DrainTimeout(t *testing.T) {
+	d := shutdown.NewDrainer()
+	_, ok := d.Begin("POST", "/files")
+	assert.True(t, ok)
+	done, _ := d.Begin("GET", "/files/{id}")
+	done()
+	done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	aborted := d.Drain(ctx)
+
+	assert.Len(t, aborted, 1)
+	assert.Equal(t, "POST", aborted[0].Method)
+	assert.Equal(t, "/files", aborted[0].Path)
+}
+
+func TestWorkers_Stop(t *testing.T) {
+	w := shutdown.NewWorkers()
+	w.Go("watcher", func(ctx context.Context) {
+		<-ctx.Done()
+	})
+	w.Go("stuck", func(ctx context.Context) {
+		time.Sleep(200 * time.Millisecond)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, []string{"stuck"}, w.Stop(ctx))
+	assert.NotNil(t, w.Context().Err())
+}
@@ -0,0 +1,146 @@
+// Package tenant resolves the business unit a request belongs to and holds
+// the storage settings and quotas of the tenants.
+package tenant
+
+import (
+	"context"
+	"net/textproto"
+	"sort"
+
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+)
+
+// Header carries the tenant of the service to service calls
+var Header = textproto.CanonicalMIMEHeaderKey("x-tenant-id")
+
+// DefaultID is the tenant of the deployments with a single tenant
+const DefaultID = "default"
+
+// DefaultBucket is the bucket of the tenants without their own one
+const DefaultBucket = "micro-store-s3"
+
+// Default is used when no tenant is resolved for the context
+var Default = &Tenant{
+	ID:     DefaultID,
+	Bucket: DefaultBucket,
+}
+
+// Tenant holds the object storage location and the quotas of a tenant,
+// zero quotas are not enforced
+type Tenant struct {
+	ID         string `json:"id"`
+	Bucket     string `json:"bucket"`
+	Prefix     string `json:"prefix"`
+	MaxObjects int64  `json:"max_objects"`
+	MaxBytes   int64  `json:"max_bytes"`
+}
+
+// Key returns the object key of the file in the bucket of the tenant
+func (t *Tenant) Key(id string) string {
+	return t.Prefix + id
+}
+
+// DerivedKey returns the object key of the derivative name of the file, like its
+// thumbnails, the derived keys never collide with the keys of the files
+func (t *Tenant) DerivedKey(id, name string) string {
+	return t.Prefix + "derived/" + id + "/" + name
+}
+
+type tenantKey struct{}
+
+// NewContext returns a copy of ctx carrying t
+func NewContext(ctx context.Context, t *Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey{}, t)
+}
+
+// FromContext returns the tenant of ctx or Default
+func FromContext(ctx context.Context) *Tenant {
+	if t, ok := ctx.Value(tenantKey{}).(*Tenant); ok {
+		return t
+	}
+	return Default
+}
+
+// Registry holds the configured tenants
+type Registry struct {
+	defaultID string
+	tenants   map[string]*Tenant
+}
+
+// NewRegistry returns a registry of tenants, the ones without a bucket use
+// DefaultBucket with their id as the key prefix
+func NewRegistry(defaultID string, tenants []*Tenant) *Registry {
+	r := &Registry{
+		defaultID: defaultID,
+		tenants:   make(map[string]*Tenant, len(tenants)),
+	}
+	for _, t := range tenants {
+		if t.Bucket == "" {
+			t.Bucket = DefaultBucket
+			if t.Prefix == "" {
+				t.Prefix = t.ID + "/"
+			}
+		}
+		r.tenants[t.ID] = t
+	}
+	return r
+}
+
+// Lookup ...
+func (r *Registry) Lookup(id string) (*Tenant, error) {
+	t, ok := r.tenants[id]
+	if !ok {
+		return nil, apperrors.Invalid(apperrors.CodeUnknownTenant, "Unknown tenant %s", id)
+	}
+	return t, nil
+}
+
+// Tenants returns the tenants ordered by id
+func (r *Registry) Tenants() []*Tenant {
+	tenants := make([]*Tenant, 0, len(r.tenants))
+	for _, t := range r.tenants {
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].ID < tenants[j].ID
+	})
+	return tenants
+}
+
+// Buckets returns the distinct buckets of the tenants
+func (r *Registry) Buckets() []string {
+	seen := make(map[string]bool, len(r.tenants))
+	buckets := []string{}
+	for _, t := range r.tenants {
+		if !seen[t.Bucket] {
+			seen[t.Bucket] = true
+			buckets = append(buckets, t.Bucket)
+		}
+	}
+	sort.Strings(buckets)
+	return buckets
+}
+
+// Resolve returns the tenant of the caller. The tenant claim of a principal
+// wins, the header is accepted from authenticated service callers only, the
+// users without the claim and the anonymous requests belong to the default
+// tenant.
+func (r *Registry) Resolve(principal *middleware.Principal, header string) (*Tenant, error) {
+	if principal != nil && principal.Tenant != "" {
+		if header != "" && header != principal.Tenant {
+			return nil, apperrors.Forbidden(apperrors.CodeForbidden, "Access to tenant %s denied", header)
+		}
+		return r.Lookup(principal.Tenant)
+	}
+	if header != "" && principal != nil && principal.Kind == middleware.PrincipalService {
+		return r.Lookup(header)
+	}
+	if header != "" && header != r.defaultID {
+		return nil, apperrors.Forbidden(apperrors.CodeForbidden, "Access to tenant %s denied", header)
+	}
+	if r.defaultID == "" {
+		return nil, apperrors.Invalid(apperrors.CodeUnknownTenant, "Tenant is not resolved")
+	}
+	return r.Lookup(r.defaultID)
+}
@@ -0,0 +1,101 @@
+package tenant_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/http/middleware"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+func prepareRegistry(defaultID string) *tenant.Registry {
+	return tenant.NewRegistry(defaultID, []*tenant.Tenant{
+		{ID: "default", Bucket: tenant.DefaultBucket},
+		{ID: "hr"},
+		{ID: "sales", Bucket: "sales-bucket"},
+	})
+}
+
+func TestRegistry_Resolve_PrincipalTenant(t *testing.T) {
+	registry := prepareRegistry("default")
+	user := &middleware.Principal{Subject: "user", Kind: middleware.PrincipalUser, Tenant: "hr"}
+
+	res, err := registry.Resolve(user, "")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "hr", res.ID)
+	assert.Equal(t, tenant.DefaultBucket, res.Bucket)
+	assert.Equal(t, "hr/file", res.Key("file"))
+
+	_, err = registry.Resolve(user, "sales")
+
+	assert.True(t, apperrors.Is(err, apperrors.KindForbidden))
+}
+
+func TestRegistry_Resolve_Header(t *testing.T) {
+	registry := prepareRegistry("default")
+	service := &middleware.Principal{Subject: "billing", Kind: middleware.PrincipalService}
+	user := &middleware.Principal{Subject: "user", Kind: middleware.PrincipalUser}
+
+	res, err := registry.Resolve(service, "sales")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "sales-bucket", res.Bucket)
+	assert.Equal(t, "file", res.Key("file"))
+
+	res, err = registry.Resolve(user, "")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "default", res.ID)
+
+	_, err = registry.Resolve(user, "sales")
+
+	assert.True(t, apperrors.Is(err, apperrors.KindForbidden))
+
+	_, err = registry.Resolve(service, "unknown")
+
+	assert.True(t, apperrors.Is(err, apperrors.KindInvalid))
+}
+
+func TestRegistry_Resolve_AnonymousHeader(t *testing.T) {
+	registry := prepareRegistry("default")
+
+	_, err := registry.Resolve(nil, "sales")
+
+	assert.True(t, apperrors.Is(err, apperrors.KindForbidden))
+
+	res, err := registry.Resolve(nil, "default")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "default", res.ID)
+}
+
+func TestRegistry_Resolve_NoDefault(t *testing.T) {
+	registry := prepareRegistry("")
+
+	_, err := registry.Resolve(nil, "")
+
+	assert.NotNil(t, err)
+}
+
+func TestFromContext(t *testing.T) {
+	hr := &tenant.Tenant{ID: "hr"}
+
+	assert.Equal(t, tenant.Default, tenant.FromContext(context.Background()))
+	assert.Equal(t, hr, tenant.FromContext(tenant.NewContext(context.Background(), hr)))
+}
+
+func TestRegistry_Buckets(t *testing.T) {
+	assert.Equal(t, []string{tenant.DefaultBucket, "sales-bucket"}, prepareRegistry("default").Buckets())
+}
+
+func TestRegistry_Tenants(t *testing.T) {
+	ids := []string{}
+	for _, tn := range prepareRegistry("default").Tenants() {
+		ids = append(ids, tn.ID)
+	}
+
+	assert.Equal(t, []string{"default", "hr", "sales"}, ids)
+}
@@ -0,0 +1,161 @@
+package thumbnails
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	s3store "github.com/unistack-org/micro-store-s3/v3"
+	"github.com/unistack-org/micro/v3/broker"
+	"github.com/unistack-org/micro/v3/logger"
+	"github.com/unistack-org/micro/v3/store"
+	metrics "github.com/vielendanke/file-service/internal/app/fileservice/commons/metrics"
+	"github.com/vielendanke/file-service/internal/app/fileservice/events"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+)
+
+var (
+	generated = metrics.GetOrMakeCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NS,
+			Name:      "thumbnails_generated_total",
+			Help:      "Total number of the files with generated thumbnails by content type.",
+		},
+		[]string{"content_type"},
+	)
+	renderErrors = metrics.GetOrMakeCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NS,
+			Name:      "thumbnail_render_errors_total",
+			Help:      "Total number of the files which could not be rendered by content type.",
+		},
+		[]string{"content_type"},
+	)
+)
+
+// sniffLen is the number of bytes of a file sniffed for its content type
+const sniffLen = 512
+
+// Opener opens the objects of the clean store for streaming reads, a missing
+// object is reported as store.ErrNotFound
+type Opener interface {
+	Open(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+}
+
+// Generator is the publisher of the relay writing the thumbnails of a file when it
+// becomes clean and removing them when it is deleted. A file which can not be
+// rendered is skipped, the failures of the store are retried by the relay.
+type Generator struct {
+	store         store.Store
+	opener        Opener
+	tenants       *tenant.Registry
+	maxBytes      int64
+	sizes         []int
+	renderers     map[string]Renderer
+	renderTimeout time.Duration
+}
+
+// NewGenerator returns a generator of the thumbnails of sizes in the clean store,
+// the files are read through cleanOpener. Renderers are keyed by the sniffed
+// content type of the files, the files of more than maxBytes are not rendered.
+func NewGenerator(cleanStore store.Store, cleanOpener Opener, tenants *tenant.Registry, maxBytes int64, sizes []int, renderers map[string]Renderer, renderTimeout time.Duration) *Generator {
+	return &Generator{
+		store:         cleanStore,
+		opener:        cleanOpener,
+		tenants:       tenants,
+		maxBytes:      maxBytes,
+		sizes:         sizes,
+		renderers:     renderers,
+		renderTimeout: renderTimeout,
+	}
+}
+
+// Publish ...
+func (g *Generator) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	if topic != events.TopicFileClean && topic != events.TopicFileDeleted {
+		return nil
+	}
+	t, err := g.tenants.Lookup(msg.Header[events.HeaderTenantID])
+	if err != nil {
+		logger.Errorf(ctx, "Error generating thumbnails of %s, %v", msg.Header[events.HeaderFileID], err)
+		return nil
+	}
+	if topic == events.TopicFileDeleted {
+		return g.remove(ctx, t, msg.Header[events.HeaderFileID])
+	}
+	return g.generate(ctx, t, msg.Header[events.HeaderFileID])
+}
+
+func (g *Generator) generate(ctx context.Context, t *tenant.Tenant, id string) error {
+	body, err := g.opener.Open(ctx, t.Bucket, t.Key(id))
+	if errors.Is(err, store.ErrNotFound) {
+		logger.Infof(ctx, "File %s of tenant %s is no longer in the clean store, skipping thumbnails", id, t.ID)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Error reading file %s for thumbnails, %v", id, err)
+	}
+	defer body.Close()
+	// the type is sniffed before the file is read, the files which are not
+	// rendered are not read any further
+	br := bufio.NewReaderSize(body, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("Error reading file %s for thumbnails, %v", id, err)
+	}
+	contentType := http.DetectContentType(head)
+	renderer, ok := g.renderers[contentType]
+	if !ok {
+		return nil
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(br, g.maxBytes+1))
+	if err != nil {
+		return fmt.Errorf("Error reading file %s for thumbnails, %v", id, err)
+	}
+	if int64(len(data)) > g.maxBytes {
+		logger.Infof(ctx, "File %s of tenant %s is larger than %d bytes, skipping thumbnails", id, t.ID, g.maxBytes)
+		return nil
+	}
+	renderCtx, cancel := context.WithTimeout(ctx, g.renderTimeout)
+	defer cancel()
+	img, err := renderer.Render(renderCtx, data)
+	if err != nil {
+		renderErrors.WithLabelValues(contentType).Inc()
+		logger.Errorf(ctx, "Error rendering thumbnails of %s of tenant %s, %v", id, t.ID, err)
+		return nil
+	}
+	for _, size := range g.sizes {
+		thumbnail, thumbnailType, err := Encode(Resize(img, size))
+		if err != nil {
+			return err
+		}
+		if err := g.store.Write(
+			ctx,
+			t.DerivedKey(id, Name(size)),
+			bytes.NewReader(thumbnail),
+			s3store.WriteBucket(t.Bucket),
+			s3store.ContentType(thumbnailType),
+		); err != nil {
+			return fmt.Errorf("Error writing thumbnail of %s, %v", id, err)
+		}
+	}
+	generated.WithLabelValues(contentType).Inc()
+	return nil
+}
+
+func (g *Generator) remove(ctx context.Context, t *tenant.Tenant, id string) error {
+	for _, size := range g.sizes {
+		err := g.store.Delete(ctx, t.DerivedKey(id, Name(size)), s3store.DeleteBucket(t.Bucket))
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("Error deleting thumbnail of %s, %v", id, err)
+		}
+	}
+	return nil
+}
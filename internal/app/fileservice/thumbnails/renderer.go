@@ -0,0 +1,66 @@
+package thumbnails
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"os/exec"
+
+	// the decoders of the rendered image formats
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// Renderer renders the first page of a document to an image
+type Renderer interface {
+	Render(ctx context.Context, data []byte) (image.Image, error)
+}
+
+// ImageRenderer decodes the images supported by the standard library, the
+// images of more than MaxPixels are rejected before they are decoded
+type ImageRenderer struct {
+	MaxPixels int
+}
+
+// Render ...
+func (ir *ImageRenderer) Render(ctx context.Context, data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding image, %v", err)
+	}
+	if ir.MaxPixels > 0 && cfg.Width*cfg.Height > ir.MaxPixels {
+		return nil, fmt.Errorf("Image of %dx%d exceeds %d pixels", cfg.Width, cfg.Height, ir.MaxPixels)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding image, %v", err)
+	}
+	return img, nil
+}
+
+// CommandRenderer renders with an external program like a PDF rasterizer, the
+// document is written to its stdin and the image of the first page is read from
+// its stdout in a format of ImageRenderer
+type CommandRenderer struct {
+	Command   []string
+	MaxPixels int
+}
+
+// Render ...
+func (cr *CommandRenderer) Render(ctx context.Context, data []byte) (image.Image, error) {
+	if len(cr.Command) == 0 {
+		return nil, fmt.Errorf("Error rendering, no command")
+	}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, cr.Command[0], cr.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("Error running %s, %v: %s", cr.Command[0], err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return (&ImageRenderer{MaxPixels: cr.MaxPixels}).Render(ctx, stdout.Bytes())
+}
@@ -0,0 +1,86 @@
+// Package thumbnails derives the previews of the clean files. The derivatives are
+// written next to the originals under the derived keys of the tenant, the originals
+// are only read.
+package thumbnails
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+// jpegQuality is the quality of the opaque thumbnails
+const jpegQuality = 85
+
+// Name returns the name of the thumbnail of size under the derived keys of a file
+func Name(size int) string {
+	return fmt.Sprintf("thumbnail-%d", size)
+}
+
+// Resize scales img down so its longest side is size pixels keeping the aspect
+// ratio, every pixel of the result averages the source pixels it covers. The
+// images not larger than size are copied unscaled.
+func Resize(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return src
+	}
+	dw, dh := size, size
+	if w > h {
+		dh = max(1, h*size/w)
+	} else {
+		dw = max(1, w*size/h)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		sy0, sy1 := dy*h/dh, max((dy+1)*h/dh, dy*h/dh+1)
+		for dx := 0; dx < dw; dx++ {
+			sx0, sx1 := dx*w/dw, max((dx+1)*w/dw, dx*w/dw+1)
+			var r, g, bl, a, n uint32
+			for sy := sy0; sy < sy1; sy++ {
+				row := src.Pix[sy*src.Stride+sx0*4 : sy*src.Stride+sx1*4]
+				for i := 0; i < len(row); i += 4 {
+					r += uint32(row[i])
+					g += uint32(row[i+1])
+					bl += uint32(row[i+2])
+					a += uint32(row[i+3])
+					n++
+				}
+			}
+			o := dy*dst.Stride + dx*4
+			dst.Pix[o] = uint8(r / n)
+			dst.Pix[o+1] = uint8(g / n)
+			dst.Pix[o+2] = uint8(bl / n)
+			dst.Pix[o+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// Encode returns the thumbnail as a JPEG or as a PNG if it is transparent, with its content type
+func Encode(img *image.RGBA) ([]byte, string, error) {
+	buf := &bytes.Buffer{}
+	if !img.Opaque() {
+		if err := png.Encode(buf, img); err != nil {
+			return nil, "", fmt.Errorf("Error encoding thumbnail, %v", err)
+		}
+		return buf.Bytes(), "image/png", nil
+	}
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, "", fmt.Errorf("Error encoding thumbnail, %v", err)
+	}
+	return buf.Bytes(), "image/jpeg", nil
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
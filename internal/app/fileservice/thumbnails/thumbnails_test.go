@@ -0,0 +1,158 @@
+package thumbnails_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/unistack-org/micro/v3/broker"
+	"github.com/unistack-org/micro/v3/store"
+	"github.com/vielendanke/file-service/internal/app/fileservice/events"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/tenant"
+	"github.com/vielendanke/file-service/internal/app/fileservice/thumbnails"
+)
+
+func solid(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatalf("Error encoding png, %v", err)
+	}
+	return buf.Bytes()
+}
+
+func prepareGenerator(s *mocks.MockStore, o *mocks.ObjectOpener) *thumbnails.Generator {
+	tenants := tenant.NewRegistry("sales", []*tenant.Tenant{{ID: "sales", Bucket: "docs", Prefix: "sales/"}})
+	images := &thumbnails.ImageRenderer{MaxPixels: 1000000}
+	return thumbnails.NewGenerator(s, o, tenants, 1<<20, []int{20, 50}, map[string]thumbnails.Renderer{"image/png": images}, time.Second)
+}
+
+// countingReader counts the bytes read from the object
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += n
+	return n, err
+}
+
+func TestResize(t *testing.T) {
+	src := solid(400, 100, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+
+	dst := thumbnails.Resize(src, 200)
+
+	assert.Equal(t, 200, dst.Bounds().Dx())
+	assert.Equal(t, 50, dst.Bounds().Dy())
+	assert.Equal(t, color.RGBA{R: 200, G: 100, B: 50, A: 255}, dst.RGBAAt(10, 10))
+}
+
+func TestResize_NoUpscale(t *testing.T) {
+	dst := thumbnails.Resize(solid(30, 60, color.White), 200)
+
+	assert.Equal(t, image.Rect(0, 0, 30, 60), dst.Bounds())
+}
+
+func TestEncode(t *testing.T) {
+	_, contentType, err := thumbnails.Encode(solid(10, 10, color.White))
+	assert.Nil(t, err)
+	assert.Equal(t, "image/jpeg", contentType)
+
+	_, contentType, err = thumbnails.Encode(solid(10, 10, color.Transparent))
+	assert.Nil(t, err)
+	assert.Equal(t, "image/png", contentType)
+}
+
+func TestImageRenderer_MaxPixels(t *testing.T) {
+	data := encodePNG(t, solid(100, 100, color.White))
+
+	_, err := (&thumbnails.ImageRenderer{MaxPixels: 5000}).Render(context.Background(), data)
+
+	assert.NotNil(t, err)
+}
+
+func TestGenerator_Publish_FileClean(t *testing.T) {
+	ctx := context.Background()
+	s := new(mocks.MockStore)
+	o := new(mocks.ObjectOpener)
+	original := encodePNG(t, solid(100, 40, color.Black))
+	o.On("Open", ctx, "docs", "sales/f1").Return(ioutil.NopCloser(bytes.NewReader(original)), nil)
+	s.On("Write", ctx, "sales/derived/f1/thumbnail-20", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	s.On("Write", ctx, "sales/derived/f1/thumbnail-50", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	err := prepareGenerator(s, o).Publish(ctx, events.TopicFileClean, &broker.Message{
+		Header: map[string]string{events.HeaderTenantID: "sales", events.HeaderFileID: "f1"},
+	})
+
+	assert.Nil(t, err)
+	s.AssertExpectations(t)
+	o.AssertExpectations(t)
+	s.AssertNotCalled(t, "Write", ctx, "sales/f1", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGenerator_Publish_SkipsUnsupported(t *testing.T) {
+	ctx := context.Background()
+	s := new(mocks.MockStore)
+	o := new(mocks.ObjectOpener)
+	body := &countingReader{r: strings.NewReader("plain text document " + strings.Repeat("text ", 1<<20))}
+	o.On("Open", ctx, "docs", "sales/f1").Return(ioutil.NopCloser(body), nil)
+
+	err := prepareGenerator(s, o).Publish(ctx, events.TopicFileClean, &broker.Message{
+		Header: map[string]string{events.HeaderTenantID: "sales", events.HeaderFileID: "f1"},
+	})
+
+	assert.Nil(t, err)
+	// only the head of the file is read to sniff its type
+	assert.LessOrEqual(t, body.n, 512)
+	s.AssertNotCalled(t, "Write", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGenerator_Publish_SkipsTooLarge(t *testing.T) {
+	ctx := context.Background()
+	s := new(mocks.MockStore)
+	o := new(mocks.ObjectOpener)
+	large := append(encodePNG(t, solid(10, 10, color.Black)), make([]byte, 2<<20)...)
+	o.On("Open", ctx, "docs", "sales/f1").Return(ioutil.NopCloser(bytes.NewReader(large)), nil)
+
+	err := prepareGenerator(s, o).Publish(ctx, events.TopicFileClean, &broker.Message{
+		Header: map[string]string{events.HeaderTenantID: "sales", events.HeaderFileID: "f1"},
+	})
+
+	assert.Nil(t, err)
+	s.AssertNotCalled(t, "Write", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGenerator_Publish_NotInCleanStore(t *testing.T) {
+	ctx := context.Background()
+	s := new(mocks.MockStore)
+	o := new(mocks.ObjectOpener)
+	o.On("Open", ctx, "docs", "sales/f1").Return(nil, store.ErrNotFound)
+
+	err := prepareGenerator(s, o).Publish(ctx, events.TopicFileClean, &broker.Message{
+		Header: map[string]string{events.HeaderTenantID: "sales", events.HeaderFileID: "f1"},
+	})
+
+	assert.Nil(t, err)
+	s.AssertNotCalled(t, "Write", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
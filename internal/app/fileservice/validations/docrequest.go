@@ -1,6 +1,6 @@
 package validations
 
-import "fmt"
+import "github.com/vielendanke/file-service/internal/app/fileservice/apperrors"
 
 // ValidateJSONDocumentRequest ...
 func ValidateJSONDocumentRequest(properties map[string]interface{}) error {
@@ -13,13 +13,18 @@ func ValidateJSONDocumentRequest(properties map[string]interface{}) error {
 	_, isDocNumExists := properties[docNumProperty]
 
 	if !isDocClassExists {
-		return fmt.Errorf("Bad request. Field %s does not exists", docClassProperty)
+		return apperrors.Invalid(apperrors.CodeInvalidRequest, "Bad request. Field %s does not exists", docClassProperty)
 	}
 	if !isDocTypeExists {
-		return fmt.Errorf("Bad request. Field %s does not exists", docTypeProperty)
+		return apperrors.Invalid(apperrors.CodeInvalidRequest, "Bad request. Field %s does not exists", docTypeProperty)
 	}
 	if !isDocNumExists {
-		return fmt.Errorf("Bad request. Field %s does not exists", docNumProperty)
+		return apperrors.Invalid(apperrors.CodeInvalidRequest, "Bad request. Field %s does not exists", docNumProperty)
+	}
+	for _, v := range []string{docClassProperty, docTypeProperty, docNumProperty} {
+		if _, ok := properties[v].(string); !ok {
+			return apperrors.Invalid(apperrors.CodeInvalidRequest, "Bad request. Field %s must be a string", v)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,166 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/unistack-org/micro/v3/logger"
+	metrics "github.com/vielendanke/file-service/internal/app/fileservice/commons/metrics"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/retry"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+)
+
+// Results of the delivery attempts
+const (
+	ResultDelivered = "delivered"
+	ResultRetried   = "retried"
+	ResultDead      = "dead"
+)
+
+// maxDrainedBody bounds the part of the response read to reuse the connection
+const maxDrainedBody = 64 << 10
+
+var (
+	deliveries = metrics.GetOrMakeCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NS,
+			Name:      "webhook_deliveries_total",
+			Help:      "Total number of the webhook delivery attempts by subscription and result.",
+		},
+		[]string{"subscription", "result"},
+	)
+	deliveryDuration = metrics.GetOrMakeHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.NS,
+			Name:      "webhook_delivery_duration_seconds",
+			Help:      "Duration of the webhook delivery attempts by subscription.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"subscription"},
+	)
+)
+
+// Dispatcher posts the due deliveries to the endpoints of their subscriptions, a
+// delivery is done once the endpoint answered with a 2xx status
+type Dispatcher struct {
+	repo        repository.WebhookRepository
+	client      *http.Client
+	batchSize   int
+	lease       time.Duration
+	backoff     retry.Backoff
+	maxAttempts int
+}
+
+// NewDispatcher returns a dispatcher posting up to batchSize deliveries at once, a
+// failed delivery is retried after the delay of backoff for its number of attempts
+// and dead-lettered after maxAttempts
+func NewDispatcher(repo repository.WebhookRepository, client *http.Client, batchSize int, lease time.Duration, backoff retry.Backoff, maxAttempts int) *Dispatcher {
+	return &Dispatcher{
+		repo:        repo,
+		client:      client,
+		batchSize:   batchSize,
+		lease:       lease,
+		backoff:     backoff,
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Run delivers the due deliveries every interval until ctx is done, the full
+// batches are followed by the next one without waiting
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := d.DeliverDue(ctx)
+		if err != nil {
+			logger.Errorf(ctx, "Error dispatching webhooks, %v", err)
+		}
+		if n == d.batchSize && ctx.Err() == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue posts one batch of the due deliveries concurrently and returns the
+// number of claimed deliveries
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	batch, err := d.repo.ClaimDeliveries(ctx, d.batchSize, d.lease)
+	if err != nil {
+		return 0, err
+	}
+	errs := make([]error, len(batch))
+	wg := sync.WaitGroup{}
+	for i, delivery := range batch {
+		wg.Add(1)
+		go func(i int, delivery *model.WebhookDelivery) {
+			defer wg.Done()
+			errs[i] = d.deliver(ctx, delivery)
+		}(i, delivery)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return len(batch), err
+		}
+	}
+	return len(batch), nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery *model.WebhookDelivery) error {
+	start := time.Now()
+	status, err := d.post(ctx, delivery)
+	deliveryDuration.WithLabelValues(delivery.SubscriptionID).Observe(metrics.SinceSeconds(start))
+	if err == nil {
+		deliveries.WithLabelValues(delivery.SubscriptionID, ResultDelivered).Inc()
+		return d.repo.DeleteDelivery(ctx, delivery.ID)
+	}
+	attempt := delivery.Attempts + 1
+	if attempt >= d.maxAttempts {
+		deliveries.WithLabelValues(delivery.SubscriptionID, ResultDead).Inc()
+		logger.Errorf(ctx, "Error delivering %s of %s to webhook %s, dead after %d attempts, %v", delivery.EventType, delivery.EventID, delivery.SubscriptionID, attempt, err)
+		return d.repo.DeadLetterDelivery(ctx, delivery.ID, status, err.Error())
+	}
+	deliveries.WithLabelValues(delivery.SubscriptionID, ResultRetried).Inc()
+	delay := d.backoff.Delay(attempt)
+	logger.Infof(ctx, "Error delivering %s of %s to webhook %s, attempt %d, retrying in %s, %v", delivery.EventType, delivery.EventID, delivery.SubscriptionID, attempt, delay, err)
+	return d.repo.RetryDelivery(ctx, delivery.ID, delay, status, err.Error())
+}
+
+// post sends the signed payload and returns the status of the response, 0 if
+// there was no response
+func (d *Dispatcher) post(ctx context.Context, delivery *model.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("Error building webhook request, %v", err)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, delivery.EventID)
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Payload))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxDrainedBody))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("Webhook answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,108 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned for the endpoints on the loopback, link-local,
+// private or unspecified addresses, the webhooks must not reach the internal network
+var ErrForbiddenAddress = errors.New("address not allowed for webhooks")
+
+// privateNets are the private networks of RFC 1918 and the unique local IPv6 addresses
+var privateNets = []*net.IPNet{
+	mustParseCIDR("10.0.0.0/8"),
+	mustParseCIDR("172.16.0.0/12"),
+	mustParseCIDR("192.168.0.0/16"),
+	mustParseCIDR("fc00::/7"),
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// Resolver looks up the addresses of the endpoint hosts, net.DefaultResolver
+// satisfies it
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// AllowedIP reports whether the webhooks may be delivered to ip
+func AllowedIP(ip net.IP) bool {
+	if ip == nil || ip.IsUnspecified() || ip.IsLoopback() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, n := range privateNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckEndpoint resolves the host of u and fails unless all its addresses are
+// allowed, the dialer of NewClient checks the address again on every delivery
+// since the answers of the DNS may change
+func CheckEndpoint(ctx context.Context, r Resolver, u *url.URL) error {
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !AllowedIP(ip) {
+			return ErrForbiddenAddress
+		}
+		return nil
+	}
+	addrs, err := r.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("Error resolving %s, %v", host, err)
+	}
+	for _, addr := range addrs {
+		if !AllowedIP(addr.IP) {
+			return ErrForbiddenAddress
+		}
+	}
+	return nil
+}
+
+// NewClient returns the client of the deliveries, it refuses to connect to the
+// addresses which are not allowed and does not follow the redirects, a
+// redirect answers the delivery with its 3xx status. The proxies of the
+// environment are not used so that the dialed address is the endpoint's own
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		// the address is resolved by now, a host resolving to another address
+		// than at the creation of the subscription is checked here
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !AllowedIP(net.ParseIP(host)) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,86 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/unistack-org/micro/v3/broker"
+	"github.com/vielendanke/file-service/internal/app/fileservice/events"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/repository"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// envelope is the body of the delivered requests
+type envelope struct {
+	Type  string          `json:"type"`
+	Event json.RawMessage `json:"event"`
+}
+
+// Fanout is the publisher of the relay saving a delivery of the event for each
+// matching subscription of its tenant
+type Fanout struct {
+	repo repository.WebhookRepository
+}
+
+// NewFanout ...
+func NewFanout(repo repository.WebhookRepository) *Fanout {
+	return &Fanout{
+		repo: repo,
+	}
+}
+
+// Publish ...
+func (f *Fanout) Publish(ctx context.Context, topic string, msg *broker.Message, opts ...broker.PublishOption) error {
+	tenantID := msg.Header[events.HeaderTenantID]
+	docClass := msg.Header[events.HeaderDocClass]
+	eventID := msg.Header[events.HeaderEventID]
+	subscriptions, err := f.repo.FindTenantSubscriptions(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	deliveries := []*model.WebhookDelivery{}
+	var payload []byte
+	for _, s := range subscriptions {
+		if !s.Matches(topic, docClass) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = encode(topic, msg.Body); err != nil {
+				return err
+			}
+		}
+		deliveries = append(deliveries, &model.WebhookDelivery{
+			ID:             strings.ReplaceAll(uuid.New().String(), "-", ""),
+			SubscriptionID: s.ID,
+			TenantID:       tenantID,
+			EventID:        eventID,
+			EventType:      topic,
+			Payload:        payload,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return f.repo.SaveDeliveries(ctx, deliveries)
+}
+
+// encode converts the protobuf payload of the event to the json envelope
+func encode(topic string, body []byte) ([]byte, error) {
+	m := events.NewMessage(topic)
+	if m == nil {
+		return nil, fmt.Errorf("Error encoding webhook payload, unknown topic %s", topic)
+	}
+	if err := proto.Unmarshal(body, m); err != nil {
+		return nil, fmt.Errorf("Error decoding event %s, %v", topic, err)
+	}
+	event, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("Error encoding webhook payload of %s, %v", topic, err)
+	}
+	return json.Marshal(&envelope{Type: topic, Event: event})
+}
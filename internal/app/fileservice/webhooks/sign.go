@@ -0,0 +1,38 @@
+// Package webhooks delivers the domain events to the http endpoints subscribed by
+// the tenants. The events of the outbox are fanned out to a delivery per matching
+// subscription, the dispatcher posts them signed with the secret of the subscription
+// and retries the failed ones until they are dead-lettered.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// Headers of the delivered requests
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderID        = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+)
+
+const signaturePrefix = "sha256="
+
+// Sign returns the signature of the body sent at the unix timestamp, the HMAC-SHA256
+// of the timestamp and the body joined by a dot keyed with secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports if signature is the signature of the body sent at timestamp,
+// the receivers also reject the old timestamps to prevent replays
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
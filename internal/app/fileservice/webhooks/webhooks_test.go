@@ -0,0 +1,216 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/unistack-org/micro/v3/broker"
+	"github.com/vielendanke/file-service/internal/app/fileservice/commons/retry"
+	"github.com/vielendanke/file-service/internal/app/fileservice/events"
+	"github.com/vielendanke/file-service/internal/app/fileservice/mocks"
+	"github.com/vielendanke/file-service/internal/app/fileservice/model"
+	"github.com/vielendanke/file-service/internal/app/fileservice/webhooks"
+)
+
+// receiver records the verified requests and answers with status
+type receiver struct {
+	status  int
+	secret  string
+	bodies  [][]byte
+	headers []http.Header
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	timestamp, _ := strconv.ParseInt(r.Header.Get(webhooks.HeaderTimestamp), 10, 64)
+	if !webhooks.Verify(rc.secret, timestamp, body, r.Header.Get(webhooks.HeaderSignature)) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	rc.bodies = append(rc.bodies, body)
+	rc.headers = append(rc.headers, r.Header)
+	w.WriteHeader(rc.status)
+}
+
+func prepareDispatcher(repo *mocks.WebhookRepository) *webhooks.Dispatcher {
+	return webhooks.NewDispatcher(repo, http.DefaultClient, 10, time.Minute, retry.Backoff{Initial: 10 * time.Second, Max: time.Hour}, 3)
+}
+
+func TestSign_Verify(t *testing.T) {
+	signature := webhooks.Sign("secret", 1600000000, []byte(`{"type":"file.deleted.v1"}`))
+
+	assert.Regexp(t, "^sha256=[0-9a-f]{64}$", signature)
+	assert.True(t, webhooks.Verify("secret", 1600000000, []byte(`{"type":"file.deleted.v1"}`), signature))
+	assert.False(t, webhooks.Verify("other", 1600000000, []byte(`{"type":"file.deleted.v1"}`), signature))
+	assert.False(t, webhooks.Verify("secret", 1600000001, []byte(`{"type":"file.deleted.v1"}`), signature))
+}
+
+func TestDispatcher_DeliverDue(t *testing.T) {
+	ctx := context.Background()
+	rc := &receiver{status: http.StatusNoContent, secret: "s3cr3t"}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	repo := &mocks.WebhookRepository{}
+	repo.On("ClaimDeliveries", ctx, 10, time.Minute).Return([]*model.WebhookDelivery{
+		{ID: "d1", SubscriptionID: "w1", EventID: "e1", EventType: events.TopicFileDeleted, Payload: []byte(`{"type":"file.deleted.v1"}`), URL: srv.URL, Secret: "s3cr3t"},
+	}, nil)
+	repo.On("DeleteDelivery", ctx, "d1").Return(nil)
+
+	n, err := prepareDispatcher(repo).DeliverDue(ctx)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Len(t, rc.bodies, 1)
+	assert.JSONEq(t, `{"type":"file.deleted.v1"}`, string(rc.bodies[0]))
+	assert.Equal(t, "e1", rc.headers[0].Get(webhooks.HeaderID))
+	assert.Equal(t, events.TopicFileDeleted, rc.headers[0].Get(webhooks.HeaderEvent))
+	repo.AssertExpectations(t)
+}
+
+func TestDispatcher_DeliverDue_RetriesWithBackoff(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(&receiver{status: http.StatusServiceUnavailable, secret: "s3cr3t"})
+	defer srv.Close()
+	repo := &mocks.WebhookRepository{}
+	repo.On("ClaimDeliveries", ctx, 10, time.Minute).Return([]*model.WebhookDelivery{
+		{ID: "d1", SubscriptionID: "w1", Attempts: 1, Payload: []byte(`{}`), URL: srv.URL, Secret: "s3cr3t"},
+	}, nil)
+	repo.On("RetryDelivery", ctx, "d1", 20*time.Second, http.StatusServiceUnavailable, mock.Anything).Return(nil)
+
+	_, err := prepareDispatcher(repo).DeliverDue(ctx)
+
+	assert.Nil(t, err)
+	repo.AssertExpectations(t)
+}
+
+func TestDispatcher_DeliverDue_DeadLettersLastAttempt(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(&receiver{status: http.StatusOK, secret: "rotated"})
+	defer srv.Close()
+	repo := &mocks.WebhookRepository{}
+	repo.On("ClaimDeliveries", ctx, 10, time.Minute).Return([]*model.WebhookDelivery{
+		{ID: "d1", SubscriptionID: "w1", Attempts: 2, Payload: []byte(`{}`), URL: srv.URL, Secret: "s3cr3t"},
+	}, nil)
+	repo.On("DeadLetterDelivery", ctx, "d1", http.StatusUnauthorized, mock.Anything).Return(nil)
+
+	_, err := prepareDispatcher(repo).DeliverDue(ctx)
+
+	assert.Nil(t, err)
+	repo.AssertExpectations(t)
+}
+
+func TestDispatcher_DeliverDue_RefusesInternalAddress(t *testing.T) {
+	ctx := context.Background()
+	rc := &receiver{status: http.StatusOK, secret: "s3cr3t"}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	repo := &mocks.WebhookRepository{}
+	repo.On("ClaimDeliveries", ctx, 10, time.Minute).Return([]*model.WebhookDelivery{
+		{ID: "d1", SubscriptionID: "w1", Payload: []byte(`{}`), URL: srv.URL, Secret: "s3cr3t"},
+	}, nil)
+	repo.On("RetryDelivery", ctx, "d1", 10*time.Second, 0, mock.MatchedBy(func(msg string) bool {
+		return strings.Contains(msg, webhooks.ErrForbiddenAddress.Error())
+	})).Return(nil)
+	dispatcher := webhooks.NewDispatcher(repo, webhooks.NewClient(time.Second), 10, time.Minute, retry.Backoff{Initial: 10 * time.Second, Max: time.Hour}, 3)
+
+	_, err := dispatcher.DeliverDue(ctx)
+
+	assert.Nil(t, err)
+	assert.Empty(t, rc.bodies)
+	repo.AssertExpectations(t)
+}
+
+func TestNewClient_DoesNotFollowRedirects(t *testing.T) {
+	target := &receiver{status: http.StatusOK, secret: "s3cr3t"}
+	srv := httptest.NewServer(target)
+	defer srv.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(srv.URL, http.StatusFound))
+	defer redirect.Close()
+	client := webhooks.NewClient(time.Second)
+	// the test servers listen on the loopback refused by the dialer of the client
+	client.Transport = http.DefaultTransport
+
+	resp, err := client.Post(redirect.URL, "application/json", strings.NewReader(`{}`))
+
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Empty(t, target.bodies)
+}
+
+func TestAllowedIP(t *testing.T) {
+	for ip, allowed := range map[string]bool{
+		"203.0.113.10":    true,
+		"2001:db8::1":     true,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"0.0.0.0":         false,
+		"::":              false,
+		"169.254.169.254": false,
+		"fe80::1":         false,
+		"10.1.2.3":        false,
+		"172.20.0.1":      false,
+		"192.168.0.1":     false,
+		"fd00::1":         false,
+		"::ffff:10.0.0.1": false,
+	} {
+		assert.Equal(t, allowed, webhooks.AllowedIP(net.ParseIP(ip)), ip)
+	}
+}
+
+func TestFanout_Publish(t *testing.T) {
+	ctx := context.Background()
+	repo := &mocks.WebhookRepository{}
+	repo.On("FindTenantSubscriptions", ctx, "sales").Return([]*model.WebhookSubscription{
+		{ID: "all", Enabled: true},
+		{ID: "invoices-deleted", Enabled: true, DocClasses: []string{"invoice"}, EventTypes: []string{"file.deleted"}},
+		{ID: "contracts", Enabled: true, DocClasses: []string{"contract"}},
+		{ID: "created", Enabled: true, EventTypes: []string{events.TopicFileCreated}},
+	}, nil)
+	var saved []*model.WebhookDelivery
+	repo.On("SaveDeliveries", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(1).([]*model.WebhookDelivery)
+	}).Return(nil)
+	e, err := events.FileDeleted("sales", "f1", "invoice")
+	assert.Nil(t, err)
+
+	err = webhooks.NewFanout(repo).Publish(ctx, e.Topic, &broker.Message{
+		Header: map[string]string{events.HeaderEventID: e.ID, events.HeaderTenantID: "sales", events.HeaderDocClass: "invoice"},
+		Body:   e.Payload,
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, saved, 2)
+	assert.Equal(t, "all", saved[0].SubscriptionID)
+	assert.Equal(t, "invoices-deleted", saved[1].SubscriptionID)
+	assert.Equal(t, e.ID, saved[0].EventID)
+	payload := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(saved[0].Payload, &payload))
+	assert.Equal(t, events.TopicFileDeleted, payload["type"])
+	assert.Equal(t, "f1", payload["event"].(map[string]interface{})["header"].(map[string]interface{})["file_id"])
+}
+
+func TestFanout_Publish_NoMatch(t *testing.T) {
+	ctx := context.Background()
+	repo := &mocks.WebhookRepository{}
+	repo.On("FindTenantSubscriptions", ctx, "sales").Return([]*model.WebhookSubscription{
+		{ID: "disabled", Enabled: false},
+	}, nil)
+
+	err := webhooks.NewFanout(repo).Publish(ctx, events.TopicFileClean, &broker.Message{
+		Header: map[string]string{events.HeaderTenantID: "sales"},
+	})
+
+	assert.Nil(t, err)
+	repo.AssertNotCalled(t, "SaveDeliveries", mock.Anything, mock.Anything)
+}
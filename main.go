@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
@@ -13,20 +12,73 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && fileservice.IsAdminCommand(os.Args[1]) {
+		os.Exit(runAdmin(os.Args[1:]))
+	}
+	os.Exit(run())
+}
+
+// runMigrate runs the migrate subcommand, see fileservice.RunMigrations
+func runMigrate(args []string) int {
+	ctx := context.Background()
+	if err := fileservice.RunMigrations(ctx, args, os.Stdout); err != nil {
+		logger.Errorf(ctx, "Migration failed: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// runAdmin runs one of the admin commands, see fileservice.RunAdmin
+func runAdmin(args []string) int {
+	ctx := context.Background()
+	if err := fileservice.RunAdmin(ctx, args, os.Stdout); err != nil {
+		logger.Errorf(ctx, "Command %s failed: %v", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// run returns the exit status of the service, it is not zero if the service failed
+func run() int {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	logger.DefaultLogger = logger.NewLogger(logger.WithLevel(logger.TraceLevel))
 
-	errCh := make(chan error, 1)
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
 
+	errCh := make(chan error, 1)
 	go func() {
-		sigCh := make(chan os.Signal)
-		signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
-		errCh <- fmt.Errorf("%s", <-sigCh)
+		errCh <- fileservice.StartFileService(ctx)
 	}()
 
-	go fileservice.StartFileService(ctx, errCh)
+	select {
+	case sig := <-sigCh:
+		logger.Infof(ctx, "Received %s, stopping service", sig)
+		cancel()
+	case err := <-errCh:
+		return exitStatus(ctx, err)
+	}
+
+	// a second signal skips the graceful shutdown
+	select {
+	case err := <-errCh:
+		return exitStatus(ctx, err)
+	case sig := <-sigCh:
+		logger.Errorf(ctx, "Received %s, service terminated without draining", sig)
+		return 1
+	}
+}
 
-	logger.Infof(ctx, "Service terminated: %v", <-errCh)
+func exitStatus(ctx context.Context, err error) int {
+	if err != nil {
+		logger.Errorf(ctx, "Service failed: %v", err)
+		return 1
+	}
+	logger.Infof(ctx, "Service terminated")
+	return 0
 }
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id varchar primary key,
+    name varchar not null,
+    key_hash varchar not null unique,
+    scopes varchar not null default '',
+    created_at timestamptz not null default now(),
+    revoked_at timestamptz
+);
\ No newline at end of file
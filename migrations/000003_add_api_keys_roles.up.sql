@@ -0,0 +1 @@
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS roles varchar not null default '';
\ No newline at end of file
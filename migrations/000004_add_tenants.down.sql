@@ -0,0 +1,7 @@
+ALTER TABLE api_keys DROP COLUMN IF EXISTS tenant_id;
+DROP POLICY IF EXISTS files_tenant_isolation ON files;
+ALTER TABLE files NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE files DISABLE ROW LEVEL SECURITY;
+DROP INDEX IF EXISTS files_tenant_id_idx;
+ALTER TABLE files DROP COLUMN IF EXISTS size;
+ALTER TABLE files DROP COLUMN IF EXISTS tenant_id;
\ No newline at end of file
@@ -0,0 +1,9 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS tenant_id varchar not null default 'default';
+ALTER TABLE files ADD COLUMN IF NOT EXISTS size bigint not null default 0;
+CREATE INDEX IF NOT EXISTS files_tenant_id_idx ON files (tenant_id);
+ALTER TABLE files ENABLE ROW LEVEL SECURITY;
+ALTER TABLE files FORCE ROW LEVEL SECURITY;
+CREATE POLICY files_tenant_isolation ON files
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS tenant_id varchar;
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    seq bigserial primary key,
+    event_id varchar not null unique,
+    topic varchar not null,
+    tenant_id varchar not null,
+    file_id varchar not null,
+    payload bytea not null,
+    attempts int not null default 0,
+    last_error varchar not null default '',
+    created_at timestamptz not null default now(),
+    next_attempt_at timestamptz not null default now()
+);
+CREATE INDEX IF NOT EXISTS outbox_next_attempt_at_idx ON outbox (next_attempt_at);
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
+ALTER TABLE outbox DROP COLUMN IF EXISTS doc_class;
\ No newline at end of file
@@ -0,0 +1,28 @@
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS doc_class varchar not null default '';
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id varchar primary key,
+    tenant_id varchar not null,
+    url varchar not null,
+    secret varchar not null,
+    doc_classes varchar not null default '',
+    event_types varchar not null default '',
+    enabled boolean not null default true,
+    created_at timestamptz not null default now()
+);
+CREATE INDEX IF NOT EXISTS webhook_subscriptions_tenant_id_idx ON webhook_subscriptions (tenant_id);
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id varchar primary key,
+    subscription_id varchar not null references webhook_subscriptions (id) on delete cascade,
+    tenant_id varchar not null,
+    event_id varchar not null,
+    event_type varchar not null,
+    payload bytea not null,
+    attempts int not null default 0,
+    last_status int not null default 0,
+    last_error varchar not null default '',
+    dead boolean not null default false,
+    created_at timestamptz not null default now(),
+    next_attempt_at timestamptz not null default now(),
+    unique (subscription_id, event_id)
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_next_attempt_at_idx ON webhook_deliveries (next_attempt_at) WHERE NOT dead;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE files DROP COLUMN IF EXISTS content_type;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS content_type varchar not null default 'application/octet-stream';
\ No newline at end of file
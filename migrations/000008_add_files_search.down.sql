@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS files_search_vector_idx;
+ALTER TABLE files DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE files DROP COLUMN IF EXISTS content_text;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS content_text text not null default '';
+ALTER TABLE files ADD COLUMN IF NOT EXISTS search_vector tsvector;
+CREATE INDEX IF NOT EXISTS files_search_vector_idx ON files USING gin (search_vector);
\ No newline at end of file
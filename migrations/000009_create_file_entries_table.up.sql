@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS file_entries (
+    file_id varchar not null references files (id) on delete cascade,
+    position int not null,
+    tenant_id varchar not null,
+    path varchar not null,
+    size bigint not null,
+    content_type varchar not null,
+    depth int not null,
+    primary key (file_id, position)
+);
+ALTER TABLE file_entries ENABLE ROW LEVEL SECURITY;
+ALTER TABLE file_entries FORCE ROW LEVEL SECURITY;
+CREATE POLICY file_entries_tenant_isolation ON file_entries
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
\ No newline at end of file
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS collection_files;
+DROP TABLE IF EXISTS collections;
+DROP TABLE IF EXISTS file_relations;
+ALTER TABLE files DROP COLUMN IF EXISTS legal_hold;
\ No newline at end of file
@@ -0,0 +1,44 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS legal_hold boolean not null default false;
+CREATE TABLE IF NOT EXISTS file_relations (
+    parent_id varchar not null references files (id) on delete cascade,
+    child_id varchar not null references files (id) on delete cascade,
+    type varchar not null,
+    tenant_id varchar not null,
+    cascade_delete boolean not null default false,
+    cascade_hold boolean not null default false,
+    created_at timestamptz not null default now(),
+    primary key (parent_id, child_id, type),
+    check (parent_id <> child_id)
+);
+CREATE INDEX IF NOT EXISTS file_relations_child_id_idx ON file_relations (child_id);
+CREATE TABLE IF NOT EXISTS collections (
+    id varchar primary key,
+    tenant_id varchar not null,
+    name varchar not null,
+    description varchar not null default '',
+    created_at timestamptz not null default now(),
+    unique (tenant_id, name)
+);
+CREATE TABLE IF NOT EXISTS collection_files (
+    collection_id varchar not null references collections (id) on delete cascade,
+    file_id varchar not null references files (id) on delete cascade,
+    tenant_id varchar not null,
+    position int not null,
+    primary key (collection_id, file_id)
+);
+CREATE INDEX IF NOT EXISTS collection_files_file_id_idx ON collection_files (file_id);
+ALTER TABLE file_relations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE file_relations FORCE ROW LEVEL SECURITY;
+CREATE POLICY file_relations_tenant_isolation ON file_relations
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+ALTER TABLE collections ENABLE ROW LEVEL SECURITY;
+ALTER TABLE collections FORCE ROW LEVEL SECURITY;
+CREATE POLICY collections_tenant_isolation ON collections
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+ALTER TABLE collection_files ENABLE ROW LEVEL SECURITY;
+ALTER TABLE collection_files FORCE ROW LEVEL SECURITY;
+CREATE POLICY collection_files_tenant_isolation ON collection_files
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
\ No newline at end of file
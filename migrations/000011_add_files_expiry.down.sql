@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS files_expires_at_idx;
+ALTER TABLE files DROP COLUMN IF EXISTS expiry_notice;
+ALTER TABLE files DROP COLUMN IF EXISTS expiry_field;
+ALTER TABLE files DROP COLUMN IF EXISTS expires_at;
\ No newline at end of file
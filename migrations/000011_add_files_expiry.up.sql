@@ -0,0 +1,4 @@
+ALTER TABLE files ADD COLUMN IF NOT EXISTS expires_at timestamptz;
+ALTER TABLE files ADD COLUMN IF NOT EXISTS expiry_field varchar not null default '';
+ALTER TABLE files ADD COLUMN IF NOT EXISTS expiry_notice varchar not null default '';
+CREATE INDEX IF NOT EXISTS files_expires_at_idx ON files (tenant_id, expires_at, id) WHERE expires_at IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS files_change_notify ON files;
+DROP FUNCTION IF EXISTS notify_file_change();
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE OR REPLACE FUNCTION notify_file_change() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('file_changes', json_build_object('tenant_id', OLD.tenant_id, 'id', OLD.id)::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS files_change_notify ON files;
+CREATE TRIGGER files_change_notify AFTER UPDATE OF metadata, doc_class, doc_type, doc_num OR DELETE ON files
+    FOR EACH ROW EXECUTE PROCEDURE notify_file_change();
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS access_log;
\ No newline at end of file
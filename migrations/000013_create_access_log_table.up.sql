@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS access_log (
+    id bigserial primary key,
+    tenant_id varchar not null,
+    file_id varchar not null,
+    action varchar not null,
+    principal varchar not null default '',
+    principal_kind varchar not null default '',
+    ip varchar not null default '',
+    user_agent varchar not null default '',
+    request_id varchar not null default '',
+    accessed_at timestamptz not null
+);
+CREATE INDEX IF NOT EXISTS access_log_file_id_idx ON access_log (tenant_id, file_id, id);
+CREATE INDEX IF NOT EXISTS access_log_principal_idx ON access_log (tenant_id, principal, id);
+CREATE INDEX IF NOT EXISTS access_log_accessed_at_idx ON access_log (tenant_id, accessed_at);
+ALTER TABLE access_log ENABLE ROW LEVEL SECURITY;
+ALTER TABLE access_log FORCE ROW LEVEL SECURITY;
+CREATE POLICY access_log_tenant_isolation ON access_log
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS quota_reservations;
\ No newline at end of file
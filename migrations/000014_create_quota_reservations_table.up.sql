@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS quota_reservations (
+    file_id varchar primary key,
+    tenant_id varchar not null,
+    bytes bigint not null,
+    expires_at timestamptz not null
+);
+CREATE INDEX IF NOT EXISTS quota_reservations_tenant_idx ON quota_reservations (tenant_id, expires_at);
+ALTER TABLE quota_reservations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE quota_reservations FORCE ROW LEVEL SECURITY;
+CREATE POLICY quota_reservations_tenant_isolation ON quota_reservations
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
\ No newline at end of file
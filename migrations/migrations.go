@@ -0,0 +1,9 @@
+// Package migrations embeds the SQL migrations of the database schema
+package migrations
+
+import "embed"
+
+// FS holds the migrations named <version>_<name>.up.sql and <version>_<name>.down.sql
+//
+//go:embed *.sql
+var FS embed.FS
@@ -55,6 +55,25 @@ type FileProcessingService interface {
 	GetFileMetadata(context.Context, *GetMetadataRequest, ...micro_client.CallOption) (*GetMetadataResponse, error)
 	DownloadFile(context.Context, *FileDownloadRequest, ...micro_client.CallOption) (*FileDownloadResponse, error)
 	UpdateFileMetadata(context.Context, *UpdateMetadataRequest, ...micro_client.CallOption) (*UpdateMetadataResponse, error)
+	UploadFile(context.Context, ...micro_client.CallOption) (FileProcessing_UploadFileClient, error)
+	DownloadFileStream(context.Context, *FileDownloadRequest, ...micro_client.CallOption) (FileProcessing_DownloadFileStreamClient, error)
+}
+
+type FileProcessing_UploadFileClient interface {
+	Context() context.Context
+	SendMsg(msg interface{}) error
+	RecvMsg(msg interface{}) error
+	Close() error
+	Send(msg *UploadFileRequest) error
+	Recv() (*UploadFileResponse, error)
+}
+
+type FileProcessing_DownloadFileStreamClient interface {
+	Context() context.Context
+	SendMsg(msg interface{}) error
+	RecvMsg(msg interface{}) error
+	Close() error
+	Recv() (*FileChunk, error)
 }
 
 // Micro server stuff
@@ -65,6 +84,25 @@ type FileProcessingHandler interface {
 	GetFileMetadata(context.Context, *GetMetadataRequest, *GetMetadataResponse) error
 	DownloadFile(context.Context, *FileDownloadRequest, *FileDownloadResponse) error
 	UpdateFileMetadata(context.Context, *UpdateMetadataRequest, *UpdateMetadataResponse) error
+	UploadFile(context.Context, FileProcessing_UploadFileStream) error
+	DownloadFileStream(context.Context, *FileDownloadRequest, FileProcessing_DownloadFileStreamStream) error
+}
+
+type FileProcessing_UploadFileStream interface {
+	Context() context.Context
+	SendMsg(msg interface{}) error
+	RecvMsg(msg interface{}) error
+	Close() error
+	Recv() (*UploadFileRequest, error)
+	Send(msg *UploadFileResponse) error
+}
+
+type FileProcessing_DownloadFileStreamStream interface {
+	Context() context.Context
+	SendMsg(msg interface{}) error
+	RecvMsg(msg interface{}) error
+	Close() error
+	Send(msg *FileChunk) error
 }
 
 // RegisterFileProcessingHandler registers server handler
@@ -74,6 +112,8 @@ func RegisterFileProcessingHandler(s micro_server.Server, sh FileProcessingHandl
 		GetFileMetadata(context.Context, *GetMetadataRequest, *GetMetadataResponse) error
 		DownloadFile(context.Context, *FileDownloadRequest, *FileDownloadResponse) error
 		UpdateFileMetadata(context.Context, *UpdateMetadataRequest, *UpdateMetadataResponse) error
+		UploadFile(context.Context, micro_server.Stream) error
+		DownloadFileStream(context.Context, micro_server.Stream) error
 	}
 	type FileProcessing struct {
 		fileProcessing
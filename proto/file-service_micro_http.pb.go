@@ -79,6 +79,85 @@ func (c *fileProcessingService) UpdateFileMetadata(ctx context.Context, req *Upd
 	return rsp, nil
 }
 
+func (c *fileProcessingService) UploadFile(ctx context.Context, opts ...micro_client.CallOption) (FileProcessing_UploadFileClient, error) {
+	stream, err := c.c.Stream(ctx, c.c.NewRequest(c.name, "FileProcessing.UploadFile", &UploadFileRequest{}), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &fileProcessingServiceUploadFile{stream}, nil
+}
+
+type fileProcessingServiceUploadFile struct {
+	stream micro_client.Stream
+}
+
+func (s *fileProcessingServiceUploadFile) Close() error {
+	return s.stream.Close()
+}
+
+func (s *fileProcessingServiceUploadFile) Context() context.Context {
+	return s.stream.Context()
+}
+
+func (s *fileProcessingServiceUploadFile) SendMsg(msg interface{}) error {
+	return s.stream.Send(msg)
+}
+
+func (s *fileProcessingServiceUploadFile) RecvMsg(msg interface{}) error {
+	return s.stream.Recv(msg)
+}
+
+func (s *fileProcessingServiceUploadFile) Send(msg *UploadFileRequest) error {
+	return s.stream.Send(msg)
+}
+
+func (s *fileProcessingServiceUploadFile) Recv() (*UploadFileResponse, error) {
+	msg := &UploadFileResponse{}
+	if err := s.stream.Recv(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (c *fileProcessingService) DownloadFileStream(ctx context.Context, req *FileDownloadRequest, opts ...micro_client.CallOption) (FileProcessing_DownloadFileStreamClient, error) {
+	stream, err := c.c.Stream(ctx, c.c.NewRequest(c.name, "FileProcessing.DownloadFileStream", &FileDownloadRequest{}), opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Send(req); err != nil {
+		return nil, err
+	}
+	return &fileProcessingServiceDownloadFileStream{stream}, nil
+}
+
+type fileProcessingServiceDownloadFileStream struct {
+	stream micro_client.Stream
+}
+
+func (s *fileProcessingServiceDownloadFileStream) Close() error {
+	return s.stream.Close()
+}
+
+func (s *fileProcessingServiceDownloadFileStream) Context() context.Context {
+	return s.stream.Context()
+}
+
+func (s *fileProcessingServiceDownloadFileStream) SendMsg(msg interface{}) error {
+	return s.stream.Send(msg)
+}
+
+func (s *fileProcessingServiceDownloadFileStream) RecvMsg(msg interface{}) error {
+	return s.stream.Recv(msg)
+}
+
+func (s *fileProcessingServiceDownloadFileStream) Recv() (*FileChunk, error) {
+	msg := &FileChunk{}
+	if err := s.stream.Recv(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
 // Micro server stuff
 
 type fileProcessingHandler struct {
@@ -100,3 +179,71 @@ func (h *fileProcessingHandler) DownloadFile(ctx context.Context, req *FileDownl
 func (h *fileProcessingHandler) UpdateFileMetadata(ctx context.Context, req *UpdateMetadataRequest, rsp *UpdateMetadataResponse) error {
 	return h.FileProcessingHandler.UpdateFileMetadata(ctx, req, rsp)
 }
+
+func (h *fileProcessingHandler) UploadFile(ctx context.Context, stream micro_server.Stream) error {
+	return h.FileProcessingHandler.UploadFile(ctx, &fileProcessingUploadFileStream{stream})
+}
+
+type fileProcessingUploadFileStream struct {
+	stream micro_server.Stream
+}
+
+func (s *fileProcessingUploadFileStream) Close() error {
+	return s.stream.Close()
+}
+
+func (s *fileProcessingUploadFileStream) Context() context.Context {
+	return s.stream.Context()
+}
+
+func (s *fileProcessingUploadFileStream) SendMsg(msg interface{}) error {
+	return s.stream.Send(msg)
+}
+
+func (s *fileProcessingUploadFileStream) RecvMsg(msg interface{}) error {
+	return s.stream.Recv(msg)
+}
+
+func (s *fileProcessingUploadFileStream) Send(msg *UploadFileResponse) error {
+	return s.stream.Send(msg)
+}
+
+func (s *fileProcessingUploadFileStream) Recv() (*UploadFileRequest, error) {
+	msg := &UploadFileRequest{}
+	if err := s.stream.Recv(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (h *fileProcessingHandler) DownloadFileStream(ctx context.Context, stream micro_server.Stream) error {
+	msg := &FileDownloadRequest{}
+	if err := stream.Recv(msg); err != nil {
+		return err
+	}
+	return h.FileProcessingHandler.DownloadFileStream(ctx, msg, &fileProcessingDownloadFileStreamStream{stream})
+}
+
+type fileProcessingDownloadFileStreamStream struct {
+	stream micro_server.Stream
+}
+
+func (s *fileProcessingDownloadFileStreamStream) Close() error {
+	return s.stream.Close()
+}
+
+func (s *fileProcessingDownloadFileStreamStream) Context() context.Context {
+	return s.stream.Context()
+}
+
+func (s *fileProcessingDownloadFileStreamStream) SendMsg(msg interface{}) error {
+	return s.stream.Send(msg)
+}
+
+func (s *fileProcessingDownloadFileStreamStream) RecvMsg(msg interface{}) error {
+	return s.stream.Recv(msg)
+}
+
+func (s *fileProcessingDownloadFileStreamStream) Send(msg *FileChunk) error {
+	return s.stream.Send(msg)
+}